@@ -2,6 +2,7 @@ package main
 
 import (
 	"context"
+	"fmt"
 	_ "github.com/TakoB222/postingAds-api/docs"
 	"github.com/TakoB222/postingAds-api/internal/config"
 	"github.com/TakoB222/postingAds-api/internal/delivery/http"
@@ -10,8 +11,10 @@ import (
 	"github.com/TakoB222/postingAds-api/internal/service"
 	"github.com/TakoB222/postingAds-api/pkg/auth"
 	"github.com/TakoB222/postingAds-api/pkg/database"
+	"github.com/TakoB222/postingAds-api/pkg/email"
 	"github.com/TakoB222/postingAds-api/pkg/hash"
 	"github.com/TakoB222/postingAds-api/pkg/logger"
+	"github.com/TakoB222/postingAds-api/pkg/storage"
 	"github.com/jmoiron/sqlx"
 	"github.com/sirupsen/logrus"
 
@@ -30,7 +33,9 @@ func Init() {
 
 type dependecies struct {
 	tokenManager *auth.Manager
-	hasher       *hash.SHA1Hasher
+	hasher       hash.PasswordHasher
+	mailer       email.Mailer
+	storage      storage.Storage
 }
 
 // @title Application for posting ads
@@ -62,17 +67,65 @@ func main() {
 	dep := initDependencies(cfg)
 
 	repos := repository.NewRepositories(db)
+	if err := repos.CategoryTree.Load(); err != nil {
+		logrus.Fatalf("error loading categories: %s", err.Error())
+	}
 	service := service.NewServices(service.Dependencies{
 		Repository:      repos,
 		TokenManager:    dep.tokenManager,
 		Hasher:          dep.hasher,
+		Mailer:          dep.mailer,
+		Storage:         dep.storage,
 		AccessTokenTTL:  cfg.Auth.AccessTokenTTL,
 		RefreshTokenTTL: cfg.Auth.RefreshTokenTTL,
+		AccountEmails: service.AccountEmailsConfig{
+			RequireVerification:   cfg.Auth.RequireEmailVerification,
+			VerificationTokenTTL:  cfg.Auth.VerificationTokenTTL,
+			PasswordResetTokenTTL: cfg.Auth.PasswordResetTokenTTL,
+			EmailCooldown:         cfg.Auth.AccountEmailCooldown,
+			VerificationURL:       cfg.Email.VerificationURL,
+			PasswordResetURL:      cfg.Email.PasswordResetURL,
+		},
+		TwoFactor: service.TwoFactorConfig{
+			Issuer:            cfg.Auth.TwoFactor.Issuer,
+			ChallengeTTL:      cfg.Auth.TwoFactor.ChallengeTTL,
+			RequiredForAdmins: cfg.Auth.TwoFactor.RequiredForAdmins,
+		},
+		Images: service.ImagesConfig{
+			MaxSize:       cfg.Images.MaxUploadMegabytes << 20,
+			MaxPixels:     cfg.Images.MaxMegapixels * 1000000,
+			UnattachedTTL: cfg.Images.UnattachedTTL,
+		},
+		SavedSearches: service.SavedSearchesConfig{
+			CheckInterval:  cfg.SavedSearches.CheckInterval,
+			MaxPerUser:     cfg.SavedSearches.MaxPerUser,
+			WebhookTimeout: cfg.SavedSearches.WebhookTimeout,
+			WebhookSecret:  cfg.SavedSearches.WebhookSecret,
+		},
+		Events: service.EventsConfig{
+			ReplaySize: cfg.Events.ReplaySize,
+			ReplayTTL:  cfg.Events.ReplayTTL,
+		},
+		Contacts: service.ContactsConfig{
+			RevealLimit:  cfg.Contacts.RevealLimit,
+			RevealWindow: cfg.Contacts.RevealWindow,
+		},
 	})
-	handler := http.NewHandler(service, dep.tokenManager)
+	handler := http.NewHandler(service, dep.tokenManager, cfg.Events.AllowedOrigins)
+
+	workersCtx, stopWorkers := context.WithCancel(context.Background())
+	go dep.tokenManager.RunRotation(workersCtx)
+	go service.Image.RunCleanup(workersCtx)
+	go service.SavedSearch.RunNotifications(workersCtx)
+	go service.Events.RunCleanup(workersCtx)
+
+	router := handler.Init()
+	if cfg.Storage.Driver == "local" {
+		router.Static("/uploads", cfg.Storage.Local.Dir)
+	}
 
 	server := server.NewServer(server.Config{Host: cfg.Http.Host, Port: cfg.Http.Port, MaxHeaderBytes: cfg.Http.MaxHeaderMegabytes,
-		ReadTimeout: cfg.Http.ReadTimeout, WriteTimeout: cfg.Http.WriteTimeout}, handler.Init())
+		ReadTimeout: cfg.Http.ReadTimeout, WriteTimeout: cfg.Http.WriteTimeout}, router)
 	go func() {
 		if err := server.Run(); err != nil {
 			logger.Errorf("error occurred while running http server: %s\n", err.Error())
@@ -87,6 +140,8 @@ func main() {
 
 	<-quit
 
+	stopWorkers()
+
 	const timeout = 5 * time.Second
 
 	ctx, shutdown := context.WithTimeout(context.Background(), timeout)
@@ -111,14 +166,91 @@ func initPostgresDB(cfg *config.Config) (*sqlx.DB, error) {
 }
 
 func initDependencies(cfg *config.Config) *dependecies {
-	tokenManager, err := auth.NewManager(cfg.Auth.TokenSigningKey)
+	tokenManager, err := auth.NewManager(auth.ManagerConfig{
+		Algorithm:        cfg.Auth.JWT.Algorithm,
+		SigningKey:       cfg.Auth.TokenSigningKey,
+		KeysDir:          cfg.Auth.JWT.KeysDir,
+		RotationInterval: cfg.Auth.JWT.RotationInterval,
+		VerificationTTL:  cfg.Auth.AccessTokenTTL,
+	})
 	if err != nil {
 		logrus.Fatalf("error with initializing token manager: %s", err.Error())
 	}
-	hasher, err := hash.NewSHA1Hasher(cfg.Auth.PasswordSalt)
+	hasher, err := initPasswordHasher(cfg)
 	if err != nil {
 		logrus.Fatalf("error with initializing password hasher: %s", err.Error())
 	}
 
-	return &dependecies{tokenManager: tokenManager, hasher: hasher}
+	mailer, err := initMailer(cfg)
+	if err != nil {
+		logrus.Fatalf("error with initializing mailer: %s", err.Error())
+	}
+
+	storage, err := initStorage(cfg)
+	if err != nil {
+		logrus.Fatalf("error with initializing storage: %s", err.Error())
+	}
+
+	return &dependecies{tokenManager: tokenManager, hasher: hasher, mailer: mailer, storage: storage}
+}
+
+func initStorage(cfg *config.Config) (storage.Storage, error) {
+	switch cfg.Storage.Driver {
+	case "local":
+		return storage.NewLocalStorage(cfg.Storage.Local.Dir, cfg.Storage.Local.BaseURL)
+	case "s3":
+		return storage.NewS3Storage(storage.S3Config{Endpoint: cfg.Storage.S3.Endpoint, Region: cfg.Storage.S3.Region,
+			Bucket: cfg.Storage.S3.Bucket, AccessKey: cfg.Storage.S3.AccessKey, SecretKey: cfg.Storage.S3.SecretKey,
+			PublicURL: cfg.Storage.S3.PublicURL})
+	default:
+		return nil, fmt.Errorf("unsupported storage driver: %s", cfg.Storage.Driver)
+	}
+}
+
+func initMailer(cfg *config.Config) (email.Mailer, error) {
+	switch cfg.Email.Driver {
+	case "smtp":
+		return email.NewSMTPMailer(email.SMTPConfig{Host: cfg.Email.SMTP.Host, Port: cfg.Email.SMTP.Port,
+			Username: cfg.Email.SMTP.Username, Password: cfg.Email.SMTP.Password, From: cfg.Email.From})
+	case "file":
+		return email.NewFileMailer(cfg.Email.Dir, cfg.Email.From), nil
+	default:
+		return nil, fmt.Errorf("unsupported email driver: %s", cfg.Email.Driver)
+	}
+}
+
+func initPasswordHasher(cfg *config.Config) (hash.PasswordHasher, error) {
+	argon2Hasher, err := hash.NewArgon2idHasher(hash.DefaultArgon2Params)
+	if err != nil {
+		return nil, err
+	}
+	bcryptHasher, err := hash.NewBcryptHasher(cfg.Auth.BcryptCost)
+	if err != nil {
+		return nil, err
+	}
+
+	// hashes of every supported algorithm stay verifiable, so switching the primary one never locks anybody out
+	var primary hash.PasswordHasher
+	legacy := make([]hash.PasswordHasher, 0)
+	switch cfg.Auth.PasswordHasher {
+	case "argon2id":
+		primary = argon2Hasher
+		legacy = append(legacy, bcryptHasher)
+	case "bcrypt":
+		primary = bcryptHasher
+		legacy = append(legacy, argon2Hasher)
+	default:
+		return nil, fmt.Errorf("unknown password hasher: %s", cfg.Auth.PasswordHasher)
+	}
+
+	// accounts created before the migration from SHA1 are rehashed on their next sign in
+	if cfg.Auth.PasswordSalt != "" {
+		sha1Hasher, err := hash.NewSHA1Hasher(cfg.Auth.PasswordSalt)
+		if err != nil {
+			return nil, err
+		}
+		legacy = append(legacy, sha1Hasher)
+	}
+
+	return hash.NewUpgradeableHasher(primary, legacy...), nil
 }
@@ -17,6 +17,34 @@ const (
 	defaultHttpRWTimeout          = 10 * time.Second
 	defaultHttpMaxHeaderMegabytes = 1
 
+	defaultPasswordHasher = "argon2id"
+	defaultBcryptCost     = 12
+
+	defaultJWTAlgorithm = "HS256"
+
+	defaultVerificationTokenTTL  = 24 * time.Hour
+	defaultPasswordResetTokenTTL = time.Hour
+	defaultAccountEmailCooldown  = 2 * time.Minute
+	defaultEmailDriver           = "file"
+
+	defaultTwoFactorIssuer       = "postingAds"
+	defaultTwoFactorChallengeTTL = 5 * time.Minute
+
+	defaultImagesMaxUploadMegabytes = 10
+	defaultImagesMaxMegapixels      = 40
+	defaultImagesUnattachedTTL      = 24 * time.Hour
+	defaultStorageDriver            = "local"
+
+	defaultSavedSearchesCheckInterval  = 10 * time.Minute
+	defaultSavedSearchesMaxPerUser     = 20
+	defaultSavedSearchesWebhookTimeout = 10 * time.Second
+
+	defaultEventsReplaySize = 100
+	defaultEventsReplayTTL  = 10 * time.Minute
+
+	defaultContactsRevealLimit  = 30
+	defaultContactsRevealWindow = 24 * time.Hour
+
 	defaultConfigPath = "../configs/config.yml"
 	//envBase = "../"
 )
@@ -25,7 +53,13 @@ type (
 	Config struct {
 		Http HttpServer
 		Postgres
-		Auth Auth
+		Auth          Auth
+		Email         Email
+		Images        Images
+		Storage       Storage
+		SavedSearches SavedSearches
+		Events        Events
+		Contacts      Contacts
 	}
 
 	HttpServer struct {
@@ -49,6 +83,90 @@ type (
 		TokenSigningKey string
 		AccessTokenTTL  time.Duration `mapstructure:"accessTokenTTL"`
 		RefreshTokenTTL time.Duration `mapstructure:"refreshTokenTTL"`
+		PasswordHasher  string        `mapstructure:"passwordHasher"`
+		BcryptCost      int           `mapstructure:"bcryptCost"`
+		JWT             JWT           `mapstructure:"jwt"`
+
+		RequireEmailVerification bool          `mapstructure:"requireEmailVerification"`
+		VerificationTokenTTL     time.Duration `mapstructure:"verificationTokenTTL"`
+		PasswordResetTokenTTL    time.Duration `mapstructure:"passwordResetTokenTTL"`
+		AccountEmailCooldown     time.Duration `mapstructure:"accountEmailCooldown"`
+		TwoFactor                TwoFactor     `mapstructure:"twoFactor"`
+	}
+
+	TwoFactor struct {
+		Issuer            string        `mapstructure:"issuer"`
+		ChallengeTTL      time.Duration `mapstructure:"challengeTTL"`
+		RequiredForAdmins bool          `mapstructure:"requiredForAdmins"`
+	}
+
+	JWT struct {
+		Algorithm        string        `mapstructure:"algorithm"`
+		KeysDir          string        `mapstructure:"keysDir"`
+		RotationInterval time.Duration `mapstructure:"rotationInterval"`
+	}
+
+	Email struct {
+		Driver           string `mapstructure:"driver"` // smtp or file
+		From             string `mapstructure:"from"`
+		Dir              string `mapstructure:"dir"` // emails of the file driver are only logged when empty
+		VerificationURL  string `mapstructure:"verificationURL"`
+		PasswordResetURL string `mapstructure:"passwordResetURL"`
+		SMTP             SMTP   `mapstructure:"smtp"`
+	}
+
+	SMTP struct {
+		Host     string `mapstructure:"host"`
+		Port     int    `mapstructure:"port"`
+		Username string `mapstructure:"username"`
+		Password string
+	}
+
+	Images struct {
+		MaxUploadMegabytes int           `mapstructure:"maxUploadMegabytes"`
+		MaxMegapixels      int           `mapstructure:"maxMegapixels"`
+		UnattachedTTL      time.Duration `mapstructure:"unattachedTTL"`
+	}
+
+	SavedSearches struct {
+		CheckInterval  time.Duration `mapstructure:"checkInterval"`
+		MaxPerUser     int           `mapstructure:"maxPerUser"`
+		WebhookTimeout time.Duration `mapstructure:"webhookTimeout"`
+		WebhookSecret  string
+	}
+
+	// Events are pushed to connected clients, the last ones are kept to replay to reconnecting clients.
+	Events struct {
+		ReplaySize int           `mapstructure:"replaySize"`
+		ReplayTTL  time.Duration `mapstructure:"replayTTL"`
+		// AllowedOrigins are pages of other origins than the api allowed to open the WebSocket of events
+		AllowedOrigins []string `mapstructure:"allowedOrigins"`
+	}
+
+	// Contacts of ads are masked, a user may reveal contacts of RevealLimit ads per RevealWindow.
+	Contacts struct {
+		RevealLimit  int           `mapstructure:"revealLimit"`
+		RevealWindow time.Duration `mapstructure:"revealWindow"`
+	}
+
+	Storage struct {
+		Driver string       `mapstructure:"driver"` // local or s3
+		Local  LocalStorage `mapstructure:"local"`
+		S3     S3           `mapstructure:"s3"`
+	}
+
+	LocalStorage struct {
+		Dir     string `mapstructure:"dir"`
+		BaseURL string `mapstructure:"baseURL"`
+	}
+
+	S3 struct {
+		Endpoint  string `mapstructure:"endpoint"`
+		Region    string `mapstructure:"region"`
+		Bucket    string `mapstructure:"bucket"`
+		PublicURL string `mapstructure:"publicURL"` // bucket url is used when empty
+		AccessKey string
+		SecretKey string
 	}
 )
 
@@ -81,6 +199,26 @@ func populateDefaults() {
 	viper.SetDefault("http.readTimeout", defaultHttpRWTimeout)
 	viper.SetDefault("http.writeTimeout", defaultHttpRWTimeout)
 	viper.SetDefault("http.maxHeaderBytes", defaultHttpMaxHeaderMegabytes)
+	viper.SetDefault("auth.passwordHasher", defaultPasswordHasher)
+	viper.SetDefault("auth.bcryptCost", defaultBcryptCost)
+	viper.SetDefault("auth.jwt.algorithm", defaultJWTAlgorithm)
+	viper.SetDefault("auth.verificationTokenTTL", defaultVerificationTokenTTL)
+	viper.SetDefault("auth.passwordResetTokenTTL", defaultPasswordResetTokenTTL)
+	viper.SetDefault("auth.accountEmailCooldown", defaultAccountEmailCooldown)
+	viper.SetDefault("email.driver", defaultEmailDriver)
+	viper.SetDefault("auth.twoFactor.issuer", defaultTwoFactorIssuer)
+	viper.SetDefault("auth.twoFactor.challengeTTL", defaultTwoFactorChallengeTTL)
+	viper.SetDefault("images.maxUploadMegabytes", defaultImagesMaxUploadMegabytes)
+	viper.SetDefault("images.maxMegapixels", defaultImagesMaxMegapixels)
+	viper.SetDefault("images.unattachedTTL", defaultImagesUnattachedTTL)
+	viper.SetDefault("storage.driver", defaultStorageDriver)
+	viper.SetDefault("savedSearches.checkInterval", defaultSavedSearchesCheckInterval)
+	viper.SetDefault("savedSearches.maxPerUser", defaultSavedSearchesMaxPerUser)
+	viper.SetDefault("savedSearches.webhookTimeout", defaultSavedSearchesWebhookTimeout)
+	viper.SetDefault("events.replaySize", defaultEventsReplaySize)
+	viper.SetDefault("events.replayTTL", defaultEventsReplayTTL)
+	viper.SetDefault("contacts.revealLimit", defaultContactsRevealLimit)
+	viper.SetDefault("contacts.revealWindow", defaultContactsRevealWindow)
 }
 
 func parseConfigFile(filePath string) error {
@@ -96,6 +234,10 @@ func setFromEnv(cfg *Config) {
 	cfg.Postgres.Password = viper.GetString("password")
 	cfg.Auth.PasswordSalt = viper.GetString("password_salt")
 	cfg.Auth.TokenSigningKey = viper.GetString("signing_key")
+	cfg.Email.SMTP.Password = viper.GetString("smtp_password")
+	cfg.Storage.S3.AccessKey = viper.GetString("s3_access_key")
+	cfg.Storage.S3.SecretKey = viper.GetString("s3_secret_key")
+	cfg.SavedSearches.WebhookSecret = viper.GetString("webhook_secret")
 }
 
 func unmarshal(cfg *Config) error {
@@ -105,6 +247,24 @@ func unmarshal(cfg *Config) error {
 	if err := viper.UnmarshalKey("auth", &cfg.Auth); err != nil {
 		return err
 	}
+	if err := viper.UnmarshalKey("email", &cfg.Email); err != nil {
+		return err
+	}
+	if err := viper.UnmarshalKey("images", &cfg.Images); err != nil {
+		return err
+	}
+	if err := viper.UnmarshalKey("storage", &cfg.Storage); err != nil {
+		return err
+	}
+	if err := viper.UnmarshalKey("savedSearches", &cfg.SavedSearches); err != nil {
+		return err
+	}
+	if err := viper.UnmarshalKey("events", &cfg.Events); err != nil {
+		return err
+	}
+	if err := viper.UnmarshalKey("contacts", &cfg.Contacts); err != nil {
+		return err
+	}
 	return viper.UnmarshalKey("db.postgres", &cfg.Postgres)
 }
 
@@ -147,6 +307,22 @@ func parseEnv() error {
 		logger.Error(err.Error())
 	}
 
+	if err := viper.BindEnv("smtp_password", "SMTP_PASSWORD"); err != nil {
+		logger.Error(err.Error())
+	}
+
+	if err := viper.BindEnv("s3_access_key", "S3_ACCESS_KEY"); err != nil {
+		logger.Error(err.Error())
+	}
+
+	if err := viper.BindEnv("s3_secret_key", "S3_SECRET_KEY"); err != nil {
+		logger.Error(err.Error())
+	}
+
+	if err := viper.BindEnv("webhook_secret", "WEBHOOK_SECRET"); err != nil {
+		logger.Error(err.Error())
+	}
+
 	return parsePostgresEnv()
 }
 
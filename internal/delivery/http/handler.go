@@ -1,6 +1,7 @@
 package http
 
 import (
+	"fmt"
 	"github.com/TakoB222/postingAds-api/internal/delivery/http/v1"
 	"github.com/TakoB222/postingAds-api/internal/service"
 	"github.com/TakoB222/postingAds-api/pkg/auth"
@@ -8,23 +9,31 @@ import (
 	swaggerFiles "github.com/swaggo/files"
 	"github.com/swaggo/gin-swagger"
 	"net/http"
+	"net/url"
+	"strings"
+	"time"
 )
 
+// secretQueryParams carry credentials, browsers pass access tokens of event streams in the query
+// and links of account emails carry their tokens.
+var secretQueryParams = []string{"access_token", "token"}
+
 type Handler struct {
-	services     *service.Service
-	tokenManager auth.TokenManager
+	services       *service.Service
+	tokenManager   auth.TokenManager
+	allowedOrigins []string
 }
 
-func NewHandler(service *service.Service, tokeManager auth.TokenManager) *Handler {
-	return &Handler{services: service, tokenManager: tokeManager}
+func NewHandler(service *service.Service, tokeManager auth.TokenManager, allowedOrigins []string) *Handler {
+	return &Handler{services: service, tokenManager: tokeManager, allowedOrigins: allowedOrigins}
 }
 
 func (h *Handler) Init() *gin.Engine {
-	router := gin.Default()
+	router := gin.New()
 
 	router.Use(
 		gin.Recovery(),
-		gin.Logger(),
+		gin.LoggerWithFormatter(logFormatter),
 	)
 
 	router.GET("/ping", func(ctx *gin.Context) {
@@ -33,15 +42,57 @@ func (h *Handler) Init() *gin.Engine {
 
 	router.GET("/swagger/*any", ginSwagger.WrapHandler(swaggerFiles.Handler))
 
+	router.GET("/.well-known/jwks.json", h.jwks)
+
 	h.initAPI(router)
 
 	return router
 }
 
 func (h *Handler) initAPI(router *gin.Engine) {
-	handlerV1 := v1.NewHandler(h.services, h.tokenManager)
+	handlerV1 := v1.NewHandler(h.services, h.tokenManager, h.allowedOrigins)
 	api := router.Group("/api")
 	{
 		handlerV1.Init(api)
 	}
 }
+
+// jwks publishes public keys of access tokens, so other services can verify them without sharing a secret.
+func (h *Handler) jwks(ctx *gin.Context) {
+	ctx.Header("Cache-Control", "public, max-age=300")
+	ctx.JSON(http.StatusOK, h.tokenManager.JWKS())
+}
+
+// logFormatter writes requests in the format of gin, with secret query params redacted.
+func logFormatter(param gin.LogFormatterParams) string {
+	return fmt.Sprintf("[GIN] %v | %3d | %13v | %15s | %-7s %#v\n%s",
+		param.TimeStamp.Format("2006/01/02 - 15:04:05"),
+		param.StatusCode,
+		param.Latency.Truncate(time.Microsecond),
+		param.ClientIP,
+		param.Method,
+		redactQuery(param.Path),
+		param.ErrorMessage,
+	)
+}
+
+func redactQuery(path string) string {
+	parts := strings.SplitN(path, "?", 2)
+	if len(parts) < 2 {
+		return path
+	}
+
+	query, err := url.ParseQuery(parts[1])
+	if err != nil {
+		// a query which does not parse is dropped as a whole, it may still contain a secret
+		return parts[0]
+	}
+
+	for _, param := range secretQueryParams {
+		if _, ok := query[param]; ok {
+			query.Set(param, "REDACTED")
+		}
+	}
+
+	return parts[0] + "?" + query.Encode()
+}
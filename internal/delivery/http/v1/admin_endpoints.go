@@ -1,9 +1,13 @@
 package v1
 
 import (
+	"github.com/TakoB222/postingAds-api/internal/domain"
 	"github.com/TakoB222/postingAds-api/internal/service"
+	"github.com/TakoB222/postingAds-api/pkg/apperror"
+	"github.com/TakoB222/postingAds-api/pkg/auth"
 	"github.com/gin-gonic/gin"
 	"net/http"
+	"strconv"
 	"strings"
 )
 
@@ -11,16 +15,59 @@ func (h *Handler) InitAdminRoutes(groupApi *gin.RouterGroup) {
 	admins := groupApi.Group("/admins")
 	{
 		admins.POST("/Sign-In", h.adminSignIn)
+		admins.POST("/Sign-In/2fa", h.adminSignInSecondFactor)
 		admins.POST("/refreshTokens", h.adminRefreshTokens)
+		admins.POST("/logout", h.adminIdentity, h.adminLogout)
 
-		api := admins.Group("/api", h.adminIdentity)
+		// users moderate too when their roles grant them the permissions
+		staff := admins.Group("/api", h.staffIdentity)
 		{
-			ads := api.Group("/ads")
+			ads := staff.Group("/ads", h.requirePermission(domain.PermissionAdsModerate))
 			{
 				ads.GET("/", h.adminGetAllAds)
 				ads.GET("/:id", h.adminGetAd)
-				ads.PUT("/:id", h.adminUpdateAd)
-				ads.DELETE("/:id", h.adminDeleteAd)
+				ads.PUT("/:id", h.requirePermission(domain.PermissionAdsManage), h.adminUpdateAd)
+				ads.DELETE("/:id", h.requirePermission(domain.PermissionAdsManage), h.adminDeleteAd)
+				ads.POST("/:id/approve", h.adminApproveAd)
+				ads.POST("/:id/reject", h.adminRejectAd)
+			}
+			moderation := staff.Group("/moderation", h.requirePermission(domain.PermissionAdsModerate))
+			{
+				moderation.GET("/", h.adminGetAdsForModeration)
+			}
+			users := staff.Group("/users", h.requirePermission(domain.PermissionUsersBan))
+			{
+				users.POST("/:id/ban", h.adminBanUser)
+				users.DELETE("/:id/ban", h.adminUnbanUser)
+			}
+		}
+
+		api := admins.Group("/api", h.adminIdentity)
+		{
+			roles := api.Group("/roles", h.requirePermission(domain.PermissionRolesManage))
+			{
+				roles.GET("/", h.adminGetRoles)
+				roles.POST("/assign", h.adminAssignRole)
+				roles.POST("/revoke", h.adminRevokeRole)
+			}
+			categories := api.Group("/categories", h.requirePermission(domain.PermissionCategoriesWrite))
+			{
+				categories.POST("/", h.adminCreateCategory)
+				categories.PUT("/:id", h.adminUpdateCategory)
+				categories.PUT("/:id/attributes", h.adminSetCategoryAttributes)
+				categories.DELETE("/:id", h.adminDeleteCategory)
+			}
+			sessions := api.Group("/sessions")
+			{
+				sessions.GET("/", h.adminGetSessions)
+				sessions.DELETE("/:id", h.adminRevokeSession)
+				sessions.POST("/revoke-others", h.adminRevokeOtherSessions)
+			}
+			twoFactor := api.Group("/2fa")
+			{
+				twoFactor.POST("/enroll", h.adminEnrollTwoFactor)
+				twoFactor.POST("/confirm", h.adminConfirmTwoFactor)
+				twoFactor.POST("/disable", h.adminDisableTwoFactor)
 			}
 		}
 	}
@@ -33,12 +80,31 @@ type (
 	}
 	adminUpdateAdInput struct {
 		Title       string                   `json:"title" binding:"required"`
-		Category    string                   `json:"category" binding:"required"`
+		Category    string                   `json:"category" binding:"required"` // id or full path of the category
 		Description string                   `json:"description" binding:"required"`
 		Price       int                      `json:"price" binding:"required"`
 		Contacts    adminInputUpdateContacts `json:"contacts" binding:"required"`
-		Published   bool                     `json:"published"`
 		ImagesURL   []string                 `json:"images_url" binding:"required"`
+		Attributes  map[string]interface{}   `json:"attributes"` // the current ones are kept when empty
+		Language    string                   `json:"language"`   // the current one is kept when empty and the text is the same
+	}
+	adminRejectAdInput struct {
+		Reason string `json:"reason" binding:"required"`
+	}
+	adminBanUserInput struct {
+		Reason string `json:"reason" binding:"required"`
+	}
+	adminRoleAssignmentInput struct {
+		Kind      string `json:"kind" binding:"required,oneof=user admin"`
+		SubjectId string `json:"subject_id" binding:"required"`
+		Role      string `json:"role" binding:"required"`
+	}
+	adminCategoryInput struct {
+		Category       string `json:"category" binding:"required,max=255"`
+		ParentCategory *int   `json:"parent_category"`
+	}
+	adminCategoryAttributesInput struct {
+		Attributes domain.AttributeSchema `json:"attributes"`
 	}
 	adminInputUpdateContacts struct {
 		Name         string `json:"name" binding:"required"`
@@ -55,23 +121,26 @@ type (
 // @Produce  json
 // @Param input body adminSignInInput true "sign in info"
 // @Success 200 {object} tokenResponse
-// @Failure 400 {object} response
-// @Failure 500 {object} response
-// @Failure default {object} response
+// @Failure 400 {object} problem
+// @Failure 401 {object} problem
+// @Failure 500 {object} problem
+// @Failure default {object} problem
 // @Router /admins/Sign-In [post]
 func (h *Handler) adminSignIn(ctx *gin.Context) {
 	var input adminSignInInput
-	if err := ctx.BindJSON(&input); err != nil {
-		newResponse(ctx, http.StatusBadRequest, "invalid input body")
+	if err := ctx.ShouldBindJSON(&input); err != nil {
+		newBindingErrorResponse(ctx, err)
 		return
 	}
 
 	tokens, err := h.services.Admin.AdminSignIn(service.SignInInput{
-		Email:    input.Email,
-		Password: input.Password,
+		Email:     input.Email,
+		Password:  input.Password,
+		UserAgent: ctx.Request.UserAgent(),
+		Ip:        ctx.ClientIP(),
 	})
 	if err != nil {
-		newResponse(ctx, http.StatusInternalServerError, err.Error())
+		newErrorResponse(ctx, err)
 		return
 	}
 
@@ -85,29 +154,65 @@ func (h *Handler) adminSignIn(ctx *gin.Context) {
 // @Produce  json
 // @Param input body refreshTokensInput true "refresh token info"
 // @Success 200 {object} tokenResponse
-// @Failure 400 {object} response
-// @Failure 500 {object} response
-// @Failure default {object} response
+// @Failure 400 {object} problem
+// @Failure 500 {object} problem
+// @Failure default {object} problem
 // @Router /admins/refreshTokens [post]
 func (h *Handler) adminRefreshTokens(ctx *gin.Context) {
 	var refreshInput refreshTokensInput
 
-	if err := ctx.BindJSON(&refreshInput); err != nil {
-		newResponse(ctx, http.StatusBadRequest, "invalid input body")
+	if err := ctx.ShouldBindJSON(&refreshInput); err != nil {
+		newBindingErrorResponse(ctx, err)
 		return
 	}
 
 	tokens, err := h.services.AdminRefreshSession(service.RefreshInput{
 		RefreshToken: refreshInput.RefreshToken,
+		UserAgent:    ctx.Request.UserAgent(),
+		Ip:           ctx.ClientIP(),
 	})
 	if err != nil {
-		newResponse(ctx, http.StatusInternalServerError, err.Error())
+		newErrorResponse(ctx, err)
 		return
 	}
 
 	ctx.JSON(http.StatusOK, tokens)
 }
 
+// @Summary Admin Logout
+// @Security AdminAuth
+// @Tags admin-auth
+// @Description admin closes the session of the current device
+// @Accept  json
+// @Produce  json
+// @Param input body refreshTokensInput true "refresh token of the session"
+// @Success 200 {object} string "logged out"
+// @Failure 400 {object} problem
+// @Failure 401 {object} problem
+// @Failure 500 {object} problem
+// @Failure default {object} problem
+// @Router /admins/logout [post]
+func (h *Handler) adminLogout(ctx *gin.Context) {
+	adminId, err := getAdminId(ctx)
+	if err != nil {
+		newErrorResponse(ctx, err)
+		return
+	}
+
+	var input refreshTokensInput
+	if err := ctx.ShouldBindJSON(&input); err != nil {
+		newBindingErrorResponse(ctx, err)
+		return
+	}
+
+	if err := h.services.AdminLogout(adminId, service.RefreshInput{RefreshToken: input.RefreshToken}); err != nil {
+		newErrorResponse(ctx, err)
+		return
+	}
+
+	ctx.JSON(http.StatusOK, "logged out")
+}
+
 // @Summary Admin Get All His Ads
 // @Security AdminAuth
 // @Tags admin-ads
@@ -115,14 +220,14 @@ func (h *Handler) adminRefreshTokens(ctx *gin.Context) {
 // @Accept  json
 // @Produce  json
 // @Success 200 {object} []domain.Ad
-// @Failure 400 {object} response
-// @Failure 500 {object} response
-// @Failure default {object} response
+// @Failure 400 {object} problem
+// @Failure 500 {object} problem
+// @Failure default {object} problem
 // @Router /admins/api/ads/ [get]
 func (h *Handler) adminGetAllAds(ctx *gin.Context) {
 	ads, err := h.services.Admin.AdminGetAllAdsByAdmin()
 	if err != nil {
-		newResponse(ctx, http.StatusInternalServerError, err.Error())
+		newErrorResponse(ctx, err)
 		return
 	}
 
@@ -137,16 +242,19 @@ func (h *Handler) adminGetAllAds(ctx *gin.Context) {
 // @Produce  json
 // @Param id path string true "adId"
 // @Success 200 {object} domain.Ad
-// @Failure 400 {object} response
-// @Failure 500 {object} response
-// @Failure default {object} response
+// @Failure 400 {object} problem
+// @Failure 500 {object} problem
+// @Failure default {object} problem
 // @Router /admins/api/ads/{id} [get]
 func (h *Handler) adminGetAd(ctx *gin.Context) {
-	id := ctx.Param("id")
+	id, ok := idParam(ctx, "ad")
+	if !ok {
+		return
+	}
 
 	ad, err := h.services.Admin.AdminGetAd(id)
 	if err != nil {
-		newResponse(ctx, http.StatusInternalServerError, err.Error())
+		newErrorResponse(ctx, err)
 		return
 	}
 
@@ -161,15 +269,18 @@ func (h *Handler) adminGetAd(ctx *gin.Context) {
 // @Produce  json
 // @Param id path string true "adId"
 // @Success 200 {object} string "deleted"
-// @Failure 400 {object} response
-// @Failure 500 {object} response
-// @Failure default {object} response
+// @Failure 400 {object} problem
+// @Failure 500 {object} problem
+// @Failure default {object} problem
 // @Router /admins/api/ads/{id} [delete]
 func (h *Handler) adminDeleteAd(ctx *gin.Context) {
-	id := ctx.Param("id")
+	id, ok := idParam(ctx, "ad")
+	if !ok {
+		return
+	}
 
 	if err := h.services.Admin.AdminDeleteUserAdById(id); err != nil {
-		newResponse(ctx, http.StatusInternalServerError, err.Error())
+		newErrorResponse(ctx, err)
 		return
 	}
 
@@ -185,21 +296,20 @@ func (h *Handler) adminDeleteAd(ctx *gin.Context) {
 // @Param id path string true "adId"
 // @Param input body inputAd true "ad info"
 // @Success 200 {object} domain.Ad
-// @Failure 400 {object} response
-// @Failure 500 {object} response
-// @Failure default {object} response
+// @Failure 400 {object} problem
+// @Failure 500 {object} problem
+// @Failure default {object} problem
 // @Router /admins/api/ads/{id} [put]
 func (h *Handler) adminUpdateAd(ctx *gin.Context) {
-	adId := ctx.Param("id")
-
-	var inputAd adminUpdateAdInput
-	if err := ctx.BindJSON(&inputAd); err != nil {
-		newResponse(ctx, http.StatusBadRequest, "invalid input body")
+	adId, ok := idParam(ctx, "ad")
+	if !ok {
 		return
 	}
 
-	if category := strings.Split(inputAd.Category, "/"); len(category) > 0 {
-		inputAd.Category = category[len(category)-1]
+	var inputAd adminUpdateAdInput
+	if err := ctx.ShouldBindJSON(&inputAd); err != nil {
+		newBindingErrorResponse(ctx, err)
+		return
 	}
 
 	ad, err := h.services.AdminUpdateAd(adId, service.Ads{
@@ -208,13 +318,647 @@ func (h *Handler) adminUpdateAd(ctx *gin.Context) {
 		Description: inputAd.Description,
 		Price:       inputAd.Price,
 		Contacts:    service.Contacts(inputAd.Contacts),
-		Published:   inputAd.Published,
 		ImagesURL:   inputAd.ImagesURL,
+		Attributes:  inputAd.Attributes,
+		Language:    inputAd.Language,
 	})
 	if err != nil {
-		newResponse(ctx, http.StatusInternalServerError, err.Error())
+		newErrorResponse(ctx, err)
 		return
 	}
 
 	ctx.JSON(http.StatusOK, ad)
 }
+
+// @Summary Admin Get Ads For Moderation
+// @Security AdminAuth
+// @Tags admin-moderation
+// @Description admin get ads waiting for review, oldest first
+// @Accept  json
+// @Produce  json
+// @Success 200 {object} []domain.Ad
+// @Failure 500 {object} problem
+// @Failure default {object} problem
+// @Router /admins/api/moderation/ [get]
+func (h *Handler) adminGetAdsForModeration(ctx *gin.Context) {
+	ads, err := h.services.Admin.AdminGetAdsForModeration()
+	if err != nil {
+		newErrorResponse(ctx, err)
+		return
+	}
+
+	ctx.JSON(http.StatusOK, ads)
+}
+
+// @Summary Admin Approve Ad
+// @Security AdminAuth
+// @Tags admin-moderation
+// @Description admin approve ad waiting for review and publish it
+// @Accept  json
+// @Produce  json
+// @Param id path string true "adId"
+// @Success 200 {object} domain.Ad
+// @Failure 403 {object} problem
+// @Failure 409 {object} problem
+// @Failure 500 {object} problem
+// @Failure default {object} problem
+// @Router /admins/api/ads/{id}/approve [post]
+func (h *Handler) adminApproveAd(ctx *gin.Context) {
+	adId, ok := idParam(ctx, "ad")
+	if !ok {
+		return
+	}
+
+	moderator, err := getModerator(ctx)
+	if err != nil {
+		newErrorResponse(ctx, err)
+		return
+	}
+
+	ad, err := h.services.Admin.AdminApproveAd(moderator, adId)
+	if err != nil {
+		newErrorResponse(ctx, err)
+		return
+	}
+
+	ctx.JSON(http.StatusOK, ad)
+}
+
+// @Summary Admin Reject Ad
+// @Security AdminAuth
+// @Tags admin-moderation
+// @Description admin reject ad waiting for review with a reason
+// @Accept  json
+// @Produce  json
+// @Param id path string true "adId"
+// @Param input body adminRejectAdInput true "rejection reason"
+// @Success 200 {object} domain.Ad
+// @Failure 400 {object} problem
+// @Failure 403 {object} problem
+// @Failure 409 {object} problem
+// @Failure 500 {object} problem
+// @Failure default {object} problem
+// @Router /admins/api/ads/{id}/reject [post]
+func (h *Handler) adminRejectAd(ctx *gin.Context) {
+	adId, ok := idParam(ctx, "ad")
+	if !ok {
+		return
+	}
+
+	var input adminRejectAdInput
+	if err := ctx.ShouldBindJSON(&input); err != nil {
+		newBindingErrorResponse(ctx, err)
+		return
+	}
+
+	moderator, err := getModerator(ctx)
+	if err != nil {
+		newErrorResponse(ctx, err)
+		return
+	}
+
+	ad, err := h.services.Admin.AdminRejectAd(moderator, adId, input.Reason)
+	if err != nil {
+		newErrorResponse(ctx, err)
+		return
+	}
+
+	ctx.JSON(http.StatusOK, ad)
+}
+
+// @Summary Admin Ban User
+// @Security AdminAuth
+// @Tags admin-moderation
+// @Description ban a user, banned users can not sign in and their sessions are ended
+// @Accept  json
+// @Produce  json
+// @Param id path string true "userId"
+// @Param input body adminBanUserInput true "ban reason"
+// @Success 200 {object} string "banned"
+// @Failure 400 {object} problem
+// @Failure 403 {object} problem
+// @Failure 404 {object} problem
+// @Failure 500 {object} problem
+// @Failure default {object} problem
+// @Router /admins/api/users/{id}/ban [post]
+func (h *Handler) adminBanUser(ctx *gin.Context) {
+	userId, ok := idParam(ctx, "user")
+	if !ok {
+		return
+	}
+
+	var input adminBanUserInput
+	if err := ctx.ShouldBindJSON(&input); err != nil {
+		newBindingErrorResponse(ctx, err)
+		return
+	}
+
+	if err := h.services.Admin.BanUser(userId, input.Reason); err != nil {
+		newErrorResponse(ctx, err)
+		return
+	}
+
+	ctx.JSON(http.StatusOK, "banned")
+}
+
+// @Summary Admin Unban User
+// @Security AdminAuth
+// @Tags admin-moderation
+// @Description lift the ban of a user
+// @Accept  json
+// @Produce  json
+// @Param id path string true "userId"
+// @Success 200 {object} string "unbanned"
+// @Failure 403 {object} problem
+// @Failure 404 {object} problem
+// @Failure 500 {object} problem
+// @Failure default {object} problem
+// @Router /admins/api/users/{id}/ban [delete]
+func (h *Handler) adminUnbanUser(ctx *gin.Context) {
+	userId, ok := idParam(ctx, "user")
+	if !ok {
+		return
+	}
+
+	if err := h.services.Admin.UnbanUser(userId); err != nil {
+		newErrorResponse(ctx, err)
+		return
+	}
+
+	ctx.JSON(http.StatusOK, "unbanned")
+}
+
+// @Summary Admin Get Roles
+// @Security AdminAuth
+// @Tags admin-roles
+// @Description admin get roles with their permissions
+// @Accept  json
+// @Produce  json
+// @Success 200 {object} []domain.Role
+// @Failure 403 {object} problem
+// @Failure 500 {object} problem
+// @Failure default {object} problem
+// @Router /admins/api/roles/ [get]
+func (h *Handler) adminGetRoles(ctx *gin.Context) {
+	roles, err := h.services.GetRoles()
+	if err != nil {
+		newErrorResponse(ctx, err)
+		return
+	}
+
+	ctx.JSON(http.StatusOK, roles)
+}
+
+// @Summary Admin Assign Role
+// @Security AdminAuth
+// @Tags admin-roles
+// @Description admin assign role to user or admin, it takes effect with the next access token
+// @Accept  json
+// @Produce  json
+// @Param input body adminRoleAssignmentInput true "role assignment info"
+// @Success 200 {object} string "assigned"
+// @Failure 400 {object} problem
+// @Failure 403 {object} problem
+// @Failure 404 {object} problem
+// @Failure 500 {object} problem
+// @Failure default {object} problem
+// @Router /admins/api/roles/assign [post]
+func (h *Handler) adminAssignRole(ctx *gin.Context) {
+	var input adminRoleAssignmentInput
+	if err := ctx.ShouldBindJSON(&input); err != nil {
+		newBindingErrorResponse(ctx, err)
+		return
+	}
+
+	err := h.services.AssignRole(service.RoleAssignmentInput{Kind: input.Kind, SubjectId: input.SubjectId, Role: input.Role})
+	if err != nil {
+		newErrorResponse(ctx, err)
+		return
+	}
+
+	ctx.JSON(http.StatusOK, "assigned")
+}
+
+// @Summary Admin Revoke Role
+// @Security AdminAuth
+// @Tags admin-roles
+// @Description admin revoke role from user or admin, it takes effect with the next access token
+// @Accept  json
+// @Produce  json
+// @Param input body adminRoleAssignmentInput true "role assignment info"
+// @Success 200 {object} string "revoked"
+// @Failure 400 {object} problem
+// @Failure 403 {object} problem
+// @Failure 404 {object} problem
+// @Failure 500 {object} problem
+// @Failure default {object} problem
+// @Router /admins/api/roles/revoke [post]
+func (h *Handler) adminRevokeRole(ctx *gin.Context) {
+	var input adminRoleAssignmentInput
+	if err := ctx.ShouldBindJSON(&input); err != nil {
+		newBindingErrorResponse(ctx, err)
+		return
+	}
+
+	err := h.services.RevokeRole(service.RoleAssignmentInput{Kind: input.Kind, SubjectId: input.SubjectId, Role: input.Role})
+	if err != nil {
+		newErrorResponse(ctx, err)
+		return
+	}
+
+	ctx.JSON(http.StatusOK, "revoked")
+}
+
+// @Summary Admin Create Category
+// @Security AdminAuth
+// @Tags admin-categories
+// @Description admin create category, it is a root category when parent_category is empty
+// @Accept  json
+// @Produce  json
+// @Param input body adminCategoryInput true "category info"
+// @Success 201 {object} domain.Categories
+// @Failure 400 {object} problem
+// @Failure 403 {object} problem
+// @Failure 404 {object} problem
+// @Failure 409 {object} problem
+// @Failure 500 {object} problem
+// @Failure default {object} problem
+// @Router /admins/api/categories/ [post]
+func (h *Handler) adminCreateCategory(ctx *gin.Context) {
+	var input adminCategoryInput
+	if err := ctx.ShouldBindJSON(&input); err != nil {
+		newBindingErrorResponse(ctx, err)
+		return
+	}
+
+	category, err := h.services.CreateCategory(service.CategoryInput{Name: strings.TrimSpace(input.Category), ParentId: input.ParentCategory})
+	if err != nil {
+		newErrorResponse(ctx, err)
+		return
+	}
+
+	ctx.JSON(http.StatusCreated, category)
+}
+
+// @Summary Admin Update Category
+// @Security AdminAuth
+// @Tags admin-categories
+// @Description admin rename category and move it under another parent, empty parent_category moves it to the root
+// @Accept  json
+// @Produce  json
+// @Param id path int true "categoryId"
+// @Param input body adminCategoryInput true "category info"
+// @Success 200 {object} domain.Categories
+// @Failure 400 {object} problem
+// @Failure 403 {object} problem
+// @Failure 404 {object} problem
+// @Failure 409 {object} problem
+// @Failure 500 {object} problem
+// @Failure default {object} problem
+// @Router /admins/api/categories/{id} [put]
+func (h *Handler) adminUpdateCategory(ctx *gin.Context) {
+	categoryId, err := strconv.Atoi(ctx.Param("id"))
+	if err != nil {
+		newParamErrorResponse(ctx, "id", "invalid category id")
+		return
+	}
+
+	var input adminCategoryInput
+	if err := ctx.ShouldBindJSON(&input); err != nil {
+		newBindingErrorResponse(ctx, err)
+		return
+	}
+
+	category, err := h.services.UpdateCategory(categoryId, service.CategoryInput{Name: strings.TrimSpace(input.Category), ParentId: input.ParentCategory})
+	if err != nil {
+		newErrorResponse(ctx, err)
+		return
+	}
+
+	ctx.JSON(http.StatusOK, category)
+}
+
+// @Summary Admin Set Category Attributes
+// @Security AdminAuth
+// @Tags admin-categories
+// @Description admin replace attributes declared by the category, subcategories inherit them
+// @Accept  json
+// @Produce  json
+// @Param id path int true "categoryId"
+// @Param input body adminCategoryAttributesInput true "attributes"
+// @Success 200 {object} domain.Categories
+// @Failure 400 {object} problem
+// @Failure 403 {object} problem
+// @Failure 404 {object} problem
+// @Failure 500 {object} problem
+// @Failure default {object} problem
+// @Router /admins/api/categories/{id}/attributes [put]
+func (h *Handler) adminSetCategoryAttributes(ctx *gin.Context) {
+	categoryId, err := strconv.Atoi(ctx.Param("id"))
+	if err != nil {
+		newParamErrorResponse(ctx, "id", "invalid category id")
+		return
+	}
+
+	var input adminCategoryAttributesInput
+	if err := ctx.ShouldBindJSON(&input); err != nil {
+		newBindingErrorResponse(ctx, err)
+		return
+	}
+
+	category, err := h.services.SetCategoryAttributes(categoryId, input.Attributes)
+	if err != nil {
+		newErrorResponse(ctx, err)
+		return
+	}
+
+	ctx.JSON(http.StatusOK, category)
+}
+
+// @Summary Admin Delete Category
+// @Security AdminAuth
+// @Tags admin-categories
+// @Description admin delete category without subcategories and ads
+// @Accept  json
+// @Produce  json
+// @Param id path int true "categoryId"
+// @Success 200 {object} string "deleted"
+// @Failure 400 {object} problem
+// @Failure 403 {object} problem
+// @Failure 404 {object} problem
+// @Failure 409 {object} problem
+// @Failure 500 {object} problem
+// @Failure default {object} problem
+// @Router /admins/api/categories/{id} [delete]
+func (h *Handler) adminDeleteCategory(ctx *gin.Context) {
+	categoryId, err := strconv.Atoi(ctx.Param("id"))
+	if err != nil {
+		newParamErrorResponse(ctx, "id", "invalid category id")
+		return
+	}
+
+	if err := h.services.DeleteCategory(categoryId); err != nil {
+		newErrorResponse(ctx, err)
+		return
+	}
+
+	ctx.JSON(http.StatusOK, "deleted")
+}
+
+// @Summary Admin SignIn Second Factor
+// @Tags admin-auth
+// @Description admin exchanges the challenge token of sign in and a TOTP or recovery code for tokens
+// @Accept  json
+// @Produce  json
+// @Param input body secondFactorInput true "challenge token and code"
+// @Success 200 {object} tokenResponse
+// @Failure 400 {object} problem
+// @Failure 401 {object} problem
+// @Failure 429 {object} problem
+// @Failure 500 {object} problem
+// @Failure default {object} problem
+// @Router /admins/Sign-In/2fa [post]
+func (h *Handler) adminSignInSecondFactor(ctx *gin.Context) {
+	var input secondFactorInput
+	if err := ctx.ShouldBindJSON(&input); err != nil {
+		newBindingErrorResponse(ctx, err)
+		return
+	}
+
+	tokens, err := h.services.AdminSignInSecondFactor(service.TwoFactorSignInInput{
+		ChallengeToken: input.ChallengeToken,
+		Code:           input.Code,
+		UserAgent:      ctx.Request.UserAgent(),
+		Ip:             ctx.ClientIP(),
+	})
+	if err != nil {
+		newErrorResponse(ctx, err)
+		return
+	}
+
+	ctx.JSON(http.StatusOK, tokens)
+}
+
+// @Summary Admin Enroll Two Factor
+// @Security AdminAuth
+// @Tags admin-2fa
+// @Description admin starts TOTP enrolment, the provisioning uri is meant to be shown as QR code
+// @Accept  json
+// @Produce  json
+// @Success 200 {object} service.TwoFactorEnrollment
+// @Failure 400 {object} problem
+// @Failure 409 {object} problem
+// @Failure 500 {object} problem
+// @Failure default {object} problem
+// @Router /admins/api/2fa/enroll [post]
+func (h *Handler) adminEnrollTwoFactor(ctx *gin.Context) {
+	id, err := getAdminId(ctx)
+	if err != nil {
+		newErrorResponse(ctx, err)
+		return
+	}
+
+	enrollment, err := h.services.AdminEnrollTwoFactor(id)
+	if err != nil {
+		newErrorResponse(ctx, err)
+		return
+	}
+
+	ctx.JSON(http.StatusOK, enrollment)
+}
+
+// @Summary Admin Confirm Two Factor
+// @Security AdminAuth
+// @Tags admin-2fa
+// @Description admin enables TOTP with the first code of the authenticator app, recovery codes are returned only once
+// @Accept  json
+// @Produce  json
+// @Param input body twoFactorCodeInput true "TOTP code"
+// @Success 200 {object} recoveryCodesResponse
+// @Failure 400 {object} problem
+// @Failure 409 {object} problem
+// @Failure 500 {object} problem
+// @Failure default {object} problem
+// @Router /admins/api/2fa/confirm [post]
+func (h *Handler) adminConfirmTwoFactor(ctx *gin.Context) {
+	id, err := getAdminId(ctx)
+	if err != nil {
+		newErrorResponse(ctx, err)
+		return
+	}
+
+	var input twoFactorCodeInput
+	if err := ctx.ShouldBindJSON(&input); err != nil {
+		newBindingErrorResponse(ctx, err)
+		return
+	}
+
+	codes, err := h.services.AdminConfirmTwoFactor(id, input.Code)
+	if err != nil {
+		newErrorResponse(ctx, err)
+		return
+	}
+
+	ctx.JSON(http.StatusOK, recoveryCodesResponse{RecoveryCodes: codes})
+}
+
+// @Summary Admin Disable Two Factor
+// @Security AdminAuth
+// @Tags admin-2fa
+// @Description admin disables TOTP with a TOTP or recovery code
+// @Accept  json
+// @Produce  json
+// @Param input body twoFactorCodeInput true "TOTP or recovery code"
+// @Success 200 {object} string "disabled"
+// @Failure 400 {object} problem
+// @Failure 403 {object} problem
+// @Failure 409 {object} problem
+// @Failure 429 {object} problem
+// @Failure 500 {object} problem
+// @Failure default {object} problem
+// @Router /admins/api/2fa/disable [post]
+func (h *Handler) adminDisableTwoFactor(ctx *gin.Context) {
+	id, err := getAdminId(ctx)
+	if err != nil {
+		newErrorResponse(ctx, err)
+		return
+	}
+
+	var input twoFactorCodeInput
+	if err := ctx.ShouldBindJSON(&input); err != nil {
+		newBindingErrorResponse(ctx, err)
+		return
+	}
+
+	if err := h.services.AdminDisableTwoFactor(id, input.Code); err != nil {
+		newErrorResponse(ctx, err)
+		return
+	}
+
+	ctx.JSON(http.StatusOK, "disabled")
+}
+
+// @Summary Admin Get Sessions
+// @Security AdminAuth
+// @Tags admin-sessions
+// @Description admin get sessions of all their devices
+// @Accept  json
+// @Produce  json
+// @Success 200 {object} []domain.AdminSession
+// @Failure 400 {object} problem
+// @Failure 500 {object} problem
+// @Failure default {object} problem
+// @Router /admins/api/sessions/ [get]
+func (h *Handler) adminGetSessions(ctx *gin.Context) {
+	adminId, err := getAdminId(ctx)
+	if err != nil {
+		newErrorResponse(ctx, err)
+		return
+	}
+
+	sessions, err := h.services.AdminGetSessions(adminId)
+	if err != nil {
+		newErrorResponse(ctx, err)
+		return
+	}
+
+	ctx.JSON(http.StatusOK, sessions)
+}
+
+// @Summary Admin Revoke Session
+// @Security AdminAuth
+// @Tags admin-sessions
+// @Description admin revoke session of one of their devices
+// @Accept  json
+// @Produce  json
+// @Param id path string true "sessionId"
+// @Success 200 {object} string "revoked"
+// @Failure 400 {object} problem
+// @Failure 404 {object} problem
+// @Failure 500 {object} problem
+// @Failure default {object} problem
+// @Router /admins/api/sessions/{id} [delete]
+func (h *Handler) adminRevokeSession(ctx *gin.Context) {
+	sessionId, ok := idParam(ctx, "session")
+	if !ok {
+		return
+	}
+
+	adminId, err := getAdminId(ctx)
+	if err != nil {
+		newErrorResponse(ctx, err)
+		return
+	}
+
+	if err := h.services.AdminRevokeSession(adminId, sessionId); err != nil {
+		newErrorResponse(ctx, err)
+		return
+	}
+
+	ctx.JSON(http.StatusOK, "revoked")
+}
+
+// @Summary Admin Revoke Other Sessions
+// @Security AdminAuth
+// @Tags admin-sessions
+// @Description admin revoke sessions of all devices except the current one
+// @Accept  json
+// @Produce  json
+// @Param input body refreshTokensInput true "refresh token of the current session"
+// @Success 200 {object} string "revoked"
+// @Failure 400 {object} problem
+// @Failure 401 {object} problem
+// @Failure 500 {object} problem
+// @Failure default {object} problem
+// @Router /admins/api/sessions/revoke-others [post]
+func (h *Handler) adminRevokeOtherSessions(ctx *gin.Context) {
+	adminId, err := getAdminId(ctx)
+	if err != nil {
+		newErrorResponse(ctx, err)
+		return
+	}
+
+	var input refreshTokensInput
+	if err := ctx.ShouldBindJSON(&input); err != nil {
+		newBindingErrorResponse(ctx, err)
+		return
+	}
+
+	if err := h.services.AdminRevokeOtherSessions(adminId, service.RefreshInput{RefreshToken: input.RefreshToken}); err != nil {
+		newErrorResponse(ctx, err)
+		return
+	}
+
+	ctx.JSON(http.StatusOK, "revoked")
+}
+
+// getModerator returns the admin or the user let in by staffIdentity.
+func getModerator(ctx *gin.Context) (auth.Subject, error) {
+	if adminId := ctx.GetString(adminContext); adminId != "" {
+		return auth.Subject{Id: adminId, Kind: auth.KindAdmin}, nil
+	}
+
+	if userId := ctx.GetString(userContext); userId != "" {
+		return auth.Subject{Id: userId, Kind: auth.KindUser}, nil
+	}
+
+	return auth.Subject{}, apperror.Unauthorized("unauthorized", "empty staff context")
+}
+
+func getAdminId(ctx *gin.Context) (string, error) {
+	id, ok := ctx.Get(adminContext)
+	if !ok {
+		return "", apperror.Unauthorized("unauthorized", "empty admin context")
+	}
+
+	if id == "" {
+		return "", apperror.Unauthorized("unauthorized", "empty body of admin context")
+	}
+
+	adminId, ok := id.(string)
+	if !ok {
+		return "", apperror.Unauthorized("unauthorized", "invalid type of adminId from context")
+	}
+
+	return adminId, nil
+}
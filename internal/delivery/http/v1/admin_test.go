@@ -3,8 +3,10 @@ package v1
 import (
 	"bytes"
 	"errors"
+	"fmt"
 	"github.com/TakoB222/postingAds-api/internal/domain"
 	"github.com/TakoB222/postingAds-api/internal/service"
+	"github.com/TakoB222/postingAds-api/pkg/auth"
 	"github.com/gin-gonic/gin"
 	"github.com/golang/mock/gomock"
 	"github.com/stretchr/testify/assert"
@@ -46,7 +48,7 @@ func TestAdminSignIn(t *testing.T){
 			},
 			mockBehavior: func(s *mock_service.MockAdmin, input service.SignInInput) {},
 			expectedStatusCode: 400,
-			expectedResponseBody: `{"message":"invalid input body"}`,
+			expectedResponseBody: `{"type":"about:blank","title":"Bad Request","status":400,"detail":"invalid input","instance":"/adminSignIn","code":"invalid_input","errors":[{"field":"password","message":"is required"}]}`,
 		},
 		{
 			name: "ok",
@@ -59,7 +61,7 @@ func TestAdminSignIn(t *testing.T){
 				s.EXPECT().AdminSignIn(input).Return(service.Tokens{}, errors.New("service failure"))
 			},
 			expectedStatusCode: 500,
-			expectedResponseBody: `{"message":"service failure"}`,
+			expectedResponseBody: `{"type":"about:blank","title":"Internal Server Error","status":500,"detail":"internal server error","instance":"/adminSignIn","code":"internal_error"}`,
 		},
 	}
 
@@ -72,6 +74,7 @@ func TestAdminSignIn(t *testing.T){
 			testCase.mockBehavior(admin, service.SignInInput{
 				Email: testCase.inputSignIn.Email,
 				Password: testCase.inputSignIn.Password,
+				Ip: "192.0.2.1",
 			})
 
 			services := &service.Service{Admin:admin}
@@ -120,7 +123,7 @@ func TestAdminRefreshTokens(t *testing.T){
 			inputRefresh: refreshTokensInput{},
 			mockBehavior: func(s *mock_service.MockAdmin, input service.RefreshInput) {},
 			expectedStatusCode: 400,
-			expectedResponseBody: `{"message":"invalid input body"}`,
+			expectedResponseBody: `{"type":"about:blank","title":"Bad Request","status":400,"detail":"invalid input","instance":"/adminRefreshTokens","code":"invalid_input"}`,
 		},
 		{
 			name: "service error",
@@ -132,7 +135,7 @@ func TestAdminRefreshTokens(t *testing.T){
 				s.EXPECT().AdminRefreshSession(input).Return(service.Tokens{}, errors.New("service failure"))
 			},
 			expectedStatusCode: 500,
-			expectedResponseBody: `{"message":"service failure"}`,
+			expectedResponseBody: `{"type":"about:blank","title":"Internal Server Error","status":500,"detail":"internal server error","instance":"/adminRefreshTokens","code":"internal_error"}`,
 		},
 	}
 
@@ -144,6 +147,7 @@ func TestAdminRefreshTokens(t *testing.T){
 			admin := mock_service.NewMockAdmin(c)
 			testCase.mockBehavior(admin, service.RefreshInput{
 				RefreshToken: testCase.inputRefresh.RefreshToken,
+				Ip: "192.0.2.1",
 			})
 
 			services := &service.Service{Admin:admin}
@@ -186,7 +190,7 @@ func TestAdminGetAllAds(t *testing.T){
 				s.EXPECT().AdminGetAllAdsByAdmin().Return([]domain.Ad{}, errors.New("service failure"))
 			},
 			expectedStatusCode: 500,
-			expectedResponseBody: `{"message":"service failure"}`,
+			expectedResponseBody: `{"type":"about:blank","title":"Internal Server Error","status":500,"detail":"internal server error","instance":"/adminGetAllAds","code":"internal_error"}`,
 		},
 	}
 
@@ -230,7 +234,7 @@ func TestAdminGetAd(t *testing.T){
 				s.EXPECT().AdminGetAd("1").Return(domain.Ad{}, nil)
 			},
 			expectedStatusCode: 200,
-			expectedResponseBody: `{"Id":0,"UserId":"","Title":"","Category":"","Description":"","Price":0,"Contacts":"","Published":false,"ImagesURL":null}`,
+			expectedResponseBody: `{"Id":0,"UserId":"","Title":"","Category":"","Description":"","Price":0,"Contacts":"","ImagesURL":null,"CreatedAt":"0001-01-01T00:00:00Z","Status":"","RejectionReason":"","FavoritesCount":0}`,
 		},
 		{
 			name: "service error",
@@ -238,7 +242,7 @@ func TestAdminGetAd(t *testing.T){
 				s.EXPECT().AdminGetAd("1").Return(domain.Ad{}, errors.New("service failure"))
 			},
 			expectedStatusCode: 500,
-			expectedResponseBody: `{"message":"service failure"}`,
+			expectedResponseBody: `{"type":"about:blank","title":"Internal Server Error","status":500,"detail":"internal server error","instance":"/adminGetAd/1","code":"internal_error"}`,
 		},
 	}
 
@@ -280,7 +284,7 @@ func TestAdminUpdateAd(t *testing.T){
 	}{
 		{
 			name: "ok",
-			inputBody: `{"title":"title","category":"category","description":"description","price":100,"contacts":{"name":"name", "phone_number":"number", "email":"email", "location":"location"},"published":true,"images_url":["url"]}`,
+			inputBody: `{"title":"title","category":"category","description":"description","price":100,"contacts":{"name":"name", "phone_number":"number", "email":"email", "location":"location"},"images_url":["url"]}`,
 			inputAd: adminUpdateAdInput{
 				Title: "title",
 				Category: "category",
@@ -292,26 +296,25 @@ func TestAdminUpdateAd(t *testing.T){
 					Email: "email",
 					Location: "location",
 				},
-				Published: true,
 				ImagesURL: []string{"url"},
 			},
 			mockBehavior: func(s *mock_service.MockAdmin, ad service.Ads) {
 				s.EXPECT().AdminUpdateAd("1", ad).Return(domain.Ad{}, nil)
 			},
 			expectedStatusCode: 200,
-			expectedResponseBody: `{"Id":0,"UserId":"","Title":"","Category":"","Description":"","Price":0,"Contacts":"","Published":false,"ImagesURL":null}`,
+			expectedResponseBody: `{"Id":0,"UserId":"","Title":"","Category":"","Description":"","Price":0,"Contacts":"","ImagesURL":null,"CreatedAt":"0001-01-01T00:00:00Z","Status":"","RejectionReason":"","FavoritesCount":0}`,
 		},
 		{
 			name: "Empty input field",
-			inputBody: `{"title":"title","description":"description","price":100,"contacts":{"name":"name", "phone_number":"number", "email":"email", "location":"location"},"published":true,"images_url":["url"]}`,
+			inputBody: `{"title":"title","description":"description","price":100,"contacts":{"name":"name", "phone_number":"number", "email":"email", "location":"location"},"images_url":["url"]}`,
 			inputAd: adminUpdateAdInput{},
 			mockBehavior: func(s *mock_service.MockAdmin, ad service.Ads) {},
 			expectedStatusCode: 400,
-			expectedResponseBody: `{"message":"invalid input body"}`,
+			expectedResponseBody: `{"type":"about:blank","title":"Bad Request","status":400,"detail":"invalid input","instance":"/adminUpdateAd/1","code":"invalid_input","errors":[{"field":"category","message":"is required"}]}`,
 		},
 		{
 			name: "service failure",
-			inputBody: `{"title":"title","category":"category","description":"description","price":100,"contacts":{"name":"name", "phone_number":"number", "email":"email", "location":"location"},"published":true,"images_url":["url"]}`,
+			inputBody: `{"title":"title","category":"category","description":"description","price":100,"contacts":{"name":"name", "phone_number":"number", "email":"email", "location":"location"},"images_url":["url"]}`,
 			inputAd: adminUpdateAdInput{
 				Title: "title",
 				Category: "category",
@@ -323,14 +326,13 @@ func TestAdminUpdateAd(t *testing.T){
 					Email: "email",
 					Location: "location",
 				},
-				Published: true,
 				ImagesURL: []string{"url"},
 			},
 			mockBehavior: func(s *mock_service.MockAdmin, ad service.Ads) {
 				s.EXPECT().AdminUpdateAd("1", ad).Return(domain.Ad{}, errors.New("service failure"))
 			},
 			expectedStatusCode: 500,
-			expectedResponseBody: `{"message":"service failure"}`,
+			expectedResponseBody: `{"type":"about:blank","title":"Internal Server Error","status":500,"detail":"internal server error","instance":"/adminUpdateAd/1","code":"internal_error"}`,
 		},
 	}
 
@@ -346,7 +348,6 @@ func TestAdminUpdateAd(t *testing.T){
 				Description: testCase.inputAd.Description,
 				Price: testCase.inputAd.Price,
 				Contacts: service.Contacts(testCase.inputAd.Contacts),
-				Published: testCase.inputAd.Published,
 				ImagesURL: testCase.inputAd.ImagesURL,
 			})
 
@@ -366,3 +367,358 @@ func TestAdminUpdateAd(t *testing.T){
 		})
 	}
 }
+
+func TestAdminRejectAd(t *testing.T) {
+	type mockBehavior func(s *mock_service.MockAdmin)
+
+	moderator := auth.Subject{Id: "2", Kind: auth.KindUser}
+
+	testTable := []struct {
+		name                 string
+		inputBody            string
+		mockBehavior         mockBehavior
+		expectedStatusCode   int
+		expectedResponseBody string
+	}{
+		{
+			name:      "ok",
+			inputBody: `{"reason":"spam"}`,
+			mockBehavior: func(s *mock_service.MockAdmin) {
+				s.EXPECT().AdminRejectAd(moderator, "1", "spam").Return(domain.Ad{Id: 1, Status: domain.AdStatusRejected, RejectionReason: "spam"}, nil)
+			},
+			expectedStatusCode:   200,
+			expectedResponseBody: `{"Id":1,"UserId":"","Title":"","Category":"","Description":"","Price":0,"Contacts":"","ImagesURL":null,"CreatedAt":"0001-01-01T00:00:00Z","Status":"rejected","RejectionReason":"spam","FavoritesCount":0}`,
+		},
+		{
+			name:                 "empty reason",
+			inputBody:            `{}`,
+			mockBehavior:         func(s *mock_service.MockAdmin) {},
+			expectedStatusCode:   400,
+			expectedResponseBody: `{"type":"about:blank","title":"Bad Request","status":400,"detail":"invalid input","instance":"/adminRejectAd/1","code":"invalid_input","errors":[{"field":"reason","message":"is required"}]}`,
+		},
+		{
+			name:      "illegal transition",
+			inputBody: `{"reason":"spam"}`,
+			mockBehavior: func(s *mock_service.MockAdmin) {
+				s.EXPECT().AdminRejectAd(moderator, "1", "spam").Return(domain.Ad{}, fmt.Errorf("%w from approved to rejected", service.ErrIllegalAdStatusTransition))
+			},
+			expectedStatusCode:   409,
+			expectedResponseBody: `{"type":"about:blank","title":"Conflict","status":409,"detail":"illegal ad status transition from approved to rejected","instance":"/adminRejectAd/1","code":"illegal_ad_status_transition"}`,
+		},
+		{
+			name:      "own ad",
+			inputBody: `{"reason":"spam"}`,
+			mockBehavior: func(s *mock_service.MockAdmin) {
+				s.EXPECT().AdminRejectAd(moderator, "1", "spam").Return(domain.Ad{}, service.ErrSelfModeration)
+			},
+			expectedStatusCode:   403,
+			expectedResponseBody: `{"type":"about:blank","title":"Forbidden","status":403,"detail":"ads can not be moderated by their owner","instance":"/adminRejectAd/1","code":"self_moderation"}`,
+		},
+		{
+			name:      "service failure",
+			inputBody: `{"reason":"spam"}`,
+			mockBehavior: func(s *mock_service.MockAdmin) {
+				s.EXPECT().AdminRejectAd(moderator, "1", "spam").Return(domain.Ad{}, errors.New("service failure"))
+			},
+			expectedStatusCode:   500,
+			expectedResponseBody: `{"type":"about:blank","title":"Internal Server Error","status":500,"detail":"internal server error","instance":"/adminRejectAd/1","code":"internal_error"}`,
+		},
+	}
+
+	for _, testCase := range testTable {
+		t.Run(testCase.name, func(t *testing.T) {
+			c := gomock.NewController(t)
+			defer c.Finish()
+
+			admin := mock_service.NewMockAdmin(c)
+			testCase.mockBehavior(admin)
+
+			services := &service.Service{Admin: admin}
+			handler := Handler{services: services}
+
+			r := gin.New()
+			r.POST("/adminRejectAd/:id", func(ctx *gin.Context) {
+				ctx.Set(userContext, moderator.Id)
+			}, handler.adminRejectAd)
+
+			w := httptest.NewRecorder()
+			req := httptest.NewRequest("POST", "/adminRejectAd/1", bytes.NewBufferString(testCase.inputBody))
+
+			r.ServeHTTP(w, req)
+
+			assert.Equal(t, testCase.expectedStatusCode, w.Code)
+			assert.Equal(t, testCase.expectedResponseBody, w.Body.String())
+		})
+	}
+}
+
+func TestAdminAssignRole(t *testing.T) {
+	type mockBehavior func(s *mock_service.MockRole)
+
+	testTable := []struct {
+		name                 string
+		permissions          []string
+		inputBody            string
+		mockBehavior         mockBehavior
+		expectedStatusCode   int
+		expectedResponseBody string
+	}{
+		{
+			name:        "ok",
+			permissions: []string{domain.PermissionAdsModerate, domain.PermissionRolesManage},
+			inputBody:   `{"kind":"admin","subject_id":"2","role":"moderator"}`,
+			mockBehavior: func(s *mock_service.MockRole) {
+				s.EXPECT().AssignRole(service.RoleAssignmentInput{Kind: "admin", SubjectId: "2", Role: "moderator"}).Return(nil)
+			},
+			expectedStatusCode:   200,
+			expectedResponseBody: `"assigned"`,
+		},
+		{
+			name:                 "permission denied",
+			permissions:          []string{domain.PermissionAdsModerate},
+			inputBody:            `{"kind":"admin","subject_id":"2","role":"moderator"}`,
+			mockBehavior:         func(s *mock_service.MockRole) {},
+			expectedStatusCode:   403,
+			expectedResponseBody: `{"type":"about:blank","title":"Forbidden","status":403,"detail":"permission denied: roles:manage","instance":"/adminAssignRole","code":"permission_denied"}`,
+		},
+		{
+			name:                 "unknown kind",
+			permissions:          []string{domain.PermissionRolesManage},
+			inputBody:            `{"kind":"guest","subject_id":"2","role":"moderator"}`,
+			mockBehavior:         func(s *mock_service.MockRole) {},
+			expectedStatusCode:   400,
+			expectedResponseBody: `{"type":"about:blank","title":"Bad Request","status":400,"detail":"invalid input","instance":"/adminAssignRole","code":"invalid_input","errors":[{"field":"kind","message":"must be one of user, admin"}]}`,
+		},
+		{
+			name:        "role not found",
+			permissions: []string{domain.PermissionRolesManage},
+			inputBody:   `{"kind":"user","subject_id":"2","role":"owner"}`,
+			mockBehavior: func(s *mock_service.MockRole) {
+				s.EXPECT().AssignRole(service.RoleAssignmentInput{Kind: "user", SubjectId: "2", Role: "owner"}).Return(service.ErrRoleNotFound)
+			},
+			expectedStatusCode:   404,
+			expectedResponseBody: `{"type":"about:blank","title":"Not Found","status":404,"detail":"role not found","instance":"/adminAssignRole","code":"role_not_found"}`,
+		},
+		{
+			name:        "service failure",
+			permissions: []string{domain.PermissionRolesManage},
+			inputBody:   `{"kind":"user","subject_id":"2","role":"moderator"}`,
+			mockBehavior: func(s *mock_service.MockRole) {
+				s.EXPECT().AssignRole(service.RoleAssignmentInput{Kind: "user", SubjectId: "2", Role: "moderator"}).Return(errors.New("service failure"))
+			},
+			expectedStatusCode:   500,
+			expectedResponseBody: `{"type":"about:blank","title":"Internal Server Error","status":500,"detail":"internal server error","instance":"/adminAssignRole","code":"internal_error"}`,
+		},
+	}
+
+	for _, testCase := range testTable {
+		t.Run(testCase.name, func(t *testing.T) {
+			c := gomock.NewController(t)
+			defer c.Finish()
+
+			role := mock_service.NewMockRole(c)
+			testCase.mockBehavior(role)
+
+			services := &service.Service{Role: role}
+			handler := Handler{services: services}
+
+			r := gin.New()
+			r.POST("/adminAssignRole", func(ctx *gin.Context) {
+				ctx.Set(permissionsContext, testCase.permissions)
+			}, handler.requirePermission(domain.PermissionRolesManage), handler.adminAssignRole)
+
+			w := httptest.NewRecorder()
+			req := httptest.NewRequest("POST", "/adminAssignRole", bytes.NewBufferString(testCase.inputBody))
+
+			r.ServeHTTP(w, req)
+
+			assert.Equal(t, testCase.expectedStatusCode, w.Code)
+			assert.Equal(t, testCase.expectedResponseBody, w.Body.String())
+		})
+	}
+}
+
+func TestAdminBanUser(t *testing.T) {
+	type mockBehavior func(s *mock_service.MockAdmin)
+
+	testTable := []struct {
+		name                 string
+		permissions          []string
+		inputBody            string
+		mockBehavior         mockBehavior
+		expectedStatusCode   int
+		expectedResponseBody string
+	}{
+		{
+			name:        "ok",
+			permissions: []string{domain.PermissionAdsModerate, domain.PermissionUsersBan},
+			inputBody:   `{"reason":"spam"}`,
+			mockBehavior: func(s *mock_service.MockAdmin) {
+				s.EXPECT().BanUser("2", "spam").Return(nil)
+			},
+			expectedStatusCode:   200,
+			expectedResponseBody: `"banned"`,
+		},
+		{
+			name:                 "permission denied",
+			permissions:          []string{domain.PermissionAdsModerate},
+			inputBody:            `{"reason":"spam"}`,
+			mockBehavior:         func(s *mock_service.MockAdmin) {},
+			expectedStatusCode:   403,
+			expectedResponseBody: `{"type":"about:blank","title":"Forbidden","status":403,"detail":"permission denied: users:ban","instance":"/adminBanUser/2","code":"permission_denied"}`,
+		},
+		{
+			name:                 "empty reason",
+			permissions:          []string{domain.PermissionUsersBan},
+			inputBody:            `{}`,
+			mockBehavior:         func(s *mock_service.MockAdmin) {},
+			expectedStatusCode:   400,
+			expectedResponseBody: `{"type":"about:blank","title":"Bad Request","status":400,"detail":"invalid input","instance":"/adminBanUser/2","code":"invalid_input","errors":[{"field":"reason","message":"is required"}]}`,
+		},
+		{
+			name:        "user not found",
+			permissions: []string{domain.PermissionUsersBan},
+			inputBody:   `{"reason":"spam"}`,
+			mockBehavior: func(s *mock_service.MockAdmin) {
+				s.EXPECT().BanUser("2", "spam").Return(service.ErrUserNotFound)
+			},
+			expectedStatusCode:   404,
+			expectedResponseBody: `{"type":"about:blank","title":"Not Found","status":404,"detail":"user not found","instance":"/adminBanUser/2","code":"user_not_found"}`,
+		},
+	}
+
+	for _, testCase := range testTable {
+		t.Run(testCase.name, func(t *testing.T) {
+			c := gomock.NewController(t)
+			defer c.Finish()
+
+			admin := mock_service.NewMockAdmin(c)
+			testCase.mockBehavior(admin)
+
+			services := &service.Service{Admin: admin}
+			handler := Handler{services: services}
+
+			r := gin.New()
+			r.POST("/adminBanUser/:id", func(ctx *gin.Context) {
+				ctx.Set(permissionsContext, testCase.permissions)
+			}, handler.requirePermission(domain.PermissionUsersBan), handler.adminBanUser)
+
+			w := httptest.NewRecorder()
+			req := httptest.NewRequest("POST", "/adminBanUser/2", bytes.NewBufferString(testCase.inputBody))
+
+			r.ServeHTTP(w, req)
+
+			assert.Equal(t, testCase.expectedStatusCode, w.Code)
+			assert.Equal(t, testCase.expectedResponseBody, w.Body.String())
+		})
+	}
+}
+
+func TestAdminUpdateCategory(t *testing.T) {
+	type mockBehavior func(s *mock_service.MockCategory)
+
+	parentId := 1
+
+	testTable := []struct {
+		name                 string
+		permissions          []string
+		categoryId           string
+		inputBody            string
+		mockBehavior         mockBehavior
+		expectedStatusCode   int
+		expectedResponseBody string
+	}{
+		{
+			name:        "ok",
+			permissions: []string{domain.PermissionCategoriesWrite},
+			categoryId:  "2",
+			inputBody:   `{"category":"Buses","parent_category":1}`,
+			mockBehavior: func(s *mock_service.MockCategory) {
+				s.EXPECT().UpdateCategory(2, service.CategoryInput{Name: "Buses", ParentId: &parentId}).
+					Return(domain.Categories{Id: 2, Category: "Buses", ParentCategory: &parentId}, nil)
+			},
+			expectedStatusCode:   200,
+			expectedResponseBody: `{"id":2,"category":"Buses","parent_category":1,"ads_count":0,"attributes":null}`,
+		},
+		{
+			name:                 "permission denied",
+			permissions:          []string{domain.PermissionAdsModerate},
+			categoryId:           "2",
+			inputBody:            `{"category":"Buses","parent_category":1}`,
+			mockBehavior:         func(s *mock_service.MockCategory) {},
+			expectedStatusCode:   403,
+			expectedResponseBody: `{"type":"about:blank","title":"Forbidden","status":403,"detail":"permission denied: categories:write","instance":"/adminUpdateCategory/2","code":"permission_denied"}`,
+		},
+		{
+			name:                 "invalid id",
+			permissions:          []string{domain.PermissionCategoriesWrite},
+			categoryId:           "buses",
+			inputBody:            `{"category":"Buses"}`,
+			mockBehavior:         func(s *mock_service.MockCategory) {},
+			expectedStatusCode:   400,
+			expectedResponseBody: `{"type":"about:blank","title":"Bad Request","status":400,"detail":"invalid category id","instance":"/adminUpdateCategory/buses","code":"invalid_param","errors":[{"field":"id","message":"invalid category id"}]}`,
+		},
+		{
+			name:        "cycle",
+			permissions: []string{domain.PermissionCategoriesWrite},
+			categoryId:  "1",
+			inputBody:   `{"category":"Transport","parent_category":1}`,
+			mockBehavior: func(s *mock_service.MockCategory) {
+				s.EXPECT().UpdateCategory(1, service.CategoryInput{Name: "Transport", ParentId: &parentId}).
+					Return(domain.Categories{}, service.ErrCategoryCycle)
+			},
+			expectedStatusCode:   409,
+			expectedResponseBody: `{"type":"about:blank","title":"Conflict","status":409,"detail":"category can not be moved into its own subtree","instance":"/adminUpdateCategory/1","code":"category_cycle"}`,
+		},
+		{
+			name:        "numeric name",
+			permissions: []string{domain.PermissionCategoriesWrite},
+			categoryId:  "2",
+			inputBody:   `{"category":"42"}`,
+			mockBehavior: func(s *mock_service.MockCategory) {
+				s.EXPECT().UpdateCategory(2, service.CategoryInput{Name: "42"}).
+					Return(domain.Categories{}, service.ErrInvalidCategoryName.Invalid("category", "must not be a number"))
+			},
+			expectedStatusCode:   400,
+			expectedResponseBody: `{"type":"about:blank","title":"Bad Request","status":400,"detail":"invalid category name","instance":"/adminUpdateCategory/2","code":"invalid_category_name","errors":[{"field":"category","message":"must not be a number"}]}`,
+		},
+		{
+			name:        "not found",
+			permissions: []string{domain.PermissionCategoriesWrite},
+			categoryId:  "100",
+			inputBody:   `{"category":"Boats"}`,
+			mockBehavior: func(s *mock_service.MockCategory) {
+				s.EXPECT().UpdateCategory(100, service.CategoryInput{Name: "Boats"}).
+					Return(domain.Categories{}, service.ErrCategoryNotFound)
+			},
+			expectedStatusCode:   404,
+			expectedResponseBody: `{"type":"about:blank","title":"Not Found","status":404,"detail":"category not found","instance":"/adminUpdateCategory/100","code":"category_not_found"}`,
+		},
+	}
+
+	for _, testCase := range testTable {
+		t.Run(testCase.name, func(t *testing.T) {
+			c := gomock.NewController(t)
+			defer c.Finish()
+
+			category := mock_service.NewMockCategory(c)
+			testCase.mockBehavior(category)
+
+			services := &service.Service{Category: category}
+			handler := Handler{services: services}
+
+			r := gin.New()
+			r.PUT("/adminUpdateCategory/:id", func(ctx *gin.Context) {
+				ctx.Set(permissionsContext, testCase.permissions)
+			}, handler.requirePermission(domain.PermissionCategoriesWrite), handler.adminUpdateCategory)
+
+			w := httptest.NewRecorder()
+			req := httptest.NewRequest("PUT", "/adminUpdateCategory/"+testCase.categoryId, bytes.NewBufferString(testCase.inputBody))
+
+			r.ServeHTTP(w, req)
+
+			assert.Equal(t, testCase.expectedStatusCode, w.Code)
+			assert.Equal(t, testCase.expectedResponseBody, w.Body.String())
+		})
+	}
+}
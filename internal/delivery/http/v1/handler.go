@@ -9,10 +9,12 @@ import (
 type Handler struct {
 	services     *service.Service
 	tokenManager auth.TokenManager
+	// allowedOrigins may open the WebSocket of events besides the origin of the api
+	allowedOrigins []string
 }
 
-func NewHandler(service *service.Service, tokeManager auth.TokenManager) *Handler {
-	return &Handler{services: service, tokenManager: tokeManager}
+func NewHandler(service *service.Service, tokeManager auth.TokenManager, allowedOrigins []string) *Handler {
+	return &Handler{services: service, tokenManager: tokeManager, allowedOrigins: allowedOrigins}
 }
 
 func (h *Handler) Init(groupApi *gin.RouterGroup) {
@@ -1,51 +1,121 @@
 package v1
 
 import (
-	"errors"
+	"fmt"
+	"github.com/TakoB222/postingAds-api/pkg/apperror"
+	"github.com/TakoB222/postingAds-api/pkg/auth"
 	"github.com/gin-gonic/gin"
-	"net/http"
 	"strings"
 )
 
 const (
 	authorizationHeader = "Authorization"
 
-	userContext  = "userId"
-	adminContext = "adminId"
+	userContext        = "userId"
+	adminContext       = "adminId"
+	permissionsContext = "permissions"
+	sessionContext     = "sessionId"
+	tokenExpiryContext = "tokenExpiresAt"
+)
+
+var (
+	errEmptyAuthHeader    = apperror.Unauthorized("empty_auth_header", "empty auth header")
+	errInvalidAuthHeader  = apperror.Unauthorized("invalid_auth_header", "invalid auth header")
+	errEmptyToken         = apperror.Unauthorized("empty_token", "token is empty")
+	errInvalidAccessToken = apperror.Unauthorized("invalid_access_token", "access token is invalid or expired")
+	errWrongTokenKind     = apperror.Unauthorized("wrong_token_kind", "token is not issued for")
+	errPermissionDenied   = apperror.Forbidden("permission_denied", "permission denied")
 )
 
 func (h *Handler) userIdentity(ctx *gin.Context) {
-	userId, err := h.parseAuthHeader(ctx)
+	subject, err := h.parseAuthHeader(ctx, auth.KindUser)
 	if err != nil {
-		newResponse(ctx, http.StatusUnauthorized, err.Error())
+		newErrorResponse(ctx, err)
+		return
+	}
+
+	ctx.Set(userContext, subject.Id)
+	ctx.Set(permissionsContext, subject.Permissions)
+	ctx.Set(sessionContext, subject.SessionId)
+	ctx.Set(tokenExpiryContext, subject.ExpiresAt)
+}
+
+// streamIdentity also takes the token from the access_token query param,
+// browsers can not set headers of EventSource and WebSocket requests. The param is redacted from the request log.
+func (h *Handler) streamIdentity(ctx *gin.Context) {
+	if token := ctx.Query("access_token"); token != "" && ctx.GetHeader(authorizationHeader) == "" {
+		ctx.Request.Header.Set(authorizationHeader, "Bearer "+token)
 	}
 
-	ctx.Set(userContext, userId)
+	h.userIdentity(ctx)
 }
 
 func (h *Handler) adminIdentity(ctx *gin.Context) {
-	adminId, err := h.parseAuthHeader(ctx)
+	subject, err := h.parseAuthHeader(ctx, auth.KindAdmin)
 	if err != nil {
-		newResponse(ctx, http.StatusUnauthorized, err.Error())
+		newErrorResponse(ctx, err)
+		return
 	}
 
-	ctx.Set(adminContext, adminId)
+	ctx.Set(adminContext, subject.Id)
+	ctx.Set(permissionsContext, subject.Permissions)
 }
 
-func (h *Handler) parseAuthHeader(ctx *gin.Context) (string, error) {
+// staffIdentity lets in both admins and users, routes behind it require permissions users get through their roles.
+func (h *Handler) staffIdentity(ctx *gin.Context) {
+	subject, err := h.parseAuthHeader(ctx, auth.KindAdmin, auth.KindUser)
+	if err != nil {
+		newErrorResponse(ctx, err)
+		return
+	}
+
+	if subject.Kind == auth.KindAdmin {
+		ctx.Set(adminContext, subject.Id)
+	} else {
+		ctx.Set(userContext, subject.Id)
+	}
+	ctx.Set(permissionsContext, subject.Permissions)
+}
+
+// requirePermission lets the request through only if one of the roles of the token owner grants the permission.
+func (h *Handler) requirePermission(permission string) gin.HandlerFunc {
+	return func(ctx *gin.Context) {
+		for _, granted := range ctx.GetStringSlice(permissionsContext) {
+			if granted == permission {
+				return
+			}
+		}
+
+		newErrorResponse(ctx, fmt.Errorf("%w: %s", errPermissionDenied, permission))
+	}
+}
+
+func (h *Handler) parseAuthHeader(ctx *gin.Context, kinds ...string) (auth.Subject, error) {
 	header := ctx.GetHeader(authorizationHeader)
 	if header == "" {
-		return "", errors.New("empty auth header")
+		return auth.Subject{}, errEmptyAuthHeader
 	}
 
 	headerParts := strings.Split(header, " ")
 	if len(headerParts) != 2 || headerParts[0] != "Bearer" {
-		return "", errors.New("invalid auth header")
+		return auth.Subject{}, errInvalidAuthHeader
 	}
 
 	if len(headerParts[1]) == 0 {
-		return "", errors.New("token is empty")
+		return auth.Subject{}, errEmptyToken
+	}
+
+	subject, err := h.tokenManager.Parse(headerParts[1])
+	if err != nil {
+		return auth.Subject{}, errInvalidAccessToken.Wrap(err)
+	}
+
+	// users and admins are stored apart, so a token of one kind must never pass for the other
+	for _, kind := range kinds {
+		if subject.Kind == kind {
+			return subject, nil
+		}
 	}
 
-	return h.tokenManager.Parse(headerParts[1])
+	return auth.Subject{}, fmt.Errorf("%w %s", errWrongTokenKind, strings.Join(kinds, " or "))
 }
@@ -1,15 +1,163 @@
 package v1
 
 import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"github.com/TakoB222/postingAds-api/internal/service"
+	"github.com/TakoB222/postingAds-api/pkg/apperror"
 	"github.com/TakoB222/postingAds-api/pkg/logger"
 	"github.com/gin-gonic/gin"
+	"github.com/gin-gonic/gin/binding"
+	"github.com/go-playground/validator/v10"
+	"net/http"
+	"reflect"
+	"strings"
 )
 
-type response struct {
-	Message string `json:"message"`
+const (
+	problemContentType = "application/problem+json"
+	problemType        = "about:blank" // problems are told apart by their code, not by a type uri
+
+	codeInternal = "internal_error"
+)
+
+var statusByKind = map[apperror.Kind]int{
+	apperror.KindNotFound:        http.StatusNotFound,
+	apperror.KindConflict:        http.StatusConflict,
+	apperror.KindValidation:      http.StatusBadRequest,
+	apperror.KindUnauthorized:    http.StatusUnauthorized,
+	apperror.KindForbidden:       http.StatusForbidden,
+	apperror.KindTooManyRequests: http.StatusTooManyRequests,
+	apperror.KindTooLarge:        http.StatusRequestEntityTooLarge,
+}
+
+var errInvalidInput = apperror.Validation("invalid_input", "invalid input")
+
+// problem is an RFC 7807 problem details response, clients tell errors apart by Code.
+type problem struct {
+	Type        string                `json:"type"`
+	Title       string                `json:"title"`
+	Status      int                   `json:"status"`
+	Detail      string                `json:"detail,omitempty"`
+	Instance    string                `json:"instance,omitempty"`
+	Code        string                `json:"code"`
+	Errors      []apperror.FieldError `json:"errors,omitempty"`      // invalid fields of the input
+	Suggestions []string              `json:"suggestions,omitempty"` // paths of categories close to the requested one
+}
+
+func init() {
+	if v, ok := binding.Validator.Engine().(*validator.Validate); ok {
+		v.RegisterTagNameFunc(fieldName)
+	}
 }
 
-func newResponse(ctx *gin.Context, statusCode int, message string) {
-	logger.Error(message)
-	ctx.AbortWithStatusJSON(statusCode, response{message})
+// newErrorResponse is the only place errors become responses. Application errors get the status of their kind,
+// any other error is internal: it is logged, while the client only learns that something went wrong.
+func newErrorResponse(ctx *gin.Context, err error) {
+	appErr, ok := apperror.As(err)
+	if !ok || appErr.Kind == apperror.KindInternal {
+		logger.Errorf("%s %s: %s", ctx.Request.Method, ctx.Request.URL.Path, err.Error())
+		writeProblem(ctx, problem{Status: http.StatusInternalServerError, Code: codeInternal, Detail: "internal server error"})
+		return
+	}
+
+	p := problem{
+		Status: statusByKind[appErr.Kind],
+		Code:   appErr.Code,
+		Detail: err.Error(),
+		Errors: appErr.Fields,
+	}
+
+	var categoryNotFound *service.CategoryNotFoundError
+	if errors.As(err, &categoryNotFound) {
+		p.Suggestions = categoryNotFound.Suggestions
+	}
+
+	writeProblem(ctx, p)
+}
+
+// newBindingErrorResponse answers input which could not be bound, naming the invalid fields when it is known.
+func newBindingErrorResponse(ctx *gin.Context, err error) {
+	var validationErrors validator.ValidationErrors
+	if errors.As(err, &validationErrors) {
+		fields := make([]apperror.FieldError, 0, len(validationErrors))
+		for _, fieldErr := range validationErrors {
+			fields = append(fields, apperror.FieldError{Field: fieldPath(fieldErr), Message: validationMessage(fieldErr)})
+		}
+		newErrorResponse(ctx, errInvalidInput.WithFields(fields...))
+		return
+	}
+
+	var typeErr *json.UnmarshalTypeError
+	if errors.As(err, &typeErr) && typeErr.Field != "" {
+		newErrorResponse(ctx, errInvalidInput.Invalid(typeErr.Field, "must be "+typeErr.Type.String()))
+		return
+	}
+
+	newErrorResponse(ctx, errInvalidInput.Wrap(err))
+}
+
+// newParamErrorResponse answers a malformed path or query param.
+func newParamErrorResponse(ctx *gin.Context, param, message string) {
+	newErrorResponse(ctx, apperror.Validation("invalid_param", message).Invalid(param, message))
+}
+
+func writeProblem(ctx *gin.Context, p problem) {
+	p.Type = problemType
+	p.Title = http.StatusText(p.Status)
+	p.Instance = ctx.Request.URL.Path
+
+	ctx.Header("Content-Type", problemContentType)
+	ctx.AbortWithStatusJSON(p.Status, p)
+}
+
+// fieldName names fields in validation errors the way clients send them.
+func fieldName(field reflect.StructField) string {
+	for _, tag := range []string{"json", "form", "uri"} {
+		name := strings.SplitN(field.Tag.Get(tag), ",", 2)[0]
+		if name == "-" {
+			return ""
+		}
+		if name != "" {
+			return name
+		}
+	}
+
+	return field.Name
+}
+
+// fieldPath drops the name of the input struct from the namespace of the field, e.g. inputAd.contacts.name is contacts.name.
+func fieldPath(err validator.FieldError) string {
+	parts := strings.SplitN(err.Namespace(), ".", 2)
+	if len(parts) < 2 {
+		return err.Field()
+	}
+
+	return parts[1]
+}
+
+func validationMessage(err validator.FieldError) string {
+	unit := ""
+	switch err.Kind() {
+	case reflect.String:
+		unit = " characters"
+	case reflect.Slice, reflect.Map:
+		unit = " items"
+	}
+
+	switch err.Tag() {
+	case "required":
+		return "is required"
+	case "min":
+		return fmt.Sprintf("must be at least %s%s", err.Param(), unit)
+	case "max":
+		return fmt.Sprintf("must be at most %s%s", err.Param(), unit)
+	case "email":
+		return "must be an email"
+	case "oneof":
+		return "must be one of " + strings.ReplaceAll(err.Param(), " ", ", ")
+	default:
+		return "does not satisfy " + err.Tag()
+	}
 }
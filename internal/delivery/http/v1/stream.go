@@ -0,0 +1,284 @@
+package v1
+
+import (
+	"bufio"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"github.com/TakoB222/postingAds-api/internal/service"
+	"github.com/TakoB222/postingAds-api/pkg/hub"
+	"github.com/TakoB222/postingAds-api/pkg/logger"
+	"github.com/gin-gonic/gin"
+	"golang.org/x/net/websocket"
+	"io"
+	"io/ioutil"
+	"net"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+)
+
+const (
+	lastEventIdHeader = "Last-Event-ID"
+
+	// heartbeats keep proxies from closing an idle stream and find clients that went away
+	streamHeartbeatInterval = 30 * time.Second
+	streamWriteTimeout      = 10 * time.Second
+	sseRetry                = 3 * time.Second
+)
+
+// streamOwner is the user a stream is opened for, the stream lasts as long as the token and the session do.
+type streamOwner struct {
+	userId         string
+	sessionId      string
+	tokenExpiresAt time.Time
+}
+
+type streamEvent struct {
+	Id   string          `json:"id"`
+	Type string          `json:"type"`
+	Data json.RawMessage `json:"data"`
+}
+
+// eventStream writes events to a client over one of the transports.
+type eventStream interface {
+	send(event hub.Event) error
+	heartbeat() error
+	// done is closed when the client closes the connection
+	done() <-chan struct{}
+}
+
+// @Summary User Stream Events
+// @Security UsersAuth
+// @Tags users-events
+// @Description pushes events of the user: new_message, ad_approved, ad_rejected and favorite_price_changed.
+// @Description A WebSocket upgrade request gets the events as json text messages, any other request gets a text/event-stream.
+// @Description Browsers may pass the token in access_token, as they can not set headers of these requests.
+// @Description A reconnecting client passes the id of the last event it got in the Last-Event-ID header or in last_event_id
+// @Description and gets the events it missed, as long as they are kept.
+// @Description The stream is closed when the access token expires or its session is revoked, the client reconnects with a new token.
+// @Description WebSocket requests of browsers are accepted from the origin of the api and the configured allowed origins only.
+// @Description Events are kept in the memory of the instance (pkg/hub) and are not fanned out across instances:
+// @Description a client gets the events published by the instance it is connected to.
+// @Produce  text/event-stream
+// @Param access_token query string false "access token, when the Authorization header can not be set"
+// @Param last_event_id query string false "id of the last event the client got"
+// @Success 200 {object} streamEvent
+// @Failure 401 {object} problem
+// @Failure 500 {object} problem
+// @Failure default {object} problem
+// @Router /auth/api/events [get]
+func (h *Handler) streamEvents(ctx *gin.Context) {
+	userId, err := getUserId(ctx)
+	if err != nil {
+		newErrorResponse(ctx, err)
+		return
+	}
+
+	owner := streamOwner{userId: userId, sessionId: ctx.GetString(sessionContext), tokenExpiresAt: ctx.GetTime(tokenExpiryContext)}
+
+	lastEventId := ctx.GetHeader(lastEventIdHeader)
+	if lastEventId == "" {
+		lastEventId = ctx.Query("last_event_id")
+	}
+
+	if ctx.IsWebsocket() {
+		server := websocket.Server{
+			// a page of another site could open the stream with a token in the query, so the origin is checked
+			Handshake: h.checkOrigin,
+			Handler: func(conn *websocket.Conn) {
+				h.pushEvents(owner, lastEventId, newWebSocketStream(conn))
+			},
+		}
+		server.ServeHTTP(ctx.Writer, ctx.Request)
+		return
+	}
+
+	// the stream outlives the write timeout of the server, so the connection is taken over from it
+	conn, rw, err := ctx.Writer.Hijack()
+	if err != nil {
+		newErrorResponse(ctx, err)
+		return
+	}
+	defer conn.Close()
+
+	stream, err := newSSEStream(conn, rw)
+	if err != nil {
+		return
+	}
+
+	h.pushEvents(owner, lastEventId, stream)
+}
+
+var errOriginNotAllowed = errors.New("origin is not allowed")
+
+// checkOrigin lets browsers open the WebSocket from pages of the api itself or of the allowed origins only,
+// clients other than browsers send no Origin.
+func (h *Handler) checkOrigin(_ *websocket.Config, req *http.Request) error {
+	origin := req.Header.Get("Origin")
+	if origin == "" {
+		return nil
+	}
+
+	if u, err := url.Parse(origin); err == nil && strings.EqualFold(u.Host, req.Host) {
+		return nil
+	}
+
+	for _, allowed := range h.allowedOrigins {
+		if strings.EqualFold(strings.TrimSuffix(allowed, "/"), origin) {
+			return nil
+		}
+	}
+
+	return fmt.Errorf("%w: %s", errOriginNotAllowed, origin)
+}
+
+// pushEvents sends events of the user until the client goes away, the token expires or the session is revoked.
+// A subscriber that falls behind is dropped, the connection is closed then and the client gets the missed events when it reconnects.
+func (h *Handler) pushEvents(owner streamOwner, lastEventId string, stream eventStream) {
+	sub := h.services.Subscribe(owner.userId, lastEventId)
+	defer h.services.Unsubscribe(sub)
+
+	ticker := time.NewTicker(streamHeartbeatInterval)
+	defer ticker.Stop()
+
+	expiry := time.NewTimer(time.Until(owner.tokenExpiresAt))
+	defer expiry.Stop()
+
+	for {
+		select {
+		case event, ok := <-sub.Events:
+			if !ok {
+				return
+			}
+			if err := stream.send(event); err != nil {
+				return
+			}
+		case <-ticker.C:
+			// a revoked session is noticed by the next heartbeat
+			if !h.sessionActive(owner) {
+				return
+			}
+			if err := stream.heartbeat(); err != nil {
+				return
+			}
+		case <-expiry.C:
+			return
+		case <-stream.done():
+			return
+		}
+	}
+}
+
+// sessionActive keeps streams of tokens issued without a session, they end with the token anyway.
+// A failed check keeps the stream too, it is checked again by the next heartbeat.
+func (h *Handler) sessionActive(owner streamOwner) bool {
+	if owner.sessionId == "" {
+		return true
+	}
+
+	err := h.services.CheckSession(owner.userId, owner.sessionId)
+	if err != nil && !errors.Is(err, service.ErrSessionNotFound) {
+		logger.Errorf("failed to check session %s of stream of user %s: %s", owner.sessionId, owner.userId, err.Error())
+		return true
+	}
+
+	return err == nil
+}
+
+type webSocketStream struct {
+	conn   *websocket.Conn
+	closed chan struct{}
+}
+
+func newWebSocketStream(conn *websocket.Conn) *webSocketStream {
+	s := &webSocketStream{conn: conn, closed: make(chan struct{})}
+
+	// the timeouts of the http server would end the stream, writes get their own deadlines instead
+	_ = conn.SetDeadline(time.Time{})
+
+	// clients do not send anything, reading only answers pings and notices the close
+	go func() {
+		defer close(s.closed)
+		_, _ = io.Copy(ioutil.Discard, conn)
+	}()
+
+	return s
+}
+
+func (s *webSocketStream) send(event hub.Event) error {
+	if err := s.conn.SetWriteDeadline(time.Now().Add(streamWriteTimeout)); err != nil {
+		return err
+	}
+
+	return websocket.JSON.Send(s.conn, streamEvent{Id: event.Id, Type: event.Type, Data: event.Data})
+}
+
+func (s *webSocketStream) heartbeat() error {
+	if err := s.conn.SetWriteDeadline(time.Now().Add(streamWriteTimeout)); err != nil {
+		return err
+	}
+
+	s.conn.PayloadType = websocket.PingFrame
+	defer func() { s.conn.PayloadType = websocket.TextFrame }()
+
+	_, err := s.conn.Write(nil)
+	return err
+}
+
+func (s *webSocketStream) done() <-chan struct{} {
+	return s.closed
+}
+
+type sseStream struct {
+	conn   net.Conn
+	w      *bufio.Writer
+	closed chan struct{}
+}
+
+func newSSEStream(conn net.Conn, rw *bufio.ReadWriter) (*sseStream, error) {
+	s := &sseStream{conn: conn, w: rw.Writer, closed: make(chan struct{})}
+
+	// the timeouts of the http server would end the stream, writes get their own deadlines instead
+	if err := conn.SetDeadline(time.Time{}); err != nil {
+		return nil, err
+	}
+
+	// the body ends with the connection, so it is neither chunked nor sized
+	if err := s.write("HTTP/1.1 200 OK\r\nContent-Type: text/event-stream\r\nCache-Control: no-cache\r\nConnection: close\r\n\r\n"+
+		"retry: %d\n\n", sseRetry.Milliseconds()); err != nil {
+		return nil, err
+	}
+
+	go func() {
+		defer close(s.closed)
+		_, _ = io.Copy(ioutil.Discard, rw.Reader)
+	}()
+
+	return s, nil
+}
+
+func (s *sseStream) send(event hub.Event) error {
+	return s.write("id: %s\nevent: %s\ndata: %s\n\n", event.Id, event.Type, event.Data)
+}
+
+func (s *sseStream) heartbeat() error {
+	return s.write(": ping\n\n")
+}
+
+func (s *sseStream) done() <-chan struct{} {
+	return s.closed
+}
+
+func (s *sseStream) write(format string, args ...interface{}) error {
+	if err := s.conn.SetWriteDeadline(time.Now().Add(streamWriteTimeout)); err != nil {
+		return err
+	}
+
+	if _, err := fmt.Fprintf(s.w, format, args...); err != nil {
+		return err
+	}
+
+	return s.w.Flush()
+}
@@ -1,20 +1,59 @@
 package v1
 
 import (
-	"errors"
-	"fmt"
+	"github.com/TakoB222/postingAds-api/internal/domain"
+	"github.com/TakoB222/postingAds-api/internal/repository"
 	"github.com/TakoB222/postingAds-api/internal/service"
+	"github.com/TakoB222/postingAds-api/pkg/apperror"
 	"github.com/gin-gonic/gin"
+	"io/ioutil"
 	"net/http"
-	"strings"
+	"strconv"
+)
+
+const (
+	defaultAdsLimit         = 20
+	defaultSuggestionsLimit = 10
+
+	// limits the whole multipart body, the size of the image itself is checked by the service
+	maxImageUploadBytes = 32 << 20
 )
 
 func (h *Handler) InitUsersRoutes(groupApi *gin.RouterGroup) {
+	ads := groupApi.Group("/ads")
+	{
+		ads.GET("/", h.getPublishedAds)
+	}
+
+	search := groupApi.Group("/search")
+	{
+		search.GET("/suggest", h.suggestSearch)
+	}
+
+	categories := groupApi.Group("/categories")
+	{
+		categories.GET("/", h.getCategories)
+		categories.GET("/:id/attributes", h.getCategoryAttributes)
+	}
+
+	images := groupApi.Group("/images")
+	{
+		images.GET("/:id", h.getImage)
+	}
+
 	auth := groupApi.Group("/auth")
 	{
 		auth.POST("/Sign-In", h.signIn)
+		auth.POST("/Sign-In/2fa", h.signInSecondFactor)
 		auth.POST("/Sign-Up", h.signUp)
 		auth.POST("/refreshTokens", h.refreshTokens)
+		auth.POST("/logout", h.userIdentity, h.logout)
+		auth.GET("/verify-email", h.verifyEmail)
+		auth.POST("/verify-email/resend", h.resendVerificationEmail)
+		auth.POST("/password-reset", h.requestPasswordReset)
+		auth.POST("/password-reset/confirm", h.resetPassword)
+
+		auth.GET("/api/events", h.streamIdentity, h.streamEvents)
 
 		api := auth.Group("/api", h.userIdentity)
 		{
@@ -25,11 +64,69 @@ func (h *Handler) InitUsersRoutes(groupApi *gin.RouterGroup) {
 				ads.GET("/:id", h.getAdById)
 				ads.PUT("/:id", h.updateAd)
 				ads.DELETE("/:id", h.deleteAd)
+				ads.POST("/:id/archive", h.archiveAd)
+				ads.POST("/:id/contacts", h.revealContacts)
+			}
+			contactReveals := api.Group("/contact-reveals")
+			{
+				contactReveals.GET("/", h.getContactRevealStats)
+			}
+			contactProfiles := api.Group("/contact-profiles")
+			{
+				contactProfiles.GET("/", h.getContactProfiles)
+				contactProfiles.POST("/", h.createContactProfile)
+				contactProfiles.GET("/:id", h.getContactProfile)
+				contactProfiles.PUT("/:id", h.updateContactProfile)
+				contactProfiles.DELETE("/:id", h.deleteContactProfile)
+			}
+			images := api.Group("/images")
+			{
+				images.POST("/", h.uploadImage)
 			}
 			fts := api.Group("/fts")
 			{
 				fts.GET("/", h.fts)
 			}
+			savedSearches := api.Group("/saved-searches")
+			{
+				savedSearches.GET("/", h.getSavedSearches)
+				savedSearches.POST("/", h.createSavedSearch)
+				savedSearches.DELETE("/:id", h.deleteSavedSearch)
+			}
+			favorites := api.Group("/favorites")
+			{
+				favorites.GET("/", h.getFavorites)
+				favorites.PUT("/:id", h.addFavorite)
+				favorites.DELETE("/:id", h.removeFavorite)
+			}
+			threads := api.Group("/threads")
+			{
+				threads.GET("/", h.getThreads)
+				threads.POST("/", h.openThread)
+				threads.GET("/:id", h.getThread)
+				threads.GET("/:id/messages", h.getMessages)
+				threads.POST("/:id/messages", h.sendMessage)
+				threads.POST("/:id/read", h.readThread)
+				threads.POST("/:id/block", h.blockBuyer)
+				threads.DELETE("/:id/block", h.unblockBuyer)
+			}
+			notifications := api.Group("/notifications")
+			{
+				notifications.GET("/", h.getNotifications)
+				notifications.POST("/:id/read", h.readNotification)
+			}
+			sessions := api.Group("/sessions")
+			{
+				sessions.GET("/", h.getSessions)
+				sessions.DELETE("/:id", h.revokeSession)
+				sessions.POST("/revoke-others", h.revokeOtherSessions)
+			}
+			twoFactor := api.Group("/2fa")
+			{
+				twoFactor.POST("/enroll", h.enrollTwoFactor)
+				twoFactor.POST("/confirm", h.confirmTwoFactor)
+				twoFactor.POST("/disable", h.disableTwoFactor)
+			}
 		}
 	}
 }
@@ -51,17 +148,139 @@ type (
 		RefreshToken string `json:"RefreshToken" binding:"required"`
 	}
 
+	emailInput struct {
+		Email string `json:"email" binding:"required,email"`
+	}
+
+	resetPasswordInput struct {
+		Token    string `json:"token" binding:"required"`
+		Password string `json:"password" binding:"required"`
+	}
+
+	secondFactorInput struct {
+		ChallengeToken string `json:"challenge_token" binding:"required"`
+		Code           string `json:"code" binding:"required"`
+	}
+
+	twoFactorCodeInput struct {
+		Code string `json:"code" binding:"required"`
+	}
+
 	tokenResponse struct {
-		AccessToken  string `json:"accessToken"`
-		RefreshToken string `json:"refreshToken"`
+		AccessToken    string `json:"accessToken"`
+		RefreshToken   string `json:"refreshToken"`
+		ChallengeToken string `json:"challenge_token"` // returned alone when the account has the second factor
+	}
+
+	recoveryCodesResponse struct {
+		RecoveryCodes []string `json:"recovery_codes"`
+	}
+
+	ftsQuery struct {
+		Request  string `form:"q" binding:"required"`
+		Language string `form:"lang"`
+		Facets   bool   `form:"facets"`
+		Category string `form:"category"`
+		MinPrice *int   `form:"min_price" binding:"omitempty,min=0"`
+		MaxPrice *int   `form:"max_price" binding:"omitempty,min=0"`
+		Location string `form:"location"`
+		Limit    int    `form:"limit" binding:"omitempty,min=1,max=100"`
+		Offset   int    `form:"offset" binding:"omitempty,min=0"`
+	}
+
+	ftsResponse struct {
+		Ads        []repository.FtsResponse `json:"ads"`
+		Total      int                      `json:"total"`
+		Limit      int                      `json:"limit"`
+		Offset     int                      `json:"offset"`
+		DidYouMean string                   `json:"did_you_mean,omitempty"` // corrected request when nothing is found
+		Facets     *repository.SearchFacets `json:"facets,omitempty"`
+	}
+
+	savedSearchInput struct {
+		Name       string            `json:"name"` // the query is used when empty
+		Query      string            `json:"query" binding:"required"`
+		Language   string            `json:"language"`
+		Category   string            `json:"category"` // id or full path of the category
+		MinPrice   *int              `json:"min_price" binding:"omitempty,min=0"`
+		MaxPrice   *int              `json:"max_price" binding:"omitempty,min=0"`
+		Location   string            `json:"location"`
+		Attributes map[string]string `json:"attributes"`                 // filters on attributes of the category, see GET /ads/
+		Channel    string            `json:"channel" binding:"required"` // email, webhook or inbox
+		WebhookURL string            `json:"webhook_url"`
+	}
+
+	notificationsQuery struct {
+		Limit  int `form:"limit" binding:"omitempty,min=1,max=100"`
+		Offset int `form:"offset" binding:"omitempty,min=0"`
+	}
+
+	notificationsResponse struct {
+		Notifications []domain.Notification `json:"notifications"`
+		Unread        int                   `json:"unread"`
+		Limit         int                   `json:"limit"`
+		Offset        int                   `json:"offset"`
+	}
+
+	openThreadInput struct {
+		AdId    int    `json:"ad_id" binding:"required,min=1"`
+		Message string `json:"message" binding:"required,max=2000"`
+	}
+
+	messageInput struct {
+		Message string `json:"message" binding:"required,max=2000"`
+	}
+
+	pageQuery struct {
+		Limit  int `form:"limit" binding:"omitempty,min=1,max=100"`
+		Offset int `form:"offset" binding:"omitempty,min=0"`
+	}
+
+	threadsResponse struct {
+		Threads []domain.Thread `json:"threads"`
+		Unread  int             `json:"unread"`
+		Limit   int             `json:"limit"`
+		Offset  int             `json:"offset"`
+	}
+
+	contactProfileInput struct {
+		Label        string `json:"label" binding:"required,max=255"`
+		Name         string `json:"name" binding:"required"`
+		Phone_number string `json:"phone_number" binding:"required"`
+		Email        string `json:"email" binding:"required"`
+		Location     string `json:"location" binding:"required"`
+	}
+
+	messagesResponse struct {
+		Messages []domain.Message `json:"messages"`
+		Limit    int              `json:"limit"`
+		Offset   int              `json:"offset"`
+	}
+
+	suggestQuery struct {
+		Query string `form:"q" binding:"required"`
+		Limit int    `form:"limit" binding:"omitempty,min=1,max=20"`
+	}
+
+	publishedAdsQuery struct {
+		Category string `form:"category"`
+		MinPrice *int   `form:"min_price" binding:"omitempty,min=0"`
+		MaxPrice *int   `form:"max_price" binding:"omitempty,min=0"`
+		Location string `form:"location"`
+		Sort     string `form:"sort" binding:"omitempty,oneof=newest oldest price_asc price_desc"`
+		Limit    int    `form:"limit" binding:"omitempty,min=1,max=100"`
+		Offset   int    `form:"offset" binding:"omitempty,min=0"`
 	}
 
-	inputFTSRequest struct {
-		Request string `json:"request" binding:"required"`
+	adsListResponse struct {
+		Ads    []domain.Ad `json:"ads"`
+		Total  int         `json:"total"`
+		Limit  int         `json:"limit"`
+		Offset int         `json:"offset"`
 	}
 )
 
-//------------------Authorization------------------
+// ------------------Authorization------------------
 // @Summary User SignIn
 // @Tags users-auth
 // @Description user sign in
@@ -69,23 +288,27 @@ type (
 // @Produce  json
 // @Param input body signInInput true "sign in info"
 // @Success 200 {object} tokenResponse
-// @Failure 400 {object} response
-// @Failure 500 {object} response
-// @Failure default {object} response
+// @Failure 400 {object} problem
+// @Failure 401 {object} problem
+// @Failure 403 {object} problem
+// @Failure 500 {object} problem
+// @Failure default {object} problem
 // @Router /auth/Sign-In [post]
 func (h *Handler) signIn(ctx *gin.Context) {
 	var input signInInput
-	if err := ctx.BindJSON(&input); err != nil {
-		newResponse(ctx, http.StatusBadRequest, "invalid input body")
+	if err := ctx.ShouldBindJSON(&input); err != nil {
+		newBindingErrorResponse(ctx, err)
 		return
 	}
 
 	tokens, err := h.services.Authorization.SignIn(service.SignInInput{
-		Email:    input.Email,
-		Password: input.Password,
+		Email:     input.Email,
+		Password:  input.Password,
+		UserAgent: ctx.Request.UserAgent(),
+		Ip:        ctx.ClientIP(),
 	})
 	if err != nil {
-		newResponse(ctx, http.StatusInternalServerError, err.Error())
+		newErrorResponse(ctx, err)
 		return
 	}
 
@@ -99,14 +322,14 @@ func (h *Handler) signIn(ctx *gin.Context) {
 // @Produce  json
 // @Param input body signUpInput true "sign up info"
 // @Success 201 {object} map[string]interface{}
-// @Failure 400 {object} response
-// @Failure 500 {object} response
-// @Failure default {object} response
+// @Failure 400 {object} problem
+// @Failure 500 {object} problem
+// @Failure default {object} problem
 // @Router /auth/Sign-Up [post]
 func (h *Handler) signUp(ctx *gin.Context) {
 	var input signUpInput
-	if err := ctx.BindJSON(&input); err != nil {
-		newResponse(ctx, http.StatusBadRequest, "invalid input body")
+	if err := ctx.ShouldBindJSON(&input); err != nil {
+		newBindingErrorResponse(ctx, err)
 		return
 	}
 
@@ -118,7 +341,7 @@ func (h *Handler) signUp(ctx *gin.Context) {
 	})
 
 	if err != nil {
-		newResponse(ctx, http.StatusInternalServerError, err.Error())
+		newErrorResponse(ctx, err)
 		return
 	}
 
@@ -134,277 +357,1793 @@ func (h *Handler) signUp(ctx *gin.Context) {
 // @Produce  json
 // @Param input body refreshTokensInput true "refresh token info"
 // @Success 200 {object} tokenResponse
-// @Failure 400 {object} response
-// @Failure 500 {object} response
-// @Failure default {object} response
+// @Failure 400 {object} problem
+// @Failure 500 {object} problem
+// @Failure default {object} problem
 // @Router /auth/refreshTokens [post]
 func (h *Handler) refreshTokens(ctx *gin.Context) {
 	var refreshInput refreshTokensInput
 
-	if err := ctx.BindJSON(&refreshInput); err != nil {
-		newResponse(ctx, http.StatusBadRequest, "invalid input body")
+	if err := ctx.ShouldBindJSON(&refreshInput); err != nil {
+		newBindingErrorResponse(ctx, err)
 		return
 	}
 
 	tokens, err := h.services.RefreshSession(service.RefreshInput{
 		RefreshToken: refreshInput.RefreshToken,
+		UserAgent:    ctx.Request.UserAgent(),
+		Ip:           ctx.ClientIP(),
 	})
 	if err != nil {
-		newResponse(ctx, http.StatusInternalServerError, err.Error())
+		newErrorResponse(ctx, err)
 		return
 	}
 
 	ctx.JSON(http.StatusOK, tokens)
 }
 
-//------------------Ads------------------
+// @Summary User Verify Email
+// @Tags users-auth
+// @Description user verifies the email with the token from the link sent to it
+// @Accept  json
+// @Produce  json
+// @Param token query string true "verification token"
+// @Success 200 {object} string "verified"
+// @Failure 400 {object} problem
+// @Failure 500 {object} problem
+// @Failure default {object} problem
+// @Router /auth/verify-email [get]
+func (h *Handler) verifyEmail(ctx *gin.Context) {
+	token := ctx.Query("token")
+	if token == "" {
+		newParamErrorResponse(ctx, "token", "empty token")
+		return
+	}
 
-//TODO: create input data struct validator
+	if err := h.services.VerifyEmail(token); err != nil {
+		newErrorResponse(ctx, err)
+		return
+	}
 
-type (
-	inputAd struct {
-		Title       string        `json:"title" binding:"required"`
-		Category    string        `json:"category" binding:"required"`
-		Description string        `json:"description" binding:"required"`
-		Price       int           `json:"price" binding:"required"`
-		Contacts    inputContacts `json:"contacts" binding:"required"`
-		Published   bool          `json:"published"`
-		ImagesURL   []string      `json:"images_url" binding:"required"`
+	ctx.JSON(http.StatusOK, "verified")
+}
+
+// @Summary User Resend Verification Email
+// @Tags users-auth
+// @Description user requests a new verification email, the response does not depend on the account existence
+// @Accept  json
+// @Produce  json
+// @Param input body emailInput true "account email"
+// @Success 200 {object} string "sent"
+// @Failure 400 {object} problem
+// @Failure 500 {object} problem
+// @Failure default {object} problem
+// @Router /auth/verify-email/resend [post]
+func (h *Handler) resendVerificationEmail(ctx *gin.Context) {
+	var input emailInput
+	if err := ctx.ShouldBindJSON(&input); err != nil {
+		newBindingErrorResponse(ctx, err)
+		return
 	}
-	inputContacts struct {
-		Name         string `json:"name" binding:"required"`
-		Phone_number string `json:"phone_number" binding:"required"`
-		Email        string `json:"email" binding:"required"`
-		Location     string `json:"location" binding:"required"`
+
+	if err := h.services.ResendVerificationEmail(input.Email); err != nil {
+		newErrorResponse(ctx, err)
+		return
 	}
-)
 
-// @Summary User Get All His Ads
-// @Security UsersAuth
-// @Tags users-ads
-// @Description user get all his ads by userId
+	ctx.JSON(http.StatusOK, "sent")
+}
+
+// @Summary User Request Password Reset
+// @Tags users-auth
+// @Description user requests a password reset email, the response does not depend on the account existence
 // @Accept  json
 // @Produce  json
-// @Success 200 {object} []domain.Ad
-// @Failure 400 {object} response
-// @Failure 500 {object} response
-// @Failure default {object} response
-// @Router /auth/api/ads/ [get]
-func (h *Handler) getAllAds(ctx *gin.Context) {
-	userId, err := getUserId(ctx)
-	if err != nil {
-		newResponse(ctx, http.StatusBadRequest, err.Error())
+// @Param input body emailInput true "account email"
+// @Success 200 {object} string "sent"
+// @Failure 400 {object} problem
+// @Failure 500 {object} problem
+// @Failure default {object} problem
+// @Router /auth/password-reset [post]
+func (h *Handler) requestPasswordReset(ctx *gin.Context) {
+	var input emailInput
+	if err := ctx.ShouldBindJSON(&input); err != nil {
+		newBindingErrorResponse(ctx, err)
 		return
 	}
 
-	ads, err := h.services.GetAllAds(userId)
-	if err != nil {
-		newResponse(ctx, http.StatusInternalServerError, err.Error())
+	if err := h.services.RequestPasswordReset(input.Email); err != nil {
+		newErrorResponse(ctx, err)
 		return
 	}
 
-	ctx.JSON(http.StatusOK, ads)
+	ctx.JSON(http.StatusOK, "sent")
 }
 
-// @Summary User Create Own Ad
-// @Security UsersAuth
-// @Tags users-ads
-// @Description user create his own ad
+// @Summary User Reset Password
+// @Tags users-auth
+// @Description user sets a new password with the token from the reset email, all sessions are closed
 // @Accept  json
 // @Produce  json
-// @Param input body inputAd true "create ad info"
-// @Success 200 {object} map[string]interface{}
-// @Failure 400 {object} response
-// @Failure 500 {object} response
-// @Failure default {object} response
-// @Router /auth/api/ads/ [post]
-func (h *Handler) createAd(ctx *gin.Context) {
-	var inputAd inputAd
-	if err := ctx.BindJSON(&inputAd); err != nil {
-		newResponse(ctx, http.StatusBadRequest, "invalid input body")
+// @Param input body resetPasswordInput true "reset token and new password"
+// @Success 200 {object} string "reset"
+// @Failure 400 {object} problem
+// @Failure 500 {object} problem
+// @Failure default {object} problem
+// @Router /auth/password-reset/confirm [post]
+func (h *Handler) resetPassword(ctx *gin.Context) {
+	var input resetPasswordInput
+	if err := ctx.ShouldBindJSON(&input); err != nil {
+		newBindingErrorResponse(ctx, err)
 		return
 	}
 
-	userId, err := getUserId(ctx)
+	err := h.services.ResetPassword(service.PasswordResetInput{Token: input.Token, Password: input.Password})
 	if err != nil {
-		newResponse(ctx, http.StatusBadRequest, err.Error())
+		newErrorResponse(ctx, err)
 		return
 	}
 
-	if category := strings.Split(inputAd.Category, "/"); len(category) > 0 {
-		inputAd.Category = category[len(category)-1]
-	}
+	ctx.JSON(http.StatusOK, "reset")
+}
 
-	fmt.Println(inputAd.Category)
+// @Summary User SignIn Second Factor
+// @Tags users-auth
+// @Description user exchanges the challenge token of sign in and a TOTP or recovery code for tokens
+// @Accept  json
+// @Produce  json
+// @Param input body secondFactorInput true "challenge token and code"
+// @Success 200 {object} tokenResponse
+// @Failure 400 {object} problem
+// @Failure 401 {object} problem
+// @Failure 429 {object} problem
+// @Failure 500 {object} problem
+// @Failure default {object} problem
+// @Router /auth/Sign-In/2fa [post]
+func (h *Handler) signInSecondFactor(ctx *gin.Context) {
+	var input secondFactorInput
+	if err := ctx.ShouldBindJSON(&input); err != nil {
+		newBindingErrorResponse(ctx, err)
+		return
+	}
 
-	adId, err := h.services.Ad.CreateAd(userId, service.Ads{
-		Title:       inputAd.Title,
-		Category:    inputAd.Category,
-		Description: inputAd.Description,
-		Price:       inputAd.Price,
-		Contacts:    service.Contacts(inputAd.Contacts),
-		Published:   inputAd.Published,
-		ImagesURL:   inputAd.ImagesURL,
+	tokens, err := h.services.SignInSecondFactor(service.TwoFactorSignInInput{
+		ChallengeToken: input.ChallengeToken,
+		Code:           input.Code,
+		UserAgent:      ctx.Request.UserAgent(),
+		Ip:             ctx.ClientIP(),
 	})
 	if err != nil {
-		newResponse(ctx, http.StatusInternalServerError, err.Error())
+		newErrorResponse(ctx, err)
 		return
 	}
 
-	ctx.JSON(http.StatusCreated, map[string]interface{}{
-		"id": adId,
-	})
+	ctx.JSON(http.StatusOK, tokens)
 }
 
-// @Summary User Get Ad By AdId
+// @Summary User Enroll Two Factor
 // @Security UsersAuth
-// @Tags users-ads
-// @Description user get ad by adId
+// @Tags users-2fa
+// @Description user starts TOTP enrolment, the provisioning uri is meant to be shown as QR code
 // @Accept  json
 // @Produce  json
-// @Param id path string true "adId"
-// @Success 200 {object} domain.Ad
-// @Failure 400 {object} response
-// @Failure 500 {object} response
-// @Failure default {object} response
-// @Router /auth/api/ads/{id} [get]
-func (h *Handler) getAdById(ctx *gin.Context) {
-	adId := ctx.Param("id")
+// @Success 200 {object} service.TwoFactorEnrollment
+// @Failure 400 {object} problem
+// @Failure 409 {object} problem
+// @Failure 500 {object} problem
+// @Failure default {object} problem
+// @Router /auth/api/2fa/enroll [post]
+func (h *Handler) enrollTwoFactor(ctx *gin.Context) {
+	id, err := getUserId(ctx)
+	if err != nil {
+		newErrorResponse(ctx, err)
+		return
+	}
 
-	userId, err := getUserId(ctx)
+	enrollment, err := h.services.EnrollTwoFactor(id)
 	if err != nil {
-		newResponse(ctx, http.StatusBadRequest, err.Error())
+		newErrorResponse(ctx, err)
 		return
 	}
 
-	ad, err := h.services.GetAdById(userId, adId)
+	ctx.JSON(http.StatusOK, enrollment)
+}
+
+// @Summary User Confirm Two Factor
+// @Security UsersAuth
+// @Tags users-2fa
+// @Description user enables TOTP with the first code of the authenticator app, recovery codes are returned only once
+// @Accept  json
+// @Produce  json
+// @Param input body twoFactorCodeInput true "TOTP code"
+// @Success 200 {object} recoveryCodesResponse
+// @Failure 400 {object} problem
+// @Failure 409 {object} problem
+// @Failure 500 {object} problem
+// @Failure default {object} problem
+// @Router /auth/api/2fa/confirm [post]
+func (h *Handler) confirmTwoFactor(ctx *gin.Context) {
+	id, err := getUserId(ctx)
 	if err != nil {
-		newResponse(ctx, http.StatusInternalServerError, err.Error())
+		newErrorResponse(ctx, err)
+		return
 	}
 
-	ctx.JSON(http.StatusOK, ad)
+	var input twoFactorCodeInput
+	if err := ctx.ShouldBindJSON(&input); err != nil {
+		newBindingErrorResponse(ctx, err)
+		return
+	}
+
+	codes, err := h.services.ConfirmTwoFactor(id, input.Code)
+	if err != nil {
+		newErrorResponse(ctx, err)
+		return
+	}
+
+	ctx.JSON(http.StatusOK, recoveryCodesResponse{RecoveryCodes: codes})
 }
 
-// @Summary User Update His Ad
+// @Summary User Disable Two Factor
 // @Security UsersAuth
-// @Tags users-ads
-// @Description user create ad
+// @Tags users-2fa
+// @Description user disables TOTP with a TOTP or recovery code
 // @Accept  json
 // @Produce  json
-// @Param id path string true "adId"
-// @Param input body inputAd true "ad info"
-// @Success 200 {object} domain.Ad
-// @Failure 400 {object} response
-// @Failure 500 {object} response
-// @Failure default {object} response
-// @Router /auth/api/ads/{id} [put]
-func (h *Handler) updateAd(ctx *gin.Context) {
-	adId := ctx.Param("id")
+// @Param input body twoFactorCodeInput true "TOTP or recovery code"
+// @Success 200 {object} string "disabled"
+// @Failure 400 {object} problem
+// @Failure 403 {object} problem
+// @Failure 409 {object} problem
+// @Failure 429 {object} problem
+// @Failure 500 {object} problem
+// @Failure default {object} problem
+// @Router /auth/api/2fa/disable [post]
+func (h *Handler) disableTwoFactor(ctx *gin.Context) {
+	id, err := getUserId(ctx)
+	if err != nil {
+		newErrorResponse(ctx, err)
+		return
+	}
 
+	var input twoFactorCodeInput
+	if err := ctx.ShouldBindJSON(&input); err != nil {
+		newBindingErrorResponse(ctx, err)
+		return
+	}
+
+	if err := h.services.DisableTwoFactor(id, input.Code); err != nil {
+		newErrorResponse(ctx, err)
+		return
+	}
+
+	ctx.JSON(http.StatusOK, "disabled")
+}
+
+// @Summary User Logout
+// @Security UsersAuth
+// @Tags users-auth
+// @Description user closes the session of the current device
+// @Accept  json
+// @Produce  json
+// @Param input body refreshTokensInput true "refresh token of the session"
+// @Success 200 {object} string "logged out"
+// @Failure 400 {object} problem
+// @Failure 401 {object} problem
+// @Failure 500 {object} problem
+// @Failure default {object} problem
+// @Router /auth/logout [post]
+func (h *Handler) logout(ctx *gin.Context) {
 	userId, err := getUserId(ctx)
 	if err != nil {
-		newResponse(ctx, http.StatusBadRequest, err.Error())
+		newErrorResponse(ctx, err)
 		return
 	}
 
-	var inputAds inputAd
-	if err = ctx.BindJSON(&inputAds); err != nil {
-		newResponse(ctx, http.StatusBadRequest, err.Error())
+	var input refreshTokensInput
+	if err := ctx.ShouldBindJSON(&input); err != nil {
+		newBindingErrorResponse(ctx, err)
 		return
 	}
 
-	if category := strings.Split(inputAds.Category, "/"); len(category) > 0 {
-		inputAds.Category = category[len(category)-1]
+	if err := h.services.Logout(userId, service.RefreshInput{RefreshToken: input.RefreshToken}); err != nil {
+		newErrorResponse(ctx, err)
+		return
 	}
 
-	ad ,err := h.services.UpdateAd(userId, adId, service.Ads{
-		Title:       inputAds.Title,
-		Category:    inputAds.Category,
-		Description: inputAds.Description,
-		Price:       inputAds.Price,
-		Contacts:    service.Contacts(inputAds.Contacts),
-		Published:   inputAds.Published,
-		ImagesURL:   inputAds.ImagesURL,
-	})
+	ctx.JSON(http.StatusOK, "logged out")
+}
+
+//------------------Sessions------------------
+
+// @Summary User Get Sessions
+// @Security UsersAuth
+// @Tags users-sessions
+// @Description user get sessions of all their devices
+// @Accept  json
+// @Produce  json
+// @Success 200 {object} []domain.Session
+// @Failure 400 {object} problem
+// @Failure 500 {object} problem
+// @Failure default {object} problem
+// @Router /auth/api/sessions/ [get]
+func (h *Handler) getSessions(ctx *gin.Context) {
+	userId, err := getUserId(ctx)
 	if err != nil {
-		newResponse(ctx, http.StatusInternalServerError, err.Error())
+		newErrorResponse(ctx, err)
 		return
 	}
 
-	ctx.JSON(http.StatusOK, ad)
+	sessions, err := h.services.GetSessions(userId)
+	if err != nil {
+		newErrorResponse(ctx, err)
+		return
+	}
+
+	ctx.JSON(http.StatusOK, sessions)
 }
 
-// @Summary User Delete Ad
+// @Summary User Revoke Session
 // @Security UsersAuth
-// @Tags users-ads
-// @Description user delete his ad
+// @Tags users-sessions
+// @Description user revoke session of one of their devices
 // @Accept  json
 // @Produce  json
-// @Param id path string true "adId"
-// @Success 200 {object} string "deleted"
-// @Failure 400 {object} response
-// @Failure 500 {object} response
-// @Failure default {object} response
-// @Router /auth/api/ads/{id} [delete]
-func (h *Handler) deleteAd(ctx *gin.Context) {
-	adId := ctx.Param("id")
+// @Param id path string true "sessionId"
+// @Success 200 {object} string "revoked"
+// @Failure 400 {object} problem
+// @Failure 404 {object} problem
+// @Failure 500 {object} problem
+// @Failure default {object} problem
+// @Router /auth/api/sessions/{id} [delete]
+func (h *Handler) revokeSession(ctx *gin.Context) {
+	sessionId, ok := idParam(ctx, "session")
+	if !ok {
+		return
+	}
 
 	userId, err := getUserId(ctx)
 	if err != nil {
-		newResponse(ctx, http.StatusBadRequest, err.Error())
+		newErrorResponse(ctx, err)
 		return
 	}
 
-	if err = h.services.DeleteAd(userId, adId); err != nil {
-		newResponse(ctx, http.StatusInternalServerError, err.Error())
+	if err := h.services.RevokeSession(userId, sessionId); err != nil {
+		newErrorResponse(ctx, err)
 		return
 	}
 
-	ctx.JSON(http.StatusOK, "deleted")
+	ctx.JSON(http.StatusOK, "revoked")
 }
 
-// @Summary User Search Ads
+// @Summary User Revoke Other Sessions
 // @Security UsersAuth
-// @Tags users-ads
-// @Description user search ads by his request string
+// @Tags users-sessions
+// @Description user revoke sessions of all devices except the current one
 // @Accept  json
 // @Produce  json
-// @Param input body inputFTSRequest true "search request"
-// @Success 200 {object} repository.FtsResponse
-// @Failure 400 {object} response
-// @Failure 500 {object} response
-// @Failure default {object} response
-// @Router /auth/api/fts/ [get]
-func (h *Handler) fts(ctx *gin.Context) {
-	var input inputFTSRequest
-	if err := ctx.BindJSON(&input); err != nil {
-		newResponse(ctx, http.StatusBadRequest, "invalid input body")
+// @Param input body refreshTokensInput true "refresh token of the current session"
+// @Success 200 {object} string "revoked"
+// @Failure 400 {object} problem
+// @Failure 401 {object} problem
+// @Failure 500 {object} problem
+// @Failure default {object} problem
+// @Router /auth/api/sessions/revoke-others [post]
+func (h *Handler) revokeOtherSessions(ctx *gin.Context) {
+	userId, err := getUserId(ctx)
+	if err != nil {
+		newErrorResponse(ctx, err)
 		return
 	}
 
-	searchResult, err := h.services.Ad.Fts(input.Request)
-	if err != nil {
-		newResponse(ctx, http.StatusInternalServerError, err.Error())
+	var input refreshTokensInput
+	if err := ctx.ShouldBindJSON(&input); err != nil {
+		newBindingErrorResponse(ctx, err)
+		return
+	}
+
+	if err := h.services.RevokeOtherSessions(userId, service.RefreshInput{RefreshToken: input.RefreshToken}); err != nil {
+		newErrorResponse(ctx, err)
 		return
 	}
 
-	ctx.JSON(http.StatusOK, searchResult)
+	ctx.JSON(http.StatusOK, "revoked")
 }
 
-func getUserId(ctx *gin.Context) (string, error) {
-	id, ok := ctx.Get(userContext)
-	if !ok {
-		return "", errors.New("empty user context")
+//------------------Public catalogue------------------
+
+// @Summary Get Published Ads
+// @Tags ads
+// @Description public catalogue of published ads with pagination, filtering and sorting
+// @Accept  json
+// @Produce  json
+// @Param category query string false "category id or full path like Транспорт/Автобусы, includes all subcategories"
+// @Param min_price query int false "minimal price"
+// @Param max_price query int false "maximal price"
+// @Param location query string false "location"
+// @Param attr[name] query string false "attribute of the category: a value, a substring of a text or an integer range as min..max"
+// @Param sort query string false "newest, oldest, price_asc or price_desc"
+// @Param limit query int false "page size, 20 by default, 100 at most"
+// @Param offset query int false "number of ads to skip"
+// @Success 200 {object} adsListResponse
+// @Failure 400 {object} problem
+// @Failure 500 {object} problem
+// @Failure default {object} problem
+// @Router /ads/ [get]
+func (h *Handler) getPublishedAds(ctx *gin.Context) {
+	var input publishedAdsQuery
+	if err := ctx.ShouldBindQuery(&input); err != nil {
+		newBindingErrorResponse(ctx, err)
+		return
 	}
 
-	if id == "" {
-		return "", errors.New("empty body of user context")
+	if input.MinPrice != nil && input.MaxPrice != nil && *input.MinPrice > *input.MaxPrice {
+		newParamErrorResponse(ctx, "min_price", "min_price is greater than max_price")
+		return
 	}
 
-	userId, ok := id.(string)
-	if !ok {
-		return "", errors.New("invalid type of userId from context")
+	if input.Limit == 0 {
+		input.Limit = defaultAdsLimit
 	}
 
-	return userId, nil
+	filter := service.AdsFilter{
+		Category: input.Category,
+		MinPrice: input.MinPrice,
+		MaxPrice: input.MaxPrice,
+		Location: input.Location,
+		Sort:     input.Sort,
+		Limit:    input.Limit,
+		Offset:   input.Offset,
+	}
+	if attributes := ctx.QueryMap("attr"); len(attributes) > 0 {
+		filter.Attributes = attributes
+	}
+
+	ads, total, err := h.services.Ad.GetPublishedAds(filter)
+	if err != nil {
+		newErrorResponse(ctx, err)
+		return
+	}
+
+	ctx.JSON(http.StatusOK, adsListResponse{
+		Ads:    ads,
+		Total:  total,
+		Limit:  input.Limit,
+		Offset: input.Offset,
+	})
+}
+
+//------------------Ads------------------
+
+//TODO: create input data struct validator
+
+type (
+	inputAd struct {
+		Title            string                 `json:"title" binding:"required"`
+		Category         string                 `json:"category" binding:"required"` // id or full path of the category
+		Description      string                 `json:"description" binding:"required"`
+		Price            int                    `json:"price" binding:"required"`
+		Contacts         *inputContacts         `json:"contacts"`           // own contacts of the ad, required without contact_profile_id
+		ContactProfileId int                    `json:"contact_profile_id"` // id of a contact profile to use instead of own contacts
+		Published        bool                   `json:"published"`          // submit for moderation instead of saving as a draft
+		ImagesURL        []string               `json:"images_url" binding:"required"`
+		Attributes       map[string]interface{} `json:"attributes"` // values of attributes of the category
+		Language         string                 `json:"language"`   // russian, ukrainian or english, detected by the text when empty
+	}
+	inputContacts struct {
+		Name         string `json:"name" binding:"required"`
+		Phone_number string `json:"phone_number" binding:"required"`
+		Email        string `json:"email" binding:"required"`
+		Location     string `json:"location" binding:"required"`
+	}
+)
+
+func (i inputAd) contacts() service.Contacts {
+	if i.Contacts == nil {
+		return service.Contacts{}
+	}
+
+	return service.Contacts(*i.Contacts)
+}
+
+// @Summary User Get All His Ads
+// @Security UsersAuth
+// @Tags users-ads
+// @Description user get all their ads by userId
+// @Accept  json
+// @Produce  json
+// @Success 200 {object} []domain.Ad
+// @Failure 400 {object} problem
+// @Failure 500 {object} problem
+// @Failure default {object} problem
+// @Router /auth/api/ads/ [get]
+func (h *Handler) getAllAds(ctx *gin.Context) {
+	userId, err := getUserId(ctx)
+	if err != nil {
+		newErrorResponse(ctx, err)
+		return
+	}
+
+	ads, err := h.services.GetAllAds(userId)
+	if err != nil {
+		newErrorResponse(ctx, err)
+		return
+	}
+
+	ctx.JSON(http.StatusOK, ads)
+}
+
+// @Summary User Create Own Ad
+// @Security UsersAuth
+// @Tags users-ads
+// @Description user create their own ad
+// @Accept  json
+// @Produce  json
+// @Param input body inputAd true "create ad info"
+// @Success 200 {object} map[string]interface{}
+// @Failure 400 {object} problem
+// @Failure 500 {object} problem
+// @Failure default {object} problem
+// @Router /auth/api/ads/ [post]
+func (h *Handler) createAd(ctx *gin.Context) {
+	var inputAd inputAd
+	if err := ctx.ShouldBindJSON(&inputAd); err != nil {
+		newBindingErrorResponse(ctx, err)
+		return
+	}
+
+	userId, err := getUserId(ctx)
+	if err != nil {
+		newErrorResponse(ctx, err)
+		return
+	}
+
+	adId, err := h.services.Ad.CreateAd(userId, service.Ads{
+		Title:            inputAd.Title,
+		Category:         inputAd.Category,
+		Description:      inputAd.Description,
+		Price:            inputAd.Price,
+		Contacts:         inputAd.contacts(),
+		ContactProfileId: inputAd.ContactProfileId,
+		Published:        inputAd.Published,
+		ImagesURL:        inputAd.ImagesURL,
+		Attributes:       inputAd.Attributes,
+		Language:         inputAd.Language,
+	})
+	if err != nil {
+		newErrorResponse(ctx, err)
+		return
+	}
+
+	ctx.JSON(http.StatusCreated, map[string]interface{}{
+		"id": adId,
+	})
+}
+
+// @Summary User Get Ad By AdId
+// @Security UsersAuth
+// @Tags users-ads
+// @Description user get ad by adId
+// @Accept  json
+// @Produce  json
+// @Param id path string true "adId"
+// @Success 200 {object} domain.Ad
+// @Failure 400 {object} problem
+// @Failure 500 {object} problem
+// @Failure default {object} problem
+// @Router /auth/api/ads/{id} [get]
+func (h *Handler) getAdById(ctx *gin.Context) {
+	adId, ok := idParam(ctx, "ad")
+	if !ok {
+		return
+	}
+
+	userId, err := getUserId(ctx)
+	if err != nil {
+		newErrorResponse(ctx, err)
+		return
+	}
+
+	ad, err := h.services.GetAdById(userId, adId)
+	if err != nil {
+		newErrorResponse(ctx, err)
+		return
+	}
+
+	ctx.JSON(http.StatusOK, ad)
+}
+
+// @Summary User Update His Ad
+// @Security UsersAuth
+// @Tags users-ads
+// @Description user create ad
+// @Accept  json
+// @Produce  json
+// @Param id path string true "adId"
+// @Param input body inputAd true "ad info"
+// @Success 200 {object} domain.Ad
+// @Failure 400 {object} problem
+// @Failure 500 {object} problem
+// @Failure default {object} problem
+// @Router /auth/api/ads/{id} [put]
+func (h *Handler) updateAd(ctx *gin.Context) {
+	adId, ok := idParam(ctx, "ad")
+	if !ok {
+		return
+	}
+
+	userId, err := getUserId(ctx)
+	if err != nil {
+		newErrorResponse(ctx, err)
+		return
+	}
+
+	var inputAds inputAd
+	if err = ctx.ShouldBindJSON(&inputAds); err != nil {
+		newBindingErrorResponse(ctx, err)
+		return
+	}
+
+	ad, err := h.services.UpdateAd(userId, adId, service.Ads{
+		Title:            inputAds.Title,
+		Category:         inputAds.Category,
+		Description:      inputAds.Description,
+		Price:            inputAds.Price,
+		Contacts:         inputAds.contacts(),
+		ContactProfileId: inputAds.ContactProfileId,
+		Published:        inputAds.Published,
+		ImagesURL:        inputAds.ImagesURL,
+		Attributes:       inputAds.Attributes,
+		Language:         inputAds.Language,
+	})
+	if err != nil {
+		newErrorResponse(ctx, err)
+		return
+	}
+
+	ctx.JSON(http.StatusOK, ad)
+}
+
+// @Summary User Delete Ad
+// @Security UsersAuth
+// @Tags users-ads
+// @Description user delete their ad
+// @Accept  json
+// @Produce  json
+// @Param id path string true "adId"
+// @Success 200 {object} string "deleted"
+// @Failure 400 {object} problem
+// @Failure 500 {object} problem
+// @Failure default {object} problem
+// @Router /auth/api/ads/{id} [delete]
+func (h *Handler) deleteAd(ctx *gin.Context) {
+	adId, ok := idParam(ctx, "ad")
+	if !ok {
+		return
+	}
+
+	userId, err := getUserId(ctx)
+	if err != nil {
+		newErrorResponse(ctx, err)
+		return
+	}
+
+	if err = h.services.DeleteAd(userId, adId); err != nil {
+		newErrorResponse(ctx, err)
+		return
+	}
+
+	ctx.JSON(http.StatusOK, "deleted")
+}
+
+// @Summary User Archive Ad
+// @Security UsersAuth
+// @Tags users-ads
+// @Description user withdraws their ad from the catalogue
+// @Accept  json
+// @Produce  json
+// @Param id path string true "adId"
+// @Success 200 {object} domain.Ad
+// @Failure 400 {object} problem
+// @Failure 409 {object} problem
+// @Failure 500 {object} problem
+// @Failure default {object} problem
+// @Router /auth/api/ads/{id}/archive [post]
+func (h *Handler) archiveAd(ctx *gin.Context) {
+	adId, ok := idParam(ctx, "ad")
+	if !ok {
+		return
+	}
+
+	userId, err := getUserId(ctx)
+	if err != nil {
+		newErrorResponse(ctx, err)
+		return
+	}
+
+	ad, err := h.services.ArchiveAd(userId, adId)
+	if err != nil {
+		newErrorResponse(ctx, err)
+		return
+	}
+
+	ctx.JSON(http.StatusOK, ad)
+}
+
+// @Summary User Search Ads
+// @Security UsersAuth
+// @Tags users-ads
+// @Description user search published ads by their request string, the results are ordered by relevance and have snippets with matched words in <b> tags
+// @Accept  json
+// @Produce  json
+// @Param q query string true "search request"
+// @Param lang query string false "russian, ukrainian or english, ads of any language are searched by default"
+// @Param category query string false "category id or full path like Транспорт/Автобусы, includes all subcategories"
+// @Param min_price query int false "minimal price"
+// @Param max_price query int false "maximal price"
+// @Param location query string false "location"
+// @Param attr[name] query string false "attribute of the category: a value, a substring of a text or an integer range as min..max"
+// @Param facets query bool false "count found ads per category with subcategories, location and price bucket"
+// @Param limit query int false "page size, 20 by default, 100 at most"
+// @Param offset query int false "number of ads to skip"
+// @Success 200 {object} ftsResponse
+// @Failure 400 {object} problem
+// @Failure 500 {object} problem
+// @Failure default {object} problem
+// @Router /auth/api/fts/ [get]
+func (h *Handler) fts(ctx *gin.Context) {
+	var input ftsQuery
+	if err := ctx.ShouldBindQuery(&input); err != nil {
+		newBindingErrorResponse(ctx, err)
+		return
+	}
+
+	if input.MinPrice != nil && input.MaxPrice != nil && *input.MinPrice > *input.MaxPrice {
+		newParamErrorResponse(ctx, "min_price", "min_price is greater than max_price")
+		return
+	}
+
+	if input.Limit == 0 {
+		input.Limit = defaultAdsLimit
+	}
+
+	filter := service.AdsFilter{
+		Category: input.Category,
+		MinPrice: input.MinPrice,
+		MaxPrice: input.MaxPrice,
+		Location: input.Location,
+		Limit:    input.Limit,
+		Offset:   input.Offset,
+	}
+	if attributes := ctx.QueryMap("attr"); len(attributes) > 0 {
+		filter.Attributes = attributes
+	}
+
+	result, err := h.services.Ad.Fts(service.FtsInput{Request: input.Request, Language: input.Language, Filter: filter, Facets: input.Facets})
+	if err != nil {
+		newErrorResponse(ctx, err)
+		return
+	}
+
+	ctx.JSON(http.StatusOK, ftsResponse{
+		Ads:        result.Ads,
+		Total:      result.Total,
+		Limit:      input.Limit,
+		Offset:     input.Offset,
+		DidYouMean: result.DidYouMean,
+		Facets:     result.Facets,
+	})
+}
+
+// @Summary Suggest Search Requests
+// @Tags ads
+// @Description as you type suggestions of titles of published ads and paths of categories, prefix matches go first and misspellings are tolerated
+// @Accept  json
+// @Produce  json
+// @Param q query string true "request typed so far, at least 2 characters"
+// @Param limit query int false "number of suggestions, 10 by default, 20 at most"
+// @Success 200 {object} []repository.Suggestion
+// @Failure 400 {object} problem
+// @Failure 500 {object} problem
+// @Failure default {object} problem
+// @Router /search/suggest [get]
+func (h *Handler) suggestSearch(ctx *gin.Context) {
+	var input suggestQuery
+	if err := ctx.ShouldBindQuery(&input); err != nil {
+		newBindingErrorResponse(ctx, err)
+		return
+	}
+
+	if input.Limit == 0 {
+		input.Limit = defaultSuggestionsLimit
+	}
+
+	suggestions, err := h.services.Ad.Suggest(input.Query, input.Limit)
+	if err != nil {
+		newErrorResponse(ctx, err)
+		return
+	}
+
+	ctx.JSON(http.StatusOK, suggestions)
+}
+
+// @Summary User Upload Image
+// @Security UsersAuth
+// @Tags users-images
+// @Description user uploads a jpeg, png or gif image, the returned id goes to images_url of an ad
+// @Accept  mpfd
+// @Produce  json
+// @Param image formData file true "image"
+// @Success 201 {object} service.UploadedImage
+// @Failure 400 {object} problem
+// @Failure 413 {object} problem
+// @Failure 500 {object} problem
+// @Failure default {object} problem
+// @Router /auth/api/images/ [post]
+func (h *Handler) uploadImage(ctx *gin.Context) {
+	userId, err := getUserId(ctx)
+	if err != nil {
+		newErrorResponse(ctx, err)
+		return
+	}
+
+	if ctx.Request.ContentLength > maxImageUploadBytes {
+		newErrorResponse(ctx, service.ErrImageTooLarge)
+		return
+	}
+	ctx.Request.Body = http.MaxBytesReader(ctx.Writer, ctx.Request.Body, maxImageUploadBytes)
+
+	file, err := ctx.FormFile("image")
+	if err != nil {
+		newParamErrorResponse(ctx, "image", "invalid image upload")
+		return
+	}
+
+	f, err := file.Open()
+	if err != nil {
+		newErrorResponse(ctx, err)
+		return
+	}
+	defer f.Close()
+
+	data, err := ioutil.ReadAll(f)
+	if err != nil {
+		newErrorResponse(ctx, err)
+		return
+	}
+
+	image, err := h.services.UploadImage(userId, data)
+	if err != nil {
+		newErrorResponse(ctx, err)
+		return
+	}
+
+	ctx.JSON(http.StatusCreated, image)
+}
+
+// @Summary Get Categories
+// @Tags categories
+// @Description get the category tree, ads_count of a category includes published ads of its subcategories
+// @Produce  json
+// @Success 200 {object} []domain.CategoryNode
+// @Failure 500 {object} problem
+// @Failure default {object} problem
+// @Router /categories/ [get]
+func (h *Handler) getCategories(ctx *gin.Context) {
+	tree, err := h.services.GetCategoryTree()
+	if err != nil {
+		newErrorResponse(ctx, err)
+		return
+	}
+
+	ctx.JSON(http.StatusOK, tree)
+}
+
+// @Summary Get Category Attributes
+// @Tags categories
+// @Description get attributes of ads of the category, including the ones inherited from parent categories
+// @Produce  json
+// @Param id path int true "categoryId"
+// @Success 200 {object} []domain.Attribute
+// @Failure 400 {object} problem
+// @Failure 404 {object} problem
+// @Failure 500 {object} problem
+// @Failure default {object} problem
+// @Router /categories/{id}/attributes [get]
+func (h *Handler) getCategoryAttributes(ctx *gin.Context) {
+	categoryId, err := strconv.Atoi(ctx.Param("id"))
+	if err != nil {
+		newParamErrorResponse(ctx, "id", "invalid category id")
+		return
+	}
+
+	attributes, err := h.services.GetCategoryAttributes(categoryId)
+	if err != nil {
+		newErrorResponse(ctx, err)
+		return
+	}
+
+	ctx.JSON(http.StatusOK, attributes)
+}
+
+// @Summary Get Image
+// @Tags images
+// @Description redirects to the image or one of its thumbnails
+// @Param id path string true "imageId"
+// @Param size query string false "small, medium or large, the original image when empty"
+// @Success 302
+// @Failure 400 {object} problem
+// @Failure 404 {object} problem
+// @Failure default {object} problem
+// @Router /images/{id} [get]
+func (h *Handler) getImage(ctx *gin.Context) {
+	url, err := h.services.GetImageURL(ctx.Param("id"), ctx.Query("size"))
+	if err != nil {
+		newErrorResponse(ctx, err)
+		return
+	}
+
+	ctx.Redirect(http.StatusFound, url)
+}
+
+//------------------Contacts------------------
+
+// @Summary User Reveal Contacts
+// @Security UsersAuth
+// @Tags users-contacts
+// @Description published ads show masked contacts, user reveals the full ones of an ad. The seller sees how many times they were revealed.
+// @Description A user may reveal contacts of a limited number of ads per day, revealing the same ad again is not counted.
+// @Accept  json
+// @Produce  json
+// @Param id path string true "adId"
+// @Success 200 {object} domain.Contacts
+// @Failure 400 {object} problem
+// @Failure 404 {object} problem
+// @Failure 429 {object} problem
+// @Failure 500 {object} problem
+// @Failure default {object} problem
+// @Router /auth/api/ads/{id}/contacts [post]
+func (h *Handler) revealContacts(ctx *gin.Context) {
+	adId, ok := idParam(ctx, "ad")
+	if !ok {
+		return
+	}
+
+	userId, err := getUserId(ctx)
+	if err != nil {
+		newErrorResponse(ctx, err)
+		return
+	}
+
+	contacts, err := h.services.RevealContacts(userId, adId, ctx.ClientIP())
+	if err != nil {
+		newErrorResponse(ctx, err)
+		return
+	}
+
+	ctx.JSON(http.StatusOK, contacts)
+}
+
+// @Summary User Get Contact Reveals
+// @Security UsersAuth
+// @Tags users-contacts
+// @Description how many times contacts of every ad of the user were revealed and by how many users, the most revealed go first
+// @Accept  json
+// @Produce  json
+// @Success 200 {object} []domain.ContactRevealStats
+// @Failure 400 {object} problem
+// @Failure 500 {object} problem
+// @Failure default {object} problem
+// @Router /auth/api/contact-reveals/ [get]
+func (h *Handler) getContactRevealStats(ctx *gin.Context) {
+	userId, err := getUserId(ctx)
+	if err != nil {
+		newErrorResponse(ctx, err)
+		return
+	}
+
+	stats, err := h.services.GetContactRevealStats(userId)
+	if err != nil {
+		newErrorResponse(ctx, err)
+		return
+	}
+
+	ctx.JSON(http.StatusOK, stats)
+}
+
+//------------------Contact profiles------------------
+
+// @Summary User Create Contact Profile
+// @Security UsersAuth
+// @Tags users-contact-profiles
+// @Description user saves contacts under a label, ads refer to the profile by contact_profile_id instead of repeating the contacts
+// @Accept  json
+// @Produce  json
+// @Param input body contactProfileInput true "label and contacts"
+// @Success 201 {object} domain.ContactProfile
+// @Failure 400 {object} problem
+// @Failure 409 {object} problem
+// @Failure 500 {object} problem
+// @Failure default {object} problem
+// @Router /auth/api/contact-profiles/ [post]
+func (h *Handler) createContactProfile(ctx *gin.Context) {
+	userId, err := getUserId(ctx)
+	if err != nil {
+		newErrorResponse(ctx, err)
+		return
+	}
+
+	var input contactProfileInput
+	if err := ctx.ShouldBindJSON(&input); err != nil {
+		newBindingErrorResponse(ctx, err)
+		return
+	}
+
+	profile, err := h.services.CreateContactProfile(userId, input.profile(0))
+	if err != nil {
+		newErrorResponse(ctx, err)
+		return
+	}
+
+	ctx.JSON(http.StatusCreated, profile)
+}
+
+// @Summary User Get Contact Profiles
+// @Security UsersAuth
+// @Tags users-contact-profiles
+// @Description user gets their contact profiles with the number of ads using each of them
+// @Accept  json
+// @Produce  json
+// @Success 200 {object} []domain.ContactProfile
+// @Failure 400 {object} problem
+// @Failure 500 {object} problem
+// @Failure default {object} problem
+// @Router /auth/api/contact-profiles/ [get]
+func (h *Handler) getContactProfiles(ctx *gin.Context) {
+	userId, err := getUserId(ctx)
+	if err != nil {
+		newErrorResponse(ctx, err)
+		return
+	}
+
+	profiles, err := h.services.GetContactProfiles(userId)
+	if err != nil {
+		newErrorResponse(ctx, err)
+		return
+	}
+
+	ctx.JSON(http.StatusOK, profiles)
+}
+
+// @Summary User Get Contact Profile
+// @Security UsersAuth
+// @Tags users-contact-profiles
+// @Description user gets their contact profile by id
+// @Accept  json
+// @Produce  json
+// @Param id path int true "contact profile id"
+// @Success 200 {object} domain.ContactProfile
+// @Failure 400 {object} problem
+// @Failure 404 {object} problem
+// @Failure 500 {object} problem
+// @Failure default {object} problem
+// @Router /auth/api/contact-profiles/{id} [get]
+func (h *Handler) getContactProfile(ctx *gin.Context) {
+	userId, err := getUserId(ctx)
+	if err != nil {
+		newErrorResponse(ctx, err)
+		return
+	}
+
+	profileId, err := strconv.Atoi(ctx.Param("id"))
+	if err != nil {
+		newParamErrorResponse(ctx, "id", "invalid contact profile id")
+		return
+	}
+
+	profile, err := h.services.GetContactProfile(userId, profileId)
+	if err != nil {
+		newErrorResponse(ctx, err)
+		return
+	}
+
+	ctx.JSON(http.StatusOK, profile)
+}
+
+// @Summary User Update Contact Profile
+// @Security UsersAuth
+// @Tags users-contact-profiles
+// @Description user changes their contact profile, every ad using it shows the new contacts
+// @Accept  json
+// @Produce  json
+// @Param id path int true "contact profile id"
+// @Param input body contactProfileInput true "label and contacts"
+// @Success 200 {object} domain.ContactProfile
+// @Failure 400 {object} problem
+// @Failure 404 {object} problem
+// @Failure 409 {object} problem
+// @Failure 500 {object} problem
+// @Failure default {object} problem
+// @Router /auth/api/contact-profiles/{id} [put]
+func (h *Handler) updateContactProfile(ctx *gin.Context) {
+	userId, err := getUserId(ctx)
+	if err != nil {
+		newErrorResponse(ctx, err)
+		return
+	}
+
+	profileId, err := strconv.Atoi(ctx.Param("id"))
+	if err != nil {
+		newParamErrorResponse(ctx, "id", "invalid contact profile id")
+		return
+	}
+
+	var input contactProfileInput
+	if err := ctx.ShouldBindJSON(&input); err != nil {
+		newBindingErrorResponse(ctx, err)
+		return
+	}
+
+	profile, err := h.services.UpdateContactProfile(userId, input.profile(profileId))
+	if err != nil {
+		newErrorResponse(ctx, err)
+		return
+	}
+
+	ctx.JSON(http.StatusOK, profile)
+}
+
+// @Summary User Delete Contact Profile
+// @Security UsersAuth
+// @Tags users-contact-profiles
+// @Description user deletes a contact profile no ad uses
+// @Accept  json
+// @Produce  json
+// @Param id path int true "contact profile id"
+// @Success 200 {object} string "deleted"
+// @Failure 400 {object} problem
+// @Failure 404 {object} problem
+// @Failure 409 {object} problem
+// @Failure 500 {object} problem
+// @Failure default {object} problem
+// @Router /auth/api/contact-profiles/{id} [delete]
+func (h *Handler) deleteContactProfile(ctx *gin.Context) {
+	userId, err := getUserId(ctx)
+	if err != nil {
+		newErrorResponse(ctx, err)
+		return
+	}
+
+	profileId, err := strconv.Atoi(ctx.Param("id"))
+	if err != nil {
+		newParamErrorResponse(ctx, "id", "invalid contact profile id")
+		return
+	}
+
+	if err := h.services.DeleteContactProfile(userId, profileId); err != nil {
+		newErrorResponse(ctx, err)
+		return
+	}
+
+	ctx.JSON(http.StatusOK, "deleted")
+}
+
+func (i contactProfileInput) profile(id int) domain.ContactProfile {
+	return domain.ContactProfile{
+		Id:           id,
+		Label:        i.Label,
+		Name:         i.Name,
+		Phone_number: i.Phone_number,
+		Email:        i.Email,
+		Location:     i.Location,
+	}
+}
+
+//------------------Saved searches------------------
+
+// @Summary User Create Saved Search
+// @Security UsersAuth
+// @Tags users-saved-searches
+// @Description user saves a search request, newly published ads matching it are notified by email, webhook or in the inbox
+// @Accept  json
+// @Produce  json
+// @Param input body savedSearchInput true "search request, filters and notification channel"
+// @Success 201 {object} domain.SavedSearch
+// @Failure 400 {object} problem
+// @Failure 409 {object} problem
+// @Failure 500 {object} problem
+// @Failure default {object} problem
+// @Router /auth/api/saved-searches/ [post]
+func (h *Handler) createSavedSearch(ctx *gin.Context) {
+	userId, err := getUserId(ctx)
+	if err != nil {
+		newErrorResponse(ctx, err)
+		return
+	}
+
+	var input savedSearchInput
+	if err := ctx.ShouldBindJSON(&input); err != nil {
+		newBindingErrorResponse(ctx, err)
+		return
+	}
+
+	if input.MinPrice != nil && input.MaxPrice != nil && *input.MinPrice > *input.MaxPrice {
+		newParamErrorResponse(ctx, "min_price", "min_price is greater than max_price")
+		return
+	}
+
+	search, err := h.services.CreateSavedSearch(userId, service.SavedSearchInput{
+		Name:     input.Name,
+		Query:    input.Query,
+		Language: input.Language,
+		Filter: service.AdsFilter{
+			Category:   input.Category,
+			MinPrice:   input.MinPrice,
+			MaxPrice:   input.MaxPrice,
+			Location:   input.Location,
+			Attributes: input.Attributes,
+		},
+		Channel:    input.Channel,
+		WebhookURL: input.WebhookURL,
+	})
+	if err != nil {
+		newErrorResponse(ctx, err)
+		return
+	}
+
+	ctx.JSON(http.StatusCreated, search)
+}
+
+// @Summary User Get Saved Searches
+// @Security UsersAuth
+// @Tags users-saved-searches
+// @Description user get their saved searches
+// @Accept  json
+// @Produce  json
+// @Success 200 {object} []domain.SavedSearch
+// @Failure 400 {object} problem
+// @Failure 500 {object} problem
+// @Failure default {object} problem
+// @Router /auth/api/saved-searches/ [get]
+func (h *Handler) getSavedSearches(ctx *gin.Context) {
+	userId, err := getUserId(ctx)
+	if err != nil {
+		newErrorResponse(ctx, err)
+		return
+	}
+
+	searches, err := h.services.GetSavedSearches(userId)
+	if err != nil {
+		newErrorResponse(ctx, err)
+		return
+	}
+
+	ctx.JSON(http.StatusOK, searches)
+}
+
+// @Summary User Delete Saved Search
+// @Security UsersAuth
+// @Tags users-saved-searches
+// @Description user deletes their saved search, its notifications stop
+// @Accept  json
+// @Produce  json
+// @Param id path int true "saved search id"
+// @Success 200 {object} string "deleted"
+// @Failure 400 {object} problem
+// @Failure 404 {object} problem
+// @Failure 500 {object} problem
+// @Failure default {object} problem
+// @Router /auth/api/saved-searches/{id} [delete]
+func (h *Handler) deleteSavedSearch(ctx *gin.Context) {
+	userId, err := getUserId(ctx)
+	if err != nil {
+		newErrorResponse(ctx, err)
+		return
+	}
+
+	searchId, err := strconv.Atoi(ctx.Param("id"))
+	if err != nil {
+		newParamErrorResponse(ctx, "id", "invalid saved search id")
+		return
+	}
+
+	if err := h.services.DeleteSavedSearch(userId, searchId); err != nil {
+		newErrorResponse(ctx, err)
+		return
+	}
+
+	ctx.JSON(http.StatusOK, "deleted")
+}
+
+//------------------Favorites------------------
+
+// @Summary User Add Favorite
+// @Security UsersAuth
+// @Tags users-favorites
+// @Description user adds a published ad of another user to their favorites, they are notified when its price changes or it is removed
+// @Accept  json
+// @Produce  json
+// @Param id path string true "adId"
+// @Success 200 {object} string "added"
+// @Failure 400 {object} problem
+// @Failure 404 {object} problem
+// @Failure 500 {object} problem
+// @Failure default {object} problem
+// @Router /auth/api/favorites/{id} [put]
+func (h *Handler) addFavorite(ctx *gin.Context) {
+	adId, ok := idParam(ctx, "ad")
+	if !ok {
+		return
+	}
+
+	userId, err := getUserId(ctx)
+	if err != nil {
+		newErrorResponse(ctx, err)
+		return
+	}
+
+	if err := h.services.AddFavorite(userId, adId); err != nil {
+		newErrorResponse(ctx, err)
+		return
+	}
+
+	ctx.JSON(http.StatusOK, "added")
+}
+
+// @Summary User Remove Favorite
+// @Security UsersAuth
+// @Tags users-favorites
+// @Description user removes an ad from their favorites
+// @Accept  json
+// @Produce  json
+// @Param id path string true "adId"
+// @Success 200 {object} string "removed"
+// @Failure 400 {object} problem
+// @Failure 404 {object} problem
+// @Failure 500 {object} problem
+// @Failure default {object} problem
+// @Router /auth/api/favorites/{id} [delete]
+func (h *Handler) removeFavorite(ctx *gin.Context) {
+	adId, ok := idParam(ctx, "ad")
+	if !ok {
+		return
+	}
+
+	userId, err := getUserId(ctx)
+	if err != nil {
+		newErrorResponse(ctx, err)
+		return
+	}
+
+	if err := h.services.RemoveFavorite(userId, adId); err != nil {
+		newErrorResponse(ctx, err)
+		return
+	}
+
+	ctx.JSON(http.StatusOK, "removed")
+}
+
+// @Summary User Get Favorites
+// @Security UsersAuth
+// @Tags users-favorites
+// @Description user get their favorite ads, the last added go first. Ads that are not approved only have their id and status
+// @Accept  json
+// @Produce  json
+// @Success 200 {object} []domain.Ad
+// @Failure 400 {object} problem
+// @Failure 500 {object} problem
+// @Failure default {object} problem
+// @Router /auth/api/favorites/ [get]
+func (h *Handler) getFavorites(ctx *gin.Context) {
+	userId, err := getUserId(ctx)
+	if err != nil {
+		newErrorResponse(ctx, err)
+		return
+	}
+
+	ads, err := h.services.GetFavorites(userId)
+	if err != nil {
+		newErrorResponse(ctx, err)
+		return
+	}
+
+	ctx.JSON(http.StatusOK, ads)
+}
+
+//------------------Notifications------------------
+
+// @Summary User Get Notifications
+// @Security UsersAuth
+// @Tags users-notifications
+// @Description in app inbox of the user, the newest notifications go first
+// @Accept  json
+// @Produce  json
+// @Param limit query int false "page size, 20 by default, 100 at most"
+// @Param offset query int false "number of notifications to skip"
+// @Success 200 {object} notificationsResponse
+// @Failure 400 {object} problem
+// @Failure 500 {object} problem
+// @Failure default {object} problem
+// @Router /auth/api/notifications/ [get]
+func (h *Handler) getNotifications(ctx *gin.Context) {
+	userId, err := getUserId(ctx)
+	if err != nil {
+		newErrorResponse(ctx, err)
+		return
+	}
+
+	var input notificationsQuery
+	if err := ctx.ShouldBindQuery(&input); err != nil {
+		newBindingErrorResponse(ctx, err)
+		return
+	}
+
+	if input.Limit == 0 {
+		input.Limit = defaultAdsLimit
+	}
+
+	notifications, unread, err := h.services.GetNotifications(userId, input.Limit, input.Offset)
+	if err != nil {
+		newErrorResponse(ctx, err)
+		return
+	}
+
+	ctx.JSON(http.StatusOK, notificationsResponse{
+		Notifications: notifications,
+		Unread:        unread,
+		Limit:         input.Limit,
+		Offset:        input.Offset,
+	})
+}
+
+// @Summary User Read Notification
+// @Security UsersAuth
+// @Tags users-notifications
+// @Description user marks a notification of the inbox as read
+// @Accept  json
+// @Produce  json
+// @Param id path int true "notification id"
+// @Success 200 {object} string "read"
+// @Failure 400 {object} problem
+// @Failure 404 {object} problem
+// @Failure 500 {object} problem
+// @Failure default {object} problem
+// @Router /auth/api/notifications/{id}/read [post]
+func (h *Handler) readNotification(ctx *gin.Context) {
+	userId, err := getUserId(ctx)
+	if err != nil {
+		newErrorResponse(ctx, err)
+		return
+	}
+
+	notificationId, err := strconv.Atoi(ctx.Param("id"))
+	if err != nil {
+		newParamErrorResponse(ctx, "id", "invalid notification id")
+		return
+	}
+
+	if err := h.services.ReadNotification(userId, notificationId); err != nil {
+		newErrorResponse(ctx, err)
+		return
+	}
+
+	ctx.JSON(http.StatusOK, "read")
+}
+
+// idParam returns the id of the path if it is a number, services take ids as strings but they are stored as integers.
+func idParam(ctx *gin.Context, resource string) (string, bool) {
+	id := ctx.Param("id")
+	if _, err := strconv.Atoi(id); err != nil {
+		newParamErrorResponse(ctx, "id", "invalid "+resource+" id")
+		return "", false
+	}
+
+	return id, true
+}
+
+func getUserId(ctx *gin.Context) (string, error) {
+	id, ok := ctx.Get(userContext)
+	if !ok {
+		return "", apperror.Unauthorized("unauthorized", "empty user context")
+	}
+
+	if id == "" {
+		return "", apperror.Unauthorized("unauthorized", "empty body of user context")
+	}
+
+	userId, ok := id.(string)
+	if !ok {
+		return "", apperror.Unauthorized("unauthorized", "invalid type of userId from context")
+	}
+
+	return userId, nil
+}
+
+//------------------Threads------------------
+
+// @Summary User Open Thread
+// @Security UsersAuth
+// @Tags users-threads
+// @Description buyer writes to the seller of a published ad, writing about the same ad again continues their thread
+// @Accept  json
+// @Produce  json
+// @Param input body openThreadInput true "ad and the first message"
+// @Success 201 {object} domain.Thread
+// @Failure 400 {object} problem
+// @Failure 403 {object} problem
+// @Failure 404 {object} problem
+// @Failure 500 {object} problem
+// @Failure default {object} problem
+// @Router /auth/api/threads/ [post]
+func (h *Handler) openThread(ctx *gin.Context) {
+	userId, err := getUserId(ctx)
+	if err != nil {
+		newErrorResponse(ctx, err)
+		return
+	}
+
+	var input openThreadInput
+	if err := ctx.ShouldBindJSON(&input); err != nil {
+		newBindingErrorResponse(ctx, err)
+		return
+	}
+
+	thread, err := h.services.OpenThread(userId, strconv.Itoa(input.AdId), input.Message)
+	if err != nil {
+		newErrorResponse(ctx, err)
+		return
+	}
+
+	ctx.JSON(http.StatusCreated, thread)
+}
+
+// @Summary User Get Threads
+// @Security UsersAuth
+// @Tags users-threads
+// @Description threads the user takes part in as a buyer or a seller, the last active go first
+// @Accept  json
+// @Produce  json
+// @Param limit query int false "page size, 20 by default, 100 at most"
+// @Param offset query int false "number of threads to skip"
+// @Success 200 {object} threadsResponse
+// @Failure 400 {object} problem
+// @Failure 500 {object} problem
+// @Failure default {object} problem
+// @Router /auth/api/threads/ [get]
+func (h *Handler) getThreads(ctx *gin.Context) {
+	userId, err := getUserId(ctx)
+	if err != nil {
+		newErrorResponse(ctx, err)
+		return
+	}
+
+	var input pageQuery
+	if err := ctx.ShouldBindQuery(&input); err != nil {
+		newBindingErrorResponse(ctx, err)
+		return
+	}
+
+	if input.Limit == 0 {
+		input.Limit = defaultAdsLimit
+	}
+
+	threads, unread, err := h.services.GetThreads(userId, input.Limit, input.Offset)
+	if err != nil {
+		newErrorResponse(ctx, err)
+		return
+	}
+
+	ctx.JSON(http.StatusOK, threadsResponse{
+		Threads: threads,
+		Unread:  unread,
+		Limit:   input.Limit,
+		Offset:  input.Offset,
+	})
+}
+
+// @Summary User Get Thread
+// @Security UsersAuth
+// @Tags users-threads
+// @Description only participants of a thread can see it
+// @Accept  json
+// @Produce  json
+// @Param id path int true "thread id"
+// @Success 200 {object} domain.Thread
+// @Failure 400 {object} problem
+// @Failure 404 {object} problem
+// @Failure 500 {object} problem
+// @Failure default {object} problem
+// @Router /auth/api/threads/{id} [get]
+func (h *Handler) getThread(ctx *gin.Context) {
+	userId, threadId, ok := threadParams(ctx)
+	if !ok {
+		return
+	}
+
+	thread, err := h.services.GetThread(userId, threadId)
+	if err != nil {
+		newErrorResponse(ctx, err)
+		return
+	}
+
+	ctx.JSON(http.StatusOK, thread)
+}
+
+// @Summary User Get Messages
+// @Security UsersAuth
+// @Tags users-threads
+// @Description messages of a thread, the newest go first
+// @Accept  json
+// @Produce  json
+// @Param id path int true "thread id"
+// @Param limit query int false "page size, 20 by default, 100 at most"
+// @Param offset query int false "number of messages to skip"
+// @Success 200 {object} messagesResponse
+// @Failure 400 {object} problem
+// @Failure 404 {object} problem
+// @Failure 500 {object} problem
+// @Failure default {object} problem
+// @Router /auth/api/threads/{id}/messages [get]
+func (h *Handler) getMessages(ctx *gin.Context) {
+	userId, threadId, ok := threadParams(ctx)
+	if !ok {
+		return
+	}
+
+	var input pageQuery
+	if err := ctx.ShouldBindQuery(&input); err != nil {
+		newBindingErrorResponse(ctx, err)
+		return
+	}
+
+	if input.Limit == 0 {
+		input.Limit = defaultAdsLimit
+	}
+
+	messages, err := h.services.GetMessages(userId, threadId, input.Limit, input.Offset)
+	if err != nil {
+		newErrorResponse(ctx, err)
+		return
+	}
+
+	ctx.JSON(http.StatusOK, messagesResponse{
+		Messages: messages,
+		Limit:    input.Limit,
+		Offset:   input.Offset,
+	})
+}
+
+// @Summary User Send Message
+// @Security UsersAuth
+// @Tags users-threads
+// @Description participant of a thread sends a message to the other one
+// @Accept  json
+// @Produce  json
+// @Param id path int true "thread id"
+// @Param input body messageInput true "message"
+// @Success 201 {object} domain.Message
+// @Failure 400 {object} problem
+// @Failure 403 {object} problem
+// @Failure 404 {object} problem
+// @Failure 500 {object} problem
+// @Failure default {object} problem
+// @Router /auth/api/threads/{id}/messages [post]
+func (h *Handler) sendMessage(ctx *gin.Context) {
+	userId, threadId, ok := threadParams(ctx)
+	if !ok {
+		return
+	}
+
+	var input messageInput
+	if err := ctx.ShouldBindJSON(&input); err != nil {
+		newBindingErrorResponse(ctx, err)
+		return
+	}
+
+	message, err := h.services.SendMessage(userId, threadId, input.Message)
+	if err != nil {
+		newErrorResponse(ctx, err)
+		return
+	}
+
+	ctx.JSON(http.StatusCreated, message)
+}
+
+// @Summary User Read Thread
+// @Security UsersAuth
+// @Tags users-threads
+// @Description user marks messages they received in a thread as read, the sender sees when they were read
+// @Accept  json
+// @Produce  json
+// @Param id path int true "thread id"
+// @Success 200 {object} string "read"
+// @Failure 400 {object} problem
+// @Failure 404 {object} problem
+// @Failure 500 {object} problem
+// @Failure default {object} problem
+// @Router /auth/api/threads/{id}/read [post]
+func (h *Handler) readThread(ctx *gin.Context) {
+	userId, threadId, ok := threadParams(ctx)
+	if !ok {
+		return
+	}
+
+	if err := h.services.ReadThread(userId, threadId); err != nil {
+		newErrorResponse(ctx, err)
+		return
+	}
+
+	ctx.JSON(http.StatusOK, "read")
+}
+
+// @Summary User Block Buyer
+// @Security UsersAuth
+// @Tags users-threads
+// @Description seller stops the buyer of a thread from writing to them
+// @Accept  json
+// @Produce  json
+// @Param id path int true "thread id"
+// @Success 200 {object} string "blocked"
+// @Failure 400 {object} problem
+// @Failure 403 {object} problem
+// @Failure 404 {object} problem
+// @Failure 500 {object} problem
+// @Failure default {object} problem
+// @Router /auth/api/threads/{id}/block [post]
+func (h *Handler) blockBuyer(ctx *gin.Context) {
+	userId, threadId, ok := threadParams(ctx)
+	if !ok {
+		return
+	}
+
+	if err := h.services.BlockBuyer(userId, threadId); err != nil {
+		newErrorResponse(ctx, err)
+		return
+	}
+
+	ctx.JSON(http.StatusOK, "blocked")
+}
+
+// @Summary User Unblock Buyer
+// @Security UsersAuth
+// @Tags users-threads
+// @Description seller lets the blocked buyer of a thread write to them again
+// @Accept  json
+// @Produce  json
+// @Param id path int true "thread id"
+// @Success 200 {object} string "unblocked"
+// @Failure 400 {object} problem
+// @Failure 403 {object} problem
+// @Failure 404 {object} problem
+// @Failure 500 {object} problem
+// @Failure default {object} problem
+// @Router /auth/api/threads/{id}/block [delete]
+func (h *Handler) unblockBuyer(ctx *gin.Context) {
+	userId, threadId, ok := threadParams(ctx)
+	if !ok {
+		return
+	}
+
+	if err := h.services.UnblockBuyer(userId, threadId); err != nil {
+		newErrorResponse(ctx, err)
+		return
+	}
+
+	ctx.JSON(http.StatusOK, "unblocked")
+}
+
+func threadParams(ctx *gin.Context) (string, int, bool) {
+	userId, err := getUserId(ctx)
+	if err != nil {
+		newErrorResponse(ctx, err)
+		return "", 0, false
+	}
+
+	threadId, err := strconv.Atoi(ctx.Param("id"))
+	if err != nil {
+		newParamErrorResponse(ctx, "id", "invalid thread id")
+		return "", 0, false
+	}
+
+	return userId, threadId, true
 }
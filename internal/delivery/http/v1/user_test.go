@@ -2,15 +2,27 @@ package v1
 
 import (
 	"bytes"
+	"encoding/json"
 	"errors"
+	"fmt"
 	"github.com/TakoB222/postingAds-api/internal/domain"
+	"github.com/TakoB222/postingAds-api/internal/repository"
 	"github.com/TakoB222/postingAds-api/internal/service"
 	mock_service "github.com/TakoB222/postingAds-api/internal/service/mocks"
+	"github.com/TakoB222/postingAds-api/pkg/apperror"
+	"github.com/TakoB222/postingAds-api/pkg/hub"
 	"github.com/gin-gonic/gin"
 	"github.com/golang/mock/gomock"
 	"github.com/stretchr/testify/assert"
+	"golang.org/x/net/websocket"
+	"io"
+	"io/ioutil"
+	"net/http"
 	"net/http/httptest"
+	"strconv"
+	"strings"
 	"testing"
+	"time"
 )
 
 //------------------Test functions for Authorization implementation------------------
@@ -51,7 +63,7 @@ func TestSignUp(t *testing.T) {
 			},
 			mockBehavior:         func(s *mock_service.MockAuthorization, input service.UserSignUpInput) {},
 			expectedStatusCode:   400,
-			expectedResponseBody: `{"message":"invalid input body"}`,
+			expectedResponseBody: `{"type":"about:blank","title":"Bad Request","status":400,"detail":"invalid input","instance":"/signUp","code":"invalid_input","errors":[{"field":"firstName","message":"is required"}]}`,
 		},
 		{
 			name:      "service error",
@@ -66,7 +78,7 @@ func TestSignUp(t *testing.T) {
 				s.EXPECT().SignUp(input).Return(1, errors.New("service failure"))
 			},
 			expectedStatusCode:   500,
-			expectedResponseBody: `{"message":"service failure"}`,
+			expectedResponseBody: `{"type":"about:blank","title":"Internal Server Error","status":500,"detail":"internal server error","instance":"/signUp","code":"internal_error"}`,
 		},
 	}
 
@@ -127,7 +139,17 @@ func TestRefreshTokens(t *testing.T) {
 			inputRefresh:         refreshTokensInput{RefreshToken: ""},
 			mockBehavior:         func(s *mock_service.MockAuthorization, input service.RefreshInput) {},
 			expectedStatusCode:   400,
-			expectedResponseBody: `{"message":"invalid input body"}`,
+			expectedResponseBody: `{"type":"about:blank","title":"Bad Request","status":400,"detail":"invalid input","instance":"/refreshTokens","code":"invalid_input","errors":[{"field":"RefreshToken","message":"is required"}]}`,
+		},
+		{
+			name:         "reused token",
+			inputBody:    `{"RefreshToken":"someToken"}`,
+			inputRefresh: refreshTokensInput{RefreshToken: "someToken"},
+			mockBehavior: func(s *mock_service.MockAuthorization, input service.RefreshInput) {
+				s.EXPECT().RefreshSession(input).Return(service.Tokens{}, service.ErrRefreshTokenReused)
+			},
+			expectedStatusCode:   401,
+			expectedResponseBody: `{"type":"about:blank","title":"Unauthorized","status":401,"detail":"refresh token has already been used","instance":"/refreshTokens","code":"refresh_token_reused"}`,
 		},
 		{
 			name:         "service error",
@@ -137,7 +159,7 @@ func TestRefreshTokens(t *testing.T) {
 				s.EXPECT().RefreshSession(input).Return(service.Tokens{}, errors.New("service failure"))
 			},
 			expectedStatusCode:   500,
-			expectedResponseBody: `{"message":"service failure"}`,
+			expectedResponseBody: `{"type":"about:blank","title":"Internal Server Error","status":500,"detail":"internal server error","instance":"/refreshTokens","code":"internal_error"}`,
 		},
 	}
 
@@ -149,6 +171,7 @@ func TestRefreshTokens(t *testing.T) {
 			auth := mock_service.NewMockAuthorization(c)
 			testCase.mockBehavior(auth, service.RefreshInput{
 				RefreshToken: testCase.inputRefresh.RefreshToken,
+				Ip:           "192.0.2.1",
 			})
 
 			services := &service.Service{Authorization: auth}
@@ -195,7 +218,27 @@ func TestSignIn(t *testing.T) {
 			inputSignIn:          signInInput{Email: "example@gmail.com"},
 			mockBehavior:         func(s *mock_service.MockAuthorization, input service.SignInInput) {},
 			expectedStatusCode:   400,
-			expectedResponseBody: `{"message":"invalid input body"}`,
+			expectedResponseBody: `{"type":"about:blank","title":"Bad Request","status":400,"detail":"invalid input","instance":"/signIn","code":"invalid_input","errors":[{"field":"password","message":"is required"}]}`,
+		},
+		{
+			name:        "invalid credentials",
+			inputBody:   `{"email":"example@gmail.com","password":"wrongPassword"}`,
+			inputSignIn: signInInput{Email: "example@gmail.com", Password: "wrongPassword"},
+			mockBehavior: func(s *mock_service.MockAuthorization, input service.SignInInput) {
+				s.EXPECT().SignIn(input).Return(service.Tokens{}, service.ErrInvalidCredentials)
+			},
+			expectedStatusCode:   401,
+			expectedResponseBody: `{"type":"about:blank","title":"Unauthorized","status":401,"detail":"invalid email or password","instance":"/signIn","code":"invalid_credentials"}`,
+		},
+		{
+			name:        "email not verified",
+			inputBody:   `{"email":"example@gmail.com","password":"somePassword"}`,
+			inputSignIn: signInInput{Email: "example@gmail.com", Password: "somePassword"},
+			mockBehavior: func(s *mock_service.MockAuthorization, input service.SignInInput) {
+				s.EXPECT().SignIn(input).Return(service.Tokens{}, service.ErrEmailNotVerified)
+			},
+			expectedStatusCode:   403,
+			expectedResponseBody: `{"type":"about:blank","title":"Forbidden","status":403,"detail":"email is not verified","instance":"/signIn","code":"email_not_verified"}`,
 		},
 		{
 			name:        "service error",
@@ -205,7 +248,7 @@ func TestSignIn(t *testing.T) {
 				s.EXPECT().SignIn(input).Return(service.Tokens{}, errors.New("service failure"))
 			},
 			expectedStatusCode:   500,
-			expectedResponseBody: `{"message":"service failure"}`,
+			expectedResponseBody: `{"type":"about:blank","title":"Internal Server Error","status":500,"detail":"internal server error","instance":"/signIn","code":"internal_error"}`,
 		},
 	}
 
@@ -218,6 +261,7 @@ func TestSignIn(t *testing.T) {
 			testCase.mockBehavior(auth, service.SignInInput{
 				Email:    testCase.inputSignIn.Email,
 				Password: testCase.inputSignIn.Password,
+				Ip:       "192.0.2.1",
 			})
 
 			services := &service.Service{Authorization: auth}
@@ -237,8 +281,168 @@ func TestSignIn(t *testing.T) {
 	}
 }
 
-//-------------------------------------------------------------------------
-//тестирование вспомагательной функции - getUserId
+func TestSignInSecondFactor(t *testing.T) {
+	type mockBehavior func(s *mock_service.MockAuthorization, input service.TwoFactorSignInInput)
+
+	testTable := []struct {
+		name                 string
+		inputBody            string
+		input                service.TwoFactorSignInInput
+		mockBehavior         mockBehavior
+		expectedStatusCode   int
+		expectedResponseBody string
+	}{
+		{
+			name:      "ok",
+			inputBody: `{"challenge_token":"someChallenge","code":"123456"}`,
+			input:     service.TwoFactorSignInInput{ChallengeToken: "someChallenge", Code: "123456", Ip: "192.0.2.1"},
+			mockBehavior: func(s *mock_service.MockAuthorization, input service.TwoFactorSignInInput) {
+				s.EXPECT().SignInSecondFactor(input).Return(service.Tokens{AccessToken: "someAccessToken", RefreshToken: "someRefreshToken"}, nil)
+			},
+			expectedStatusCode:   200,
+			expectedResponseBody: `{"access_token":"someAccessToken","refresh_token":"someRefreshToken"}`,
+		},
+		{
+			name:                 "empty code",
+			inputBody:            `{"challenge_token":"someChallenge"}`,
+			mockBehavior:         func(s *mock_service.MockAuthorization, input service.TwoFactorSignInInput) {},
+			expectedStatusCode:   400,
+			expectedResponseBody: `{"type":"about:blank","title":"Bad Request","status":400,"detail":"invalid input","instance":"/signInSecondFactor","code":"invalid_input","errors":[{"field":"code","message":"is required"}]}`,
+		},
+		{
+			name:      "invalid code",
+			inputBody: `{"challenge_token":"someChallenge","code":"000000"}`,
+			input:     service.TwoFactorSignInInput{ChallengeToken: "someChallenge", Code: "000000", Ip: "192.0.2.1"},
+			mockBehavior: func(s *mock_service.MockAuthorization, input service.TwoFactorSignInInput) {
+				s.EXPECT().SignInSecondFactor(input).Return(service.Tokens{}, service.ErrInvalidTwoFactorCode.WithKind(apperror.KindUnauthorized))
+			},
+			expectedStatusCode:   401,
+			expectedResponseBody: `{"type":"about:blank","title":"Unauthorized","status":401,"detail":"invalid two-factor code","instance":"/signInSecondFactor","code":"invalid_two_factor_code"}`,
+		},
+		{
+			name:      "locked",
+			inputBody: `{"challenge_token":"someChallenge","code":"000000"}`,
+			input:     service.TwoFactorSignInInput{ChallengeToken: "someChallenge", Code: "000000", Ip: "192.0.2.1"},
+			mockBehavior: func(s *mock_service.MockAuthorization, input service.TwoFactorSignInInput) {
+				s.EXPECT().SignInSecondFactor(input).Return(service.Tokens{}, service.ErrTwoFactorLocked)
+			},
+			expectedStatusCode:   429,
+			expectedResponseBody: `{"type":"about:blank","title":"Too Many Requests","status":429,"detail":"too many invalid two-factor codes, try again later","instance":"/signInSecondFactor","code":"two_factor_locked"}`,
+		},
+		{
+			name:      "service error",
+			inputBody: `{"challenge_token":"someChallenge","code":"123456"}`,
+			input:     service.TwoFactorSignInInput{ChallengeToken: "someChallenge", Code: "123456", Ip: "192.0.2.1"},
+			mockBehavior: func(s *mock_service.MockAuthorization, input service.TwoFactorSignInInput) {
+				s.EXPECT().SignInSecondFactor(input).Return(service.Tokens{}, errors.New("service failure"))
+			},
+			expectedStatusCode:   500,
+			expectedResponseBody: `{"type":"about:blank","title":"Internal Server Error","status":500,"detail":"internal server error","instance":"/signInSecondFactor","code":"internal_error"}`,
+		},
+	}
+
+	for _, testCase := range testTable {
+		t.Run(testCase.name, func(t *testing.T) {
+			c := gomock.NewController(t)
+			defer c.Finish()
+
+			auth := mock_service.NewMockAuthorization(c)
+			testCase.mockBehavior(auth, testCase.input)
+
+			services := &service.Service{Authorization: auth}
+			handler := &Handler{services: services}
+
+			r := gin.New()
+			r.POST("/signInSecondFactor", handler.signInSecondFactor)
+
+			w := httptest.NewRecorder()
+			req := httptest.NewRequest("POST", "/signInSecondFactor", bytes.NewBufferString(testCase.inputBody))
+
+			r.ServeHTTP(w, req)
+
+			assert.Equal(t, testCase.expectedStatusCode, w.Code)
+			assert.Equal(t, testCase.expectedResponseBody, w.Body.String())
+		})
+	}
+}
+
+func TestResetPassword(t *testing.T) {
+	type mockBehavior func(s *mock_service.MockAuthorization, input service.PasswordResetInput)
+
+	testTable := []struct {
+		name                 string
+		inputBody            string
+		input                service.PasswordResetInput
+		mockBehavior         mockBehavior
+		expectedStatusCode   int
+		expectedResponseBody string
+	}{
+		{
+			name:      "ok",
+			inputBody: `{"token":"someToken","password":"newPassword"}`,
+			input:     service.PasswordResetInput{Token: "someToken", Password: "newPassword"},
+			mockBehavior: func(s *mock_service.MockAuthorization, input service.PasswordResetInput) {
+				s.EXPECT().ResetPassword(input).Return(nil)
+			},
+			expectedStatusCode:   200,
+			expectedResponseBody: `"reset"`,
+		},
+		{
+			name:                 "empty password",
+			inputBody:            `{"token":"someToken"}`,
+			mockBehavior:         func(s *mock_service.MockAuthorization, input service.PasswordResetInput) {},
+			expectedStatusCode:   400,
+			expectedResponseBody: `{"type":"about:blank","title":"Bad Request","status":400,"detail":"invalid input","instance":"/resetPassword","code":"invalid_input","errors":[{"field":"password","message":"is required"}]}`,
+		},
+		{
+			name:      "invalid token",
+			inputBody: `{"token":"usedToken","password":"newPassword"}`,
+			input:     service.PasswordResetInput{Token: "usedToken", Password: "newPassword"},
+			mockBehavior: func(s *mock_service.MockAuthorization, input service.PasswordResetInput) {
+				s.EXPECT().ResetPassword(input).Return(service.ErrInvalidToken)
+			},
+			expectedStatusCode:   400,
+			expectedResponseBody: `{"type":"about:blank","title":"Bad Request","status":400,"detail":"token is invalid or expired","instance":"/resetPassword","code":"invalid_token"}`,
+		},
+		{
+			name:      "service error",
+			inputBody: `{"token":"someToken","password":"newPassword"}`,
+			input:     service.PasswordResetInput{Token: "someToken", Password: "newPassword"},
+			mockBehavior: func(s *mock_service.MockAuthorization, input service.PasswordResetInput) {
+				s.EXPECT().ResetPassword(input).Return(errors.New("service failure"))
+			},
+			expectedStatusCode:   500,
+			expectedResponseBody: `{"type":"about:blank","title":"Internal Server Error","status":500,"detail":"internal server error","instance":"/resetPassword","code":"internal_error"}`,
+		},
+	}
+
+	for _, testCase := range testTable {
+		t.Run(testCase.name, func(t *testing.T) {
+			c := gomock.NewController(t)
+			defer c.Finish()
+
+			auth := mock_service.NewMockAuthorization(c)
+			testCase.mockBehavior(auth, testCase.input)
+
+			services := &service.Service{Authorization: auth}
+			handler := &Handler{services: services}
+
+			r := gin.New()
+			r.POST("/resetPassword", handler.resetPassword)
+
+			w := httptest.NewRecorder()
+			req := httptest.NewRequest("POST", "/resetPassword", bytes.NewBufferString(testCase.inputBody))
+
+			r.ServeHTTP(w, req)
+
+			assert.Equal(t, testCase.expectedStatusCode, w.Code)
+			assert.Equal(t, testCase.expectedResponseBody, w.Body.String())
+		})
+	}
+}
+
+// -------------------------------------------------------------------------
+// тестирование вспомагательной функции - getUserId
 func TestGetUserId(t *testing.T) {
 	testTable := []struct {
 		name           string
@@ -259,20 +463,20 @@ func TestGetUserId(t *testing.T) {
 			userId:         "",
 			setUserContext: true,
 			expectedBody:   "",
-			expectedError:  errors.New("empty body of user context"),
+			expectedError:  apperror.Unauthorized("unauthorized", "empty body of user context"),
 		},
 		{
 			name:           "Wrong type of user context",
 			userId:         1,
 			setUserContext: true,
 			expectedBody:   "",
-			expectedError:  errors.New("invalid type of userId from context"),
+			expectedError:  apperror.Unauthorized("unauthorized", "invalid type of userId from context"),
 		},
 		{
 			name:           "Empty user context",
 			setUserContext: false,
 			expectedBody:   "",
-			expectedError:  errors.New("empty user context"),
+			expectedError:  apperror.Unauthorized("unauthorized", "empty user context"),
 		},
 	}
 
@@ -322,7 +526,7 @@ func TestGetAllAds(t *testing.T) {
 				s.EXPECT().GetAllAds(userId).Return([]domain.Ad{}, errors.New("service failure"))
 			},
 			expectedStatusCode:   500,
-			expectedResponseBody: `{"message":"service failure"}`,
+			expectedResponseBody: `{"type":"about:blank","title":"Internal Server Error","status":500,"detail":"internal server error","instance":"/getAllAds","code":"internal_error"}`,
 		},
 	}
 
@@ -371,10 +575,10 @@ func TestCreateAd(t *testing.T) {
 			inputBody:      `{"title": "someTitle","category": "category/category","description": "someDescription","price": 100,"contacts": {"name":"someName","phone_number":"somePhoneNumber","email":"someEmail","location":"someLocation"}, "published": true, "images_url": ["someImageURL"]}`,
 			inputAd: inputAd{
 				Title:       "someTitle",
-				Category:    "category",
+				Category:    "category/category",
 				Description: "someDescription",
 				Price:       100,
-				Contacts: inputContacts{
+				Contacts: &inputContacts{
 					Name:         "someName",
 					Phone_number: "somePhoneNumber",
 					Email:        "someEmail",
@@ -389,13 +593,89 @@ func TestCreateAd(t *testing.T) {
 			expectedStatusCode:   201,
 			expectedResponseBody: `{"id":1}`,
 		},
+		{
+			name:           "contact profile",
+			setUserContext: true,
+			inputBody:      `{"title": "someTitle","category": "category/category","description": "someDescription","price": 100,"contact_profile_id": 3, "images_url": ["someImageURL"]}`,
+			inputAd: inputAd{
+				Title:            "someTitle",
+				Category:         "category/category",
+				Description:      "someDescription",
+				Price:            100,
+				ContactProfileId: 3,
+				ImagesURL:        []string{"someImageURL"},
+			},
+			mockBehavior: func(s *mock_service.MockAd, userId string, ad service.Ads) {
+				s.EXPECT().CreateAd(userId, ad).Return(1, nil)
+			},
+			expectedStatusCode:   201,
+			expectedResponseBody: `{"id":1}`,
+		},
+		{
+			name:           "contact profile not found",
+			setUserContext: true,
+			inputBody:      `{"title": "someTitle","category": "category/category","description": "someDescription","price": 100,"contact_profile_id": 3, "images_url": ["someImageURL"]}`,
+			inputAd: inputAd{
+				Title:            "someTitle",
+				Category:         "category/category",
+				Description:      "someDescription",
+				Price:            100,
+				ContactProfileId: 3,
+				ImagesURL:        []string{"someImageURL"},
+			},
+			mockBehavior: func(s *mock_service.MockAd, userId string, ad service.Ads) {
+				s.EXPECT().CreateAd(userId, ad).Return(0, service.ErrContactProfileNotFound.Invalid("contact_profile_id", "contact profile 3 not found"))
+			},
+			expectedStatusCode:   400,
+			expectedResponseBody: `{"type":"about:blank","title":"Bad Request","status":400,"detail":"contact profile not found","instance":"/createAd","code":"contact_profile_not_found","errors":[{"field":"contact_profile_id","message":"contact profile 3 not found"}]}`,
+		},
+		{
+			name:                 "incomplete contacts",
+			setUserContext:       true,
+			inputBody:            `{"title": "someTitle","category": "category/category","description": "someDescription","price": 100,"contacts": {"name":"someName"}, "images_url": ["someImageURL"]}`,
+			mockBehavior:         func(s *mock_service.MockAd, userId string, ad service.Ads) {},
+			expectedStatusCode:   400,
+			expectedResponseBody: `{"type":"about:blank","title":"Bad Request","status":400,"detail":"invalid input","instance":"/createAd","code":"invalid_input","errors":[{"field":"contacts.phone_number","message":"is required"},{"field":"contacts.email","message":"is required"},{"field":"contacts.location","message":"is required"}]}`,
+		},
 		{
 			name:                 "Empty input field",
 			setUserContext:       false,
 			inputBody:            `{"category": "category/category","description": "someDescription","price": 100,"contacts": {"name":"someName","phone_number":"somePhoneNumber","email":"someEmail","location":"someLocation"}, "published": true, "images_url": ["someImageURL"]}`,
 			mockBehavior:         func(s *mock_service.MockAd, userId string, ad service.Ads) {},
 			expectedStatusCode:   400,
-			expectedResponseBody: `{"message":"invalid input body"}`,
+			expectedResponseBody: `{"type":"about:blank","title":"Bad Request","status":400,"detail":"invalid input","instance":"/createAd","code":"invalid_input","errors":[{"field":"title","message":"is required"}]}`,
+		},
+		{
+			name:                 "wrong type of price",
+			setUserContext:       true,
+			inputBody:            `{"title": "someTitle","category": "category/category","description": "someDescription","price": "100","contact_profile_id": 3, "images_url": ["someImageURL"]}`,
+			mockBehavior:         func(s *mock_service.MockAd, userId string, ad service.Ads) {},
+			expectedStatusCode:   400,
+			expectedResponseBody: `{"type":"about:blank","title":"Bad Request","status":400,"detail":"invalid input","instance":"/createAd","code":"invalid_input","errors":[{"field":"price","message":"must be int"}]}`,
+		},
+		{
+			name:           "category not found",
+			setUserContext: true,
+			inputBody:      `{"title": "someTitle","category": "Transport/Bus","description": "someDescription","price": 100,"contacts": {"name":"someName","phone_number":"somePhoneNumber","email":"someEmail","location":"someLocation"}, "published": true, "images_url": ["someImageURL"]}`,
+			inputAd: inputAd{
+				Title:       "someTitle",
+				Category:    "Transport/Bus",
+				Description: "someDescription",
+				Price:       100,
+				Contacts: &inputContacts{
+					Name:         "someName",
+					Phone_number: "somePhoneNumber",
+					Email:        "someEmail",
+					Location:     "someLocation",
+				},
+				Published: true,
+				ImagesURL: []string{"someImageURL"},
+			},
+			mockBehavior: func(s *mock_service.MockAd, userId string, ad service.Ads) {
+				s.EXPECT().CreateAd(userId, ad).Return(0, &service.CategoryNotFoundError{Category: "Transport/Bus", Suggestions: []string{"Transport/Buses"}})
+			},
+			expectedStatusCode:   400,
+			expectedResponseBody: `{"type":"about:blank","title":"Bad Request","status":400,"detail":"category \"Transport/Bus\" not found","instance":"/createAd","code":"category_not_found","errors":[{"field":"category","message":"category \"Transport/Bus\" not found"}],"suggestions":["Transport/Buses"]}`,
 		},
 		{
 			name:           "service fail",
@@ -403,10 +683,10 @@ func TestCreateAd(t *testing.T) {
 			inputBody:      `{"title": "someTitle","category": "category/category","description": "someDescription","price": 100,"contacts": {"name":"someName","phone_number":"somePhoneNumber","email":"someEmail","location":"someLocation"}, "published": true, "images_url": ["someImageURL"]}`,
 			inputAd: inputAd{
 				Title:       "someTitle",
-				Category:    "category",
+				Category:    "category/category",
 				Description: "someDescription",
 				Price:       100,
-				Contacts: inputContacts{
+				Contacts: &inputContacts{
 					Name:         "someName",
 					Phone_number: "somePhoneNumber",
 					Email:        "someEmail",
@@ -419,7 +699,7 @@ func TestCreateAd(t *testing.T) {
 				s.EXPECT().CreateAd(userId, ad).Return(0, errors.New("service failure"))
 			},
 			expectedStatusCode:   500,
-			expectedResponseBody: `{"message":"service failure"}`,
+			expectedResponseBody: `{"type":"about:blank","title":"Internal Server Error","status":500,"detail":"internal server error","instance":"/createAd","code":"internal_error"}`,
 		},
 	}
 
@@ -430,13 +710,14 @@ func TestCreateAd(t *testing.T) {
 
 			ad := mock_service.NewMockAd(c)
 			testCase.mockBehavior(ad, "1", service.Ads{
-				Title:       testCase.inputAd.Title,
-				Category:    testCase.inputAd.Category,
-				Description: testCase.inputAd.Description,
-				Price:       testCase.inputAd.Price,
-				Contacts:    service.Contacts(testCase.inputAd.Contacts),
-				Published:   testCase.inputAd.Published,
-				ImagesURL:   testCase.inputAd.ImagesURL,
+				Title:            testCase.inputAd.Title,
+				Category:         testCase.inputAd.Category,
+				Description:      testCase.inputAd.Description,
+				Price:            testCase.inputAd.Price,
+				Contacts:         testCase.inputAd.contacts(),
+				ContactProfileId: testCase.inputAd.ContactProfileId,
+				Published:        testCase.inputAd.Published,
+				ImagesURL:        testCase.inputAd.ImagesURL,
 			})
 
 			services := &service.Service{Ad: ad}
@@ -456,6 +737,9 @@ func TestCreateAd(t *testing.T) {
 
 			assert.Equal(t, testCase.expectedStatusCode, w.Code)
 			assert.Equal(t, testCase.expectedResponseBody, w.Body.String())
+			if testCase.expectedStatusCode >= 400 {
+				assert.Equal(t, problemContentType, w.Header().Get("Content-Type"))
+			}
 		})
 	}
 }
@@ -492,7 +776,27 @@ func TestDeleteAd(t *testing.T) {
 				s.EXPECT().DeleteAd(userId, adId).Return(errors.New("service error"))
 			},
 			expectedStatusCode:   500,
-			expectedResponseBody: `{"message":"service error"}`,
+			expectedResponseBody: `{"type":"about:blank","title":"Internal Server Error","status":500,"detail":"internal server error","instance":"/deleteAd/1","code":"internal_error"}`,
+		},
+		{
+			name:           "ad not found",
+			setUserContext: true,
+			adId:           "2",
+			userId:         "1",
+			mockBehavior: func(s *mock_service.MockAd, userId string, adId string) {
+				s.EXPECT().DeleteAd(userId, adId).Return(service.ErrAdNotFound)
+			},
+			expectedStatusCode:   404,
+			expectedResponseBody: `{"type":"about:blank","title":"Not Found","status":404,"detail":"ad not found","instance":"/deleteAd/2","code":"ad_not_found"}`,
+		},
+		{
+			name:                 "invalid id",
+			setUserContext:       true,
+			adId:                 "abc",
+			userId:               "1",
+			mockBehavior:         func(s *mock_service.MockAd, userId string, adId string) {},
+			expectedStatusCode:   400,
+			expectedResponseBody: `{"type":"about:blank","title":"Bad Request","status":400,"detail":"invalid ad id","instance":"/deleteAd/abc","code":"invalid_param","errors":[{"field":"id","message":"invalid ad id"}]}`,
 		},
 	}
 
@@ -524,3 +828,1067 @@ func TestDeleteAd(t *testing.T) {
 		})
 	}
 }
+
+func TestGetPublishedAds(t *testing.T) {
+	type mockBehavior func(s *mock_service.MockAd, filter service.AdsFilter)
+
+	minPrice, maxPrice := 100, 500
+
+	testTable := []struct {
+		name                 string
+		query                string
+		filter               service.AdsFilter
+		mockBehavior         mockBehavior
+		expectedStatusCode   int
+		expectedResponseBody string
+	}{
+		{
+			name:   "ok",
+			query:  "",
+			filter: service.AdsFilter{Limit: 20},
+			mockBehavior: func(s *mock_service.MockAd, filter service.AdsFilter) {
+				s.EXPECT().GetPublishedAds(filter).Return([]domain.Ad{}, 0, nil)
+			},
+			expectedStatusCode:   200,
+			expectedResponseBody: `{"ads":[],"total":0,"limit":20,"offset":0}`,
+		},
+		{
+			name:  "ok with filters",
+			query: "?category=%D0%A2%D1%80%D0%B0%D0%BD%D1%81%D0%BF%D0%BE%D1%80%D1%82&min_price=100&max_price=500&location=Kiev&sort=price_asc&limit=10&offset=20",
+			filter: service.AdsFilter{
+				Category: "Транспорт",
+				MinPrice: &minPrice,
+				MaxPrice: &maxPrice,
+				Location: "Kiev",
+				Sort:     "price_asc",
+				Limit:    10,
+				Offset:   20,
+			},
+			mockBehavior: func(s *mock_service.MockAd, filter service.AdsFilter) {
+				s.EXPECT().GetPublishedAds(filter).Return([]domain.Ad{}, 25, nil)
+			},
+			expectedStatusCode:   200,
+			expectedResponseBody: `{"ads":[],"total":25,"limit":10,"offset":20}`,
+		},
+		{
+			name:  "ok with attributes",
+			query: "?category=Cars&attr[mileage]=..50000&attr[fuel]=diesel",
+			filter: service.AdsFilter{
+				Category:   "Cars",
+				Attributes: map[string]string{"mileage": "..50000", "fuel": "diesel"},
+				Limit:      20,
+			},
+			mockBehavior: func(s *mock_service.MockAd, filter service.AdsFilter) {
+				s.EXPECT().GetPublishedAds(filter).Return([]domain.Ad{}, 3, nil)
+			},
+			expectedStatusCode:   200,
+			expectedResponseBody: `{"ads":[],"total":3,"limit":20,"offset":0}`,
+		},
+		{
+			name:   "invalid attributes",
+			query:  "?attr[rooms]=2",
+			filter: service.AdsFilter{Attributes: map[string]string{"rooms": "2"}, Limit: 20},
+			mockBehavior: func(s *mock_service.MockAd, filter service.AdsFilter) {
+				s.EXPECT().GetPublishedAds(filter).Return(nil, 0, fmt.Errorf("%w: attribute filters require a category", service.ErrInvalidAttributes))
+			},
+			expectedStatusCode:   400,
+			expectedResponseBody: `{"type":"about:blank","title":"Bad Request","status":400,"detail":"invalid attributes: attribute filters require a category","instance":"/ads","code":"invalid_attributes"}`,
+		},
+		{
+			name:                 "invalid sort",
+			query:                "?sort=random",
+			mockBehavior:         func(s *mock_service.MockAd, filter service.AdsFilter) {},
+			expectedStatusCode:   400,
+			expectedResponseBody: `{"type":"about:blank","title":"Bad Request","status":400,"detail":"invalid input","instance":"/ads","code":"invalid_input","errors":[{"field":"sort","message":"must be one of newest, oldest, price_asc, price_desc"}]}`,
+		},
+		{
+			name:                 "invalid price range",
+			query:                "?min_price=500&max_price=100",
+			mockBehavior:         func(s *mock_service.MockAd, filter service.AdsFilter) {},
+			expectedStatusCode:   400,
+			expectedResponseBody: `{"type":"about:blank","title":"Bad Request","status":400,"detail":"min_price is greater than max_price","instance":"/ads","code":"invalid_param","errors":[{"field":"min_price","message":"min_price is greater than max_price"}]}`,
+		},
+		{
+			name:   "service error",
+			query:  "",
+			filter: service.AdsFilter{Limit: 20},
+			mockBehavior: func(s *mock_service.MockAd, filter service.AdsFilter) {
+				s.EXPECT().GetPublishedAds(filter).Return(nil, 0, errors.New("service failure"))
+			},
+			expectedStatusCode:   500,
+			expectedResponseBody: `{"type":"about:blank","title":"Internal Server Error","status":500,"detail":"internal server error","instance":"/ads","code":"internal_error"}`,
+		},
+	}
+
+	for _, testCase := range testTable {
+		t.Run(testCase.name, func(t *testing.T) {
+			c := gomock.NewController(t)
+			defer c.Finish()
+
+			ad := mock_service.NewMockAd(c)
+			testCase.mockBehavior(ad, testCase.filter)
+
+			services := &service.Service{Ad: ad}
+			handler := Handler{services: services}
+
+			r := gin.New()
+			r.GET("/ads", handler.getPublishedAds)
+
+			w := httptest.NewRecorder()
+			req := httptest.NewRequest("GET", "/ads"+testCase.query, nil)
+
+			r.ServeHTTP(w, req)
+
+			assert.Equal(t, testCase.expectedStatusCode, w.Code)
+			assert.Equal(t, testCase.expectedResponseBody, w.Body.String())
+		})
+	}
+}
+
+func TestFts(t *testing.T) {
+	type mockBehavior func(s *mock_service.MockAd, input service.FtsInput)
+
+	maxPrice, parentId := 500, 1
+
+	testTable := []struct {
+		name                 string
+		query                string
+		input                service.FtsInput
+		mockBehavior         mockBehavior
+		expectedStatusCode   int
+		expectedResponseBody string
+	}{
+		{
+			name:  "ok",
+			query: "?q=bike",
+			input: service.FtsInput{Request: "bike", Filter: service.AdsFilter{Limit: 20}},
+			mockBehavior: func(s *mock_service.MockAd, input service.FtsInput) {
+				s.EXPECT().Fts(input).Return(service.FtsResult{Ads: []repository.FtsResponse{
+					{Ad: domain.Ad{Id: 1, Title: "Bike"}, Rank: 0.5, TitleSnippet: "<b>Bike</b>", DescriptionSnippet: "red <b>bike</b>"},
+				}, Total: 1}, nil)
+			},
+			expectedStatusCode:   200,
+			expectedResponseBody: `{"ads":[{"Id":1,"UserId":"","Title":"Bike","Category":"","Description":"","Price":0,"Contacts":"","ImagesURL":null,"CreatedAt":"0001-01-01T00:00:00Z","Status":"","RejectionReason":"","FavoritesCount":0,"rank":0.5,"title_snippet":"\u003cb\u003eBike\u003c/b\u003e","description_snippet":"red \u003cb\u003ebike\u003c/b\u003e"}],"total":1,"limit":20,"offset":0}`,
+		},
+		{
+			name:  "ok with filters",
+			query: "?q=bike&category=Sport&max_price=500&location=Kiev&attr[color]=red&limit=10&offset=10",
+			input: service.FtsInput{Request: "bike", Filter: service.AdsFilter{
+				Category:   "Sport",
+				MaxPrice:   &maxPrice,
+				Location:   "Kiev",
+				Attributes: map[string]string{"color": "red"},
+				Limit:      10,
+				Offset:     10,
+			}},
+			mockBehavior: func(s *mock_service.MockAd, input service.FtsInput) {
+				s.EXPECT().Fts(input).Return(service.FtsResult{Ads: []repository.FtsResponse{}, Total: 12}, nil)
+			},
+			expectedStatusCode:   200,
+			expectedResponseBody: `{"ads":[],"total":12,"limit":10,"offset":10}`,
+		},
+		{
+			name:  "ok with facets",
+			query: "?q=bus&facets=true",
+			input: service.FtsInput{Request: "bus", Filter: service.AdsFilter{Limit: 20}, Facets: true},
+			mockBehavior: func(s *mock_service.MockAd, input service.FtsInput) {
+				s.EXPECT().Fts(input).Return(service.FtsResult{Ads: []repository.FtsResponse{}, Total: 15, Facets: &repository.SearchFacets{
+					Categories: []repository.CategoryFacet{{Id: 1, Path: "Transport", Count: 15}, {Id: 2, ParentId: &parentId, Path: "Transport/Buses", Count: 12}},
+					Locations:  []repository.LocationFacet{{Location: "Kiev", Count: 15}},
+					Prices:     []repository.PriceFacet{{From: 100, To: 199, Count: 15}},
+				}}, nil)
+			},
+			expectedStatusCode:   200,
+			expectedResponseBody: `{"ads":[],"total":15,"limit":20,"offset":0,"facets":{"categories":[{"id":1,"parent_id":null,"path":"Transport","count":15},{"id":2,"parent_id":1,"path":"Transport/Buses","count":12}],"locations":[{"location":"Kiev","count":15}],"prices":[{"from":100,"to":199,"count":15}]}}`,
+		},
+		{
+			name:  "did you mean",
+			query: "?q=bkie",
+			input: service.FtsInput{Request: "bkie", Filter: service.AdsFilter{Limit: 20}},
+			mockBehavior: func(s *mock_service.MockAd, input service.FtsInput) {
+				s.EXPECT().Fts(input).Return(service.FtsResult{Ads: []repository.FtsResponse{}, DidYouMean: "bike"}, nil)
+			},
+			expectedStatusCode:   200,
+			expectedResponseBody: `{"ads":[],"total":0,"limit":20,"offset":0,"did_you_mean":"bike"}`,
+		},
+		{
+			name:  "unsupported language",
+			query: "?q=bike&lang=german",
+			input: service.FtsInput{Request: "bike", Language: "german", Filter: service.AdsFilter{Limit: 20}},
+			mockBehavior: func(s *mock_service.MockAd, input service.FtsInput) {
+				s.EXPECT().Fts(input).Return(service.FtsResult{}, fmt.Errorf("%w %q", service.ErrUnsupportedLanguage, "german"))
+			},
+			expectedStatusCode:   400,
+			expectedResponseBody: `{"type":"about:blank","title":"Bad Request","status":400,"detail":"unsupported language \"german\"","instance":"/fts","code":"unsupported_language"}`,
+		},
+		{
+			name:                 "empty request",
+			query:                "?category=Sport",
+			mockBehavior:         func(s *mock_service.MockAd, input service.FtsInput) {},
+			expectedStatusCode:   400,
+			expectedResponseBody: `{"type":"about:blank","title":"Bad Request","status":400,"detail":"invalid input","instance":"/fts","code":"invalid_input","errors":[{"field":"q","message":"is required"}]}`,
+		},
+		{
+			name:                 "invalid price range",
+			query:                "?q=bike&min_price=500&max_price=100",
+			mockBehavior:         func(s *mock_service.MockAd, input service.FtsInput) {},
+			expectedStatusCode:   400,
+			expectedResponseBody: `{"type":"about:blank","title":"Bad Request","status":400,"detail":"min_price is greater than max_price","instance":"/fts","code":"invalid_param","errors":[{"field":"min_price","message":"min_price is greater than max_price"}]}`,
+		},
+		{
+			name:  "service error",
+			query: "?q=bike",
+			input: service.FtsInput{Request: "bike", Filter: service.AdsFilter{Limit: 20}},
+			mockBehavior: func(s *mock_service.MockAd, input service.FtsInput) {
+				s.EXPECT().Fts(input).Return(service.FtsResult{}, errors.New("service failure"))
+			},
+			expectedStatusCode:   500,
+			expectedResponseBody: `{"type":"about:blank","title":"Internal Server Error","status":500,"detail":"internal server error","instance":"/fts","code":"internal_error"}`,
+		},
+	}
+
+	for _, testCase := range testTable {
+		t.Run(testCase.name, func(t *testing.T) {
+			c := gomock.NewController(t)
+			defer c.Finish()
+
+			ad := mock_service.NewMockAd(c)
+			testCase.mockBehavior(ad, testCase.input)
+
+			services := &service.Service{Ad: ad}
+			handler := Handler{services: services}
+
+			r := gin.New()
+			r.GET("/fts", handler.fts)
+
+			w := httptest.NewRecorder()
+			req := httptest.NewRequest("GET", "/fts"+testCase.query, nil)
+
+			r.ServeHTTP(w, req)
+
+			assert.Equal(t, testCase.expectedStatusCode, w.Code)
+			assert.Equal(t, testCase.expectedResponseBody, w.Body.String())
+		})
+	}
+}
+
+func TestSuggestSearch(t *testing.T) {
+	type mockBehavior func(s *mock_service.MockAd, query string, limit int)
+
+	categoryId := 3
+
+	testTable := []struct {
+		name                 string
+		query                string
+		q                    string
+		limit                int
+		mockBehavior         mockBehavior
+		expectedStatusCode   int
+		expectedResponseBody string
+	}{
+		{
+			name:  "ok",
+			query: "?q=bi",
+			q:     "bi",
+			limit: 10,
+			mockBehavior: func(s *mock_service.MockAd, query string, limit int) {
+				s.EXPECT().Suggest(query, limit).Return([]repository.Suggestion{
+					{Text: "Bike", Kind: "ad", Score: 1.5},
+					{Text: "Sport/Bicycles", Kind: "category", CategoryId: &categoryId, Score: 1.2},
+				}, nil)
+			},
+			expectedStatusCode:   200,
+			expectedResponseBody: `[{"text":"Bike","kind":"ad"},{"text":"Sport/Bicycles","kind":"category","category_id":3}]`,
+		},
+		{
+			name:  "ok with limit",
+			query: "?q=bike&limit=5",
+			q:     "bike",
+			limit: 5,
+			mockBehavior: func(s *mock_service.MockAd, query string, limit int) {
+				s.EXPECT().Suggest(query, limit).Return([]repository.Suggestion{}, nil)
+			},
+			expectedStatusCode:   200,
+			expectedResponseBody: `[]`,
+		},
+		{
+			name:                 "empty query",
+			query:                "",
+			mockBehavior:         func(s *mock_service.MockAd, query string, limit int) {},
+			expectedStatusCode:   400,
+			expectedResponseBody: `{"type":"about:blank","title":"Bad Request","status":400,"detail":"invalid input","instance":"/search/suggest","code":"invalid_input","errors":[{"field":"q","message":"is required"}]}`,
+		},
+		{
+			name:                 "invalid limit",
+			query:                "?q=bike&limit=100",
+			mockBehavior:         func(s *mock_service.MockAd, query string, limit int) {},
+			expectedStatusCode:   400,
+			expectedResponseBody: `{"type":"about:blank","title":"Bad Request","status":400,"detail":"invalid input","instance":"/search/suggest","code":"invalid_input","errors":[{"field":"limit","message":"must be at most 20"}]}`,
+		},
+		{
+			name:  "service error",
+			query: "?q=bike",
+			q:     "bike",
+			limit: 10,
+			mockBehavior: func(s *mock_service.MockAd, query string, limit int) {
+				s.EXPECT().Suggest(query, limit).Return(nil, errors.New("service failure"))
+			},
+			expectedStatusCode:   500,
+			expectedResponseBody: `{"type":"about:blank","title":"Internal Server Error","status":500,"detail":"internal server error","instance":"/search/suggest","code":"internal_error"}`,
+		},
+	}
+
+	for _, testCase := range testTable {
+		t.Run(testCase.name, func(t *testing.T) {
+			c := gomock.NewController(t)
+			defer c.Finish()
+
+			ad := mock_service.NewMockAd(c)
+			testCase.mockBehavior(ad, testCase.q, testCase.limit)
+
+			services := &service.Service{Ad: ad}
+			handler := Handler{services: services}
+
+			r := gin.New()
+			r.GET("/search/suggest", handler.suggestSearch)
+
+			w := httptest.NewRecorder()
+			req := httptest.NewRequest("GET", "/search/suggest"+testCase.query, nil)
+
+			r.ServeHTTP(w, req)
+
+			assert.Equal(t, testCase.expectedStatusCode, w.Code)
+			assert.Equal(t, testCase.expectedResponseBody, w.Body.String())
+		})
+	}
+}
+
+func TestCreateSavedSearch(t *testing.T) {
+	type mockBehavior func(s *mock_service.MockSavedSearch, userId string, input service.SavedSearchInput)
+
+	createdAt := time.Date(2021, 5, 1, 12, 0, 0, 0, time.UTC)
+
+	testTable := []struct {
+		name                 string
+		inputBody            string
+		input                service.SavedSearchInput
+		mockBehavior         mockBehavior
+		expectedStatusCode   int
+		expectedResponseBody string
+	}{
+		{
+			name:      "ok",
+			inputBody: `{"query":"bike","category":"Sport","max_price":500,"channel":"inbox"}`,
+			input: service.SavedSearchInput{
+				Query:   "bike",
+				Filter:  service.AdsFilter{Category: "Sport", MaxPrice: intPtr(500)},
+				Channel: "inbox",
+			},
+			mockBehavior: func(s *mock_service.MockSavedSearch, userId string, input service.SavedSearchInput) {
+				s.EXPECT().CreateSavedSearch(userId, input).Return(domain.SavedSearch{
+					Id:        1,
+					Name:      "bike",
+					Query:     "bike",
+					Filters:   domain.SearchFilters{CategoryId: 2, MaxPrice: intPtr(500)},
+					Channel:   "inbox",
+					CreatedAt: createdAt,
+					CheckedAt: createdAt,
+				}, nil)
+			},
+			expectedStatusCode:   201,
+			expectedResponseBody: `{"id":1,"name":"bike","query":"bike","filters":{"category_id":2,"max_price":500},"channel":"inbox","created_at":"2021-05-01T12:00:00Z","checked_at":"2021-05-01T12:00:00Z"}`,
+		},
+		{
+			name:      "invalid webhook url",
+			inputBody: `{"query":"bike","channel":"webhook","webhook_url":"ftp://example.com"}`,
+			input:     service.SavedSearchInput{Query: "bike", Channel: "webhook", WebhookURL: "ftp://example.com"},
+			mockBehavior: func(s *mock_service.MockSavedSearch, userId string, input service.SavedSearchInput) {
+				s.EXPECT().CreateSavedSearch(userId, input).Return(domain.SavedSearch{}, service.ErrInvalidWebhookURL)
+			},
+			expectedStatusCode:   400,
+			expectedResponseBody: `{"type":"about:blank","title":"Bad Request","status":400,"detail":"webhook url must be an absolute http or https url","instance":"/saved-searches","code":"invalid_webhook_url"}`,
+		},
+		{
+			name:      "webhook url not public",
+			inputBody: `{"query":"bike","channel":"webhook","webhook_url":"http://169.254.169.254/latest"}`,
+			input:     service.SavedSearchInput{Query: "bike", Channel: "webhook", WebhookURL: "http://169.254.169.254/latest"},
+			mockBehavior: func(s *mock_service.MockSavedSearch, userId string, input service.SavedSearchInput) {
+				s.EXPECT().CreateSavedSearch(userId, input).Return(domain.SavedSearch{}, service.ErrWebhookURLNotPublic)
+			},
+			expectedStatusCode:   400,
+			expectedResponseBody: `{"type":"about:blank","title":"Bad Request","status":400,"detail":"webhook url must point to a public address","instance":"/saved-searches","code":"webhook_url_not_public"}`,
+		},
+		{
+			name:      "too many saved searches",
+			inputBody: `{"query":"bike","channel":"email"}`,
+			input:     service.SavedSearchInput{Query: "bike", Channel: "email"},
+			mockBehavior: func(s *mock_service.MockSavedSearch, userId string, input service.SavedSearchInput) {
+				s.EXPECT().CreateSavedSearch(userId, input).Return(domain.SavedSearch{}, fmt.Errorf("%w, %d at most", service.ErrTooManySavedSearches, 20))
+			},
+			expectedStatusCode:   409,
+			expectedResponseBody: `{"type":"about:blank","title":"Conflict","status":409,"detail":"too many saved searches, 20 at most","instance":"/saved-searches","code":"too_many_saved_searches"}`,
+		},
+		{
+			name:                 "empty query",
+			inputBody:            `{"channel":"email"}`,
+			mockBehavior:         func(s *mock_service.MockSavedSearch, userId string, input service.SavedSearchInput) {},
+			expectedStatusCode:   400,
+			expectedResponseBody: `{"type":"about:blank","title":"Bad Request","status":400,"detail":"invalid input","instance":"/saved-searches","code":"invalid_input","errors":[{"field":"query","message":"is required"}]}`,
+		},
+		{
+			name:      "service error",
+			inputBody: `{"query":"bike","channel":"email"}`,
+			input:     service.SavedSearchInput{Query: "bike", Channel: "email"},
+			mockBehavior: func(s *mock_service.MockSavedSearch, userId string, input service.SavedSearchInput) {
+				s.EXPECT().CreateSavedSearch(userId, input).Return(domain.SavedSearch{}, errors.New("service failure"))
+			},
+			expectedStatusCode:   500,
+			expectedResponseBody: `{"type":"about:blank","title":"Internal Server Error","status":500,"detail":"internal server error","instance":"/saved-searches","code":"internal_error"}`,
+		},
+	}
+
+	for _, testCase := range testTable {
+		t.Run(testCase.name, func(t *testing.T) {
+			c := gomock.NewController(t)
+			defer c.Finish()
+
+			savedSearch := mock_service.NewMockSavedSearch(c)
+			testCase.mockBehavior(savedSearch, "1", testCase.input)
+
+			services := &service.Service{SavedSearch: savedSearch}
+			handler := Handler{services: services}
+
+			r := gin.New()
+			r.POST("/saved-searches", func(ctx *gin.Context) {
+				ctx.Set(userContext, "1")
+			}, handler.createSavedSearch)
+
+			w := httptest.NewRecorder()
+			req := httptest.NewRequest("POST", "/saved-searches", bytes.NewBufferString(testCase.inputBody))
+
+			r.ServeHTTP(w, req)
+
+			assert.Equal(t, testCase.expectedStatusCode, w.Code)
+			assert.Equal(t, testCase.expectedResponseBody, w.Body.String())
+		})
+	}
+}
+
+func TestAddFavorite(t *testing.T) {
+	type mockBehavior func(s *mock_service.MockFavorite, userId, adId string)
+
+	testTable := []struct {
+		name                 string
+		adId                 string
+		mockBehavior         mockBehavior
+		expectedStatusCode   int
+		expectedResponseBody string
+	}{
+		{
+			name: "ok",
+			adId: "2",
+			mockBehavior: func(s *mock_service.MockFavorite, userId, adId string) {
+				s.EXPECT().AddFavorite(userId, adId).Return(nil)
+			},
+			expectedStatusCode:   200,
+			expectedResponseBody: `"added"`,
+		},
+		{
+			name: "ad not found",
+			adId: "2",
+			mockBehavior: func(s *mock_service.MockFavorite, userId, adId string) {
+				s.EXPECT().AddFavorite(userId, adId).Return(service.ErrAdNotFound)
+			},
+			expectedStatusCode:   404,
+			expectedResponseBody: `{"type":"about:blank","title":"Not Found","status":404,"detail":"ad not found","instance":"/favorites/2","code":"ad_not_found"}`,
+		},
+		{
+			name: "own ad",
+			adId: "2",
+			mockBehavior: func(s *mock_service.MockFavorite, userId, adId string) {
+				s.EXPECT().AddFavorite(userId, adId).Return(service.ErrOwnAdFavorite)
+			},
+			expectedStatusCode:   400,
+			expectedResponseBody: `{"type":"about:blank","title":"Bad Request","status":400,"detail":"own ads can not be added to favorites","instance":"/favorites/2","code":"own_ad_favorite"}`,
+		},
+		{
+			name: "service error",
+			adId: "2",
+			mockBehavior: func(s *mock_service.MockFavorite, userId, adId string) {
+				s.EXPECT().AddFavorite(userId, adId).Return(errors.New("service failure"))
+			},
+			expectedStatusCode:   500,
+			expectedResponseBody: `{"type":"about:blank","title":"Internal Server Error","status":500,"detail":"internal server error","instance":"/favorites/2","code":"internal_error"}`,
+		},
+	}
+
+	for _, testCase := range testTable {
+		t.Run(testCase.name, func(t *testing.T) {
+			c := gomock.NewController(t)
+			defer c.Finish()
+
+			favorite := mock_service.NewMockFavorite(c)
+			testCase.mockBehavior(favorite, "1", testCase.adId)
+
+			services := &service.Service{Favorite: favorite}
+			handler := Handler{services: services}
+
+			r := gin.New()
+			r.PUT("/favorites/:id", func(ctx *gin.Context) {
+				ctx.Set(userContext, "1")
+			}, handler.addFavorite)
+
+			w := httptest.NewRecorder()
+			req := httptest.NewRequest("PUT", "/favorites/"+testCase.adId, nil)
+
+			r.ServeHTTP(w, req)
+
+			assert.Equal(t, testCase.expectedStatusCode, w.Code)
+			assert.Equal(t, testCase.expectedResponseBody, w.Body.String())
+		})
+	}
+}
+
+func TestOpenThread(t *testing.T) {
+	type mockBehavior func(s *mock_service.MockThread, userId string)
+
+	createdAt := time.Date(2021, 5, 1, 12, 0, 0, 0, time.UTC)
+
+	testTable := []struct {
+		name                 string
+		inputBody            string
+		mockBehavior         mockBehavior
+		expectedStatusCode   int
+		expectedResponseBody string
+	}{
+		{
+			name:      "ok",
+			inputBody: `{"ad_id":2,"message":"Is it still available?"}`,
+			mockBehavior: func(s *mock_service.MockThread, userId string) {
+				s.EXPECT().OpenThread(userId, "2", "Is it still available?").Return(domain.Thread{
+					Id:            1,
+					AdId:          2,
+					AdTitle:       "Bike",
+					BuyerId:       userId,
+					SellerId:      "3",
+					LastMessage:   "Is it still available?",
+					CreatedAt:     createdAt,
+					LastMessageAt: createdAt,
+				}, nil)
+			},
+			expectedStatusCode:   201,
+			expectedResponseBody: `{"id":1,"ad_id":2,"ad_title":"Bike","buyer_id":"1","seller_id":"3","last_message":"Is it still available?","unread":0,"blocked":false,"created_at":"2021-05-01T12:00:00Z","last_message_at":"2021-05-01T12:00:00Z"}`,
+		},
+		{
+			name:      "ad not found",
+			inputBody: `{"ad_id":2,"message":"hi"}`,
+			mockBehavior: func(s *mock_service.MockThread, userId string) {
+				s.EXPECT().OpenThread(userId, "2", "hi").Return(domain.Thread{}, service.ErrAdNotFound)
+			},
+			expectedStatusCode:   404,
+			expectedResponseBody: `{"type":"about:blank","title":"Not Found","status":404,"detail":"ad not found","instance":"/threads","code":"ad_not_found"}`,
+		},
+		{
+			name:      "blocked by the seller",
+			inputBody: `{"ad_id":2,"message":"hi"}`,
+			mockBehavior: func(s *mock_service.MockThread, userId string) {
+				s.EXPECT().OpenThread(userId, "2", "hi").Return(domain.Thread{}, service.ErrUserBlocked)
+			},
+			expectedStatusCode:   403,
+			expectedResponseBody: `{"type":"about:blank","title":"Forbidden","status":403,"detail":"the seller has blocked you","instance":"/threads","code":"user_blocked"}`,
+		},
+		{
+			name:                 "empty message",
+			inputBody:            `{"ad_id":2}`,
+			mockBehavior:         func(s *mock_service.MockThread, userId string) {},
+			expectedStatusCode:   400,
+			expectedResponseBody: `{"type":"about:blank","title":"Bad Request","status":400,"detail":"invalid input","instance":"/threads","code":"invalid_input","errors":[{"field":"message","message":"is required"}]}`,
+		},
+		{
+			name:      "service error",
+			inputBody: `{"ad_id":2,"message":"hi"}`,
+			mockBehavior: func(s *mock_service.MockThread, userId string) {
+				s.EXPECT().OpenThread(userId, "2", "hi").Return(domain.Thread{}, errors.New("service failure"))
+			},
+			expectedStatusCode:   500,
+			expectedResponseBody: `{"type":"about:blank","title":"Internal Server Error","status":500,"detail":"internal server error","instance":"/threads","code":"internal_error"}`,
+		},
+	}
+
+	for _, testCase := range testTable {
+		t.Run(testCase.name, func(t *testing.T) {
+			c := gomock.NewController(t)
+			defer c.Finish()
+
+			thread := mock_service.NewMockThread(c)
+			testCase.mockBehavior(thread, "1")
+
+			services := &service.Service{Thread: thread}
+			handler := Handler{services: services}
+
+			r := gin.New()
+			r.POST("/threads", func(ctx *gin.Context) {
+				ctx.Set(userContext, "1")
+			}, handler.openThread)
+
+			w := httptest.NewRecorder()
+			req := httptest.NewRequest("POST", "/threads", bytes.NewBufferString(testCase.inputBody))
+
+			r.ServeHTTP(w, req)
+
+			assert.Equal(t, testCase.expectedStatusCode, w.Code)
+			assert.Equal(t, testCase.expectedResponseBody, w.Body.String())
+		})
+	}
+}
+
+func TestStreamEvents(t *testing.T) {
+	testTable := []struct {
+		name                 string
+		websocket            bool
+		tokenExpiresIn       time.Duration
+		expectedResponseBody string
+	}{
+		{
+			name:                 "sse replays missed events",
+			tokenExpiresIn:       time.Minute,
+			expectedResponseBody: "retry: 3000\n\nid: %s\nevent: ad_approved\ndata: {\"ad_id\":2}\n\n",
+		},
+		{
+			name:                 "websocket replays missed events",
+			websocket:            true,
+			tokenExpiresIn:       time.Minute,
+			expectedResponseBody: `{"id":"%s","type":"ad_approved","data":{"ad_id":2}}`,
+		},
+		{
+			name:                 "sse ends when the token expires",
+			tokenExpiresIn:       200 * time.Millisecond,
+			expectedResponseBody: "retry: 3000\n\nid: %s\nevent: ad_approved\ndata: {\"ad_id\":2}\n\n",
+		},
+	}
+
+	for _, testCase := range testTable {
+		t.Run(testCase.name, func(t *testing.T) {
+			c := gomock.NewController(t)
+			defer c.Finish()
+
+			events := hub.New(10, time.Minute)
+			first := events.Publish("1", "new_message", json.RawMessage(`{"id":1}`))
+			second := events.Publish("1", "ad_approved", json.RawMessage(`{"ad_id":2}`))
+
+			unsubscribed := make(chan struct{})
+			eventsService := mock_service.NewMockEvents(c)
+			eventsService.EXPECT().Subscribe("1", first.Id).DoAndReturn(events.Subscribe)
+			eventsService.EXPECT().Unsubscribe(gomock.Any()).Do(func(sub *hub.Subscription) {
+				events.Unsubscribe(sub)
+				close(unsubscribed)
+			})
+
+			services := &service.Service{Events: eventsService}
+			handler := Handler{services: services}
+
+			r := gin.New()
+			r.GET("/events", func(ctx *gin.Context) {
+				ctx.Set(userContext, "1")
+				ctx.Set(tokenExpiryContext, time.Now().Add(testCase.tokenExpiresIn))
+			}, handler.streamEvents)
+
+			server := httptest.NewServer(r)
+			defer server.Close()
+
+			var body string
+			if testCase.websocket {
+				conn, err := websocket.Dial("ws"+strings.TrimPrefix(server.URL, "http")+"/events?last_event_id="+first.Id, "", server.URL)
+				if !assert.NoError(t, err) {
+					return
+				}
+
+				var message string
+				assert.NoError(t, websocket.Message.Receive(conn, &message))
+				body = message
+				conn.Close()
+			} else {
+				req, _ := http.NewRequest("GET", server.URL+"/events", nil)
+				req.Header.Set(lastEventIdHeader, first.Id)
+				resp, err := http.DefaultClient.Do(req)
+				if !assert.NoError(t, err) {
+					return
+				}
+
+				assert.Equal(t, "text/event-stream", resp.Header.Get("Content-Type"))
+				buf := make([]byte, len(fmt.Sprintf(testCase.expectedResponseBody, second.Id)))
+				_, err = io.ReadFull(resp.Body, buf)
+				assert.NoError(t, err)
+				body = string(buf)
+				if testCase.tokenExpiresIn < time.Second {
+					// the server closes the stream, reading ends with it
+					_, err = ioutil.ReadAll(resp.Body)
+					assert.NoError(t, err)
+				}
+				resp.Body.Close()
+			}
+
+			assert.Equal(t, fmt.Sprintf(testCase.expectedResponseBody, second.Id), body)
+
+			select {
+			case <-unsubscribed:
+			case <-time.After(time.Second):
+				t.Error("subscription is not closed after the client went away")
+			}
+		})
+	}
+}
+
+func TestStreamEventsOrigin(t *testing.T) {
+	handler := Handler{allowedOrigins: []string{"https://app.example.com/"}}
+
+	testTable := []struct {
+		name          string
+		origin        string
+		expectedError error
+	}{
+		{
+			name: "no origin",
+		},
+		{
+			name:   "origin of the api",
+			origin: "http://api.example.com",
+		},
+		{
+			name:   "allowed origin",
+			origin: "https://app.example.com",
+		},
+		{
+			name:          "other site",
+			origin:        "https://evil.example.com",
+			expectedError: errOriginNotAllowed,
+		},
+		{
+			name:          "other scheme of the allowed origin",
+			origin:        "http://app.example.com",
+			expectedError: errOriginNotAllowed,
+		},
+	}
+
+	for _, testCase := range testTable {
+		t.Run(testCase.name, func(t *testing.T) {
+			req := httptest.NewRequest("GET", "http://api.example.com/events", nil)
+			if testCase.origin != "" {
+				req.Header.Set("Origin", testCase.origin)
+			}
+
+			err := handler.checkOrigin(nil, req)
+			assert.True(t, errors.Is(err, testCase.expectedError), "unexpected error: %v", err)
+		})
+	}
+
+	// the handshake is refused before the user is subscribed
+	r := gin.New()
+	r.GET("/events", func(ctx *gin.Context) {
+		ctx.Set(userContext, "1")
+		ctx.Set(tokenExpiryContext, time.Now().Add(time.Minute))
+	}, handler.streamEvents)
+
+	server := httptest.NewServer(r)
+	defer server.Close()
+
+	_, err := websocket.Dial("ws"+strings.TrimPrefix(server.URL, "http")+"/events", "", "https://evil.example.com")
+	assert.Error(t, err)
+}
+
+func TestRevealContacts(t *testing.T) {
+	type mockBehavior func(s *mock_service.MockContact, userId, adId string)
+
+	testTable := []struct {
+		name                 string
+		adId                 string
+		mockBehavior         mockBehavior
+		expectedStatusCode   int
+		expectedResponseBody string
+	}{
+		{
+			name: "ok",
+			adId: "2",
+			mockBehavior: func(s *mock_service.MockContact, userId, adId string) {
+				s.EXPECT().RevealContacts(userId, adId, "192.0.2.1").Return(domain.Contacts{
+					Name:         "Ivan",
+					Phone_number: "+380671234567",
+					Email:        "ivan@example.com",
+					Location:     "Kyiv",
+				}, nil)
+			},
+			expectedStatusCode:   200,
+			expectedResponseBody: `{"name":"Ivan","phone_number":"+380671234567","email":"ivan@example.com","location":"Kyiv"}`,
+		},
+		{
+			name: "ad not found",
+			adId: "2",
+			mockBehavior: func(s *mock_service.MockContact, userId, adId string) {
+				s.EXPECT().RevealContacts(userId, adId, "192.0.2.1").Return(domain.Contacts{}, service.ErrAdNotFound)
+			},
+			expectedStatusCode:   404,
+			expectedResponseBody: `{"type":"about:blank","title":"Not Found","status":404,"detail":"ad not found","instance":"/ads/2/contacts","code":"ad_not_found"}`,
+		},
+		{
+			name: "too many reveals",
+			adId: "2",
+			mockBehavior: func(s *mock_service.MockContact, userId, adId string) {
+				s.EXPECT().RevealContacts(userId, adId, "192.0.2.1").Return(domain.Contacts{}, fmt.Errorf("%w, %d per %s at most", service.ErrTooManyContactReveals, 30, 24*time.Hour))
+			},
+			expectedStatusCode:   429,
+			expectedResponseBody: `{"type":"about:blank","title":"Too Many Requests","status":429,"detail":"too many contact reveals, try again later, 30 per 24h0m0s at most","instance":"/ads/2/contacts","code":"too_many_contact_reveals"}`,
+		},
+		{
+			name: "service error",
+			adId: "2",
+			mockBehavior: func(s *mock_service.MockContact, userId, adId string) {
+				s.EXPECT().RevealContacts(userId, adId, "192.0.2.1").Return(domain.Contacts{}, errors.New("service failure"))
+			},
+			expectedStatusCode:   500,
+			expectedResponseBody: `{"type":"about:blank","title":"Internal Server Error","status":500,"detail":"internal server error","instance":"/ads/2/contacts","code":"internal_error"}`,
+		},
+	}
+
+	for _, testCase := range testTable {
+		t.Run(testCase.name, func(t *testing.T) {
+			c := gomock.NewController(t)
+			defer c.Finish()
+
+			contact := mock_service.NewMockContact(c)
+			testCase.mockBehavior(contact, "1", testCase.adId)
+
+			services := &service.Service{Contact: contact}
+			handler := Handler{services: services}
+
+			r := gin.New()
+			r.POST("/ads/:id/contacts", func(ctx *gin.Context) {
+				ctx.Set(userContext, "1")
+			}, handler.revealContacts)
+
+			w := httptest.NewRecorder()
+			req := httptest.NewRequest("POST", "/ads/"+testCase.adId+"/contacts", nil)
+
+			r.ServeHTTP(w, req)
+
+			assert.Equal(t, testCase.expectedStatusCode, w.Code)
+			assert.Equal(t, testCase.expectedResponseBody, w.Body.String())
+		})
+	}
+}
+
+func intPtr(v int) *int {
+	return &v
+}
+
+func TestDeleteContactProfile(t *testing.T) {
+	type mockBehavior func(s *mock_service.MockContact, userId string, profileId int)
+
+	testTable := []struct {
+		name                 string
+		profileId            string
+		mockBehavior         mockBehavior
+		expectedStatusCode   int
+		expectedResponseBody string
+	}{
+		{
+			name:      "ok",
+			profileId: "3",
+			mockBehavior: func(s *mock_service.MockContact, userId string, profileId int) {
+				s.EXPECT().DeleteContactProfile(userId, profileId).Return(nil)
+			},
+			expectedStatusCode:   200,
+			expectedResponseBody: `"deleted"`,
+		},
+		{
+			name:                 "invalid id",
+			profileId:            "home",
+			mockBehavior:         func(s *mock_service.MockContact, userId string, profileId int) {},
+			expectedStatusCode:   400,
+			expectedResponseBody: `{"type":"about:blank","title":"Bad Request","status":400,"detail":"invalid contact profile id","instance":"/contact-profiles/home","code":"invalid_param","errors":[{"field":"id","message":"invalid contact profile id"}]}`,
+		},
+		{
+			name:      "not found",
+			profileId: "3",
+			mockBehavior: func(s *mock_service.MockContact, userId string, profileId int) {
+				s.EXPECT().DeleteContactProfile(userId, profileId).Return(service.ErrContactProfileNotFound)
+			},
+			expectedStatusCode:   404,
+			expectedResponseBody: `{"type":"about:blank","title":"Not Found","status":404,"detail":"contact profile not found","instance":"/contact-profiles/3","code":"contact_profile_not_found"}`,
+		},
+		{
+			name:      "used by ads",
+			profileId: "3",
+			mockBehavior: func(s *mock_service.MockContact, userId string, profileId int) {
+				s.EXPECT().DeleteContactProfile(userId, profileId).Return(fmt.Errorf("%w, 2 ads use it", service.ErrContactProfileInUse))
+			},
+			expectedStatusCode:   409,
+			expectedResponseBody: `{"type":"about:blank","title":"Conflict","status":409,"detail":"contact profile is used by ads, 2 ads use it","instance":"/contact-profiles/3","code":"contact_profile_in_use"}`,
+		},
+	}
+
+	for _, testCase := range testTable {
+		t.Run(testCase.name, func(t *testing.T) {
+			c := gomock.NewController(t)
+			defer c.Finish()
+
+			contact := mock_service.NewMockContact(c)
+			profileId, _ := strconv.Atoi(testCase.profileId)
+			testCase.mockBehavior(contact, "1", profileId)
+
+			services := &service.Service{Contact: contact}
+			handler := Handler{services: services}
+
+			r := gin.New()
+			r.DELETE("/contact-profiles/:id", func(ctx *gin.Context) {
+				ctx.Set(userContext, "1")
+			}, handler.deleteContactProfile)
+
+			w := httptest.NewRecorder()
+			req := httptest.NewRequest("DELETE", "/contact-profiles/"+testCase.profileId, nil)
+
+			r.ServeHTTP(w, req)
+
+			assert.Equal(t, testCase.expectedStatusCode, w.Code)
+			assert.Equal(t, testCase.expectedResponseBody, w.Body.String())
+		})
+	}
+}
+
+func TestRevokeSession(t *testing.T) {
+	type mockBehavior func(s *mock_service.MockAuthorization, userId, sessionId string)
+
+	testTable := []struct {
+		name                 string
+		sessionId            string
+		mockBehavior         mockBehavior
+		expectedStatusCode   int
+		expectedResponseBody string
+	}{
+		{
+			name:      "ok",
+			sessionId: "2",
+			mockBehavior: func(s *mock_service.MockAuthorization, userId, sessionId string) {
+				s.EXPECT().RevokeSession(userId, sessionId).Return(nil)
+			},
+			expectedStatusCode:   200,
+			expectedResponseBody: `"revoked"`,
+		},
+		{
+			name:      "session not found",
+			sessionId: "2",
+			mockBehavior: func(s *mock_service.MockAuthorization, userId, sessionId string) {
+				s.EXPECT().RevokeSession(userId, sessionId).Return(service.ErrSessionNotFound.WithKind(apperror.KindNotFound))
+			},
+			expectedStatusCode:   404,
+			expectedResponseBody: `{"type":"about:blank","title":"Not Found","status":404,"detail":"session not found","instance":"/sessions/2","code":"session_not_found"}`,
+		},
+		{
+			name:      "service error",
+			sessionId: "2",
+			mockBehavior: func(s *mock_service.MockAuthorization, userId, sessionId string) {
+				s.EXPECT().RevokeSession(userId, sessionId).Return(errors.New("service failure"))
+			},
+			expectedStatusCode:   500,
+			expectedResponseBody: `{"type":"about:blank","title":"Internal Server Error","status":500,"detail":"internal server error","instance":"/sessions/2","code":"internal_error"}`,
+		},
+	}
+
+	for _, testCase := range testTable {
+		t.Run(testCase.name, func(t *testing.T) {
+			c := gomock.NewController(t)
+			defer c.Finish()
+
+			auth := mock_service.NewMockAuthorization(c)
+			testCase.mockBehavior(auth, "1", testCase.sessionId)
+
+			services := &service.Service{Authorization: auth}
+			handler := Handler{services: services}
+
+			r := gin.New()
+			r.DELETE("/sessions/:id", func(ctx *gin.Context) {
+				ctx.Set(userContext, "1")
+			}, handler.revokeSession)
+
+			w := httptest.NewRecorder()
+			req := httptest.NewRequest("DELETE", "/sessions/"+testCase.sessionId, nil)
+
+			r.ServeHTTP(w, req)
+
+			assert.Equal(t, testCase.expectedStatusCode, w.Code)
+			assert.Equal(t, testCase.expectedResponseBody, w.Body.String())
+		})
+	}
+}
+
+//------------------Test functions for Image implementation------------------
+
+func TestGetImage(t *testing.T) {
+	type mockBehavior func(s *mock_service.MockImage, imageId, size string)
+
+	testTable := []struct {
+		name                 string
+		imageId              string
+		size                 string
+		mockBehavior         mockBehavior
+		expectedStatusCode   int
+		expectedLocation     string
+		expectedResponseBody string
+	}{
+		{
+			name:    "ok",
+			imageId: "0123456789abcdef0123456789abcdef",
+			size:    "small",
+			mockBehavior: func(s *mock_service.MockImage, imageId, size string) {
+				s.EXPECT().GetImageURL(imageId, size).Return("http://localhost:8000/uploads/images/"+imageId+"/small", nil)
+			},
+			expectedStatusCode: 302,
+			expectedLocation:   "http://localhost:8000/uploads/images/0123456789abcdef0123456789abcdef/small",
+		},
+		{
+			name:    "not found",
+			imageId: "legacy",
+			mockBehavior: func(s *mock_service.MockImage, imageId, size string) {
+				s.EXPECT().GetImageURL(imageId, size).Return("", service.ErrImageNotFound)
+			},
+			expectedStatusCode:   404,
+			expectedResponseBody: `{"type":"about:blank","title":"Not Found","status":404,"detail":"image not found","instance":"/images/legacy","code":"image_not_found"}`,
+		},
+		{
+			name:    "unknown size",
+			imageId: "0123456789abcdef0123456789abcdef",
+			size:    "huge",
+			mockBehavior: func(s *mock_service.MockImage, imageId, size string) {
+				s.EXPECT().GetImageURL(imageId, size).Return("", service.ErrImageSizeUnknown)
+			},
+			expectedStatusCode:   400,
+			expectedResponseBody: `{"type":"about:blank","title":"Bad Request","status":400,"detail":"unknown image size","instance":"/images/0123456789abcdef0123456789abcdef","code":"image_size_unknown"}`,
+		},
+	}
+
+	for _, testCase := range testTable {
+		t.Run(testCase.name, func(t *testing.T) {
+			c := gomock.NewController(t)
+			defer c.Finish()
+
+			image := mock_service.NewMockImage(c)
+			testCase.mockBehavior(image, testCase.imageId, testCase.size)
+
+			services := &service.Service{Image: image}
+			handler := &Handler{services: services}
+
+			r := gin.New()
+			r.GET("/images/:id", handler.getImage)
+
+			w := httptest.NewRecorder()
+			req := httptest.NewRequest("GET", "/images/"+testCase.imageId+"?size="+testCase.size, nil)
+
+			r.ServeHTTP(w, req)
+
+			assert.Equal(t, testCase.expectedStatusCode, w.Code)
+			assert.Equal(t, testCase.expectedLocation, w.Header().Get("Location"))
+			if testCase.expectedResponseBody != "" {
+				assert.Equal(t, testCase.expectedResponseBody, w.Body.String())
+			}
+		})
+	}
+}
@@ -1,30 +1,106 @@
 package domain
 
-import "github.com/lib/pq"
+import (
+	"github.com/lib/pq"
+	"time"
+)
 
 type (
 	Ad struct {
-		Id          int            `db:"id"`
-		UserId      string         `db:"userid"`
-		Title       string         `db:"title"`
-		Category    string         `db:"category_id"`
-		Description string         `db:"description"`
-		Price       int            `db:"price"`
-		Contacts    string         `db:"contacts_id"`
-		Published   bool           `db:"published"`
-		ImagesURL   pq.StringArray `db:"images_url"`
+		Id              int             `db:"id"`
+		UserId          string          `db:"userid"`
+		Title           string          `db:"title"`
+		Category        string          `db:"category_id"`
+		Description     string          `db:"description"`
+		Price           int             `db:"price"`
+		Contacts        string          `db:"contacts_id"`
+		ImagesURL       pq.StringArray  `db:"images_url"`
+		CreatedAt       time.Time       `db:"created_at"`
+		Status          AdStatus        `db:"status"`
+		RejectionReason string          `db:"rejection_reason"`
+		Attributes      AttributeValues `db:"attributes" json:",omitempty"`
+		Language        string          `db:"language" json:",omitempty"` // text search configuration of the ad
+		FavoritesCount  int             `db:"favorites_count"`
+		// ApprovedPrice is the price of the ad when it was last approved, favorites are told when it changes
+		ApprovedPrice *int `db:"approved_price" json:"-"`
+		// ContactsInfo is masked in ads shown to other users, they reveal it one ad at a time
+		ContactsInfo *Contacts `db:"contacts_info" json:",omitempty"`
 	}
 
 	Contacts struct {
-		Name         string `db:"name"`
-		Phone_number string `db:"phone_number"`
-		Email        string `db:"email"`
-		Location     string `db:"location"`
+		Name         string `db:"name" json:"name"`
+		Phone_number string `db:"phone_number" json:"phone_number"`
+		Email        string `db:"email" json:"email,omitempty"`
+		Location     string `db:"location" json:"location"`
+		Masked       bool   `db:"-" json:"masked,omitempty"`
+	}
+
+	// ContactProfile is a labeled set of contacts of a user, ads using it show its current contacts.
+	ContactProfile struct {
+		Id           int    `json:"id" db:"id"`
+		Label        string `json:"label" db:"label"`
+		Name         string `json:"name" db:"name"`
+		Phone_number string `json:"phone_number" db:"phone_number"`
+		Email        string `json:"email" db:"email"`
+		Location     string `json:"location" db:"location"`
+		AdsCount     int    `json:"ads_count" db:"ads_count"`
+	}
+
+	// ContactRevealStats counts how many times contacts of an ad were revealed and by how many users.
+	ContactRevealStats struct {
+		AdId           int        `json:"ad_id" db:"ad_id"`
+		Title          string     `json:"title" db:"title"`
+		Reveals        int        `json:"reveals" db:"reveals"`
+		Revealers      int        `json:"revealers" db:"revealers"`
+		LastRevealedAt *time.Time `json:"last_revealed_at" db:"last_revealed_at"`
 	}
 
 	Categories struct {
-		Id             int    `json:"id"`
-		Category       string `json:"category"`
-		ParentCategory int    `json:"parent_category"`
+		Id             int    `json:"id" db:"id"`
+		Category       string `json:"category" db:"category"`
+		ParentCategory *int   `json:"parent_category" db:"parent_category"`
+		AdsCount       int    `json:"ads_count" db:"ads_count"`
+		// Attributes are declared by the category itself, inherited ones are not included
+		Attributes AttributeSchema `json:"attributes" db:"attributes"`
+	}
+
+	// CategoryNode is a category with its subcategories, AdsCount includes ads of the whole subtree.
+	CategoryNode struct {
+		Categories
+		Children []CategoryNode `json:"children"`
 	}
 )
+
+type AdStatus string
+
+const (
+	AdStatusDraft         AdStatus = "draft"
+	AdStatusPendingReview AdStatus = "pending_review"
+	AdStatusApproved      AdStatus = "approved"
+	AdStatusRejected      AdStatus = "rejected"
+	AdStatusArchived      AdStatus = "archived"
+)
+
+// adStatusTransitions describes the moderation lifecycle of an ad: which states may follow the current one.
+var adStatusTransitions = map[AdStatus][]AdStatus{
+	AdStatusDraft:         {AdStatusDraft, AdStatusPendingReview, AdStatusArchived},
+	AdStatusPendingReview: {AdStatusPendingReview, AdStatusApproved, AdStatusRejected, AdStatusArchived},
+	AdStatusApproved:      {AdStatusPendingReview, AdStatusArchived},
+	AdStatusRejected:      {AdStatusPendingReview, AdStatusArchived},
+	AdStatusArchived:      {},
+}
+
+func (s AdStatus) CanTransitionTo(next AdStatus) bool {
+	for _, status := range adStatusTransitions[s] {
+		if status == next {
+			return true
+		}
+	}
+
+	return false
+}
+
+// Scan reads contacts selected as a json row.
+func (c *Contacts) Scan(src interface{}) error {
+	return scanJSON(src, c)
+}
@@ -0,0 +1,8 @@
+package domain
+
+type Admin struct {
+	Id            string `db:"id"`
+	Login         string `db:"login"`
+	Password_hash string `db:"password_hash"`
+	TwoFactor     bool   `db:"totp_enabled"`
+}
@@ -0,0 +1,262 @@
+package domain
+
+import (
+	"bytes"
+	"database/sql/driver"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math"
+	"regexp"
+	"strconv"
+	"strings"
+	"unicode/utf8"
+)
+
+type AttributeType string
+
+const (
+	AttributeEnum    AttributeType = "enum"
+	AttributeInteger AttributeType = "integer"
+	AttributeBoolean AttributeType = "boolean"
+	AttributeText    AttributeType = "text"
+)
+
+const maxAttributeTextLength = 255
+
+var attributeNamePattern = regexp.MustCompile(`^[a-z][a-z0-9_]{0,63}$`)
+
+// Attribute is a structured field of ads declared by a category, subcategories inherit it.
+type Attribute struct {
+	Name     string        `json:"name"`
+	Type     AttributeType `json:"type"`
+	Required bool          `json:"required"`
+	Options  []string      `json:"options,omitempty"` // values of an enum
+	Min      *int64        `json:"min,omitempty"`     // bounds of an integer
+	Max      *int64        `json:"max,omitempty"`
+}
+
+// Check validates the declaration of the attribute.
+func (a Attribute) Check() error {
+	if !attributeNamePattern.MatchString(a.Name) {
+		return fmt.Errorf("invalid attribute name %q", a.Name)
+	}
+
+	if a.Type != AttributeEnum && len(a.Options) > 0 {
+		return fmt.Errorf("attribute %q: options are only allowed for enum", a.Name)
+	}
+	if a.Type != AttributeInteger && (a.Min != nil || a.Max != nil) {
+		return fmt.Errorf("attribute %q: min and max are only allowed for integer", a.Name)
+	}
+
+	switch a.Type {
+	case AttributeEnum:
+		if len(a.Options) == 0 {
+			return fmt.Errorf("attribute %q: enum requires options", a.Name)
+		}
+		seen := make(map[string]bool, len(a.Options))
+		for _, option := range a.Options {
+			if option == "" || seen[option] {
+				return fmt.Errorf("attribute %q: options must be unique and not empty", a.Name)
+			}
+			seen[option] = true
+		}
+	case AttributeInteger:
+		if a.Min != nil && a.Max != nil && *a.Min > *a.Max {
+			return fmt.Errorf("attribute %q: min is greater than max", a.Name)
+		}
+	case AttributeBoolean, AttributeText:
+	default:
+		return fmt.Errorf("attribute %q: unknown type %q", a.Name, a.Type)
+	}
+
+	return nil
+}
+
+// Parse validates a value decoded from json and returns it normalized.
+func (a Attribute) Parse(value interface{}) (interface{}, error) {
+	switch a.Type {
+	case AttributeEnum:
+		s, ok := value.(string)
+		if !ok || !a.hasOption(s) {
+			return nil, fmt.Errorf("attribute %q must be one of %s", a.Name, strings.Join(a.Options, ", "))
+		}
+		return s, nil
+	case AttributeInteger:
+		var n int64
+		switch v := value.(type) {
+		case float64:
+			if v != math.Trunc(v) || math.Abs(v) > 1<<53 {
+				return nil, fmt.Errorf("attribute %q must be an integer", a.Name)
+			}
+			n = int64(v)
+		case json.Number:
+			i, err := v.Int64()
+			if err != nil {
+				return nil, fmt.Errorf("attribute %q must be an integer", a.Name)
+			}
+			n = i
+		case int64:
+			n = v
+		default:
+			return nil, fmt.Errorf("attribute %q must be an integer", a.Name)
+		}
+		if a.Min != nil && n < *a.Min || a.Max != nil && n > *a.Max {
+			return nil, fmt.Errorf("attribute %q is out of range", a.Name)
+		}
+		return n, nil
+	case AttributeBoolean:
+		b, ok := value.(bool)
+		if !ok {
+			return nil, fmt.Errorf("attribute %q must be a boolean", a.Name)
+		}
+		return b, nil
+	case AttributeText:
+		s, ok := value.(string)
+		if !ok {
+			return nil, fmt.Errorf("attribute %q must be a string", a.Name)
+		}
+		s = strings.TrimSpace(s)
+		if s == "" || utf8.RuneCountInString(s) > maxAttributeTextLength {
+			return nil, fmt.Errorf("attribute %q must be from 1 to %d characters", a.Name, maxAttributeTextLength)
+		}
+		return s, nil
+	default:
+		return nil, fmt.Errorf("attribute %q: unknown type %q", a.Name, a.Type)
+	}
+}
+
+// ParseString parses a value given as text, e.g. in a query string.
+func (a Attribute) ParseString(value string) (interface{}, error) {
+	switch a.Type {
+	case AttributeInteger:
+		n, err := strconv.ParseInt(value, 10, 64)
+		if err != nil {
+			return nil, fmt.Errorf("attribute %q must be an integer", a.Name)
+		}
+		return a.Parse(n)
+	case AttributeBoolean:
+		b, err := strconv.ParseBool(value)
+		if err != nil {
+			return nil, fmt.Errorf("attribute %q must be a boolean", a.Name)
+		}
+		return b, nil
+	default:
+		return a.Parse(value)
+	}
+}
+
+func (a Attribute) hasOption(value string) bool {
+	for _, option := range a.Options {
+		if option == value {
+			return true
+		}
+	}
+
+	return false
+}
+
+// AttributeSchema is stored in the attributes column of categories.
+type AttributeSchema []Attribute
+
+// Check validates every declaration and rejects duplicate names.
+func (s AttributeSchema) Check() error {
+	seen := make(map[string]bool, len(s))
+	for _, attribute := range s {
+		if err := attribute.Check(); err != nil {
+			return err
+		}
+		if seen[attribute.Name] {
+			return fmt.Errorf("attribute %q is declared twice", attribute.Name)
+		}
+		seen[attribute.Name] = true
+	}
+
+	return nil
+}
+
+func (s AttributeSchema) Find(name string) (Attribute, bool) {
+	for _, attribute := range s {
+		if attribute.Name == name {
+			return attribute, true
+		}
+	}
+
+	return Attribute{}, false
+}
+
+// Validate checks values of an ad against the schema and returns them normalized.
+func (s AttributeSchema) Validate(values map[string]interface{}) (AttributeValues, error) {
+	for name := range values {
+		if _, ok := s.Find(name); !ok {
+			return nil, fmt.Errorf("unknown attribute %q", name)
+		}
+	}
+
+	res := make(AttributeValues, len(values))
+	for _, attribute := range s {
+		value, ok := values[attribute.Name]
+		if !ok || value == nil {
+			if attribute.Required {
+				return nil, fmt.Errorf("attribute %q is required", attribute.Name)
+			}
+			continue
+		}
+
+		parsed, err := attribute.Parse(value)
+		if err != nil {
+			return nil, err
+		}
+		res[attribute.Name] = parsed
+	}
+
+	return res, nil
+}
+
+func (s AttributeSchema) Value() (driver.Value, error) {
+	if s == nil {
+		return "[]", nil
+	}
+
+	b, err := json.Marshal(s)
+	return string(b), err
+}
+
+func (s *AttributeSchema) Scan(src interface{}) error {
+	return scanJSON(src, s)
+}
+
+// AttributeValues are attributes of an ad stored as jsonb.
+type AttributeValues map[string]interface{}
+
+func (v AttributeValues) Value() (driver.Value, error) {
+	if v == nil {
+		return "{}", nil
+	}
+
+	b, err := json.Marshal(v)
+	return string(b), err
+}
+
+func (v *AttributeValues) Scan(src interface{}) error {
+	return scanJSON(src, v)
+}
+
+func scanJSON(src interface{}, dst interface{}) error {
+	var data []byte
+	switch v := src.(type) {
+	case []byte:
+		data = v
+	case string:
+		data = []byte(v)
+	case nil:
+		return nil
+	default:
+		return errors.New("unsupported type of a json column")
+	}
+
+	decoder := json.NewDecoder(bytes.NewReader(data))
+	decoder.UseNumber()
+
+	return decoder.Decode(dst)
+}
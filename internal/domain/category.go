@@ -0,0 +1,32 @@
+package domain
+
+import "strings"
+
+// CategoryPaths returns full paths of the categories by their ids, names are joined with a slash from the root.
+func CategoryPaths(categories []Categories) map[int]string {
+	byId := make(map[int]Categories, len(categories))
+	for _, category := range categories {
+		byId[category.Id] = category
+	}
+
+	paths := make(map[int]string, len(categories))
+	for _, category := range categories {
+		names := []string{category.Category}
+		// the depth bound keeps a corrupted tree from looping forever
+		for parent := category.ParentCategory; parent != nil && len(names) <= len(categories); {
+			p, ok := byId[*parent]
+			if !ok {
+				break
+			}
+			names = append(names, p.Category)
+			parent = p.ParentCategory
+		}
+
+		for i := 0; i < len(names)/2; i++ {
+			names[i], names[len(names)-1-i] = names[len(names)-1-i], names[i]
+		}
+		paths[category.Id] = strings.Join(names, "/")
+	}
+
+	return paths
+}
@@ -0,0 +1,53 @@
+package domain
+
+import (
+	"github.com/stretchr/testify/assert"
+	"testing"
+)
+
+func TestCategoryPaths(t *testing.T) {
+	id := func(id int) *int { return &id }
+
+	testTable := []struct {
+		name       string
+		categories []Categories
+		expected   map[int]string
+	}{
+		{
+			name:       "empty",
+			categories: []Categories{},
+			expected:   map[int]string{},
+		},
+		{
+			name: "nested",
+			categories: []Categories{
+				{Id: 1, Category: "Transport"},
+				{Id: 2, Category: "Cars", ParentCategory: id(1)},
+				{Id: 3, Category: "Electric", ParentCategory: id(2)},
+				{Id: 4, Category: "Realty"},
+			},
+			expected: map[int]string{1: "Transport", 2: "Transport/Cars", 3: "Transport/Cars/Electric", 4: "Realty"},
+		},
+		{
+			name: "missing parent",
+			categories: []Categories{
+				{Id: 2, Category: "Cars", ParentCategory: id(1)},
+			},
+			expected: map[int]string{2: "Cars"},
+		},
+		{
+			name: "cycle",
+			categories: []Categories{
+				{Id: 1, Category: "A", ParentCategory: id(2)},
+				{Id: 2, Category: "B", ParentCategory: id(1)},
+			},
+			expected: map[int]string{1: "A/B/A", 2: "B/A/B"},
+		},
+	}
+
+	for _, testCase := range testTable {
+		t.Run(testCase.name, func(t *testing.T) {
+			assert.Equal(t, testCase.expected, CategoryPaths(testCase.categories))
+		})
+	}
+}
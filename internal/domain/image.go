@@ -0,0 +1,18 @@
+package domain
+
+import (
+	"database/sql"
+	"time"
+)
+
+// Image is an uploaded picture of an ad, the ad keeps its id in images_url.
+type Image struct {
+	Id          string         `json:"id" db:"id"`
+	UserId      string         `json:"-" db:"user_id"`
+	AdId        sql.NullString `json:"-" db:"ad_id"` // null until the image is attached to an ad
+	ContentType string         `json:"content_type" db:"content_type"`
+	Width       int            `json:"width" db:"width"`
+	Height      int            `json:"height" db:"height"`
+	Size        int            `json:"size" db:"size"`
+	CreatedAt   time.Time      `json:"created_at" db:"created_at"`
+}
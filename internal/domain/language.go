@@ -0,0 +1,51 @@
+package domain
+
+import (
+	"strings"
+	"unicode"
+)
+
+// Languages of ads, the values are names of text search configurations of postgres.
+const (
+	LanguageRussian   = "russian"
+	LanguageUkrainian = "ukrainian"
+	LanguageEnglish   = "english"
+)
+
+var Languages = []string{LanguageRussian, LanguageUkrainian, LanguageEnglish}
+
+func IsLanguage(language string) bool {
+	for _, l := range Languages {
+		if l == language {
+			return true
+		}
+	}
+
+	return false
+}
+
+// DetectLanguage guesses the language of a text by its letters, a text without letters is russian.
+// It matches the backfill of the search_vector migration.
+func DetectLanguage(text string) string {
+	var cyrillic, latin, ukrainian int
+	for _, r := range text {
+		switch {
+		case strings.ContainsRune("іїєґІЇЄҐ", r):
+			ukrainian++
+			cyrillic++
+		case unicode.Is(unicode.Cyrillic, r):
+			cyrillic++
+		case unicode.Is(unicode.Latin, r):
+			latin++
+		}
+	}
+
+	switch {
+	case ukrainian > 0:
+		return LanguageUkrainian
+	case cyrillic == 0 && latin > 0:
+		return LanguageEnglish
+	default:
+		return LanguageRussian
+	}
+}
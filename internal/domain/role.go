@@ -0,0 +1,18 @@
+package domain
+
+import "github.com/lib/pq"
+
+// Permissions are granted to users and admins through roles and embedded into access tokens.
+const (
+	PermissionAdsModerate     = "ads:moderate"
+	PermissionAdsManage       = "ads:manage"
+	PermissionUsersBan        = "users:ban"
+	PermissionCategoriesWrite = "categories:write"
+	PermissionRolesManage     = "roles:manage"
+)
+
+type Role struct {
+	Id          string         `json:"id" db:"id"`
+	Name        string         `json:"name" db:"name"`
+	Permissions pq.StringArray `json:"permissions" db:"permissions"`
+}
@@ -0,0 +1,58 @@
+package domain
+
+import (
+	"database/sql/driver"
+	"encoding/json"
+	"time"
+)
+
+// Channels saved searches deliver notifications through.
+const (
+	ChannelEmail   = "email"
+	ChannelWebhook = "webhook"
+	ChannelInbox   = "inbox"
+)
+
+// SavedSearch is a search request of a user checked periodically for newly published ads.
+type SavedSearch struct {
+	Id         int           `json:"id" db:"id"`
+	UserId     string        `json:"-" db:"user_id"`
+	Name       string        `json:"name" db:"name"`
+	Query      string        `json:"query" db:"query"`
+	Filters    SearchFilters `json:"filters" db:"filters"`
+	Channel    string        `json:"channel" db:"channel"`
+	WebhookURL string        `json:"webhook_url,omitempty" db:"webhook_url"`
+	CreatedAt  time.Time     `json:"created_at" db:"created_at"`
+	CheckedAt  time.Time     `json:"checked_at" db:"checked_at"`
+}
+
+// SearchFilters narrow down a saved search, the category is kept by id, so renaming it does not break the search.
+type SearchFilters struct {
+	CategoryId int               `json:"category_id,omitempty"`
+	MinPrice   *int              `json:"min_price,omitempty"`
+	MaxPrice   *int              `json:"max_price,omitempty"`
+	Location   string            `json:"location,omitempty"`
+	Language   string            `json:"language,omitempty"`
+	Attributes map[string]string `json:"attributes,omitempty"` // as given in the query string of the search
+}
+
+func (f SearchFilters) Value() (driver.Value, error) {
+	b, err := json.Marshal(f)
+	return string(b), err
+}
+
+func (f *SearchFilters) Scan(src interface{}) error {
+	return scanJSON(src, f)
+}
+
+// Notification is a message of the in app inbox of a user.
+type Notification struct {
+	Id        int             `json:"id" db:"id"`
+	UserId    string          `json:"-" db:"user_id"`
+	Kind      string          `json:"kind" db:"kind"`
+	Title     string          `json:"title" db:"title"`
+	Body      string          `json:"body" db:"body"`
+	Payload   json.RawMessage `json:"payload" db:"payload"`
+	CreatedAt time.Time       `json:"created_at" db:"created_at"`
+	ReadAt    *time.Time      `json:"read_at" db:"read_at"`
+}
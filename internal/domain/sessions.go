@@ -3,17 +3,23 @@ package domain
 import "time"
 
 type Session struct {
-	Id           string    `db:"id"`
-	UserId       string    `db:"userid"`
-	RefreshToken string    `db:"refreshtoken"`
-	ExpiresIn    time.Time `db:"expiresin"`
-	CreatedAt    time.Time `db:"createdat"`
+	Id           string    `json:"id" db:"id"`
+	UserId       string    `json:"-" db:"userid"`
+	RefreshToken string    `json:"-" db:"refreshtoken"`
+	ExpiresIn    time.Time `json:"expires_in" db:"expiresin"`
+	CreatedAt    time.Time `json:"created_at" db:"createdat"`
+	UserAgent    string    `json:"user_agent" db:"useragent"`
+	Ip           string    `json:"ip" db:"ip"`
+	LastUsedAt   time.Time `json:"last_used_at" db:"lastusedat"`
 }
 
 type AdminSession struct {
-	Id           string    `db:"id"`
-	AdminId      string    `db:"adminid"`
-	RefreshToken string    `db:"refreshtoken"`
-	ExpiresIn    time.Time `db:"expiresin"`
-	CreatedAt    time.Time `db:"createdat"`
+	Id           string    `json:"id" db:"id"`
+	AdminId      string    `json:"-" db:"adminid"`
+	RefreshToken string    `json:"-" db:"refreshtoken"`
+	ExpiresIn    time.Time `json:"expires_in" db:"expiresin"`
+	CreatedAt    time.Time `json:"created_at" db:"createdat"`
+	UserAgent    string    `json:"user_agent" db:"useragent"`
+	Ip           string    `json:"ip" db:"ip"`
+	LastUsedAt   time.Time `json:"last_used_at" db:"lastusedat"`
 }
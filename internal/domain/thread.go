@@ -0,0 +1,26 @@
+package domain
+
+import "time"
+
+// Thread is a conversation of a buyer with the seller about an ad.
+type Thread struct {
+	Id            int       `json:"id" db:"id"`
+	AdId          int       `json:"ad_id" db:"ad_id"`
+	AdTitle       string    `json:"ad_title" db:"ad_title"`
+	BuyerId       string    `json:"buyer_id" db:"buyer_id"`
+	SellerId      string    `json:"seller_id" db:"seller_id"`
+	LastMessage   string    `json:"last_message" db:"last_message"`
+	Unread        int       `json:"unread" db:"unread"`   // messages the other participant sent that the user has not read yet
+	Blocked       bool      `json:"blocked" db:"blocked"` // the seller blocked the buyer
+	CreatedAt     time.Time `json:"created_at" db:"created_at"`
+	LastMessageAt time.Time `json:"last_message_at" db:"last_message_at"`
+}
+
+type Message struct {
+	Id        int        `json:"id" db:"id"`
+	ThreadId  int        `json:"thread_id" db:"thread_id"`
+	SenderId  string     `json:"sender_id" db:"sender_id"`
+	Body      string     `json:"body" db:"body"`
+	CreatedAt time.Time  `json:"created_at" db:"created_at"`
+	ReadAt    *time.Time `json:"read_at" db:"read_at"` // read receipt, set when the recipient reads the thread
+}
@@ -0,0 +1,13 @@
+package domain
+
+import "time"
+
+// TwoFactor is the TOTP state of a user or an admin account.
+type TwoFactor struct {
+	Account        string     `db:"account"`
+	Secret         string     `db:"totp_secret"` // set on enrolment, it is enabled only once a code is confirmed
+	Enabled        bool       `db:"totp_enabled"`
+	LastStep       int64      `db:"totp_last_step"`
+	FailedAttempts int        `db:"totp_failed_attempts"`
+	LockedUntil    *time.Time `db:"totp_locked_until"`
+}
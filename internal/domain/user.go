@@ -3,10 +3,28 @@ package domain
 import "time"
 
 type User struct {
-	Id            string    `json:"_" db:"id"`
-	Email         string    `json:"email" db:"email"`
-	Password_hash string    `json:"password_hash" db:"password_hash"`
-	First_name    string    `json:"first_name" db:"first_name"`
-	Last_name     string    `json:"last_name" db:"last_name"`
-	Registered_at time.Time `json:"registered_at" db:"registered_at"`
+	Id            string     `json:"_" db:"id"`
+	Email         string     `json:"email" db:"email"`
+	Password_hash string     `json:"password_hash" db:"password_hash"`
+	First_name    string     `json:"first_name" db:"first_name"`
+	Last_name     string     `json:"last_name" db:"last_name"`
+	Registered_at time.Time  `json:"registered_at" db:"registered_at"`
+	EmailVerified bool       `json:"email_verified" db:"email_verified"`
+	TwoFactor     bool       `json:"two_factor" db:"totp_enabled"`
+	BannedAt      *time.Time `json:"banned_at,omitempty" db:"banned_at"`
+}
+
+const (
+	UserTokenEmailVerification = "email_verification"
+	UserTokenPasswordReset     = "password_reset"
+)
+
+// UserToken is a single use token sent to the user by email, only its hash is stored.
+type UserToken struct {
+	Id        string    `db:"id"`
+	UserId    string    `db:"userid"`
+	Purpose   string    `db:"purpose"`
+	TokenHash string    `db:"tokenhash"`
+	ExpiresAt time.Time `db:"expiresat"`
+	CreatedAt time.Time `db:"createdat"`
 }
@@ -1,70 +1,50 @@
 package repository
 
 import (
-	"errors"
+	"context"
+	"database/sql"
+	"encoding/json"
 	"fmt"
 	"github.com/TakoB222/postingAds-api/internal/domain"
 	"github.com/TakoB222/postingAds-api/pkg/database"
 	"github.com/jmoiron/sqlx"
 	"github.com/lib/pq"
+	"sort"
 	"strconv"
 	"strings"
 )
 
+// adColumns are the columns of domain.Ad, the search vector is maintained by a trigger and never read.
+const adColumns = "ads.id, ads.userid, ads.title, ads.category_id, ads.description, ads.price, ads.contacts_id, " +
+	"ads.images_url, ads.created_at, ads.status, ads.rejection_reason, ads.attributes, ads.language, ads.approved_price, " +
+	"(select count(*) from " + database.FavoritesTable + " f where f.ad_id = ads.id) as favorites_count, " +
+	"(select row_to_json(ci) from " + database.ContactsInfoTable + " ci where ci.id = ads.contacts_id) as contacts_info"
+
+// the price histogram of search facets splits the range of found prices into equal buckets
+const priceFacetBuckets = 10
+
+// likeEscaper escapes wildcards of a like pattern.
+var likeEscaper = strings.NewReplacer(`\`, `\\`, "%", `\%`, "_", `\_`)
+
 type AdRepository struct {
-	db *sqlx.DB
+	db   *sqlx.DB
+	tree *CategoryTree
 }
 
-func NewAdRepository(db *sqlx.DB) *AdRepository {
-	return &AdRepository{db: db}
+func NewAdRepository(db *sqlx.DB, tree *CategoryTree) *AdRepository {
+	return &AdRepository{db: db, tree: tree}
 }
 
 func (r *AdRepository) GetAllAdsByUserId(userId string) ([]domain.Ad, error) {
 	var ads []domain.Ad
 
-	tx, err := r.db.Begin()
-	if err != nil {
-		return []domain.Ad{}, err
-	}
-	query := fmt.Sprintf("select * from %s where userid=$1", database.AdsTable)
+	query := fmt.Sprintf("select %s from %s where userid=$1", adColumns, database.AdsTable)
 	if err := r.db.Select(&ads, query, userId); err != nil {
 		return nil, err
 	}
 
-	for i := 0; i < len(ads); i++ {
-		categoryId, err := strconv.Atoi(ads[i].Category)
-		if err != nil {
-			err := tx.Rollback()
-			if err != nil {
-				return []domain.Ad{}, err
-			}
-			return []domain.Ad{}, err
-		}
-
-		var categorySeq []string
-		query = fmt.Sprintf(`with recursive r as (select id, parent_category, category from categories where id=$1 
-									union 
-									select categories.id, categories.parent_category, categories.category from %s join r on categories.id = r.parent_category) 
-									select category from r;`, database.CategoriesTable)
-		if err := r.db.Select(&categorySeq, query, categoryId); err != nil {
-			err := tx.Rollback()
-			if err != nil {
-				return []domain.Ad{}, err
-			}
-			return []domain.Ad{}, err
-		}
-
-		ads[i].Category = func(categories []string) string{
-			for i := 0; i < len(categories)/2; i++ {
-				tmp := categories[i]
-				categories[i] = categories[len(categories)-1-i]
-				categories[len(categories)-1-i] = tmp
-			}
-			return strings.Join(categories, "/")
-		}(categorySeq)
-	}
-	if err = tx.Commit(); err != nil {
-		return []domain.Ad{}, err
+	if err := r.tree.setPaths(ads); err != nil {
+		return nil, err
 	}
 
 	return ads, nil
@@ -76,27 +56,21 @@ func (r *AdRepository) CreateAd(userId string, input Ads) (int, error) {
 		return 0, err
 	}
 
-	var category int
-	query := fmt.Sprintf("select id from %s where category=$1", database.CategoriesTable)
-	err = r.db.Get(&category, query, input.Category)
-	if err != nil {
-		return 0, err
-	}
-
-	var contactId int
-	query = fmt.Sprintf("insert into %s (name, phone_number, email, location) values ($1, $2, $3, $4) returning id", database.ContactsInfoTable)
-	row := tx.QueryRow(query, input.Contacts.Name, input.Contacts.Phone_number, input.Contacts.Email, input.Contacts.Location)
-	if err := row.Scan(&contactId); err != nil {
-		err := tx.Rollback()
+	contactId := input.ContactProfileId
+	if contactId == 0 {
+		contactId, err = createContacts(tx, input.Contacts)
 		if err != nil {
+			err := tx.Rollback()
+			if err != nil {
+				return 0, err
+			}
 			return 0, err
 		}
-		return 0, err
 	}
 
 	var adId int
-	query = fmt.Sprintf("insert into %s (userid, title, category_id, description, price, contacts_id, published, images_url) values ($1, $2, $3, $4, $5, $6, $7, $8) returning id", database.AdsTable)
-	row = tx.QueryRow(query, userId, input.Title, category, input.Description, input.Price, contactId, input.Published, pq.Array(input.ImagesURL))
+	query := fmt.Sprintf("insert into %s (userid, title, category_id, description, price, contacts_id, status, images_url, attributes, language) values ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10) returning id", database.AdsTable)
+	row := tx.QueryRow(query, userId, input.Title, input.Category, input.Description, input.Price, contactId, input.Status, pq.Array(input.ImagesURL), input.Attributes, input.Language)
 	if err := row.Scan(&adId); err != nil {
 		err := tx.Rollback()
 		if err != nil {
@@ -111,65 +85,26 @@ func (r *AdRepository) CreateAd(userId string, input Ads) (int, error) {
 func (r *AdRepository) GetAdById(userId string, adId string) (domain.Ad, error) {
 	var ad domain.Ad
 
-	tx, err := r.db.Begin()
-	if err != nil {
-		return domain.Ad{}, err
-	}
-
-	query := fmt.Sprintf("select * from %s where userid=$1 and id=$2", database.AdsTable)
+	query := fmt.Sprintf("select %s from %s where userid=$1 and id=$2", adColumns, database.AdsTable)
 	if err := r.db.Get(&ad, query, userId, adId); err != nil {
-		return domain.Ad{}, err
+		return domain.Ad{}, notFound(err)
 	}
 
-	categoryId, err := strconv.Atoi(ad.Category)
+	category, err := r.tree.Path(ad.Category)
 	if err != nil {
-		err := tx.Rollback()
-		if err != nil {
-			return domain.Ad{}, err
-		}
-		return domain.Ad{}, err
-	}
-
-	var categorySeq []string
-	query = fmt.Sprintf(`with recursive r as (select id, parent_category, category from categories where id=$1 
-								union 
-								select categories.id, categories.parent_category, categories.category from %s join r on categories.id = r.parent_category) 
-								select category from r;`, database.CategoriesTable)
-	if err := r.db.Select(&categorySeq, query, categoryId); err != nil {
-		err := tx.Rollback()
-		if err != nil {
-			return domain.Ad{}, err
-		}
-		return domain.Ad{}, err
-	}
-
-	ad.Category = func(categories []string) string{
-		for i := 0; i < len(categories)/2; i++ {
-			tmp := categories[i]
-			categories[i] = categories[len(categories)-1-i]
-			categories[len(categories)-1-i] = tmp
-		}
-		return strings.Join(categories, "/")
-	}(categorySeq)
-
-	if err = tx.Commit(); err != nil {
 		return domain.Ad{}, err
 	}
+	ad.Category = category
 
 	return ad, nil
 }
 
-func (r *AdRepository) UpdateAd(userId string, adId string, ad Ads) error {
-	var categoryId int
-	query := fmt.Sprintf("select id from %s where category=$1", database.CategoriesTable)
-	if err := r.db.Get(&categoryId, query, ad.Category); err != nil {
-		return errors.New("here")
-	}
-
+// UpdateAd changes the ad only while its status is still from, otherwise it fails with ErrAdStatusChanged.
+func (r *AdRepository) UpdateAd(userId string, adId string, from domain.AdStatus, ad Ads) error {
 	var contactsId int
-	query = fmt.Sprintf("select contacts_id from %s where id=$1 and userid=$2", database.AdsTable)
+	query := fmt.Sprintf("select contacts_id from %s where id=$1 and userid=$2", database.AdsTable)
 	if err := r.db.Get(&contactsId, query, adId, userId); err != nil {
-		return err
+		return notFound(err)
 	}
 
 	setValues := make([]string, 0)
@@ -184,7 +119,7 @@ func (r *AdRepository) UpdateAd(userId string, adId string, ad Ads) error {
 
 	if ad.Category != "" {
 		setValues = append(setValues, fmt.Sprintf("category_id=$%d", argId))
-		args = append(args, categoryId)
+		args = append(args, ad.Category)
 		argId++
 	}
 
@@ -206,9 +141,23 @@ func (r *AdRepository) UpdateAd(userId string, adId string, ad Ads) error {
 		argId++
 	}
 
-	setValues = append(setValues, fmt.Sprintf("published=$%d", argId))
-	args = append(args, ad.Published)
-	argId++
+	if ad.Attributes != nil {
+		setValues = append(setValues, fmt.Sprintf("attributes=$%d", argId))
+		args = append(args, ad.Attributes)
+		argId++
+	}
+
+	if ad.Language != "" {
+		setValues = append(setValues, fmt.Sprintf("language=$%d", argId))
+		args = append(args, ad.Language)
+		argId++
+	}
+
+	if ad.Status != "" {
+		setValues = append(setValues, fmt.Sprintf("status=$%d", argId), "rejection_reason=''")
+		args = append(args, ad.Status)
+		argId++
+	}
 
 	tx, err := r.db.Begin()
 	if err != nil {
@@ -219,8 +168,8 @@ func (r *AdRepository) UpdateAd(userId string, adId string, ad Ads) error {
 		return err
 	}
 
-	query = fmt.Sprintf("update %s set name=$1, phone_number=$2, email=$3, location=$4 where id=$5", database.ContactsInfoTable)
-	if _, err := tx.Exec(query, ad.Contacts.Name, ad.Contacts.Phone_number, ad.Contacts.Email, ad.Contacts.Location, contactsId); err != nil {
+	newContactsId, err := setAdContacts(tx, contactsId, ad.ContactProfileId, ad.Contacts)
+	if err != nil {
 		err := tx.Rollback()
 		if err != nil {
 			return err
@@ -229,16 +178,29 @@ func (r *AdRepository) UpdateAd(userId string, adId string, ad Ads) error {
 	}
 
 	setValues = append(setValues, fmt.Sprintf("contacts_id=$%d", argId))
-	args = append(args, contactsId)
+	args = append(args, newContactsId)
 	argId++
 
 	setQuery := strings.Join(setValues, ", ")
 
-	query = fmt.Sprintf("UPDATE %s SET %s WHERE id = $%d and userid = $%d",
-		database.AdsTable, setQuery, argId, argId+1)
-	args = append(args, adId, userId)
+	// the status is checked once more, a moderator may have decided on the ad since it was read
+	query = fmt.Sprintf("UPDATE %s SET %s WHERE id = $%d and userid = $%d and status = $%d",
+		database.AdsTable, setQuery, argId, argId+1, argId+2)
+	args = append(args, adId, userId, from)
 
-	if _, err = r.db.Exec(query, args...); err != nil {
+	res, err := tx.Exec(query, args...)
+	if err == nil {
+		err = checkStatusChanged(res)
+	}
+	if err != nil {
+		err := tx.Rollback()
+		if err != nil {
+			return err
+		}
+		return err
+	}
+
+	if err := deleteUnusedContacts(tx, contactsId); err != nil {
 		err := tx.Rollback()
 		if err != nil {
 			return err
@@ -250,21 +212,388 @@ func (r *AdRepository) UpdateAd(userId string, adId string, ad Ads) error {
 }
 
 func (r *AdRepository) DeleteAd(userId string, adId string) error {
-	query := fmt.Sprintf("delete from %s where id=$1 and userid=$2", database.AdsTable)
-	if _, err := r.db.Exec(query, adId, userId); err != nil {
-		return err
+	return deleteAd(r.db, "id=$1 and userid=$2", adId, userId)
+}
+
+func (r *AdRepository) SearchAdByRequest(search_request string, filter AdsFilter) ([]FtsResponse, int, error) {
+	fromQuery, whereQuery, args := searchConditions(search_request, filter)
+	argId := len(args) + 1
+
+	var total int
+	query := fmt.Sprintf("select count(*) from %s where %s", fromQuery, whereQuery)
+	if err := r.db.Get(&total, query, args...); err != nil {
+		return nil, 0, err
 	}
 
-	return nil
+	// snippets are built only for the requested page as ts_headline is expensive
+	res := make([]FtsResponse, 0)
+	query = fmt.Sprintf(`with page as (select %s, ts_rank(ads.search_vector, q) as rank from %s where %s 
+		order by rank desc, ads.id desc limit $%d offset $%d) 
+		select page.*, ts_headline(page.language::regconfig, page.title, q, 'HighlightAll=true') as title_snippet, 
+		ts_headline(page.language::regconfig, page.description, q, 'MaxFragments=2, MinWords=10, MaxWords=30') as description_snippet 
+		from page, %s order by page.rank desc, page.id desc`,
+		adColumns, fromQuery, whereQuery, argId, argId+1, searchTsQuery(filter.Language))
+	args = append(args, filter.Limit, filter.Offset)
+	if err := r.db.Select(&res, query, args...); err != nil {
+		return nil, 0, err
+	}
+
+	for i := range res {
+		category, err := r.tree.Path(res[i].Category)
+		if err != nil {
+			return nil, 0, err
+		}
+		res[i].Category = category
+	}
+
+	return res, total, nil
 }
 
-func (r *AdRepository) SearchAdByRequest(search_request string)([]FtsResponse, error) {
-	var res []FtsResponse
+// categoryCondition matches ads of the category given by $argId and of its subcategories.
+func categoryCondition(argId int) string {
+	return fmt.Sprintf(`ads.category_id in (with recursive r as (select id from %s where id=$%d 
+									union 
+									select categories.id from %s join r on categories.parent_category = r.id) 
+									select id from r)`, database.CategoriesTable, argId, database.CategoriesTable)
+}
+
+// attributeConditions returns conditions on ads.attributes with their arguments numbered from argId.
+func attributeConditions(filters []AttributeFilter, argId int) ([]string, []interface{}) {
+	conditions := make([]string, 0, len(filters))
+	args := make([]interface{}, 0, len(filters)*2)
+
+	for _, filter := range filters {
+		if filter.Equals != nil {
+			value, err := json.Marshal(map[string]interface{}{filter.Name: filter.Equals})
+			if err != nil {
+				continue
+			}
+			conditions = append(conditions, fmt.Sprintf("ads.attributes @> $%d::jsonb", argId))
+			args = append(args, string(value))
+			argId++
+		}
+
+		if filter.Contains != "" {
+			conditions = append(conditions, fmt.Sprintf("strpos(lower(ads.attributes->>$%d::text), lower($%d)) > 0", argId, argId+1))
+			args = append(args, filter.Name, filter.Contains)
+			argId += 2
+		}
+
+		// the same name may be of another type in other categories, case keeps the cast from failing on them
+		number := fmt.Sprintf("(case when jsonb_typeof(ads.attributes->$%d::text) = 'number' then (ads.attributes->>$%d::text)::numeric end)", argId, argId)
+		if filter.Min != nil && filter.Max != nil {
+			conditions = append(conditions, fmt.Sprintf("%s between $%d and $%d", number, argId+1, argId+2))
+			args = append(args, filter.Name, *filter.Min, *filter.Max)
+			argId += 3
+		} else if filter.Min != nil {
+			conditions = append(conditions, fmt.Sprintf("%s >= $%d", number, argId+1))
+			args = append(args, filter.Name, *filter.Min)
+			argId += 2
+		} else if filter.Max != nil {
+			conditions = append(conditions, fmt.Sprintf("%s <= $%d", number, argId+1))
+			args = append(args, filter.Name, *filter.Max)
+			argId += 2
+		}
+	}
+
+	return conditions, args
+}
+
+var adsSortOrders = map[string]string{
+	SortNewest:    "ads.created_at desc, ads.id desc",
+	SortOldest:    "ads.created_at asc, ads.id asc",
+	SortPriceAsc:  "ads.price asc, ads.id desc",
+	SortPriceDesc: "ads.price desc, ads.id desc",
+}
+
+// GetSearchFacets counts ads found by the request per category, location and price bucket in a single query.
+func (r *AdRepository) GetSearchFacets(search_request string, filter AdsFilter) (SearchFacets, error) {
+	fromQuery, whereQuery, args := searchConditions(search_request, filter)
+
+	var rows []struct {
+		Facet     string `db:"facet"`
+		Value     string `db:"value"`
+		PriceFrom int    `db:"price_from"`
+		PriceTo   int    `db:"price_to"`
+		Count     int    `db:"count"`
+	}
+	query := fmt.Sprintf(`with matched as (select ads.category_id, %s.location, ads.price from %s where %s), 
+		bounds as (select min(price) as min_price, greatest(ceil((max(price) - min(price) + 1) / %d.0)::int, 1) as step from matched) 
+		select 'category' as facet, category_id::text as value, 0 as price_from, 0 as price_to, count(*) as count from matched group by category_id 
+		union all 
+		select 'location', min(location), 0, 0, count(*) from matched group by lower(location) 
+		union all 
+		select 'price', '', min_price + (price - min_price) / step * step, min_price + ((price - min_price) / step + 1) * step - 1, count(*) 
+		from matched, bounds group by 3, 4`,
+		database.ContactsInfoTable, fromQuery, whereQuery, priceFacetBuckets)
+	if err := r.db.Select(&rows, query, args...); err != nil {
+		return SearchFacets{}, err
+	}
+
+	facets := SearchFacets{Locations: make([]LocationFacet, 0), Prices: make([]PriceFacet, 0)}
+	categoryCounts := make(map[int]int)
+	for _, row := range rows {
+		switch row.Facet {
+		case "category":
+			id, err := strconv.Atoi(row.Value)
+			if err != nil {
+				return SearchFacets{}, err
+			}
+			categoryCounts[id] = row.Count
+		case "location":
+			facets.Locations = append(facets.Locations, LocationFacet{Location: row.Value, Count: row.Count})
+		case "price":
+			facets.Prices = append(facets.Prices, PriceFacet{From: row.PriceFrom, To: row.PriceTo, Count: row.Count})
+		}
+	}
+
+	categories, err := r.tree.rollUp(categoryCounts)
+	if err != nil {
+		return SearchFacets{}, err
+	}
+	facets.Categories = categories
+
+	sort.Slice(facets.Locations, func(i, j int) bool {
+		if facets.Locations[i].Count != facets.Locations[j].Count {
+			return facets.Locations[i].Count > facets.Locations[j].Count
+		}
+		return facets.Locations[i].Location < facets.Locations[j].Location
+	})
+	sort.Slice(facets.Prices, func(i, j int) bool {
+		return facets.Prices[i].From < facets.Prices[j].From
+	})
+
+	return facets, nil
+}
 
-	query := fmt.Sprintf("select id, ts_headline(title, q) as title from %s, plainto_tsquery('russian', $1) as q where make_tsvector(title, description) @@ q order by ts_rank(make_tsvector(title, description), q) desc", database.AdsTable)
-	if err := r.db.Select(&res, query, search_request); err != nil {
+// SuggestSearch returns titles of published ads and categories starting with the query or similar to it,
+// matches of the prefix go first.
+func (r *AdRepository) SuggestSearch(ctx context.Context, query string, limit int) ([]Suggestion, error) {
+	query = strings.ToLower(query)
+	prefix := likeEscaper.Replace(query) + "%"
+
+	res := make([]Suggestion, 0)
+	sqlQuery := fmt.Sprintf(`select text, kind, category_id, score from (
+		select distinct on (lower(title)) title as text, 'ad' as kind, null::int as category_id, 
+		(lower(title) like $2)::int + similarity(lower(title), $1) as score 
+		from %s where status=$3 and (lower(title) like $2 or lower(title) %% $1) 
+		order by lower(title), created_at desc) as titles 
+		union all 
+		select category, 'category', id, (lower(category) like $2)::int + similarity(lower(category), $1) 
+		from %s where lower(category) like $2 or lower(category) %% $1 
+		order by score desc, text limit $4`,
+		database.AdsTable, database.CategoriesTable)
+	if err := r.db.SelectContext(ctx, &res, sqlQuery, query, prefix, domain.AdStatusApproved, limit); err != nil {
 		return nil, err
 	}
 
+	for i := range res {
+		if res[i].CategoryId == nil {
+			continue
+		}
+		path, err := r.tree.Path(strconv.Itoa(*res[i].CategoryId))
+		if err != nil {
+			return nil, err
+		}
+		res[i].Text = path
+	}
+
 	return res, nil
 }
+
+// CorrectSearchWord returns the word of titles of published ads closest to the given one,
+// sql.ErrNoRows means there is no similar word.
+func (r *AdRepository) CorrectSearchWord(ctx context.Context, word string) (string, error) {
+	var res string
+	query := fmt.Sprintf(`select word from (
+		select distinct regexp_split_to_table(lower(title), '[^[:alnum:]]+') as word 
+		from %s where status=$2 and $1 <%% lower(title)) as words 
+		where word <> '' and word %% $1 order by similarity(word, $1) desc, word limit 1`, database.AdsTable)
+	if err := r.db.GetContext(ctx, &res, query, strings.ToLower(word), domain.AdStatusApproved); err != nil {
+		return "", err
+	}
+
+	return res, nil
+}
+
+func (r *AdRepository) GetPublishedAds(filter AdsFilter) ([]domain.Ad, int, error) {
+	whereValues, args := publishedAdsConditions(filter, nil)
+	argId := len(args) + 1
+
+	fromQuery := fmt.Sprintf("%s join %s on %s.id = ads.contacts_id", database.AdsTable, database.ContactsInfoTable, database.ContactsInfoTable)
+	whereQuery := strings.Join(whereValues, " and ")
+
+	var total int
+	query := fmt.Sprintf("select count(*) from %s where %s", fromQuery, whereQuery)
+	if err := r.db.Get(&total, query, args...); err != nil {
+		return nil, 0, err
+	}
+
+	orderQuery, ok := adsSortOrders[filter.Sort]
+	if !ok {
+		orderQuery = adsSortOrders[SortNewest]
+	}
+
+	ads := make([]domain.Ad, 0)
+	query = fmt.Sprintf("select %s from %s where %s order by %s limit $%d offset $%d", adColumns, fromQuery, whereQuery, orderQuery, argId, argId+1)
+	args = append(args, filter.Limit, filter.Offset)
+	if err := r.db.Select(&ads, query, args...); err != nil {
+		return nil, 0, err
+	}
+
+	if err := r.tree.setPaths(ads); err != nil {
+		return nil, 0, err
+	}
+
+	return ads, total, nil
+}
+
+func (r *AdRepository) SetAdStatus(userId, adId string, from, to domain.AdStatus) error {
+	query := fmt.Sprintf("update %s set status=$1 where id=$2 and userid=$3 and status=$4", database.AdsTable)
+	res, err := r.db.Exec(query, to, adId, userId, from)
+	if err != nil {
+		return err
+	}
+
+	return checkStatusChanged(res)
+}
+
+// publishedAdsConditions builds conditions of the filter on published ads joined with their contacts,
+// their arguments are appended to args.
+func publishedAdsConditions(filter AdsFilter, args []interface{}) ([]string, []interface{}) {
+	whereValues := []string{fmt.Sprintf("ads.status=$%d", len(args)+1)}
+	args = append(args, domain.AdStatusApproved)
+	argId := len(args) + 1
+
+	if filter.CategoryId != 0 {
+		whereValues = append(whereValues, categoryCondition(argId))
+		args = append(args, filter.CategoryId)
+		argId++
+	}
+
+	conditions, conditionArgs := attributeConditions(filter.Attributes, argId)
+	whereValues = append(whereValues, conditions...)
+	args = append(args, conditionArgs...)
+	argId += len(conditionArgs)
+
+	if filter.MinPrice != nil {
+		whereValues = append(whereValues, fmt.Sprintf("ads.price>=$%d", argId))
+		args = append(args, *filter.MinPrice)
+		argId++
+	}
+
+	if filter.MaxPrice != nil {
+		whereValues = append(whereValues, fmt.Sprintf("ads.price<=$%d", argId))
+		args = append(args, *filter.MaxPrice)
+		argId++
+	}
+
+	if filter.Location != "" {
+		whereValues = append(whereValues, fmt.Sprintf("lower(%s.location)=lower($%d)", database.ContactsInfoTable, argId))
+		args = append(args, filter.Location)
+	}
+
+	return whereValues, args
+}
+
+// searchConditions builds the from and where clauses of published ads matching the request in $1 and the filter.
+func searchConditions(search_request string, filter AdsFilter) (string, string, []interface{}) {
+	args := []interface{}{search_request}
+	whereValues, args := publishedAdsConditions(filter, args)
+	whereValues = append(whereValues, "ads.search_vector @@ q")
+
+	if filter.Language != "" {
+		whereValues = append(whereValues, fmt.Sprintf("ads.language=$%d", len(args)+1))
+		args = append(args, filter.Language)
+	}
+
+	fromQuery := fmt.Sprintf("%s join %s on %s.id = ads.contacts_id, %s",
+		database.AdsTable, database.ContactsInfoTable, database.ContactsInfoTable, searchTsQuery(filter.Language))
+
+	return fromQuery, strings.Join(whereValues, " and "), args
+}
+
+// searchTsQuery selects q parsed from the request in $1 with the configuration of the language,
+// a request of any language is parsed with every configuration as ads are indexed with their own one.
+func searchTsQuery(language string) string {
+	queries := make([]string, 0, len(domain.Languages))
+	for _, l := range domain.Languages {
+		if language == "" || language == l {
+			queries = append(queries, fmt.Sprintf("plainto_tsquery('%s', $1)", l))
+		}
+	}
+
+	return fmt.Sprintf("(select %s as q) as search", strings.Join(queries, " || "))
+}
+
+func createContacts(tx *sql.Tx, contacts Contacts) (int, error) {
+	var id int
+
+	query := fmt.Sprintf("insert into %s (name, phone_number, email, location) values ($1, $2, $3, $4) returning id", database.ContactsInfoTable)
+	if err := tx.QueryRow(query, contacts.Name, contacts.Phone_number, contacts.Email, contacts.Location).Scan(&id); err != nil {
+		return 0, err
+	}
+
+	return id, nil
+}
+
+// setAdContacts returns the contacts the ad has to point to. Own contacts of the ad are updated in place, while
+// a contact profile is shared with other ads, so the ad gets its own contacts unless they are the same as the profile ones.
+func setAdContacts(tx *sql.Tx, contactsId, profileId int, contacts Contacts) (int, error) {
+	if profileId != 0 {
+		return profileId, nil
+	}
+
+	var (
+		current Contacts
+		profile bool
+	)
+	query := fmt.Sprintf("select name, phone_number, email, location, user_id is not null from %s where id=$1", database.ContactsInfoTable)
+	if err := tx.QueryRow(query, contactsId).Scan(&current.Name, &current.Phone_number, &current.Email, &current.Location, &profile); err != nil {
+		return 0, notFound(err)
+	}
+
+	if profile {
+		if current == contacts {
+			return contactsId, nil
+		}
+		return createContacts(tx, contacts)
+	}
+
+	query = fmt.Sprintf("update %s set name=$1, phone_number=$2, email=$3, location=$4 where id=$5", database.ContactsInfoTable)
+	if _, err := tx.Exec(query, contacts.Name, contacts.Phone_number, contacts.Email, contacts.Location, contactsId); err != nil {
+		return 0, err
+	}
+
+	return contactsId, nil
+}
+
+// deleteUnusedContacts deletes own contacts of an ad that no longer points to them, profiles are kept.
+// deleteAd deletes the ad matching the condition with its own contacts, a missing ad is reported as ErrNotFound.
+func deleteAd(db *sqlx.DB, condition string, args ...interface{}) error {
+	tx, err := db.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	var contactsId int
+	query := fmt.Sprintf("delete from %s where %s returning contacts_id", database.AdsTable, condition)
+	if err := tx.QueryRow(query, args...).Scan(&contactsId); err != nil {
+		return notFound(err)
+	}
+
+	if err := deleteUnusedContacts(tx, contactsId); err != nil {
+		return err
+	}
+
+	return tx.Commit()
+}
+
+func deleteUnusedContacts(tx *sql.Tx, contactsId int) error {
+	query := fmt.Sprintf("delete from %s where id=$1 and user_id is null and not exists (select 1 from %s where contacts_id = $1)",
+		database.ContactsInfoTable, database.AdsTable)
+	_, err := tx.Exec(query, contactsId)
+
+	return err
+}
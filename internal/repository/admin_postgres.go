@@ -6,67 +6,143 @@ import (
 	"github.com/TakoB222/postingAds-api/pkg/database"
 	"github.com/jmoiron/sqlx"
 	"github.com/lib/pq"
-	"strconv"
 	"strings"
 )
 
 type AdminRepository struct {
-	db *sqlx.DB
+	db   *sqlx.DB
+	tree *CategoryTree
 }
 
-func NewAdminRepository(db *sqlx.DB) *AdminRepository {
-	return &AdminRepository{db: db}
+func NewAdminRepository(db *sqlx.DB, tree *CategoryTree) *AdminRepository {
+	return &AdminRepository{db: db, tree: tree}
 }
 
-func (r *AdminRepository) GetAdminId(email, password_hash string) (string, error) {
-	var id int
+func (r *AdminRepository) GetAdminByLogin(login string) (domain.Admin, error) {
+	var admin domain.Admin
 
-	query := fmt.Sprintf("select id from %s where login=$1 and password_hash=$2", database.AdminsTable)
-	if err := r.db.Get(&id, query, email, password_hash); err != nil {
-		return "", err
+	query := fmt.Sprintf("select id, login, password_hash, totp_enabled from %s where login=$1", database.AdminsTable)
+	if err := r.db.Get(&admin, query, login); err != nil {
+		return domain.Admin{}, notFound(err)
 	}
 
-	return strconv.Itoa(id), nil
+	return admin, nil
+}
+
+func (r *AdminRepository) UpdateAdminPasswordHash(adminId, passwordHash string) error {
+	query := fmt.Sprintf("update %s set password_hash=$1 where id=$2", database.AdminsTable)
+	if _, err := r.db.Exec(query, passwordHash, adminId); err != nil {
+		return err
+	}
+
+	return nil
 }
 
 func (r *AdminRepository) SetAdminSession(session domain.AdminSession) error {
-	var countAdminSessions []string
-	query := fmt.Sprintf("select id from %s where adminid=$1", database.AdminRefreshSessionTable)
-	if err := r.db.Select(&countAdminSessions, query, session.AdminId); err != nil {
+	tx, err := r.db.Begin()
+	if err != nil {
 		return err
 	}
 
-	fmt.Println(len(countAdminSessions))
+	query := fmt.Sprintf("insert into %s (adminid, refreshtoken, expiresin, createdat, lastusedat, useragent, ip) values ($1, $2, $3, $4, $5, $6, $7)", database.AdminRefreshSessionTable)
+	if _, err := tx.Exec(query, session.AdminId, session.RefreshToken, session.ExpiresIn, session.CreatedAt, session.LastUsedAt, session.UserAgent, session.Ip); err != nil {
+		err := tx.Rollback()
+		if err != nil {
+			return err
+		}
+		return err
+	}
 
-	if len(countAdminSessions) >= 1 {
-		tx, err := r.db.Begin()
+	query = fmt.Sprintf("delete from %s where adminid=$1 and id not in (select id from %s where adminid=$1 order by lastusedat desc, id desc limit $2)",
+		database.AdminRefreshSessionTable, database.AdminRefreshSessionTable)
+	if _, err := tx.Exec(query, session.AdminId, maxAdminSessions); err != nil {
+		err := tx.Rollback()
 		if err != nil {
 			return err
 		}
+		return err
+	}
+
+	return tx.Commit()
+}
 
-		query = fmt.Sprintf("delete from %s where adminid=$1", database.AdminRefreshSessionTable)
-		if _, err := tx.Exec(query, session.AdminId); err != nil {
-			err := tx.Rollback()
-			if err != nil {
-				return err
-			}
+func (r *AdminRepository) RotateAdminSession(session domain.AdminSession, usedRefreshToken string) error {
+	tx, err := r.db.Begin()
+	if err != nil {
+		return err
+	}
+
+	query := fmt.Sprintf("insert into %s (refreshtoken, sessionid, rotatedat) values ($1, $2, $3)", database.UsedAdminRefreshTokensTable)
+	if _, err := tx.Exec(query, usedRefreshToken, session.Id, session.LastUsedAt); err != nil {
+		err := tx.Rollback()
+		if err != nil {
 			return err
 		}
+		return err
+	}
 
-		query = fmt.Sprintf("insert into %s (adminid, refreshtoken, expiresin, createdat) values ($1, $2, $3, $4)", database.AdminRefreshSessionTable)
-		if _, err := tx.Exec(query, session.AdminId, session.RefreshToken, session.ExpiresIn, session.CreatedAt); err != nil {
-			err := tx.Rollback()
-			if err != nil {
-				return err
-			}
+	query = fmt.Sprintf("update %s set refreshtoken=$1, expiresin=$2, lastusedat=$3, useragent=$4, ip=$5 where id=$6 and refreshtoken=$7", database.AdminRefreshSessionTable)
+	res, err := tx.Exec(query, session.RefreshToken, session.ExpiresIn, session.LastUsedAt, session.UserAgent, session.Ip, session.Id, usedRefreshToken)
+	if err == nil {
+		err = checkAffected(res)
+	}
+	if err != nil {
+		err := tx.Rollback()
+		if err != nil {
 			return err
 		}
+		return err
+	}
+
+	return tx.Commit()
+}
+
+func (r *AdminRepository) GetAdminSessionByUsedRefreshToken(refreshToken string) (domain.AdminSession, error) {
+	var session domain.AdminSession
 
-		return tx.Commit()
+	query := fmt.Sprintf("select s.* from %s s join %s u on u.sessionid = s.id where u.refreshtoken=$1",
+		database.AdminRefreshSessionTable, database.UsedAdminRefreshTokensTable)
+	if err := r.db.Get(&session, query, refreshToken); err != nil {
+		return domain.AdminSession{}, notFound(err)
 	}
 
-	query = fmt.Sprintf("insert into %s (adminid, refreshtoken, expiresin, createdat) values ($1, $2, $3, $4)", database.AdminRefreshSessionTable)
-	if _, err := r.db.Exec(query, session.AdminId, session.RefreshToken, session.ExpiresIn, session.CreatedAt); err != nil {
+	return session, nil
+}
+
+func (r *AdminRepository) GetAdminSessionsByAdminId(adminId string) ([]domain.AdminSession, error) {
+	sessions := make([]domain.AdminSession, 0)
+
+	query := fmt.Sprintf("select * from %s where adminid=$1 order by lastusedat desc", database.AdminRefreshSessionTable)
+	if err := r.db.Select(&sessions, query, adminId); err != nil {
+		return nil, err
+	}
+
+	return sessions, nil
+}
+
+func (r *AdminRepository) DeleteAdminSession(adminId, sessionId string) error {
+	query := fmt.Sprintf("delete from %s where id=$1 and adminid=$2", database.AdminRefreshSessionTable)
+	res, err := r.db.Exec(query, sessionId, adminId)
+	if err != nil {
+		return err
+	}
+
+	return checkAffected(res)
+}
+
+func (r *AdminRepository) DeleteAdminSessionByRefreshToken(adminId, refreshToken string) error {
+	query := fmt.Sprintf("delete from %s where refreshtoken=$1 and adminid=$2", database.AdminRefreshSessionTable)
+	res, err := r.db.Exec(query, refreshToken, adminId)
+	if err != nil {
+		return err
+	}
+
+	return checkAffected(res)
+}
+
+func (r *AdminRepository) DeleteOtherAdminSessions(adminId, refreshToken string) error {
+	query := fmt.Sprintf("delete from %s where adminid=$1 and refreshtoken<>$2", database.AdminRefreshSessionTable)
+	if _, err := r.db.Exec(query, adminId, refreshToken); err != nil {
 		return err
 	}
 
@@ -77,7 +153,7 @@ func (r *AdminRepository) GetAdminSessionByRefreshToken(refrehsToken string) (do
 	var session domain.AdminSession
 	query := fmt.Sprintf("select * from %s where refreshtoken=$1", database.AdminRefreshSessionTable)
 	if err := r.db.Get(&session, query, refrehsToken); err != nil {
-		return domain.AdminSession{}, err
+		return domain.AdminSession{}, notFound(err)
 	}
 
 	return session, nil
@@ -95,51 +171,13 @@ func (r *AdminRepository) DeleteAdminSessionByAdminId(adminId string) error {
 func (r *AdminRepository) GetAllAdsByAdmin() ([]domain.Ad, error) {
 	var ads []domain.Ad
 
-	tx, err := r.db.Begin()
-	if err != nil {
-		return []domain.Ad{}, err
-	}
-
-	query := fmt.Sprintf("select * from %s", database.AdsTable)
+	query := fmt.Sprintf("select %s from %s", adColumns, database.AdsTable)
 	if err := r.db.Select(&ads, query); err != nil {
 		return nil, err
 	}
 
-	for i := 0; i < len(ads); i++ {
-		categoryId, err := strconv.Atoi(ads[i].Category)
-		if err != nil {
-			err := tx.Rollback()
-			if err != nil {
-				return []domain.Ad{}, err
-			}
-			return []domain.Ad{}, err
-		}
-
-		var categorySeq []string
-		query = fmt.Sprintf(`with recursive r as (select id, parent_category, category from categories where id=$1 
-								union 
-								select categories.id, categories.parent_category, categories.category from %s join r on categories.id = r.parent_category) 
-								select category from r;`, database.CategoriesTable)
-		if err := r.db.Select(&categorySeq, query, categoryId); err != nil {
-			err := tx.Rollback()
-			if err != nil {
-				return []domain.Ad{}, err
-			}
-			return []domain.Ad{}, err
-		}
-
-		ads[i].Category = func(categories []string) string{
-			for i := 0; i < len(categories)/2; i++ {
-				tmp := categories[i]
-				categories[i] = categories[len(categories)-1-i]
-				categories[len(categories)-1-i] = tmp
-			}
-			return strings.Join(categories, "/")
-		}(categorySeq)
-	}
-
-	if err = tx.Commit(); err != nil {
-		return []domain.Ad{}, err
+	if err := r.tree.setPaths(ads); err != nil {
+		return nil, err
 	}
 
 	return ads, nil
@@ -148,80 +186,35 @@ func (r *AdminRepository) GetAllAdsByAdmin() ([]domain.Ad, error) {
 func (r *AdminRepository) GetAd(adId string) (domain.Ad, error) {
 	var ad domain.Ad
 
-	tx, err := r.db.Begin()
-	if err != nil {
-		return domain.Ad{}, err
-	}
-
-	query := fmt.Sprintf("select * from %s where id=$1", database.AdsTable)
+	query := fmt.Sprintf("select %s from %s where id=$1", adColumns, database.AdsTable)
 	if err := r.db.Get(&ad, query, adId); err != nil {
-		return domain.Ad{}, err
+		return domain.Ad{}, notFound(err)
 	}
 
-	categoryId, err := strconv.Atoi(ad.Category)
+	category, err := r.tree.Path(ad.Category)
 	if err != nil {
-		err := tx.Rollback()
-		if err != nil {
-			return domain.Ad{}, err
-		}
-		return domain.Ad{}, err
-	}
-
-	var categorySeq []string
-	query = fmt.Sprintf(`with recursive r as (select id, parent_category, category from categories where id=$1 
-								union 
-								select categories.id, categories.parent_category, categories.category from %s join r on categories.id = r.parent_category) 
-								select category from r;`, database.CategoriesTable)
-	if err := r.db.Select(&categorySeq, query, categoryId); err != nil {
-		err := tx.Rollback()
-		if err != nil {
-			return domain.Ad{}, err
-		}
-		return domain.Ad{}, err
-	}
-
-	ad.Category = func(categories []string) string{
-		for i := 0; i < len(categories)/2; i++ {
-			tmp := categories[i]
-			categories[i] = categories[len(categories)-1-i]
-			categories[len(categories)-1-i] = tmp
-		}
-		return strings.Join(categories, "/")
-	}(categorySeq)
-
-	if err = tx.Commit(); err != nil {
 		return domain.Ad{}, err
 	}
+	ad.Category = category
 
 	return ad, nil
 }
 
 func (r *AdminRepository) AdminDeleteAd(adId string) error {
-	query := fmt.Sprintf("delete from %s where id=$1", database.AdsTable)
-	if _, err := r.db.Exec(query, adId); err != nil {
-		return err
-	}
-
-	return nil
+	return deleteAd(r.db, "id=$1", adId)
 }
 
 func (r *AdminRepository) AdminUpdateAd(adId string, ad Ads) error {
-	var categoryId int
-	query := fmt.Sprintf("select id from %s where category=$1", database.CategoriesTable)
-	if err := r.db.Get(&categoryId, query, ad.Category); err != nil {
-		return err
-	}
-
 	var userId string
-	query = fmt.Sprintf("select userid from %s where id=$1", database.AdsTable)
+	query := fmt.Sprintf("select userid from %s where id=$1", database.AdsTable)
 	if err := r.db.Get(&userId, query, adId); err != nil {
-		return err
+		return notFound(err)
 	}
 
 	var contactsId int
 	query = fmt.Sprintf("select contacts_id from %s where id=$1 and userid=$2", database.AdsTable)
 	if err := r.db.Get(&contactsId, query, adId, userId); err != nil {
-		return err
+		return notFound(err)
 	}
 
 	setValues := make([]string, 0)
@@ -236,7 +229,7 @@ func (r *AdminRepository) AdminUpdateAd(adId string, ad Ads) error {
 
 	if ad.Category != "" {
 		setValues = append(setValues, fmt.Sprintf("category_id=$%d", argId))
-		args = append(args, categoryId)
+		args = append(args, ad.Category)
 		argId++
 	}
 
@@ -247,7 +240,9 @@ func (r *AdminRepository) AdminUpdateAd(adId string, ad Ads) error {
 	}
 
 	if ad.Price >= 0 {
-		setValues = append(setValues, fmt.Sprintf("price=$%d", argId))
+		// an approved ad stays approved after an edit of a moderator, so its new price is approved at once
+		setValues = append(setValues, fmt.Sprintf("price=$%d", argId),
+			fmt.Sprintf("approved_price=case when status='approved' then $%d else approved_price end", argId))
 		args = append(args, ad.Price)
 		argId++
 	}
@@ -258,9 +253,23 @@ func (r *AdminRepository) AdminUpdateAd(adId string, ad Ads) error {
 		argId++
 	}
 
-	setValues = append(setValues, fmt.Sprintf("published=$%d", argId))
-	args = append(args, ad.Published)
-	argId++
+	if ad.Attributes != nil {
+		setValues = append(setValues, fmt.Sprintf("attributes=$%d", argId))
+		args = append(args, ad.Attributes)
+		argId++
+	}
+
+	if ad.Language != "" {
+		setValues = append(setValues, fmt.Sprintf("language=$%d", argId))
+		args = append(args, ad.Language)
+		argId++
+	}
+
+	if ad.Status != "" {
+		setValues = append(setValues, fmt.Sprintf("status=$%d", argId), "rejection_reason=''")
+		args = append(args, ad.Status)
+		argId++
+	}
 
 	tx, err := r.db.Begin()
 	if err != nil {
@@ -268,14 +277,14 @@ func (r *AdminRepository) AdminUpdateAd(adId string, ad Ads) error {
 		return err
 	}
 
-	query = fmt.Sprintf("update %s set name=$1, phone_number=$2, email=$3, location=$4 where id=$5", database.ContactsInfoTable)
-	if _, err := tx.Exec(query, ad.Contacts.Name, ad.Contacts.Phone_number, ad.Contacts.Email, ad.Contacts.Location, contactsId); err != nil {
+	newContactsId, err := setAdContacts(tx, contactsId, 0, ad.Contacts)
+	if err != nil {
 		tx.Rollback()
 		return err
 	}
 
 	setValues = append(setValues, fmt.Sprintf("contacts_id=$%d", argId))
-	args = append(args, contactsId)
+	args = append(args, newContactsId)
 	argId++
 
 	setQuery := strings.Join(setValues, ", ")
@@ -285,10 +294,81 @@ func (r *AdminRepository) AdminUpdateAd(adId string, ad Ads) error {
 	args = append(args, adId, userId)
 	fmt.Println(query)
 
-	if _, err = r.db.Exec(query, args...); err != nil {
+	if _, err = tx.Exec(query, args...); err != nil {
 		tx.Rollback()
 		return err
 	}
 
+	if err := deleteUnusedContacts(tx, contactsId); err != nil {
+		tx.Rollback()
+		return err
+	}
+
+	return tx.Commit()
+}
+
+func (r *AdminRepository) GetAdsByStatus(status domain.AdStatus) ([]domain.Ad, error) {
+	ads := make([]domain.Ad, 0)
+
+	query := fmt.Sprintf("select %s from %s where status=$1 order by created_at asc, id asc", adColumns, database.AdsTable)
+	if err := r.db.Select(&ads, query, status); err != nil {
+		return nil, err
+	}
+
+	if err := r.tree.setPaths(ads); err != nil {
+		return nil, err
+	}
+
+	return ads, nil
+}
+
+func (r *AdminRepository) AdminSetAdStatus(adId string, from, to domain.AdStatus, reason string) error {
+	// saved searches pick up ads by the time they were published
+	setPublished := ""
+	if to == domain.AdStatusApproved {
+		setPublished = ", published_at=now(), approved_price=price"
+	}
+
+	query := fmt.Sprintf("update %s set status=$1, rejection_reason=$2%s where id=$3 and status=$4", database.AdsTable, setPublished)
+	res, err := r.db.Exec(query, to, reason, adId, from)
+	if err != nil {
+		return err
+	}
+
+	return checkStatusChanged(res)
+}
+
+// BanUser marks the user banned and deletes their sessions, so they can not refresh tokens any more.
+func (r *AdminRepository) BanUser(userId, reason string) error {
+	tx, err := r.db.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	query := fmt.Sprintf("update %s set banned_at=now(), ban_reason=$1 where id=$2", database.UsersTable)
+	res, err := tx.Exec(query, reason, userId)
+	if err != nil {
+		return err
+	}
+	if err := checkAffected(res); err != nil {
+		return err
+	}
+
+	query = fmt.Sprintf("delete from %s where userId=$1", database.RefreshSessionsTable)
+	if _, err := tx.Exec(query, userId); err != nil {
+		return err
+	}
+
 	return tx.Commit()
 }
+
+func (r *AdminRepository) UnbanUser(userId string) error {
+	query := fmt.Sprintf("update %s set banned_at=null, ban_reason='' where id=$1", database.UsersTable)
+	res, err := r.db.Exec(query, userId)
+	if err != nil {
+		return err
+	}
+
+	return checkAffected(res)
+}
@@ -1,10 +1,14 @@
 package repository
 
 import (
+	"database/sql"
+	"errors"
 	"fmt"
 	"github.com/TakoB222/postingAds-api/internal/domain"
 	"github.com/TakoB222/postingAds-api/pkg/database"
 	"github.com/jmoiron/sqlx"
+	"github.com/lib/pq"
+	"time"
 )
 
 type AuthRepository struct {
@@ -22,25 +26,134 @@ func (r *AuthRepository) CreateUser(user domain.User) (int, error) {
 	row := r.db.QueryRow(query, user.Email, user.Password_hash, user.First_name, user.Last_name, user.Registered_at)
 
 	if err := row.Scan(&id); err != nil {
+		var pqErr *pq.Error
+		if errors.As(err, &pqErr) && pqErr.Code == uniqueViolation {
+			return 0, ErrUserExists
+		}
 		return 0, err
 	}
 
 	return id, nil
 }
 
-func (r *AuthRepository) GetUser(email, password_hash string) (domain.User, error) {
+func (r *AuthRepository) GetUserByEmail(email string) (domain.User, error) {
 	var user domain.User
 
-	query := fmt.Sprintf("Select id from %s where email=$1 and password_hash=$2", database.UsersTable)
+	query := fmt.Sprintf("Select id, email, first_name, password_hash, email_verified, totp_enabled, banned_at from %s where email=$1", database.UsersTable)
 
-	err := r.db.Get(&user, query, email, password_hash)
+	err := r.db.Get(&user, query, email)
 	if err != nil {
-		return domain.User{}, err
+		return domain.User{}, notFound(err)
 	}
 
 	return user, err
 }
 
+func (r *AuthRepository) UpdatePasswordHash(userId, passwordHash string) error {
+	query := fmt.Sprintf("update %s set password_hash=$1 where id=$2", database.UsersTable)
+
+	_, err := r.db.Exec(query, passwordHash, userId)
+	return err
+}
+
+// SetUserToken stores a new token and drops unused ones of the same purpose, so only the latest email works.
+// It fails with ErrUserTokenCooldown while an unused token of the purpose issued after cooldownSince has not expired.
+func (r *AuthRepository) SetUserToken(token domain.UserToken, cooldownSince time.Time) error {
+	tx, err := r.db.Begin()
+	if err != nil {
+		return err
+	}
+
+	// the row of the user serializes tokens of the user, so parallel requests can not both pass the cooldown
+	query := fmt.Sprintf("select exists (select 1 from %s where userid=$1 and purpose=$2 and usedat is null and expiresat > $3 and createdat > $4) from %s where id=$1 for update",
+		database.UserTokensTable, database.UsersTable)
+	var issued bool
+	if err := tx.QueryRow(query, token.UserId, token.Purpose, token.CreatedAt, cooldownSince).Scan(&issued); err != nil {
+		_ = tx.Rollback()
+		return notFound(err)
+	}
+	if issued {
+		_ = tx.Rollback()
+		return ErrUserTokenCooldown
+	}
+
+	query = fmt.Sprintf("delete from %s where userid=$1 and purpose=$2 and usedat is null", database.UserTokensTable)
+	if _, err := tx.Exec(query, token.UserId, token.Purpose); err != nil {
+		_ = tx.Rollback()
+		return err
+	}
+
+	query = fmt.Sprintf("insert into %s (userid, purpose, tokenhash, expiresat, createdat) values ($1, $2, $3, $4, $5)", database.UserTokensTable)
+	if _, err := tx.Exec(query, token.UserId, token.Purpose, token.TokenHash, token.ExpiresAt, token.CreatedAt); err != nil {
+		_ = tx.Rollback()
+		return err
+	}
+
+	return tx.Commit()
+}
+
+func (r *AuthRepository) VerifyEmail(tokenHash string, now time.Time) error {
+	tx, err := r.db.Begin()
+	if err != nil {
+		return err
+	}
+
+	userId, err := useUserToken(tx, tokenHash, domain.UserTokenEmailVerification, now)
+	if err != nil {
+		_ = tx.Rollback()
+		return err
+	}
+
+	query := fmt.Sprintf("update %s set email_verified=true where id=$1", database.UsersTable)
+	if _, err := tx.Exec(query, userId); err != nil {
+		_ = tx.Rollback()
+		return err
+	}
+
+	return tx.Commit()
+}
+
+// ResetPassword sets the new password hash and signs the user out of every device.
+func (r *AuthRepository) ResetPassword(tokenHash, passwordHash string, now time.Time) error {
+	tx, err := r.db.Begin()
+	if err != nil {
+		return err
+	}
+
+	userId, err := useUserToken(tx, tokenHash, domain.UserTokenPasswordReset, now)
+	if err != nil {
+		_ = tx.Rollback()
+		return err
+	}
+
+	// the reset link proves the ownership of the email as well
+	query := fmt.Sprintf("update %s set password_hash=$1, email_verified=true where id=$2", database.UsersTable)
+	if _, err := tx.Exec(query, passwordHash, userId); err != nil {
+		_ = tx.Rollback()
+		return err
+	}
+
+	query = fmt.Sprintf("delete from %s where userid=$1", database.RefreshSessionsTable)
+	if _, err := tx.Exec(query, userId); err != nil {
+		_ = tx.Rollback()
+		return err
+	}
+
+	return tx.Commit()
+}
+
+// useUserToken marks an unused and unexpired token as used, sql.ErrNoRows is returned for any other one.
+func useUserToken(tx *sql.Tx, tokenHash, purpose string, now time.Time) (string, error) {
+	var userId string
+
+	query := fmt.Sprintf("update %s set usedat=$1 where tokenhash=$2 and purpose=$3 and usedat is null and expiresat > $1 returning userid", database.UserTokensTable)
+	if err := tx.QueryRow(query, now, tokenHash, purpose).Scan(&userId); err != nil {
+		return "", notFound(err)
+	}
+
+	return userId, nil
+}
+
 func (r *AuthRepository) GetSessionByRefreshToken(refreshToken string) (domain.Session, error) {
 	//TODO: if ua and ip wrong, what then...
 	var session domain.Session
@@ -49,7 +162,7 @@ func (r *AuthRepository) GetSessionByRefreshToken(refreshToken string) (domain.S
 
 	err := r.db.Get(&session, query, refreshToken)
 	if err != nil {
-		return domain.Session{}, err
+		return domain.Session{}, notFound(err)
 	}
 	return session, nil
 }
@@ -65,46 +178,126 @@ func (r *AuthRepository) DeleteSessionByUserId(userId string) error {
 	return nil
 }
 
-func (r *AuthRepository) SetSession(session domain.Session) error {
-	var countUserSessions []string
-	query := fmt.Sprintf("select id from %s where userId=$1", database.RefreshSessionsTable)
-	if err := r.db.Select(&countUserSessions, query, session.UserId); err != nil {
-		return err
+func (r *AuthRepository) SetSession(session domain.Session) (string, error) {
+	tx, err := r.db.Begin()
+	if err != nil {
+		return "", err
 	}
 
-	fmt.Println(len(countUserSessions))
+	var id string
+	query := fmt.Sprintf("Insert into %s (userId, refreshToken, expiresIn, createdAt, lastUsedAt, userAgent, ip) values ($1,$2,$3,$4,$5,$6,$7) returning id", database.RefreshSessionsTable)
+	if err := tx.QueryRow(query, session.UserId, session.RefreshToken, session.ExpiresIn, session.CreatedAt, session.LastUsedAt, session.UserAgent, session.Ip).Scan(&id); err != nil {
+		err := tx.Rollback()
+		if err != nil {
+			return "", err
+		}
+		return "", err
+	}
 
-	if len(countUserSessions) >= 3 {
-		tx, err := r.db.Begin()
+	// only the most recently used sessions are kept, the rest devices have to sign in again
+	query = fmt.Sprintf("delete from %s where userId=$1 and id not in (select id from %s where userId=$1 order by lastUsedAt desc, id desc limit $2)",
+		database.RefreshSessionsTable, database.RefreshSessionsTable)
+	if _, err := tx.Exec(query, session.UserId, maxUserSessions); err != nil {
+		err := tx.Rollback()
 		if err != nil {
-			return err
+			return "", err
 		}
+		return "", err
+	}
+
+	return id, tx.Commit()
+}
+
+func (r *AuthRepository) RotateSession(session domain.Session, usedRefreshToken string) error {
+	tx, err := r.db.Begin()
+	if err != nil {
+		return err
+	}
 
-		query = fmt.Sprintf("delete from %s where userId=$1", database.RefreshSessionsTable)
-		if _, err := tx.Exec(query, session.UserId); err != nil {
-			err := tx.Rollback()
-			if err != nil {
-				return err
-			}
+	query := fmt.Sprintf("insert into %s (refreshToken, sessionId, rotatedAt) values ($1, $2, $3)", database.UsedRefreshTokensTable)
+	if _, err := tx.Exec(query, usedRefreshToken, session.Id, session.LastUsedAt); err != nil {
+		err := tx.Rollback()
+		if err != nil {
 			return err
 		}
+		return err
+	}
 
-		query = fmt.Sprintf("Insert into %s (userId, refreshToken, expiresIn, createdAt) values ($1,$2,$3,$4)", database.RefreshSessionsTable)
-		if _, err := tx.Exec(query, session.UserId, session.RefreshToken, session.ExpiresIn, session.CreatedAt); err != nil {
-			err := tx.Rollback()
-			if err != nil {
-				return err
-			}
+	query = fmt.Sprintf("update %s set refreshToken=$1, expiresIn=$2, lastUsedAt=$3, userAgent=$4, ip=$5 where id=$6 and refreshToken=$7", database.RefreshSessionsTable)
+	res, err := tx.Exec(query, session.RefreshToken, session.ExpiresIn, session.LastUsedAt, session.UserAgent, session.Ip, session.Id, usedRefreshToken)
+	if err == nil {
+		err = checkAffected(res)
+	}
+	if err != nil {
+		err := tx.Rollback()
+		if err != nil {
 			return err
 		}
+		return err
+	}
+
+	return tx.Commit()
+}
+
+func (r *AuthRepository) GetSessionByUsedRefreshToken(refreshToken string) (domain.Session, error) {
+	var session domain.Session
+
+	query := fmt.Sprintf("select s.* from %s s join %s u on u.sessionId = s.id where u.refreshToken=$1",
+		database.RefreshSessionsTable, database.UsedRefreshTokensTable)
+	if err := r.db.Get(&session, query, refreshToken); err != nil {
+		return domain.Session{}, notFound(err)
+	}
+
+	return session, nil
+}
+
+func (r *AuthRepository) GetSessionsByUserId(userId string) ([]domain.Session, error) {
+	sessions := make([]domain.Session, 0)
+
+	query := fmt.Sprintf("select * from %s where userId=$1 order by lastUsedAt desc", database.RefreshSessionsTable)
+	if err := r.db.Select(&sessions, query, userId); err != nil {
+		return nil, err
+	}
+
+	return sessions, nil
+}
+
+func (r *AuthRepository) GetSession(userId, sessionId string) (domain.Session, error) {
+	var session domain.Session
+
+	query := fmt.Sprintf("select * from %s where id=$1 and userId=$2", database.RefreshSessionsTable)
+	if err := r.db.Get(&session, query, sessionId, userId); err != nil {
+		return domain.Session{}, notFound(err)
+	}
 
-		return tx.Commit()
+	return session, nil
+}
+
+func (r *AuthRepository) DeleteSession(userId, sessionId string) error {
+	query := fmt.Sprintf("delete from %s where id=$1 and userId=$2", database.RefreshSessionsTable)
+	res, err := r.db.Exec(query, sessionId, userId)
+	if err != nil {
+		return err
 	}
 
-	query = fmt.Sprintf("Insert into %s (userId, refreshToken, expiresIn, createdAt) values ($1,$2,$3,$4)", database.RefreshSessionsTable)
-	_, err := r.db.Exec(query, session.UserId, session.RefreshToken, session.ExpiresIn, session.CreatedAt)
+	return checkAffected(res)
+}
+
+func (r *AuthRepository) DeleteSessionByRefreshToken(userId, refreshToken string) error {
+	query := fmt.Sprintf("delete from %s where refreshToken=$1 and userId=$2", database.RefreshSessionsTable)
+	res, err := r.db.Exec(query, refreshToken, userId)
 	if err != nil {
 		return err
 	}
+
+	return checkAffected(res)
+}
+
+func (r *AuthRepository) DeleteOtherSessions(userId, refreshToken string) error {
+	query := fmt.Sprintf("delete from %s where userId=$1 and refreshToken<>$2", database.RefreshSessionsTable)
+	if _, err := r.db.Exec(query, userId, refreshToken); err != nil {
+		return err
+	}
+
 	return nil
 }
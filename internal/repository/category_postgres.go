@@ -0,0 +1,251 @@
+package repository
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+	"github.com/TakoB222/postingAds-api/internal/domain"
+	"github.com/TakoB222/postingAds-api/pkg/database"
+	"github.com/jmoiron/sqlx"
+	"github.com/lib/pq"
+)
+
+const uniqueViolation = "23505"
+
+type CategoryRepository struct {
+	db   *sqlx.DB
+	tree *CategoryTree
+}
+
+func NewCategoryRepository(db *sqlx.DB, tree *CategoryTree) *CategoryRepository {
+	return &CategoryRepository{db: db, tree: tree}
+}
+
+// GetCachedCategories returns categories from the in-memory tree, AdsCount is not loaded.
+func (r *CategoryRepository) GetCachedCategories() ([]domain.Categories, error) {
+	return r.tree.Categories()
+}
+
+// GetCategories returns every category with the number of its own approved ads, subcategories are not counted.
+func (r *CategoryRepository) GetCategories() ([]domain.Categories, error) {
+	var categories []domain.Categories
+
+	query := fmt.Sprintf(`select c.id, c.category, c.parent_category, c.attributes, 
+								(select count(*) from %s ads where ads.category_id = c.id and ads.status=$1) as ads_count 
+								from %s c order by c.id`, database.AdsTable, database.CategoriesTable)
+	if err := r.db.Select(&categories, query, domain.AdStatusApproved); err != nil {
+		return nil, err
+	}
+
+	return categories, nil
+}
+
+func (r *CategoryRepository) GetCategory(categoryId int) (domain.Categories, error) {
+	var category domain.Categories
+
+	query := fmt.Sprintf("select id, category, parent_category, attributes from %s where id=$1", database.CategoriesTable)
+	if err := r.db.Get(&category, query, categoryId); err != nil {
+		return domain.Categories{}, notFound(err)
+	}
+
+	return category, nil
+}
+
+// GetCategoryByPath walks the tree from a root category, names are compared case insensitive like in the unique index.
+func (r *CategoryRepository) GetCategoryByPath(path []string) (domain.Categories, error) {
+	if len(path) == 0 {
+		return domain.Categories{}, notFound(sql.ErrNoRows)
+	}
+
+	var category domain.Categories
+
+	query := fmt.Sprintf(`with recursive r as (select id, 1 as depth from %s where parent_category is null and lower(category)=lower(($1::text[])[1]) 
+								union all 
+								select categories.id, r.depth + 1 from %s join r on categories.parent_category = r.id 
+								where r.depth < $2 and lower(categories.category)=lower(($1::text[])[r.depth + 1])) 
+								select c.id, c.category, c.parent_category, c.attributes from %s c join r on c.id = r.id where r.depth = $2`,
+		database.CategoriesTable, database.CategoriesTable, database.CategoriesTable)
+	if err := r.db.Get(&category, query, pq.Array(path), len(path)); err != nil {
+		return domain.Categories{}, notFound(err)
+	}
+
+	return category, nil
+}
+
+func (r *CategoryRepository) CreateCategory(name string, parentId *int) (int, error) {
+	tx, err := r.lockCategories()
+	if err != nil {
+		return 0, err
+	}
+
+	if err := checkParentCategory(tx, parentId); err != nil {
+		_ = tx.Rollback()
+		return 0, err
+	}
+
+	var id int
+	query := fmt.Sprintf("insert into %s (category, parent_category) values ($1, $2) returning id", database.CategoriesTable)
+	if err := tx.QueryRow(query, name, parentId).Scan(&id); err != nil {
+		_ = tx.Rollback()
+		return 0, categoryExists(err)
+	}
+
+	return id, r.commit(tx)
+}
+
+// UpdateCategory renames the category and moves it under parentId, a nil parent makes it a root category.
+func (r *CategoryRepository) UpdateCategory(categoryId int, name string, parentId *int) error {
+	tx, err := r.lockCategories()
+	if err != nil {
+		return err
+	}
+
+	if err := checkParentCategory(tx, parentId); err != nil {
+		_ = tx.Rollback()
+		return err
+	}
+
+	if parentId != nil {
+		// the new parent must not be the category itself or one of its descendants
+		var cycle bool
+		query := fmt.Sprintf(`select exists (with recursive r as (select id from %s where id=$1 
+									union 
+									select categories.id from %s join r on categories.parent_category = r.id) 
+									select 1 from r where id=$2)`, database.CategoriesTable, database.CategoriesTable)
+		if err := tx.Get(&cycle, query, categoryId, *parentId); err != nil {
+			_ = tx.Rollback()
+			return err
+		}
+		if cycle {
+			_ = tx.Rollback()
+			return ErrCategoryCycle
+		}
+	}
+
+	query := fmt.Sprintf("update %s set category=$1, parent_category=$2 where id=$3", database.CategoriesTable)
+	res, err := tx.Exec(query, name, parentId, categoryId)
+	if err != nil {
+		_ = tx.Rollback()
+		return categoryExists(err)
+	}
+	if err := checkAffected(res); err != nil {
+		_ = tx.Rollback()
+		return err
+	}
+
+	return r.commit(tx)
+}
+
+func (r *CategoryRepository) SetCategoryAttributes(categoryId int, attributes domain.AttributeSchema) error {
+	query := fmt.Sprintf("update %s set attributes=$1 where id=$2", database.CategoriesTable)
+	res, err := r.db.Exec(query, attributes, categoryId)
+	if err != nil {
+		return err
+	}
+	if err := checkAffected(res); err != nil {
+		return err
+	}
+
+	r.tree.Invalidate()
+
+	return nil
+}
+
+// DeleteCategory deletes a category without subcategories and ads of any status.
+func (r *CategoryRepository) DeleteCategory(categoryId int) error {
+	tx, err := r.lockCategories()
+	if err != nil {
+		return err
+	}
+
+	var hasSubcategories, hasAds bool
+	query := fmt.Sprintf("select exists (select 1 from %s where parent_category=$1)", database.CategoriesTable)
+	if err := tx.Get(&hasSubcategories, query, categoryId); err != nil {
+		_ = tx.Rollback()
+		return err
+	}
+	if hasSubcategories {
+		_ = tx.Rollback()
+		return ErrCategoryHasSubcategories
+	}
+
+	query = fmt.Sprintf("select exists (select 1 from %s where category_id=$1)", database.AdsTable)
+	if err := tx.Get(&hasAds, query, categoryId); err != nil {
+		_ = tx.Rollback()
+		return err
+	}
+	if hasAds {
+		_ = tx.Rollback()
+		return ErrCategoryHasAds
+	}
+
+	query = fmt.Sprintf("delete from %s where id=$1", database.CategoriesTable)
+	res, err := tx.Exec(query, categoryId)
+	if err != nil {
+		_ = tx.Rollback()
+		// an ad was created in the category after the check
+		var pqErr *pq.Error
+		if errors.As(err, &pqErr) && pqErr.Code == foreignKeyViolation {
+			return ErrCategoryHasAds
+		}
+		return err
+	}
+	if err := checkAffected(res); err != nil {
+		_ = tx.Rollback()
+		return err
+	}
+
+	return r.commit(tx)
+}
+
+func (r *CategoryRepository) commit(tx *sqlx.Tx) error {
+	if err := tx.Commit(); err != nil {
+		return err
+	}
+
+	r.tree.Invalidate()
+
+	return nil
+}
+
+// lockCategories serializes changes of the tree, so concurrent moves can not create a cycle together.
+func (r *CategoryRepository) lockCategories() (*sqlx.Tx, error) {
+	tx, err := r.db.Beginx()
+	if err != nil {
+		return nil, err
+	}
+
+	query := fmt.Sprintf("lock table %s in share row exclusive mode", database.CategoriesTable)
+	if _, err := tx.Exec(query); err != nil {
+		_ = tx.Rollback()
+		return nil, err
+	}
+
+	return tx, nil
+}
+
+func checkParentCategory(tx *sqlx.Tx, parentId *int) error {
+	if parentId == nil {
+		return nil
+	}
+
+	var exists bool
+	query := fmt.Sprintf("select exists (select 1 from %s where id=$1)", database.CategoriesTable)
+	if err := tx.Get(&exists, query, *parentId); err != nil {
+		return err
+	}
+	if !exists {
+		return ErrParentCategoryNotFound
+	}
+
+	return nil
+}
+
+func categoryExists(err error) error {
+	var pqErr *pq.Error
+	if errors.As(err, &pqErr) && pqErr.Code == uniqueViolation {
+		return ErrCategoryExists
+	}
+
+	return err
+}
@@ -0,0 +1,206 @@
+package repository
+
+import (
+	"database/sql"
+	"fmt"
+	"github.com/TakoB222/postingAds-api/internal/domain"
+	"github.com/TakoB222/postingAds-api/pkg/database"
+	"github.com/jmoiron/sqlx"
+	"sort"
+	"strconv"
+	"sync"
+	"time"
+)
+
+const (
+	// changes made by other instances of the api are picked up after categoryTreeTTL
+	categoryTreeTTL = time.Minute
+	// a category missing from a tree younger than categoryTreeMinAge does not reload it,
+	// so unknown ids do not query the database on every call
+	categoryTreeMinAge = time.Second
+)
+
+// CategoryTree keeps categories in memory, so paths of categories of ads are built without a query per ad.
+// CategoryRepository invalidates it on every change of the tree.
+type CategoryTree struct {
+	db *sqlx.DB
+
+	// loadMu lets a single load run at a time, callers waiting for it use its result
+	loadMu sync.Mutex
+
+	mu         sync.RWMutex
+	categories []domain.Categories
+	paths      map[int]string
+	loadedAt   time.Time
+	// generation counts invalidations, a load started before one does not mark the tree fresh
+	generation int
+}
+
+func NewCategoryTree(db *sqlx.DB) *CategoryTree {
+	return &CategoryTree{db: db}
+}
+
+// Load reads the tree from the database, loaded categories are never modified, readers share them.
+func (t *CategoryTree) Load() error {
+	t.mu.RLock()
+	generation := t.generation
+	t.mu.RUnlock()
+
+	categories := make([]domain.Categories, 0)
+
+	query := fmt.Sprintf("select id, category, parent_category, attributes from %s order by id", database.CategoriesTable)
+	if err := t.db.Select(&categories, query); err != nil {
+		return err
+	}
+
+	paths := domain.CategoryPaths(categories)
+
+	t.mu.Lock()
+	t.categories, t.paths = categories, paths
+	if t.generation == generation {
+		t.loadedAt = time.Now()
+	}
+	t.mu.Unlock()
+
+	return nil
+}
+
+func (t *CategoryTree) Invalidate() {
+	t.mu.Lock()
+	t.loadedAt = time.Time{}
+	t.generation++
+	t.mu.Unlock()
+}
+
+// reload loads the tree unless it has been loaded since loadedAt, the time of the tree the caller found stale.
+func (t *CategoryTree) reload(loadedAt time.Time) error {
+	t.loadMu.Lock()
+	defer t.loadMu.Unlock()
+
+	if _, _, current := t.state(); current.After(loadedAt) {
+		return nil
+	}
+
+	return t.Load()
+}
+
+// Categories returns the cached categories, AdsCount is not loaded.
+func (t *CategoryTree) Categories() ([]domain.Categories, error) {
+	categories, _, err := t.snapshot()
+	if err != nil {
+		return nil, err
+	}
+
+	return append([]domain.Categories{}, categories...), nil
+}
+
+// Path returns the full path of the category.
+func (t *CategoryTree) Path(categoryId string) (string, error) {
+	id, err := strconv.Atoi(categoryId)
+	if err != nil {
+		return "", err
+	}
+
+	if _, _, err := t.snapshot(); err != nil {
+		return "", err
+	}
+
+	_, paths, loadedAt := t.state()
+	if path, ok := paths[id]; ok {
+		return path, nil
+	}
+
+	// the category could be created by another instance after the tree was loaded
+	if time.Since(loadedAt) >= categoryTreeMinAge {
+		if err := t.reload(loadedAt); err != nil {
+			return "", err
+		}
+		_, paths, _ = t.state()
+		if path, ok := paths[id]; ok {
+			return path, nil
+		}
+	}
+
+	return "", notFound(sql.ErrNoRows)
+}
+
+// setPaths replaces ids of categories of the ads with their paths.
+func (t *CategoryTree) setPaths(ads []domain.Ad) error {
+	for i := range ads {
+		path, err := t.Path(ads[i].Category)
+		if err != nil {
+			return err
+		}
+		ads[i].Category = path
+	}
+
+	return nil
+}
+
+// rollUp adds counts of ads of categories to all their ancestors, the most popular categories go first.
+func (t *CategoryTree) rollUp(counts map[int]int) ([]CategoryFacet, error) {
+	// a miss reloads the tree, categories of the counts are known after it
+	for id := range counts {
+		if _, err := t.Path(strconv.Itoa(id)); err != nil {
+			return nil, err
+		}
+	}
+
+	categories, paths, err := t.snapshot()
+	if err != nil {
+		return nil, err
+	}
+
+	byId := make(map[int]domain.Categories, len(categories))
+	for _, category := range categories {
+		byId[category.Id] = category
+	}
+
+	totals := make(map[int]int, len(counts))
+	for id, count := range counts {
+		// the depth bound keeps a corrupted tree from looping forever
+		for depth, category, ok := 0, byId[id], true; ok && depth <= len(categories); depth++ {
+			totals[category.Id] += count
+			if category.ParentCategory == nil {
+				break
+			}
+			category, ok = byId[*category.ParentCategory]
+		}
+	}
+
+	res := make([]CategoryFacet, 0, len(totals))
+	for id, count := range totals {
+		res = append(res, CategoryFacet{Id: id, ParentId: byId[id].ParentCategory, Path: paths[id], Count: count})
+	}
+	sort.Slice(res, func(i, j int) bool {
+		if res[i].Count != res[j].Count {
+			return res[i].Count > res[j].Count
+		}
+		return res[i].Path < res[j].Path
+	})
+
+	return res, nil
+}
+
+// snapshot returns the tree, reloading it once it is older than categoryTreeTTL or invalidated.
+func (t *CategoryTree) snapshot() ([]domain.Categories, map[int]string, error) {
+	categories, paths, loadedAt := t.state()
+	if !loadedAt.IsZero() && time.Since(loadedAt) < categoryTreeTTL {
+		return categories, paths, nil
+	}
+
+	if err := t.reload(loadedAt); err != nil {
+		return nil, nil, err
+	}
+
+	categories, paths, _ = t.state()
+
+	return categories, paths, nil
+}
+
+func (t *CategoryTree) state() ([]domain.Categories, map[int]string, time.Time) {
+	t.mu.RLock()
+	defer t.mu.RUnlock()
+
+	return t.categories, t.paths, t.loadedAt
+}
@@ -0,0 +1,101 @@
+package repository
+
+import (
+	"database/sql"
+	"errors"
+	"github.com/TakoB222/postingAds-api/internal/domain"
+	"github.com/stretchr/testify/assert"
+	"testing"
+	"time"
+)
+
+// newLoadedCategoryTree returns a fresh tree without a database, a miss in it does not reload.
+func newLoadedCategoryTree() *CategoryTree {
+	id := func(id int) *int { return &id }
+
+	categories := []domain.Categories{
+		{Id: 1, Category: "Transport"},
+		{Id: 2, Category: "Cars", ParentCategory: id(1)},
+		{Id: 3, Category: "Buses", ParentCategory: id(1)},
+		{Id: 4, Category: "Electric", ParentCategory: id(2)},
+		{Id: 5, Category: "Realty"},
+	}
+
+	return &CategoryTree{categories: categories, paths: domain.CategoryPaths(categories), loadedAt: time.Now()}
+}
+
+func TestCategoryTreePath(t *testing.T) {
+	testTable := []struct {
+		name          string
+		categoryId    string
+		expectedPath  string
+		expectedError error
+	}{
+		{
+			name:         "root",
+			categoryId:   "1",
+			expectedPath: "Transport",
+		},
+		{
+			name:         "nested",
+			categoryId:   "4",
+			expectedPath: "Transport/Cars/Electric",
+		},
+		{
+			name:          "not found",
+			categoryId:    "100",
+			expectedError: sql.ErrNoRows,
+		},
+	}
+
+	for _, testCase := range testTable {
+		t.Run(testCase.name, func(t *testing.T) {
+			path, err := newLoadedCategoryTree().Path(testCase.categoryId)
+
+			assert.True(t, errors.Is(err, testCase.expectedError), "unexpected error: %v", err)
+			assert.Equal(t, testCase.expectedPath, path)
+		})
+	}
+}
+
+func TestCategoryTreeRollUp(t *testing.T) {
+	parentId := func(id int) *int { return &id }
+
+	testTable := []struct {
+		name     string
+		counts   map[int]int
+		expected []CategoryFacet
+	}{
+		{
+			name:     "empty",
+			counts:   map[int]int{},
+			expected: []CategoryFacet{},
+		},
+		{
+			name:   "ancestors",
+			counts: map[int]int{4: 2, 3: 1, 5: 3},
+			expected: []CategoryFacet{
+				{Id: 5, Path: "Realty", Count: 3},
+				{Id: 1, Path: "Transport", Count: 3},
+				{Id: 2, ParentId: parentId(1), Path: "Transport/Cars", Count: 2},
+				{Id: 4, ParentId: parentId(2), Path: "Transport/Cars/Electric", Count: 2},
+				{Id: 3, ParentId: parentId(1), Path: "Transport/Buses", Count: 1},
+			},
+		},
+	}
+
+	for _, testCase := range testTable {
+		t.Run(testCase.name, func(t *testing.T) {
+			facets, err := newLoadedCategoryTree().rollUp(testCase.counts)
+
+			assert.NoError(t, err)
+			assert.Equal(t, testCase.expected, facets)
+		})
+	}
+}
+
+func TestCategoryTreeRollUpUnknownCategory(t *testing.T) {
+	_, err := newLoadedCategoryTree().rollUp(map[int]int{100: 1})
+
+	assert.True(t, errors.Is(err, sql.ErrNoRows), "unexpected error: %v", err)
+}
@@ -0,0 +1,153 @@
+package repository
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+	"github.com/TakoB222/postingAds-api/internal/domain"
+	"github.com/TakoB222/postingAds-api/pkg/database"
+	"github.com/jmoiron/sqlx"
+	"github.com/lib/pq"
+	"time"
+)
+
+type ContactRepository struct {
+	db *sqlx.DB
+}
+
+func NewContactRepository(db *sqlx.DB) *ContactRepository {
+	return &ContactRepository{db: db}
+}
+
+// GetPublishedAdContacts returns the id of the user of a published ad and the contacts they left in it.
+func (r *ContactRepository) GetPublishedAdContacts(adId string) (string, domain.Contacts, error) {
+	var (
+		userId   string
+		contacts domain.Contacts
+	)
+
+	query := fmt.Sprintf("select ads.userid, row_to_json(ci) from %s join %s ci on ci.id = ads.contacts_id where ads.id=$1 and ads.status=$2",
+		database.AdsTable, database.ContactsInfoTable)
+	if err := r.db.QueryRow(query, adId, domain.AdStatusApproved).Scan(&userId, &contacts); err != nil {
+		return "", domain.Contacts{}, notFound(err)
+	}
+
+	return userId, contacts, nil
+}
+
+// CreateContactReveal logs the reveal unless the user revealed contacts of limit other ads since the time,
+// it fails with ErrTooManyContactReveals then. Reveals of a user are serialized, so parallel requests can not
+// all pass the limit.
+func (r *ContactRepository) CreateContactReveal(userId, adId, ip string, since time.Time, limit int) error {
+	tx, err := r.db.Beginx()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	if _, err := tx.Exec("select pg_advisory_xact_lock(hashtext($1))", database.ContactRevealsTable+":"+userId); err != nil {
+		return err
+	}
+
+	var id int
+	query := fmt.Sprintf(`insert into %s (user_id, ad_id, ip)
+		select $1, $2, $3 where (select count(distinct ad_id) from %s where user_id=$1 and created_at > $4 and ad_id <> $2) < $5 returning id`,
+		database.ContactRevealsTable, database.ContactRevealsTable)
+	if err := tx.Get(&id, query, userId, adId, ip, since, limit); err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return ErrTooManyContactReveals
+		}
+		return err
+	}
+
+	return tx.Commit()
+}
+
+// GetContactRevealStats returns reveals of contacts of every ad of the user, ads that were never revealed included.
+func (r *ContactRepository) GetContactRevealStats(userId string) ([]domain.ContactRevealStats, error) {
+	stats := make([]domain.ContactRevealStats, 0)
+
+	query := fmt.Sprintf(`select ads.id as ad_id, ads.title, count(cr.id) as reveals, count(distinct cr.user_id) as revealers, max(cr.created_at) as last_revealed_at
+		from %s left join %s cr on cr.ad_id = ads.id
+		where ads.userid=$1
+		group by ads.id, ads.title
+		order by reveals desc, ads.id desc`, database.AdsTable, database.ContactRevealsTable)
+	if err := r.db.Select(&stats, query, userId); err != nil {
+		return nil, err
+	}
+
+	return stats, nil
+}
+
+func (r *ContactRepository) CreateContactProfile(userId string, profile domain.ContactProfile) (int, error) {
+	var id int
+
+	query := fmt.Sprintf("insert into %s (user_id, label, name, phone_number, email, location) values ($1, $2, $3, $4, $5, $6) returning id",
+		database.ContactsInfoTable)
+	if err := r.db.QueryRow(query, userId, profile.Label, profile.Name, profile.Phone_number, profile.Email, profile.Location).Scan(&id); err != nil {
+		return 0, contactProfileExists(err)
+	}
+
+	return id, nil
+}
+
+func (r *ContactRepository) GetContactProfiles(userId string) ([]domain.ContactProfile, error) {
+	profiles := make([]domain.ContactProfile, 0)
+
+	query := contactProfilesQuery("ci.user_id=$1") + " order by ci.label"
+	if err := r.db.Select(&profiles, query, userId); err != nil {
+		return nil, err
+	}
+
+	return profiles, nil
+}
+
+func (r *ContactRepository) GetContactProfile(userId string, profileId int) (domain.ContactProfile, error) {
+	var profile domain.ContactProfile
+
+	query := contactProfilesQuery("ci.user_id=$1 and ci.id=$2")
+	if err := r.db.Get(&profile, query, userId, profileId); err != nil {
+		return domain.ContactProfile{}, notFound(err)
+	}
+
+	return profile, nil
+}
+
+// UpdateContactProfile changes contacts of every ad using the profile at once.
+func (r *ContactRepository) UpdateContactProfile(userId string, profile domain.ContactProfile) error {
+	query := fmt.Sprintf("update %s set label=$1, name=$2, phone_number=$3, email=$4, location=$5 where id=$6 and user_id=$7",
+		database.ContactsInfoTable)
+	res, err := r.db.Exec(query, profile.Label, profile.Name, profile.Phone_number, profile.Email, profile.Location, profile.Id, userId)
+	if err != nil {
+		return contactProfileExists(err)
+	}
+
+	return checkAffected(res)
+}
+
+// DeleteContactProfile deletes a profile no ad uses, deleting a used one would delete its ads with it.
+func (r *ContactRepository) DeleteContactProfile(userId string, profileId int) error {
+	query := fmt.Sprintf("delete from %s where id=$1 and user_id=$2 and not exists (select 1 from %s where contacts_id = $1)",
+		database.ContactsInfoTable, database.AdsTable)
+	res, err := r.db.Exec(query, profileId, userId)
+	if err != nil {
+		return err
+	}
+
+	return checkAffected(res)
+}
+
+func contactProfilesQuery(where string) string {
+	return fmt.Sprintf(`select ci.id, ci.label, ci.name, ci.phone_number, ci.email, ci.location,
+		(select count(*) from %s where ads.contacts_id = ci.id) as ads_count
+		from %s ci where %s`, database.AdsTable, database.ContactsInfoTable, where)
+}
+
+func contactProfileExists(err error) error {
+	var pqErr *pq.Error
+	if errors.As(err, &pqErr) && pqErr.Code == uniqueViolation {
+		return ErrContactProfileExists
+	}
+
+	return err
+}
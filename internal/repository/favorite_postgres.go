@@ -0,0 +1,80 @@
+package repository
+
+import (
+	"fmt"
+	"github.com/TakoB222/postingAds-api/internal/domain"
+	"github.com/TakoB222/postingAds-api/pkg/database"
+	"github.com/jmoiron/sqlx"
+)
+
+type FavoriteRepository struct {
+	db   *sqlx.DB
+	tree *CategoryTree
+}
+
+func NewFavoriteRepository(db *sqlx.DB, tree *CategoryTree) *FavoriteRepository {
+	return &FavoriteRepository{db: db, tree: tree}
+}
+
+// GetPublishedAdOwner returns the id of the user of a published ad.
+func (r *FavoriteRepository) GetPublishedAdOwner(adId string) (string, error) {
+	return getPublishedAdOwner(r.db, adId)
+}
+
+func getPublishedAdOwner(db *sqlx.DB, adId string) (string, error) {
+	var userId string
+
+	query := fmt.Sprintf("select userid from %s where id=$1 and status=$2", database.AdsTable)
+	if err := db.Get(&userId, query, adId, domain.AdStatusApproved); err != nil {
+		return "", notFound(err)
+	}
+
+	return userId, nil
+}
+
+// AddFavorite keeps the time the ad was added first.
+func (r *FavoriteRepository) AddFavorite(userId, adId string) error {
+	query := fmt.Sprintf("insert into %s (user_id, ad_id) values ($1, $2) on conflict do nothing", database.FavoritesTable)
+	_, err := r.db.Exec(query, userId, adId)
+
+	return err
+}
+
+func (r *FavoriteRepository) RemoveFavorite(userId, adId string) error {
+	query := fmt.Sprintf("delete from %s where user_id=$1 and ad_id=$2", database.FavoritesTable)
+	res, err := r.db.Exec(query, userId, adId)
+	if err != nil {
+		return err
+	}
+
+	return checkAffected(res)
+}
+
+// GetFavoriteAds returns ads the user added to favorites whatever their status is, the last added go first.
+func (r *FavoriteRepository) GetFavoriteAds(userId string) ([]domain.Ad, error) {
+	ads := make([]domain.Ad, 0)
+
+	query := fmt.Sprintf("select %s from %s join %s fav on fav.ad_id = ads.id where fav.user_id=$1 order by fav.created_at desc, ads.id desc",
+		adColumns, database.AdsTable, database.FavoritesTable)
+	if err := r.db.Select(&ads, query, userId); err != nil {
+		return nil, err
+	}
+
+	if err := r.tree.setPaths(ads); err != nil {
+		return nil, err
+	}
+
+	return ads, nil
+}
+
+// GetFavoriteUserIds returns users who added the ad to favorites.
+func (r *FavoriteRepository) GetFavoriteUserIds(adId string) ([]string, error) {
+	userIds := make([]string, 0)
+
+	query := fmt.Sprintf("select user_id from %s where ad_id=$1", database.FavoritesTable)
+	if err := r.db.Select(&userIds, query, adId); err != nil {
+		return nil, err
+	}
+
+	return userIds, nil
+}
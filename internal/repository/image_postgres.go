@@ -0,0 +1,74 @@
+package repository
+
+import (
+	"fmt"
+	"github.com/TakoB222/postingAds-api/internal/domain"
+	"github.com/TakoB222/postingAds-api/pkg/database"
+	"github.com/jmoiron/sqlx"
+	"github.com/lib/pq"
+	"time"
+)
+
+const imageColumns = "id, user_id, ad_id, content_type, width, height, size, created_at"
+
+type ImageRepository struct {
+	db *sqlx.DB
+}
+
+func NewImageRepository(db *sqlx.DB) *ImageRepository {
+	return &ImageRepository{db: db}
+}
+
+func (r *ImageRepository) CreateImage(image domain.Image) error {
+	query := fmt.Sprintf("insert into %s (id, user_id, content_type, width, height, size, created_at) values ($1, $2, $3, $4, $5, $6, $7)", database.ImagesTable)
+	_, err := r.db.Exec(query, image.Id, image.UserId, image.ContentType, image.Width, image.Height, image.Size, image.CreatedAt)
+
+	return err
+}
+
+func (r *ImageRepository) GetImagesByIds(ids []string) ([]domain.Image, error) {
+	var images []domain.Image
+
+	query := fmt.Sprintf("select %s from %s where id = any($1)", imageColumns, database.ImagesTable)
+	if err := r.db.Select(&images, query, pq.Array(ids)); err != nil {
+		return nil, err
+	}
+
+	return images, nil
+}
+
+func (r *ImageRepository) GetImagesByAdId(adId string) ([]domain.Image, error) {
+	var images []domain.Image
+
+	query := fmt.Sprintf("select %s from %s where ad_id=$1", imageColumns, database.ImagesTable)
+	if err := r.db.Select(&images, query, adId); err != nil {
+		return nil, err
+	}
+
+	return images, nil
+}
+
+func (r *ImageRepository) GetUnattachedImages(createdBefore time.Time) ([]domain.Image, error) {
+	var images []domain.Image
+
+	query := fmt.Sprintf("select %s from %s where ad_id is null and created_at < $1", imageColumns, database.ImagesTable)
+	if err := r.db.Select(&images, query, createdBefore); err != nil {
+		return nil, err
+	}
+
+	return images, nil
+}
+
+func (r *ImageRepository) AttachImages(adId string, ids []string) error {
+	query := fmt.Sprintf("update %s set ad_id=$1 where id = any($2)", database.ImagesTable)
+	_, err := r.db.Exec(query, adId, pq.Array(ids))
+
+	return err
+}
+
+func (r *ImageRepository) DeleteImages(ids []string) error {
+	query := fmt.Sprintf("delete from %s where id = any($1)", database.ImagesTable)
+	_, err := r.db.Exec(query, pq.Array(ids))
+
+	return err
+}
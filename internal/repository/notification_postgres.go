@@ -0,0 +1,75 @@
+package repository
+
+import (
+	"fmt"
+	"github.com/TakoB222/postingAds-api/internal/domain"
+	"github.com/TakoB222/postingAds-api/pkg/database"
+	"github.com/jmoiron/sqlx"
+	"github.com/lib/pq"
+)
+
+const notificationColumns = "id, user_id, kind, title, body, payload, created_at, read_at"
+
+type NotificationRepository struct {
+	db *sqlx.DB
+}
+
+func NewNotificationRepository(db *sqlx.DB) *NotificationRepository {
+	return &NotificationRepository{db: db}
+}
+
+func (r *NotificationRepository) CreateNotification(notification domain.Notification) (int, error) {
+	payload := string(notification.Payload)
+	if payload == "" {
+		payload = "{}"
+	}
+
+	var id int
+	query := fmt.Sprintf("insert into %s (user_id, kind, title, body, payload) values ($1, $2, $3, $4, $5) returning id", database.NotificationsTable)
+	if err := r.db.Get(&id, query, notification.UserId, notification.Kind, notification.Title, notification.Body, payload); err != nil {
+		return 0, err
+	}
+
+	return id, nil
+}
+
+// CreateNotifications sends the same notification to every user of the list.
+func (r *NotificationRepository) CreateNotifications(userIds []string, notification domain.Notification) error {
+	payload := string(notification.Payload)
+	if payload == "" {
+		payload = "{}"
+	}
+
+	query := fmt.Sprintf("insert into %s (user_id, kind, title, body, payload) select unnest($1::int[]), $2, $3, $4, $5", database.NotificationsTable)
+	_, err := r.db.Exec(query, pq.Array(userIds), notification.Kind, notification.Title, notification.Body, payload)
+
+	return err
+}
+
+// GetNotifications returns notifications of the user, the newest go first.
+func (r *NotificationRepository) GetNotifications(userId string, limit, offset int) ([]domain.Notification, int, error) {
+	var unread int
+	query := fmt.Sprintf("select count(*) from %s where user_id=$1 and read_at is null", database.NotificationsTable)
+	if err := r.db.Get(&unread, query, userId); err != nil {
+		return nil, 0, err
+	}
+
+	notifications := make([]domain.Notification, 0)
+	query = fmt.Sprintf("select %s from %s where user_id=$1 order by created_at desc, id desc limit $2 offset $3", notificationColumns, database.NotificationsTable)
+	if err := r.db.Select(&notifications, query, userId, limit, offset); err != nil {
+		return nil, 0, err
+	}
+
+	return notifications, unread, nil
+}
+
+// ReadNotification keeps the time a notification was read first.
+func (r *NotificationRepository) ReadNotification(userId string, notificationId int) error {
+	query := fmt.Sprintf("update %s set read_at=coalesce(read_at, now()) where id=$1 and user_id=$2", database.NotificationsTable)
+	res, err := r.db.Exec(query, notificationId, userId)
+	if err != nil {
+		return err
+	}
+
+	return checkAffected(res)
+}
@@ -1,24 +1,56 @@
 package repository
 
 import (
+	"context"
+	"database/sql"
+	"errors"
 	"github.com/TakoB222/postingAds-api/internal/domain"
+	"github.com/TakoB222/postingAds-api/pkg/apperror"
 	"github.com/jmoiron/sqlx"
+	"time"
 )
 
 const (
-	//TODO: create custom errors
-	ErrorEmptyResult = "empty result"
+	maxUserSessions  = 5
+	maxAdminSessions = 3
+)
+
+var (
+	// ErrNotFound wraps sql.ErrNoRows, so callers may check for either of them.
+	ErrNotFound                 = apperror.NotFound("not_found", "not found")
+	ErrUserExists               = apperror.Conflict("user_exists", "user with the same email already exists")
+	ErrAdStatusChanged          = apperror.Conflict("ad_status_changed", "ad status has been changed by another request")
+	ErrCategoryExists           = apperror.Conflict("category_exists", "category with the same name already exists")
+	ErrCategoryCycle            = apperror.Conflict("category_cycle", "category can not be moved into its own subtree")
+	ErrCategoryHasSubcategories = apperror.Conflict("category_has_subcategories", "category has subcategories")
+	ErrCategoryHasAds           = apperror.Conflict("category_has_ads", "category has ads")
+	ErrParentCategoryNotFound   = apperror.NotFound("parent_category_not_found", "parent category not found")
+	ErrTooManySavedSearches     = apperror.Conflict("too_many_saved_searches", "too many saved searches")
+	ErrUserTokenCooldown        = apperror.TooManyRequests("user_token_cooldown", "a token has been issued recently")
+	ErrTooManyContactReveals    = apperror.TooManyRequests("too_many_contact_reveals", "too many contact reveals")
+	ErrContactProfileExists     = apperror.Conflict("contact_profile_exists", "contact profile with the same label already exists")
+)
+
+const (
+	SortNewest    = "newest"
+	SortOldest    = "oldest"
+	SortPriceAsc  = "price_asc"
+	SortPriceDesc = "price_desc"
 )
 
 type (
 	Ads struct {
 		Title       string   `json:"title"`
-		Category    string   `json:"category"`
+		Category    string   `json:"category"` // id of the category
 		Description string   `json:"description"`
 		Price       int      `json:"price"`
 		Contacts    Contacts `json:"contacts"`
-		Published   bool     `json:"published"`
-		ImagesURL   []string `json:"images_url"`
+		// ContactProfileId makes the ad use a contact profile instead of its own Contacts
+		ContactProfileId int                    `json:"contact_profile_id"`
+		Status           domain.AdStatus        `json:"status"`
+		ImagesURL        []string               `json:"images_url"`
+		Attributes       domain.AttributeValues `json:"attributes"`
+		Language         string                 `json:"language"`
 	}
 
 	Contacts struct {
@@ -28,50 +60,291 @@ type (
 		Location     string `json:"location"`
 	}
 
+	// FtsResponse is a published ad found by a search request, matched words of the snippets are wrapped in <b> tags.
 	FtsResponse struct {
-		Id string `db:"id"`
-		Title string `db:"title"`
+		domain.Ad
+		Rank               float64 `db:"rank" json:"rank"`
+		TitleSnippet       string  `db:"title_snippet" json:"title_snippet"`
+		DescriptionSnippet string  `db:"description_snippet" json:"description_snippet"`
+	}
+
+	// SearchFacets count ads found by a search request to narrow it down.
+	SearchFacets struct {
+		Categories []CategoryFacet `json:"categories"`
+		Locations  []LocationFacet `json:"locations"`
+		Prices     []PriceFacet    `json:"prices"`
+	}
+
+	// CategoryFacet counts ads of the category together with its subcategories.
+	CategoryFacet struct {
+		Id       int    `json:"id"`
+		ParentId *int   `json:"parent_id"`
+		Path     string `json:"path"`
+		Count    int    `json:"count"`
+	}
+
+	LocationFacet struct {
+		Location string `json:"location"`
+		Count    int    `json:"count"`
+	}
+
+	// PriceFacet counts ads with a price from From to To inclusive.
+	PriceFacet struct {
+		From  int `json:"from"`
+		To    int `json:"to"`
+		Count int `json:"count"`
+	}
+
+	// DueSavedSearch is a saved search to check with the email of its user.
+	DueSavedSearch struct {
+		domain.SavedSearch
+		Email string `db:"email"`
+	}
+
+	// Suggestion completes a search request with a title of published ads or a path of a category.
+	Suggestion struct {
+		Text       string  `db:"text" json:"text"`
+		Kind       string  `db:"kind" json:"kind"` // ad or category
+		CategoryId *int    `db:"category_id" json:"category_id,omitempty"`
+		Score      float64 `db:"score" json:"-"`
+	}
+
+	AdsFilter struct {
+		CategoryId int // ads of subcategories are included
+		MinPrice   *int
+		MaxPrice   *int
+		Location   string
+		Language   string // only ads of the language are searched, empty means any
+		Attributes []AttributeFilter
+		Sort       string
+		Limit      int
+		Offset     int
+	}
+
+	// AttributeFilter matches ads by Equals, by a substring of a text in Contains or by a range of an integer.
+	AttributeFilter struct {
+		Name     string
+		Equals   interface{}
+		Contains string
+		Min      *int64
+		Max      *int64
 	}
 )
 
 type User interface {
 	CreateUser(user domain.User) (int, error)
-	GetUser(email, password_hash string) (domain.User, error)
+	GetUserByEmail(email string) (domain.User, error)
+	UpdatePasswordHash(userId, passwordHash string) error
+	SetUserToken(token domain.UserToken, cooldownSince time.Time) error
+	VerifyEmail(tokenHash string, now time.Time) error
+	ResetPassword(tokenHash, passwordHash string, now time.Time) error
+	GetUserTwoFactor(userId string) (domain.TwoFactor, error)
+	SetUserTwoFactorSecret(userId, secret string) error
+	EnableUserTwoFactor(userId string, step int64, recoveryCodeHashes []string) error
+	DisableUserTwoFactor(userId string) error
+	UseUserTotpStep(userId string, step int64) error
+	UseUserRecoveryCode(userId, codeHash string, now time.Time) error
+	FailUserTwoFactor(userId string, maxAttempts int, lockedUntil time.Time) error
 	GetSessionByRefreshToken(refreshToken string) (domain.Session, error)
 	DeleteSessionByUserId(userId string) error
-	SetSession(session domain.Session) error
+	SetSession(session domain.Session) (string, error)
+	RotateSession(session domain.Session, usedRefreshToken string) error
+	GetSessionByUsedRefreshToken(refreshToken string) (domain.Session, error)
+	GetSessionsByUserId(userId string) ([]domain.Session, error)
+	GetSession(userId, sessionId string) (domain.Session, error)
+	DeleteSession(userId, sessionId string) error
+	DeleteSessionByRefreshToken(userId, refreshToken string) error
+	DeleteOtherSessions(userId, refreshToken string) error
 }
 
 type Admin interface {
-	GetAdminId(email, password_hash string) (string, error)
+	GetAdminByLogin(login string) (domain.Admin, error)
+	UpdateAdminPasswordHash(adminId, passwordHash string) error
+	GetAdminTwoFactor(adminId string) (domain.TwoFactor, error)
+	SetAdminTwoFactorSecret(adminId, secret string) error
+	EnableAdminTwoFactor(adminId string, step int64, recoveryCodeHashes []string) error
+	DisableAdminTwoFactor(adminId string) error
+	UseAdminTotpStep(adminId string, step int64) error
+	UseAdminRecoveryCode(adminId, codeHash string, now time.Time) error
+	FailAdminTwoFactor(adminId string, maxAttempts int, lockedUntil time.Time) error
 	GetAdminSessionByRefreshToken(refrehsToken string) (domain.AdminSession, error)
 	DeleteAdminSessionByAdminId(adminId string) error
 	SetAdminSession(session domain.AdminSession) error
+	RotateAdminSession(session domain.AdminSession, usedRefreshToken string) error
+	GetAdminSessionByUsedRefreshToken(refreshToken string) (domain.AdminSession, error)
+	GetAdminSessionsByAdminId(adminId string) ([]domain.AdminSession, error)
+	DeleteAdminSession(adminId, sessionId string) error
+	DeleteAdminSessionByRefreshToken(adminId, refreshToken string) error
+	DeleteOtherAdminSessions(adminId, refreshToken string) error
 	GetAllAdsByAdmin() ([]domain.Ad, error)
 	GetAd(adId string) (domain.Ad, error)
 	AdminDeleteAd(adId string) error
 	AdminUpdateAd(adId string, ad Ads) error
+	GetAdsByStatus(status domain.AdStatus) ([]domain.Ad, error)
+	AdminSetAdStatus(adId string, from, to domain.AdStatus, reason string) error
+	BanUser(userId, reason string) error
+	UnbanUser(userId string) error
 }
 
 type Ad interface {
 	GetAllAdsByUserId(userId string) ([]domain.Ad, error)
 	CreateAd(userId string, input Ads) (int, error)
 	GetAdById(userId string, adId string) (domain.Ad, error)
-	UpdateAd(userId string, adId string, ad Ads) error
+	UpdateAd(userId string, adId string, from domain.AdStatus, ad Ads) error
 	DeleteAd(userId string, adId string) error
-	SearchAdByRequest(search_request string)([]FtsResponse, error)
+	SearchAdByRequest(search_request string, filter AdsFilter) ([]FtsResponse, int, error)
+	GetSearchFacets(search_request string, filter AdsFilter) (SearchFacets, error)
+	SuggestSearch(ctx context.Context, query string, limit int) ([]Suggestion, error)
+	CorrectSearchWord(ctx context.Context, word string) (string, error)
+	GetPublishedAds(filter AdsFilter) ([]domain.Ad, int, error)
+	SetAdStatus(userId, adId string, from, to domain.AdStatus) error
+}
+
+type Role interface {
+	GetRoles() ([]domain.Role, error)
+	GetRoleByName(name string) (domain.Role, error)
+	GetUserRoles(userId string) ([]domain.Role, error)
+	GetAdminRoles(adminId string) ([]domain.Role, error)
+	AssignUserRole(userId, roleId string) error
+	RevokeUserRole(userId, roleId string) error
+	AssignAdminRole(adminId, roleId string) error
+	RevokeAdminRole(adminId, roleId string) error
+}
+
+type Image interface {
+	CreateImage(image domain.Image) error
+	GetImagesByIds(ids []string) ([]domain.Image, error)
+	GetImagesByAdId(adId string) ([]domain.Image, error)
+	GetUnattachedImages(createdBefore time.Time) ([]domain.Image, error)
+	AttachImages(adId string, ids []string) error
+	DeleteImages(ids []string) error
+}
+
+type Category interface {
+	GetCategories() ([]domain.Categories, error)
+	GetCachedCategories() ([]domain.Categories, error)
+	GetCategory(categoryId int) (domain.Categories, error)
+	GetCategoryByPath(path []string) (domain.Categories, error)
+	CreateCategory(name string, parentId *int) (int, error)
+	UpdateCategory(categoryId int, name string, parentId *int) error
+	SetCategoryAttributes(categoryId int, attributes domain.AttributeSchema) error
+	DeleteCategory(categoryId int) error
+}
+
+type SavedSearch interface {
+	CreateSavedSearch(search domain.SavedSearch, maxPerUser int) (int, error)
+	GetSavedSearch(userId string, searchId int) (domain.SavedSearch, error)
+	GetSavedSearches(userId string) ([]domain.SavedSearch, error)
+	DeleteSavedSearch(userId string, searchId int) error
+	ClaimDueSavedSearches(checkedBefore time.Time, limit int) ([]DueSavedSearch, error)
+	GetNewSearchMatches(search domain.SavedSearch, filter AdsFilter, limit int) ([]domain.Ad, error)
+	RecordSearchMatches(searchId int, adIds []int) ([]int, error)
+}
+
+type Notification interface {
+	CreateNotification(notification domain.Notification) (int, error)
+	CreateNotifications(userIds []string, notification domain.Notification) error
+	GetNotifications(userId string, limit, offset int) ([]domain.Notification, int, error)
+	ReadNotification(userId string, notificationId int) error
+}
+
+type Favorite interface {
+	GetPublishedAdOwner(adId string) (string, error)
+	AddFavorite(userId, adId string) error
+	RemoveFavorite(userId, adId string) error
+	GetFavoriteAds(userId string) ([]domain.Ad, error)
+	GetFavoriteUserIds(adId string) ([]string, error)
+}
+
+type Thread interface {
+	GetAdSeller(adId string) (string, error)
+	CreateThread(adId, buyerId, sellerId string) (int, error)
+	GetThread(userId string, threadId int) (domain.Thread, error)
+	GetThreads(userId string, limit, offset int) ([]domain.Thread, int, error)
+	CreateMessage(threadId int, senderId, body string) (domain.Message, error)
+	GetMessages(threadId int, limit, offset int) ([]domain.Message, error)
+	ReadThread(userId string, threadId int) error
+	IsBlocked(userId, blockedUserId string) (bool, error)
+	BlockUser(userId, blockedUserId string) error
+	UnblockUser(userId, blockedUserId string) error
+}
+
+type Contact interface {
+	GetPublishedAdContacts(adId string) (string, domain.Contacts, error)
+	CreateContactReveal(userId, adId, ip string, since time.Time, limit int) error
+	GetContactRevealStats(userId string) ([]domain.ContactRevealStats, error)
+	CreateContactProfile(userId string, profile domain.ContactProfile) (int, error)
+	GetContactProfiles(userId string) ([]domain.ContactProfile, error)
+	GetContactProfile(userId string, profileId int) (domain.ContactProfile, error)
+	UpdateContactProfile(userId string, profile domain.ContactProfile) error
+	DeleteContactProfile(userId string, profileId int) error
 }
 
 type Repository struct {
 	User
 	Admin
 	Ad
+	Role
+	Image
+	Category
+	SavedSearch
+	Notification
+	Favorite
+	Thread
+	Contact
+
+	CategoryTree *CategoryTree
+}
+
+func checkAffected(res sql.Result) error {
+	affected, err := res.RowsAffected()
+	if err != nil {
+		return err
+	}
+	if affected == 0 {
+		return notFound(sql.ErrNoRows)
+	}
+
+	return nil
+}
+
+// checkStatusChanged reports an update of an ad conditioned on its status which changed no row as ErrAdStatusChanged.
+func checkStatusChanged(res sql.Result) error {
+	affected, err := res.RowsAffected()
+	if err != nil {
+		return err
+	}
+	if affected == 0 {
+		return ErrAdStatusChanged
+	}
+
+	return nil
+}
+
+// notFound reports a missing row as ErrNotFound, other errors are returned as they are.
+func notFound(err error) error {
+	if errors.Is(err, sql.ErrNoRows) {
+		return ErrNotFound.Wrap(err)
+	}
+
+	return err
 }
 
 func NewRepositories(db *sqlx.DB) *Repository {
+	tree := NewCategoryTree(db)
+
 	return &Repository{
-		User:  NewAuthRepository(db),
-		Ad:    NewAdRepository(db),
-		Admin: NewAdminRepository(db),
+		User:         NewAuthRepository(db),
+		Ad:           NewAdRepository(db, tree),
+		Admin:        NewAdminRepository(db, tree),
+		Role:         NewRoleRepository(db),
+		Image:        NewImageRepository(db),
+		Category:     NewCategoryRepository(db, tree),
+		SavedSearch:  NewSavedSearchRepository(db, tree),
+		Notification: NewNotificationRepository(db),
+		Favorite:     NewFavoriteRepository(db, tree),
+		Thread:       NewThreadRepository(db),
+		Contact:      NewContactRepository(db),
+		CategoryTree: tree,
 	}
 }
@@ -0,0 +1,111 @@
+package repository
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+	"github.com/TakoB222/postingAds-api/internal/domain"
+	"github.com/TakoB222/postingAds-api/pkg/database"
+	"github.com/jmoiron/sqlx"
+	"github.com/lib/pq"
+)
+
+const foreignKeyViolation = "23503"
+
+type RoleRepository struct {
+	db *sqlx.DB
+}
+
+func NewRoleRepository(db *sqlx.DB) *RoleRepository {
+	return &RoleRepository{db: db}
+}
+
+func (r *RoleRepository) GetRoles() ([]domain.Role, error) {
+	var roles []domain.Role
+
+	query := fmt.Sprintf("select id, name, permissions from %s order by id", database.RolesTable)
+	if err := r.db.Select(&roles, query); err != nil {
+		return nil, err
+	}
+
+	return roles, nil
+}
+
+func (r *RoleRepository) GetRoleByName(name string) (domain.Role, error) {
+	var role domain.Role
+
+	query := fmt.Sprintf("select id, name, permissions from %s where name=$1", database.RolesTable)
+	if err := r.db.Get(&role, query, name); err != nil {
+		return domain.Role{}, notFound(err)
+	}
+
+	return role, nil
+}
+
+func (r *RoleRepository) GetUserRoles(userId string) ([]domain.Role, error) {
+	var roles []domain.Role
+
+	query := fmt.Sprintf("select roles.id, roles.name, roles.permissions from %s roles inner join %s ur on ur.roleid = roles.id where ur.userid=$1 order by roles.id",
+		database.RolesTable, database.UserRolesTable)
+	if err := r.db.Select(&roles, query, userId); err != nil {
+		return nil, err
+	}
+
+	return roles, nil
+}
+
+func (r *RoleRepository) GetAdminRoles(adminId string) ([]domain.Role, error) {
+	var roles []domain.Role
+
+	query := fmt.Sprintf("select roles.id, roles.name, roles.permissions from %s roles inner join %s ar on ar.roleid = roles.id where ar.adminid=$1 order by roles.id",
+		database.RolesTable, database.AdminRolesTable)
+	if err := r.db.Select(&roles, query, adminId); err != nil {
+		return nil, err
+	}
+
+	return roles, nil
+}
+
+func (r *RoleRepository) AssignUserRole(userId, roleId string) error {
+	query := fmt.Sprintf("insert into %s (userid, roleid) values ($1, $2) on conflict do nothing", database.UserRolesTable)
+	_, err := r.db.Exec(query, userId, roleId)
+
+	return subjectNotFound(err)
+}
+
+func (r *RoleRepository) RevokeUserRole(userId, roleId string) error {
+	query := fmt.Sprintf("delete from %s where userid=$1 and roleid=$2", database.UserRolesTable)
+	res, err := r.db.Exec(query, userId, roleId)
+	if err != nil {
+		return err
+	}
+
+	return checkAffected(res)
+}
+
+func (r *RoleRepository) AssignAdminRole(adminId, roleId string) error {
+	query := fmt.Sprintf("insert into %s (adminid, roleid) values ($1, $2) on conflict do nothing", database.AdminRolesTable)
+	_, err := r.db.Exec(query, adminId, roleId)
+
+	return subjectNotFound(err)
+}
+
+func (r *RoleRepository) RevokeAdminRole(adminId, roleId string) error {
+	query := fmt.Sprintf("delete from %s where adminid=$1 and roleid=$2", database.AdminRolesTable)
+	res, err := r.db.Exec(query, adminId, roleId)
+	if err != nil {
+		return err
+	}
+
+	return checkAffected(res)
+}
+
+// subjectNotFound reports an assignment to a missing user or admin as sql.ErrNoRows.
+func subjectNotFound(err error) error {
+	var pqErr *pq.Error
+	if errors.As(err, &pqErr) && pqErr.Code == foreignKeyViolation {
+		return notFound(sql.ErrNoRows)
+	}
+
+	return err
+}
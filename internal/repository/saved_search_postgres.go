@@ -0,0 +1,123 @@
+package repository
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+	"github.com/TakoB222/postingAds-api/internal/domain"
+	"github.com/TakoB222/postingAds-api/pkg/database"
+	"github.com/jmoiron/sqlx"
+	"github.com/lib/pq"
+	"strings"
+	"time"
+)
+
+const savedSearchColumns = "id, user_id, name, query, filters, channel, webhook_url, created_at, checked_at"
+
+type SavedSearchRepository struct {
+	db   *sqlx.DB
+	tree *CategoryTree
+}
+
+func NewSavedSearchRepository(db *sqlx.DB, tree *CategoryTree) *SavedSearchRepository {
+	return &SavedSearchRepository{db: db, tree: tree}
+}
+
+// CreateSavedSearch refuses to save more than maxPerUser searches of a user.
+func (r *SavedSearchRepository) CreateSavedSearch(search domain.SavedSearch, maxPerUser int) (int, error) {
+	var id int
+	query := fmt.Sprintf(`insert into %s (user_id, name, query, filters, channel, webhook_url)
+		select $1, $2, $3, $4, $5, $6 where (select count(*) from %s where user_id=$1) < $7 returning id`,
+		database.SavedSearchesTable, database.SavedSearchesTable)
+	err := r.db.Get(&id, query, search.UserId, search.Name, search.Query, search.Filters, search.Channel, search.WebhookURL, maxPerUser)
+	if errors.Is(err, sql.ErrNoRows) {
+		return 0, ErrTooManySavedSearches
+	}
+
+	return id, err
+}
+
+func (r *SavedSearchRepository) GetSavedSearch(userId string, searchId int) (domain.SavedSearch, error) {
+	var search domain.SavedSearch
+
+	query := fmt.Sprintf("select %s from %s where id=$1 and user_id=$2", savedSearchColumns, database.SavedSearchesTable)
+	if err := r.db.Get(&search, query, searchId, userId); err != nil {
+		return domain.SavedSearch{}, notFound(err)
+	}
+
+	return search, nil
+}
+
+func (r *SavedSearchRepository) GetSavedSearches(userId string) ([]domain.SavedSearch, error) {
+	searches := make([]domain.SavedSearch, 0)
+
+	query := fmt.Sprintf("select %s from %s where user_id=$1 order by id", savedSearchColumns, database.SavedSearchesTable)
+	if err := r.db.Select(&searches, query, userId); err != nil {
+		return nil, err
+	}
+
+	return searches, nil
+}
+
+func (r *SavedSearchRepository) DeleteSavedSearch(userId string, searchId int) error {
+	query := fmt.Sprintf("delete from %s where id=$1 and user_id=$2", database.SavedSearchesTable)
+	res, err := r.db.Exec(query, searchId, userId)
+	if err != nil {
+		return err
+	}
+
+	return checkAffected(res)
+}
+
+// ClaimDueSavedSearches marks searches checked before the time as checked now and returns them,
+// locked rows are skipped, so every search is claimed by a single instance of the api.
+func (r *SavedSearchRepository) ClaimDueSavedSearches(checkedBefore time.Time, limit int) ([]DueSavedSearch, error) {
+	searches := make([]DueSavedSearch, 0)
+
+	columns := "s." + strings.Join(strings.Split(savedSearchColumns, ", "), ", s.")
+	query := fmt.Sprintf(`update %s s set checked_at=now() from %s users
+		where users.id = s.user_id and s.id in (select id from %s where checked_at < $1 order by checked_at limit $2 for update skip locked)
+		returning %s, users.email`,
+		database.SavedSearchesTable, database.UsersTable, database.SavedSearchesTable, columns)
+	if err := r.db.Select(&searches, query, checkedBefore, limit); err != nil {
+		return nil, err
+	}
+
+	return searches, nil
+}
+
+// GetNewSearchMatches returns ads of other users published after the search was saved and not matched by it yet,
+// the oldest go first.
+func (r *SavedSearchRepository) GetNewSearchMatches(search domain.SavedSearch, filter AdsFilter, limit int) ([]domain.Ad, error) {
+	fromQuery, whereQuery, args := searchConditions(search.Query, filter)
+	argId := len(args) + 1
+
+	ads := make([]domain.Ad, 0)
+	query := fmt.Sprintf(`select %s from %s where %s and ads.published_at > $%d and ads.userid <> $%d
+		and not exists (select 1 from %s m where m.saved_search_id = $%d and m.ad_id = ads.id)
+		order by ads.published_at, ads.id limit $%d`,
+		adColumns, fromQuery, whereQuery, argId, argId+1, database.SavedSearchMatchesTable, argId+2, argId+3)
+	args = append(args, search.CreatedAt, search.UserId, search.Id, limit)
+	if err := r.db.Select(&ads, query, args...); err != nil {
+		return nil, err
+	}
+
+	if err := r.tree.setPaths(ads); err != nil {
+		return nil, err
+	}
+
+	return ads, nil
+}
+
+// RecordSearchMatches returns ids of the ads which were not matched by the search before.
+func (r *SavedSearchRepository) RecordSearchMatches(searchId int, adIds []int) ([]int, error) {
+	recorded := make([]int, 0, len(adIds))
+
+	query := fmt.Sprintf("insert into %s (saved_search_id, ad_id) select $1, unnest($2::int[]) on conflict do nothing returning ad_id",
+		database.SavedSearchMatchesTable)
+	if err := r.db.Select(&recorded, query, searchId, pq.Array(adIds)); err != nil {
+		return nil, err
+	}
+
+	return recorded, nil
+}
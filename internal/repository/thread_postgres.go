@@ -0,0 +1,138 @@
+package repository
+
+import (
+	"fmt"
+	"github.com/TakoB222/postingAds-api/internal/domain"
+	"github.com/TakoB222/postingAds-api/pkg/database"
+	"github.com/jmoiron/sqlx"
+)
+
+const messageColumns = "id, thread_id, sender_id, body, created_at, read_at"
+
+type ThreadRepository struct {
+	db *sqlx.DB
+}
+
+func NewThreadRepository(db *sqlx.DB) *ThreadRepository {
+	return &ThreadRepository{db: db}
+}
+
+// threadsQuery selects threads of the user given by $1 with the last message and the number of messages the user has not read.
+func threadsQuery(where string) string {
+	return fmt.Sprintf(`select t.id, t.ad_id, a.title as ad_title, t.buyer_id, t.seller_id, t.created_at, t.last_message_at,
+		coalesce((select m.body from %[3]s m where m.thread_id = t.id order by m.created_at desc, m.id desc limit 1), '') as last_message,
+		(select count(*) from %[3]s m where m.thread_id = t.id and m.sender_id <> $1 and m.read_at is null) as unread,
+		exists(select 1 from %[4]s b where b.user_id = t.seller_id and b.blocked_user_id = t.buyer_id) as blocked
+		from %[1]s t join %[2]s a on a.id = t.ad_id
+		where (t.buyer_id = $1 or t.seller_id = $1) %[5]s`,
+		database.ThreadsTable, database.AdsTable, database.ThreadMessagesTable, database.UserBlocksTable, where)
+}
+
+// GetAdSeller returns the id of the user of a published ad.
+func (r *ThreadRepository) GetAdSeller(adId string) (string, error) {
+	return getPublishedAdOwner(r.db, adId)
+}
+
+// CreateThread returns the thread the buyer already has about the ad or opens a new one.
+func (r *ThreadRepository) CreateThread(adId, buyerId, sellerId string) (int, error) {
+	var id int
+
+	query := fmt.Sprintf(`insert into %s (ad_id, buyer_id, seller_id) values ($1, $2, $3)
+		on conflict (ad_id, buyer_id) do update set ad_id = excluded.ad_id returning id`, database.ThreadsTable)
+	if err := r.db.Get(&id, query, adId, buyerId, sellerId); err != nil {
+		return 0, err
+	}
+
+	return id, nil
+}
+
+// GetThread returns sql.ErrNoRows unless the user takes part in the thread.
+func (r *ThreadRepository) GetThread(userId string, threadId int) (domain.Thread, error) {
+	var thread domain.Thread
+
+	if err := r.db.Get(&thread, threadsQuery("and t.id = $2"), userId, threadId); err != nil {
+		return domain.Thread{}, notFound(err)
+	}
+
+	return thread, nil
+}
+
+// GetThreads returns a page of threads of the user, the last active go first, and the number of unread messages of all of them.
+func (r *ThreadRepository) GetThreads(userId string, limit, offset int) ([]domain.Thread, int, error) {
+	var unread int
+	query := fmt.Sprintf(`select count(*) from %s m join %s t on t.id = m.thread_id
+		where (t.buyer_id = $1 or t.seller_id = $1) and m.sender_id <> $1 and m.read_at is null`,
+		database.ThreadMessagesTable, database.ThreadsTable)
+	if err := r.db.Get(&unread, query, userId); err != nil {
+		return nil, 0, err
+	}
+
+	threads := make([]domain.Thread, 0)
+	if err := r.db.Select(&threads, threadsQuery("order by t.last_message_at desc, t.id desc limit $2 offset $3"), userId, limit, offset); err != nil {
+		return nil, 0, err
+	}
+
+	return threads, unread, nil
+}
+
+// CreateMessage adds a message to the thread and moves the thread to the top of the lists of its participants.
+func (r *ThreadRepository) CreateMessage(threadId int, senderId, body string) (domain.Message, error) {
+	var message domain.Message
+
+	query := fmt.Sprintf(`with message as (
+			insert into %[1]s (thread_id, sender_id, body) values ($1, $2, $3) returning %[3]s
+		), thread as (
+			update %[2]s t set last_message_at = message.created_at from message where t.id = message.thread_id
+		)
+		select %[3]s from message`, database.ThreadMessagesTable, database.ThreadsTable, messageColumns)
+	if err := r.db.Get(&message, query, threadId, senderId, body); err != nil {
+		return domain.Message{}, err
+	}
+
+	return message, nil
+}
+
+// GetMessages returns a page of messages of the thread, the newest go first.
+func (r *ThreadRepository) GetMessages(threadId int, limit, offset int) ([]domain.Message, error) {
+	messages := make([]domain.Message, 0)
+
+	query := fmt.Sprintf("select %s from %s where thread_id=$1 order by created_at desc, id desc limit $2 offset $3", messageColumns, database.ThreadMessagesTable)
+	if err := r.db.Select(&messages, query, threadId, limit, offset); err != nil {
+		return nil, err
+	}
+
+	return messages, nil
+}
+
+// ReadThread marks messages the other participant sent to the user as read.
+func (r *ThreadRepository) ReadThread(userId string, threadId int) error {
+	query := fmt.Sprintf("update %s set read_at=now() where thread_id=$1 and sender_id<>$2 and read_at is null", database.ThreadMessagesTable)
+	_, err := r.db.Exec(query, threadId, userId)
+
+	return err
+}
+
+func (r *ThreadRepository) IsBlocked(userId, blockedUserId string) (bool, error) {
+	var blocked bool
+
+	query := fmt.Sprintf("select exists(select 1 from %s where user_id=$1 and blocked_user_id=$2)", database.UserBlocksTable)
+	if err := r.db.Get(&blocked, query, userId, blockedUserId); err != nil {
+		return false, err
+	}
+
+	return blocked, nil
+}
+
+func (r *ThreadRepository) BlockUser(userId, blockedUserId string) error {
+	query := fmt.Sprintf("insert into %s (user_id, blocked_user_id) values ($1, $2) on conflict do nothing", database.UserBlocksTable)
+	_, err := r.db.Exec(query, userId, blockedUserId)
+
+	return err
+}
+
+func (r *ThreadRepository) UnblockUser(userId, blockedUserId string) error {
+	query := fmt.Sprintf("delete from %s where user_id=$1 and blocked_user_id=$2", database.UserBlocksTable)
+	_, err := r.db.Exec(query, userId, blockedUserId)
+
+	return err
+}
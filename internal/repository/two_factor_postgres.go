@@ -0,0 +1,200 @@
+package repository
+
+import (
+	"fmt"
+	"github.com/TakoB222/postingAds-api/internal/domain"
+	"github.com/TakoB222/postingAds-api/pkg/database"
+	"github.com/jmoiron/sqlx"
+	"time"
+)
+
+// twoFactorTables describes where the TOTP state of users or admins is kept, both are handled by the same queries.
+type twoFactorTables struct {
+	owner         string
+	accountColumn string
+	recoveryCodes string
+	ownerColumn   string
+}
+
+var (
+	userTwoFactorTables  = twoFactorTables{database.UsersTable, "email", database.UserRecoveryCodesTable, "userid"}
+	adminTwoFactorTables = twoFactorTables{database.AdminsTable, "login", database.AdminRecoveryCodesTable, "adminid"}
+)
+
+func getTwoFactor(db *sqlx.DB, t twoFactorTables, id string) (domain.TwoFactor, error) {
+	var twoFactor domain.TwoFactor
+
+	query := fmt.Sprintf(`select %s as account, coalesce(totp_secret, '') as totp_secret, totp_enabled, totp_last_step, totp_failed_attempts, totp_locked_until
+								from %s where id=$1`, t.accountColumn, t.owner)
+	if err := db.Get(&twoFactor, query, id); err != nil {
+		return domain.TwoFactor{}, notFound(err)
+	}
+
+	return twoFactor, nil
+}
+
+func setTwoFactorSecret(db *sqlx.DB, t twoFactorTables, id, secret string) error {
+	query := fmt.Sprintf("update %s set totp_secret=$1, totp_last_step=0, totp_failed_attempts=0, totp_locked_until=null where id=$2 and not totp_enabled", t.owner)
+	res, err := db.Exec(query, secret, id)
+	if err != nil {
+		return err
+	}
+
+	return checkAffected(res)
+}
+
+func enableTwoFactor(db *sqlx.DB, t twoFactorTables, id string, step int64, recoveryCodeHashes []string) error {
+	tx, err := db.Begin()
+	if err != nil {
+		return err
+	}
+
+	query := fmt.Sprintf("update %s set totp_enabled=true, totp_last_step=$1 where id=$2 and not totp_enabled and totp_secret is not null", t.owner)
+	res, err := tx.Exec(query, step, id)
+	if err == nil {
+		err = checkAffected(res)
+	}
+	if err != nil {
+		_ = tx.Rollback()
+		return err
+	}
+
+	query = fmt.Sprintf("delete from %s where %s=$1", t.recoveryCodes, t.ownerColumn)
+	if _, err := tx.Exec(query, id); err != nil {
+		_ = tx.Rollback()
+		return err
+	}
+
+	query = fmt.Sprintf("insert into %s (%s, codehash) values ($1, $2)", t.recoveryCodes, t.ownerColumn)
+	for _, codeHash := range recoveryCodeHashes {
+		if _, err := tx.Exec(query, id, codeHash); err != nil {
+			_ = tx.Rollback()
+			return err
+		}
+	}
+
+	return tx.Commit()
+}
+
+func disableTwoFactor(db *sqlx.DB, t twoFactorTables, id string) error {
+	tx, err := db.Begin()
+	if err != nil {
+		return err
+	}
+
+	query := fmt.Sprintf("update %s set totp_secret=null, totp_enabled=false, totp_last_step=0, totp_failed_attempts=0, totp_locked_until=null where id=$1", t.owner)
+	if _, err := tx.Exec(query, id); err != nil {
+		_ = tx.Rollback()
+		return err
+	}
+
+	query = fmt.Sprintf("delete from %s where %s=$1", t.recoveryCodes, t.ownerColumn)
+	if _, err := tx.Exec(query, id); err != nil {
+		_ = tx.Rollback()
+		return err
+	}
+
+	return tx.Commit()
+}
+
+// useTotpStep accepts a code only once: sql.ErrNoRows is returned when its step has already been used.
+func useTotpStep(db *sqlx.DB, t twoFactorTables, id string, step int64) error {
+	query := fmt.Sprintf("update %s set totp_last_step=$1, totp_failed_attempts=0, totp_locked_until=null where id=$2 and totp_last_step < $1", t.owner)
+	res, err := db.Exec(query, step, id)
+	if err != nil {
+		return err
+	}
+
+	return checkAffected(res)
+}
+
+func useRecoveryCode(db *sqlx.DB, t twoFactorTables, id, codeHash string, now time.Time) error {
+	tx, err := db.Begin()
+	if err != nil {
+		return err
+	}
+
+	query := fmt.Sprintf("update %s set usedat=$1 where %s=$2 and codehash=$3 and usedat is null", t.recoveryCodes, t.ownerColumn)
+	res, err := tx.Exec(query, now, id, codeHash)
+	if err == nil {
+		err = checkAffected(res)
+	}
+	if err != nil {
+		_ = tx.Rollback()
+		return err
+	}
+
+	query = fmt.Sprintf("update %s set totp_failed_attempts=0, totp_locked_until=null where id=$1", t.owner)
+	if _, err := tx.Exec(query, id); err != nil {
+		_ = tx.Rollback()
+		return err
+	}
+
+	return tx.Commit()
+}
+
+// failTwoFactor counts an invalid code and locks the second factor once maxAttempts is reached.
+func failTwoFactor(db *sqlx.DB, t twoFactorTables, id string, maxAttempts int, lockedUntil time.Time) error {
+	query := fmt.Sprintf(`update %s set totp_failed_attempts = case when totp_failed_attempts + 1 >= $1 then 0 else totp_failed_attempts + 1 end,
+								totp_locked_until = case when totp_failed_attempts + 1 >= $1 then $2 else totp_locked_until end
+								where id=$3`, t.owner)
+	_, err := db.Exec(query, maxAttempts, lockedUntil, id)
+
+	return err
+}
+
+func (r *AuthRepository) GetUserTwoFactor(userId string) (domain.TwoFactor, error) {
+	return getTwoFactor(r.db, userTwoFactorTables, userId)
+}
+
+func (r *AuthRepository) SetUserTwoFactorSecret(userId, secret string) error {
+	return setTwoFactorSecret(r.db, userTwoFactorTables, userId, secret)
+}
+
+func (r *AuthRepository) EnableUserTwoFactor(userId string, step int64, recoveryCodeHashes []string) error {
+	return enableTwoFactor(r.db, userTwoFactorTables, userId, step, recoveryCodeHashes)
+}
+
+func (r *AuthRepository) DisableUserTwoFactor(userId string) error {
+	return disableTwoFactor(r.db, userTwoFactorTables, userId)
+}
+
+func (r *AuthRepository) UseUserTotpStep(userId string, step int64) error {
+	return useTotpStep(r.db, userTwoFactorTables, userId, step)
+}
+
+func (r *AuthRepository) UseUserRecoveryCode(userId, codeHash string, now time.Time) error {
+	return useRecoveryCode(r.db, userTwoFactorTables, userId, codeHash, now)
+}
+
+func (r *AuthRepository) FailUserTwoFactor(userId string, maxAttempts int, lockedUntil time.Time) error {
+	return failTwoFactor(r.db, userTwoFactorTables, userId, maxAttempts, lockedUntil)
+}
+
+func (r *AdminRepository) GetAdminTwoFactor(adminId string) (domain.TwoFactor, error) {
+	return getTwoFactor(r.db, adminTwoFactorTables, adminId)
+}
+
+func (r *AdminRepository) SetAdminTwoFactorSecret(adminId, secret string) error {
+	return setTwoFactorSecret(r.db, adminTwoFactorTables, adminId, secret)
+}
+
+func (r *AdminRepository) EnableAdminTwoFactor(adminId string, step int64, recoveryCodeHashes []string) error {
+	return enableTwoFactor(r.db, adminTwoFactorTables, adminId, step, recoveryCodeHashes)
+}
+
+func (r *AdminRepository) DisableAdminTwoFactor(adminId string) error {
+	return disableTwoFactor(r.db, adminTwoFactorTables, adminId)
+}
+
+func (r *AdminRepository) UseAdminTotpStep(adminId string, step int64) error {
+	return useTotpStep(r.db, adminTwoFactorTables, adminId, step)
+}
+
+func (r *AdminRepository) UseAdminRecoveryCode(adminId, codeHash string, now time.Time) error {
+	return useRecoveryCode(r.db, adminTwoFactorTables, adminId, codeHash, now)
+}
+
+func (r *AdminRepository) FailAdminTwoFactor(adminId string, maxAttempts int, lockedUntil time.Time) error {
+	return failTwoFactor(r.db, adminTwoFactorTables, adminId, maxAttempts, lockedUntil)
+}
@@ -1,16 +1,35 @@
 package service
 
 import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
 	"github.com/TakoB222/postingAds-api/internal/domain"
 	"github.com/TakoB222/postingAds-api/internal/repository"
+	"strconv"
+	"strings"
+	"time"
+	"unicode"
+	"unicode/utf8"
+)
+
+const (
+	// suggestions are requested as the user types, they must not hold the front end
+	suggestTimeout        = 300 * time.Millisecond
+	minSuggestQueryLength = 2
 )
 
 type AdService struct {
-	repo repository.Ad
+	repo       repository.Ad
+	images     *ImageService
+	categories *CategoryService
+	favorites  *FavoriteService
+	contacts   *ContactService
 }
 
-func NewAdService(repo repository.Ad) *AdService {
-	return &AdService{repo: repo}
+func NewAdService(repo repository.Ad, images *ImageService, categories *CategoryService, favorites *FavoriteService, contacts *ContactService) *AdService {
+	return &AdService{repo: repo, images: images, categories: categories, favorites: favorites, contacts: contacts}
 }
 
 func (s *AdService) GetAllAds(userId string) ([]domain.Ad, error) {
@@ -23,60 +42,368 @@ func (s *AdService) GetAllAds(userId string) ([]domain.Ad, error) {
 }
 
 func (s *AdService) CreateAd(userId string, adInput Ads) (int, error) {
+	status := domain.AdStatusDraft
+	if adInput.Published {
+		status = domain.AdStatusPendingReview
+	}
+
+	category, err := s.categories.resolveCategory(adInput.Category)
+	if err != nil {
+		return 0, err
+	}
+
+	attributes, err := s.categories.validateAttributes(category.Id, adInput.Attributes)
+	if err != nil {
+		return 0, err
+	}
+
+	language, err := adLanguage(adInput, domain.Ad{})
+	if err != nil {
+		return 0, err
+	}
+
+	if err := s.images.checkAdImages(userId, "", adInput.ImagesURL, nil); err != nil {
+		return 0, err
+	}
+
+	if err := s.contacts.checkAdContacts(userId, adInput); err != nil {
+		return 0, err
+	}
+
 	adId, err := s.repo.CreateAd(userId, repository.Ads{
-		Title:       adInput.Title,
-		Category:    adInput.Category,
-		Description: adInput.Description,
-		Price:       adInput.Price,
-		Contacts:    repository.Contacts(adInput.Contacts),
-		Published:   adInput.Published,
-		ImagesURL:   adInput.ImagesURL,
+		Title:            adInput.Title,
+		Category:         strconv.Itoa(category.Id),
+		Description:      adInput.Description,
+		Price:            adInput.Price,
+		Contacts:         repository.Contacts(adInput.Contacts),
+		ContactProfileId: adInput.ContactProfileId,
+		Status:           status,
+		ImagesURL:        adInput.ImagesURL,
+		Attributes:       attributes,
+		Language:         language,
 	})
 	if err != nil {
 		return 0, err
 	}
+
+	if err := s.images.syncAdImages(fmt.Sprint(adId), adInput.ImagesURL); err != nil {
+		return 0, err
+	}
+
 	return adId, nil
 }
 
 func (s *AdService) GetAdById(userId string, adId string) (domain.Ad, error) {
 	ad, err := s.repo.GetAdById(userId, adId)
 	if err != nil {
-		return domain.Ad{}, err
+		return domain.Ad{}, adNotFound(err)
 	}
 
 	return ad, nil
 }
 
 func (s *AdService) UpdateAd(userId string, adId string, ad Ads) (domain.Ad, error) {
-	err := s.repo.UpdateAd(userId, adId, repository.Ads{
-		Title:       ad.Title,
-		Category:    ad.Category,
-		Description: ad.Description,
-		Price:       ad.Price,
-		Contacts:    repository.Contacts(ad.Contacts),
-		Published:   ad.Published,
-		ImagesURL:   ad.ImagesURL,
-	})
+	current, err := s.repo.GetAdById(userId, adId)
+	if err != nil {
+		return domain.Ad{}, adNotFound(err)
+	}
+
+	// any edit of an ad that has already been submitted sends it back to review
+	status := domain.AdStatusPendingReview
+	if current.Status == domain.AdStatusDraft && !ad.Published {
+		status = domain.AdStatusDraft
+	}
+
+	if !current.Status.CanTransitionTo(status) {
+		return domain.Ad{}, fmt.Errorf("%w from %s to %s", ErrIllegalAdStatusTransition, current.Status, status)
+	}
+
+	category, err := s.categories.resolveCategory(ad.Category)
 	if err != nil {
 		return domain.Ad{}, err
 	}
 
+	attributes, err := s.categories.validateAttributes(category.Id, adAttributes(ad, current))
+	if err != nil {
+		return domain.Ad{}, err
+	}
+
+	language, err := adLanguage(ad, current)
+	if err != nil {
+		return domain.Ad{}, err
+	}
+
+	if err := s.images.checkAdImages(userId, adId, ad.ImagesURL, current.ImagesURL); err != nil {
+		return domain.Ad{}, err
+	}
+
+	if err := s.contacts.checkAdContacts(userId, ad); err != nil {
+		return domain.Ad{}, err
+	}
+
+	err = s.repo.UpdateAd(userId, adId, current.Status, repository.Ads{
+		Title:            ad.Title,
+		Category:         strconv.Itoa(category.Id),
+		Description:      ad.Description,
+		Price:            ad.Price,
+		Contacts:         repository.Contacts(ad.Contacts),
+		ContactProfileId: ad.ContactProfileId,
+		Status:           status,
+		ImagesURL:        ad.ImagesURL,
+		Attributes:       attributes,
+		Language:         language,
+	})
+	if err != nil {
+		return domain.Ad{}, adNotFound(err)
+	}
+
+	// an empty list leaves the images of the ad as they are
+	if len(ad.ImagesURL) > 0 {
+		if err := s.images.syncAdImages(adId, ad.ImagesURL); err != nil {
+			return domain.Ad{}, err
+		}
+	}
+
+	// the edit goes to review, favorites learn about a new price when it is approved
 	return s.GetAdById(userId, adId)
 }
 
 func (s *AdService) DeleteAd(userId string, adId string) error {
-	if err := s.repo.DeleteAd(userId, adId); err != nil {
+	ad, err := s.repo.GetAdById(userId, adId)
+	if err != nil {
+		return adNotFound(err)
+	}
+
+	images, err := s.images.repo.GetImagesByAdId(adId)
+	if err != nil {
 		return err
 	}
 
+	userIds := s.favorites.favoriteUserIds(adId)
+
+	if err := s.repo.DeleteAd(userId, adId); err != nil {
+		return adNotFound(err)
+	}
+
+	s.images.removeImages(images)
+	s.favorites.notifyRemoved(ad, userIds)
+
 	return nil
 }
 
-func (s *AdService) Fts(search_request string)([]repository.FtsResponse, error) {
-	ads, err := s.repo.SearchAdByRequest(search_request)
+func (s *AdService) Fts(input FtsInput) (FtsResult, error) {
+	if input.Language != "" && !domain.IsLanguage(input.Language) {
+		return FtsResult{}, fmt.Errorf("%w %q", ErrUnsupportedLanguage, input.Language)
+	}
+
+	filter, err := s.publishedAdsFilter(input.Filter)
+	if err != nil {
+		return FtsResult{}, err
+	}
+	filter.Language = input.Language
+
+	ads, total, err := s.repo.SearchAdByRequest(input.Request, filter)
 	if err != nil {
+		return FtsResult{}, err
+	}
+	for i := range ads {
+		maskAdContacts(&ads[i].Ad)
+	}
+
+	res := FtsResult{Ads: ads, Total: total}
+	if total == 0 {
+		if res.DidYouMean, err = s.didYouMean(input.Request); err != nil {
+			return FtsResult{}, err
+		}
+	}
+
+	if input.Facets {
+		facets := repository.SearchFacets{
+			Categories: []repository.CategoryFacet{},
+			Locations:  []repository.LocationFacet{},
+			Prices:     []repository.PriceFacet{},
+		}
+		if total > 0 {
+			if facets, err = s.repo.GetSearchFacets(input.Request, filter); err != nil {
+				return FtsResult{}, err
+			}
+		}
+		res.Facets = &facets
+	}
+
+	return res, nil
+}
+
+// Suggest completes a search request as it is typed, suggestions taking too long are skipped.
+func (s *AdService) Suggest(query string, limit int) ([]repository.Suggestion, error) {
+	query = strings.TrimSpace(query)
+	if utf8.RuneCountInString(query) < minSuggestQueryLength {
+		return []repository.Suggestion{}, nil
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), suggestTimeout)
+	defer cancel()
+
+	suggestions, err := s.repo.SuggestSearch(ctx, query, limit)
+	if err != nil {
+		if ctx.Err() != nil {
+			return []repository.Suggestion{}, nil
+		}
 		return nil, err
 	}
 
-	return ads, nil
+	return suggestions, nil
+}
+
+func (s *AdService) GetPublishedAds(filter AdsFilter) ([]domain.Ad, int, error) {
+	repoFilter, err := s.publishedAdsFilter(filter)
+	if err != nil {
+		return nil, 0, err
+	}
+
+	ads, total, err := s.repo.GetPublishedAds(repoFilter)
+	if err != nil {
+		return nil, 0, err
+	}
+	maskContacts(ads)
+
+	return ads, total, nil
+}
+
+func (s *AdService) ArchiveAd(userId, adId string) (domain.Ad, error) {
+	ad, err := s.repo.GetAdById(userId, adId)
+	if err != nil {
+		return domain.Ad{}, adNotFound(err)
+	}
+
+	if !ad.Status.CanTransitionTo(domain.AdStatusArchived) {
+		return domain.Ad{}, fmt.Errorf("%w from %s to %s", ErrIllegalAdStatusTransition, ad.Status, domain.AdStatusArchived)
+	}
+
+	if err := s.repo.SetAdStatus(userId, adId, ad.Status, domain.AdStatusArchived); err != nil {
+		return domain.Ad{}, adNotFound(err)
+	}
+
+	// drafts and ads waiting for review were never shown in favorites, there is nothing to tell about them
+	if ad.Status == domain.AdStatusApproved {
+		s.favorites.notifyRemoved(ad, s.favorites.favoriteUserIds(adId))
+	}
+
+	return s.GetAdById(userId, adId)
+}
+
+// adNotFound reports a missing ad as ErrAdNotFound, clients get the same code for it from every endpoint.
+func adNotFound(err error) error {
+	if errors.Is(err, sql.ErrNoRows) {
+		return ErrAdNotFound
+	}
+
+	return err
+}
+
+// filterCategory resolves the category of a filter, zero means ads of any category.
+func (s *AdService) filterCategory(ref string) (int, error) {
+	if ref == "" {
+		return 0, nil
+	}
+
+	category, err := s.categories.resolveCategory(ref)
+	if err != nil {
+		return 0, err
+	}
+
+	return category.Id, nil
+}
+
+// publishedAdsFilter resolves the category and the attribute filters of the catalogue.
+func (s *AdService) publishedAdsFilter(filter AdsFilter) (repository.AdsFilter, error) {
+	categoryId, err := s.filterCategory(filter.Category)
+	if err != nil {
+		return repository.AdsFilter{}, err
+	}
+
+	attributes, err := s.categories.attributeFilters(categoryId, filter.Attributes)
+	if err != nil {
+		return repository.AdsFilter{}, err
+	}
+
+	return repository.AdsFilter{
+		CategoryId: categoryId,
+		MinPrice:   filter.MinPrice,
+		MaxPrice:   filter.MaxPrice,
+		Location:   filter.Location,
+		Attributes: attributes,
+		Sort:       filter.Sort,
+		Limit:      filter.Limit,
+		Offset:     filter.Offset,
+	}, nil
+}
+
+// didYouMean corrects misspelled words of a request which found nothing, empty means there is no correction.
+func (s *AdService) didYouMean(request string) (string, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), suggestTimeout)
+	defer cancel()
+
+	words := strings.FieldsFunc(strings.ToLower(request), func(r rune) bool {
+		return !unicode.IsLetter(r) && !unicode.IsNumber(r)
+	})
+
+	corrected := false
+	for i, word := range words {
+		correction, err := s.repo.CorrectSearchWord(ctx, word)
+		if err != nil {
+			if errors.Is(err, sql.ErrNoRows) {
+				continue
+			}
+			if ctx.Err() != nil {
+				return "", nil
+			}
+			return "", err
+		}
+
+		if correction != word {
+			words[i] = correction
+			corrected = true
+		}
+	}
+
+	if !corrected {
+		return "", nil
+	}
+
+	return strings.Join(words, " "), nil
+}
+
+// adLanguage returns the declared language of the ad, otherwise the current one is kept
+// until the text changes and is detected again.
+func adLanguage(ad Ads, current domain.Ad) (string, error) {
+	if ad.Language != "" {
+		if !domain.IsLanguage(ad.Language) {
+			return "", fmt.Errorf("%w %q", ErrUnsupportedLanguage, ad.Language)
+		}
+		return ad.Language, nil
+	}
+
+	title, description := ad.Title, ad.Description
+	if title == "" {
+		title = current.Title
+	}
+	if description == "" {
+		description = current.Description
+	}
+
+	if current.Language != "" && title == current.Title && description == current.Description {
+		return current.Language, nil
+	}
+
+	return domain.DetectLanguage(title + " " + description), nil
+}
+
+// adAttributes returns attributes of the update, the current ones are kept when the update has none.
+func adAttributes(ad Ads, current domain.Ad) map[string]interface{} {
+	if ad.Attributes != nil {
+		return ad.Attributes
+	}
+
+	return current.Attributes
 }
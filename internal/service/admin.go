@@ -1,44 +1,146 @@
 package service
 
 import (
+	"database/sql"
+	"errors"
+	"fmt"
 	"github.com/TakoB222/postingAds-api/internal/domain"
 	"github.com/TakoB222/postingAds-api/internal/repository"
+	"github.com/TakoB222/postingAds-api/pkg/apperror"
 	"github.com/TakoB222/postingAds-api/pkg/auth"
 	"github.com/TakoB222/postingAds-api/pkg/hash"
+	"github.com/TakoB222/postingAds-api/pkg/logger"
+	"github.com/TakoB222/postingAds-api/pkg/otp"
+	"strconv"
 	"time"
 )
 
 type AdminService struct {
 	repo         repository.Admin
+	roles        repository.Role
+	images       *ImageService
+	categories   *CategoryService
+	favorites    *FavoriteService
+	events       *EventService
 	tokenManager auth.TokenManager
-	hasher       *hash.SHA1Hasher
+	hasher       hash.PasswordHasher
 
 	AccessTokenTTL  time.Duration
 	RefreshTokenTTL time.Duration
+	TwoFactor       TwoFactorConfig
 }
 
-func NewAdminService(repo repository.Admin, tokenManager *auth.Manager, hasher *hash.SHA1Hasher, AccesTokenTTL, RefreshTokenTTL time.Duration) *AdminService {
-	return &AdminService{repo: repo, tokenManager: tokenManager, hasher: hasher, AccessTokenTTL: AccesTokenTTL, RefreshTokenTTL: RefreshTokenTTL}
+func NewAdminService(repo repository.Admin, roles repository.Role, images *ImageService, categories *CategoryService, favorites *FavoriteService, events *EventService,
+	tokenManager *auth.Manager, hasher hash.PasswordHasher, AccesTokenTTL, RefreshTokenTTL time.Duration, twoFactor TwoFactorConfig) *AdminService {
+	return &AdminService{repo: repo, roles: roles, images: images, categories: categories, favorites: favorites, events: events, tokenManager: tokenManager, hasher: hasher,
+		AccessTokenTTL: AccesTokenTTL, RefreshTokenTTL: RefreshTokenTTL, TwoFactor: twoFactor}
 }
 
 func (s *AdminService) AdminSignIn(input SignInInput) (Tokens, error) {
-	id, err := s.repo.GetAdminId(input.Email, s.hasher.Hash(input.Password))
+	admin, err := s.repo.GetAdminByLogin(input.Email)
 	if err != nil {
-		//TODO: create custom repository errors and handle them here
+		if errors.Is(err, sql.ErrNoRows) {
+			return Tokens{}, ErrInvalidCredentials
+		}
 		return Tokens{}, err
 	}
 
-	return s.createSession(id)
+	ok, err := s.hasher.Verify(input.Password, admin.Password_hash)
+	if err != nil {
+		return Tokens{}, err
+	}
+	if !ok {
+		return Tokens{}, ErrInvalidCredentials
+	}
+
+	// the password is known only at this point, so legacy hashes are upgraded on sign in
+	if s.hasher.NeedsRehash(admin.Password_hash) {
+		if err := s.rehashPassword(admin.Id, input.Password); err != nil {
+			logger.Errorf("failed to rehash password of admin %s: %s", admin.Id, err.Error())
+		}
+	}
+
+	if admin.TwoFactor {
+		challengeToken, err := s.tokenManager.NewChallengeToken(auth.Subject{Id: admin.Id, Kind: auth.KindAdmin}, s.TwoFactor.ChallengeTTL)
+		if err != nil {
+			return Tokens{}, err
+		}
+		return Tokens{ChallengeToken: challengeToken}, nil
+	}
+
+	return s.createSession(admin.Id, input.UserAgent, input.Ip)
+}
+
+func (s *AdminService) AdminSignInSecondFactor(input TwoFactorSignInInput) (Tokens, error) {
+	subject, err := s.tokenManager.ParseChallengeToken(input.ChallengeToken)
+	if err != nil || subject.Kind != auth.KindAdmin {
+		return Tokens{}, secondFactorError(ErrInvalidToken)
+	}
+
+	if err := s.verifySecondFactor(subject.Id, input.Code); err != nil {
+		return Tokens{}, secondFactorError(err)
+	}
+
+	return s.createSession(subject.Id, input.UserAgent, input.Ip)
 }
 
-func (s *AdminService) createSession(adminId string) (Tokens, error) {
+func (s *AdminService) rehashPassword(adminId, password string) error {
+	passwordHash, err := s.hasher.Hash(password)
+	if err != nil {
+		return err
+	}
+
+	return s.repo.UpdateAdminPasswordHash(adminId, passwordHash)
+}
+
+func (s *AdminService) createSession(adminId, userAgent, ip string) (Tokens, error) {
+	res, err := s.newTokens(adminId)
+	if err != nil {
+		return Tokens{}, err
+	}
+
+	now := time.Now()
+	session := domain.AdminSession{
+		AdminId:      adminId,
+		RefreshToken: auth.HashRefreshToken(res.RefreshToken),
+		CreatedAt:    now,
+		LastUsedAt:   now,
+		ExpiresIn:    now.Add(s.RefreshTokenTTL),
+		UserAgent:    userAgent,
+		Ip:           ip,
+	}
+
+	err = s.repo.SetAdminSession(session)
+	if err != nil {
+		return Tokens{}, err
+	}
+
+	return res, nil
+}
+
+func (s *AdminService) newTokens(adminId string) (Tokens, error) {
 	var (
 		res Tokens
 		err error
 	)
 
-	res.AccessToken, err = s.tokenManager.NewJWT(adminId, s.AccessTokenTTL)
+	roles, err := s.roles.GetAdminRoles(adminId)
+	if err != nil {
+		return res, err
+	}
 
+	// an admin without the required second factor can only enrol it, so the token grants no permissions
+	if s.TwoFactor.RequiredForAdmins {
+		twoFactor, err := s.repo.GetAdminTwoFactor(adminId)
+		if err != nil {
+			return res, err
+		}
+		if !twoFactor.Enabled {
+			roles = nil
+		}
+	}
+
+	res.AccessToken, err = s.tokenManager.NewJWT(newSubject(adminId, auth.KindAdmin, roles), s.AccessTokenTTL)
 	if err != nil {
 		return res, err
 	}
@@ -48,32 +150,118 @@ func (s *AdminService) createSession(adminId string) (Tokens, error) {
 		return res, err
 	}
 
-	session := domain.AdminSession{
-		AdminId:      adminId,
-		RefreshToken: res.RefreshToken,
-		CreatedAt:    time.Now(),
-		ExpiresIn:    time.Now().Add(s.RefreshTokenTTL),
+	return res, nil
+}
+
+func (s *AdminService) AdminRefreshSession(input RefreshInput) (Tokens, error) {
+	session, err := s.getAdminSession(input.RefreshToken)
+	if errors.Is(err, ErrSessionNotFound) {
+		return Tokens{}, s.checkRefreshTokenReuse(input)
+	}
+	if err != nil {
+		return Tokens{}, err
 	}
 
-	err = s.repo.SetAdminSession(session)
+	if session.ExpiresIn.Before(time.Now()) {
+		return Tokens{}, ErrSessionExpired
+	}
+
+	// every refresh rotates the token of the session, the previous one is remembered to detect its reuse
+	res, err := s.newTokens(session.AdminId)
 	if err != nil {
 		return Tokens{}, err
 	}
 
+	usedRefreshToken := session.RefreshToken
+
+	now := time.Now()
+	session.RefreshToken = auth.HashRefreshToken(res.RefreshToken)
+	session.ExpiresIn = now.Add(s.RefreshTokenTTL)
+	session.LastUsedAt = now
+	session.UserAgent = input.UserAgent
+	session.Ip = input.Ip
+
+	if err = s.repo.RotateAdminSession(session, usedRefreshToken); err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return Tokens{}, ErrSessionNotFound
+		}
+		return Tokens{}, err
+	}
+
 	return res, nil
 }
 
-func (s *AdminService) AdminRefreshSession(input RefreshInput) (Tokens, error) {
-	session, err := s.repo.GetAdminSessionByRefreshToken(input.RefreshToken)
+// checkRefreshTokenReuse revokes the whole session when an already rotated refresh token is presented:
+// either the legitimate client or an attacker holds a stolen token, and there is no way to tell which one.
+func (s *AdminService) checkRefreshTokenReuse(input RefreshInput) error {
+	session, err := s.repo.GetAdminSessionByUsedRefreshToken(auth.HashRefreshToken(input.RefreshToken))
 	if err != nil {
-		return Tokens{}, err
+		if errors.Is(err, sql.ErrNoRows) {
+			return ErrSessionNotFound
+		}
+		return err
 	}
 
-	if err = s.repo.DeleteAdminSessionByAdminId(session.AdminId); err != nil {
-		return Tokens{}, err
+	logger.Warnf("security event: reuse of rotated refresh token, admin %s, session %s, ip %s, user agent %s",
+		session.AdminId, session.Id, input.Ip, input.UserAgent)
+
+	if err := s.repo.DeleteAdminSession(session.AdminId, session.Id); err != nil && !errors.Is(err, sql.ErrNoRows) {
+		return err
+	}
+
+	return ErrRefreshTokenReused
+}
+
+func (s *AdminService) AdminLogout(adminId string, input RefreshInput) error {
+	err := s.repo.DeleteAdminSessionByRefreshToken(adminId, auth.HashRefreshToken(input.RefreshToken))
+	if errors.Is(err, sql.ErrNoRows) {
+		return ErrSessionNotFound
+	}
+
+	return err
+}
+
+func (s *AdminService) AdminGetSessions(adminId string) ([]domain.AdminSession, error) {
+	sessions, err := s.repo.GetAdminSessionsByAdminId(adminId)
+	if err != nil {
+		return nil, err
+	}
+
+	return sessions, nil
+}
+
+func (s *AdminService) AdminRevokeSession(adminId, sessionId string) error {
+	err := s.repo.DeleteAdminSession(adminId, sessionId)
+	if errors.Is(err, sql.ErrNoRows) {
+		return ErrSessionNotFound.WithKind(apperror.KindNotFound)
+	}
+
+	return err
+}
+
+func (s *AdminService) AdminRevokeOtherSessions(adminId string, input RefreshInput) error {
+	session, err := s.getAdminSession(input.RefreshToken)
+	if err != nil {
+		return err
 	}
 
-	return s.createSession(session.AdminId)
+	if session.AdminId != adminId {
+		return ErrSessionNotFound
+	}
+
+	return s.repo.DeleteOtherAdminSessions(adminId, session.RefreshToken)
+}
+
+func (s *AdminService) getAdminSession(refreshToken string) (domain.AdminSession, error) {
+	session, err := s.repo.GetAdminSessionByRefreshToken(auth.HashRefreshToken(refreshToken))
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return domain.AdminSession{}, ErrSessionNotFound
+		}
+		return domain.AdminSession{}, err
+	}
+
+	return session, nil
 }
 
 func (s *AdminService) AdminGetAllAdsByAdmin() ([]domain.Ad, error) {
@@ -88,24 +276,66 @@ func (s *AdminService) AdminGetAllAdsByAdmin() ([]domain.Ad, error) {
 func (s *AdminService) AdminGetAd(adId string) (domain.Ad, error) {
 	ad, err := s.repo.GetAd(adId)
 	if err != nil {
-		return domain.Ad{}, err
+		return domain.Ad{}, adNotFound(err)
 	}
 
 	return ad, nil
 }
 
 func (s *AdminService) AdminDeleteUserAdById(adId string) error {
-	if err := s.repo.AdminDeleteAd(adId); err != nil {
+	ad, err := s.repo.GetAd(adId)
+	if err != nil {
+		return adNotFound(err)
+	}
+
+	images, err := s.images.repo.GetImagesByAdId(adId)
+	if err != nil {
 		return err
 	}
 
+	userIds := s.favorites.favoriteUserIds(adId)
+
+	if err := s.repo.AdminDeleteAd(adId); err != nil {
+		return adNotFound(err)
+	}
+
+	s.images.removeImages(images)
+	s.favorites.notifyRemoved(ad, userIds)
+
 	return nil
 }
 
 func (s *AdminService) AdminUpdateAd(adId string, ad Ads) (domain.Ad, error) {
+	current, err := s.repo.GetAd(adId)
+	if err != nil {
+		return domain.Ad{}, adNotFound(err)
+	}
+
+	category, err := s.categories.resolveCategory(ad.Category)
+	if err != nil {
+		return domain.Ad{}, err
+	}
+
+	attributes, err := s.categories.validateAttributes(category.Id, adAttributes(ad, current))
+	if err != nil {
+		return domain.Ad{}, err
+	}
+
+	language, err := adLanguage(ad, current)
+	if err != nil {
+		return domain.Ad{}, err
+	}
+
+	// admins may only drop images of the ad, not add their own
+	for _, value := range ad.ImagesURL {
+		if !contains(current.ImagesURL, value) {
+			return domain.Ad{}, ErrImageNotFound.Invalid("images_url", fmt.Sprintf("image %s not found", value))
+		}
+	}
+
 	if err := s.repo.AdminUpdateAd(adId, repository.Ads{
 		Title:       ad.Title,
-		Category:    ad.Category,
+		Category:    strconv.Itoa(category.Id),
 		Description: ad.Description,
 		Price:       ad.Price,
 		Contacts: repository.Contacts{
@@ -114,11 +344,213 @@ func (s *AdminService) AdminUpdateAd(adId string, ad Ads) (domain.Ad, error) {
 			Email:        ad.Contacts.Email,
 			Location:     ad.Contacts.Location,
 		},
-		Published: ad.Published,
-		ImagesURL: ad.ImagesURL,
+		ImagesURL:  ad.ImagesURL,
+		Attributes: attributes,
+		Language:   language,
 	}); err != nil {
+		return domain.Ad{}, adNotFound(err)
+	}
+
+	if len(ad.ImagesURL) > 0 {
+		if err := s.images.syncAdImages(adId, ad.ImagesURL); err != nil {
+			return domain.Ad{}, err
+		}
+	}
+
+	updated, err := s.AdminGetAd(adId)
+	if err != nil {
+		return domain.Ad{}, err
+	}
+
+	s.favorites.notifyPriceChanged(updated, current.ApprovedPrice)
+
+	return updated, nil
+}
+
+func (s *AdminService) AdminGetAdsForModeration() ([]domain.Ad, error) {
+	ads, err := s.repo.GetAdsByStatus(domain.AdStatusPendingReview)
+	if err != nil {
+		return nil, err
+	}
+
+	return ads, nil
+}
+
+func (s *AdminService) AdminApproveAd(moderator auth.Subject, adId string) (domain.Ad, error) {
+	return s.moderateAd(moderator, adId, domain.AdStatusApproved, "")
+}
+
+func (s *AdminService) AdminRejectAd(moderator auth.Subject, adId, reason string) (domain.Ad, error) {
+	return s.moderateAd(moderator, adId, domain.AdStatusRejected, reason)
+}
+
+// moderateAd decides on an ad waiting for review, users moderating through their roles can not decide on their own ads.
+func (s *AdminService) moderateAd(moderator auth.Subject, adId string, status domain.AdStatus, reason string) (domain.Ad, error) {
+	ad, err := s.repo.GetAd(adId)
+	if err != nil {
+		return domain.Ad{}, adNotFound(err)
+	}
+
+	if moderator.Kind == auth.KindUser && moderator.Id == ad.UserId {
+		return domain.Ad{}, ErrSelfModeration
+	}
+
+	if !ad.Status.CanTransitionTo(status) {
+		return domain.Ad{}, fmt.Errorf("%w from %s to %s", ErrIllegalAdStatusTransition, ad.Status, status)
+	}
+
+	if err := s.repo.AdminSetAdStatus(adId, ad.Status, status, reason); err != nil {
+		return domain.Ad{}, err
+	}
+
+	moderated, err := s.AdminGetAd(adId)
+	if err != nil {
 		return domain.Ad{}, err
 	}
 
-	return s.AdminGetAd(adId)
+	eventType := EventAdApproved
+	if status == domain.AdStatusRejected {
+		eventType = EventAdRejected
+	}
+	s.events.publish(moderated.UserId, eventType, adModeratedEvent{
+		AdId:            moderated.Id,
+		Title:           moderated.Title,
+		Status:          moderated.Status,
+		RejectionReason: moderated.RejectionReason,
+	})
+
+	// ad.ApprovedPrice is read before the approval replaced it with the current price
+	s.favorites.notifyPriceChanged(moderated, ad.ApprovedPrice)
+
+	return moderated, nil
+}
+
+func (s *AdminService) AdminEnrollTwoFactor(adminId string) (TwoFactorEnrollment, error) {
+	twoFactor, err := s.repo.GetAdminTwoFactor(adminId)
+	if err != nil {
+		return TwoFactorEnrollment{}, err
+	}
+
+	if twoFactor.Enabled {
+		return TwoFactorEnrollment{}, ErrTwoFactorAlreadyEnabled
+	}
+
+	secret, err := otp.GenerateSecret()
+	if err != nil {
+		return TwoFactorEnrollment{}, err
+	}
+
+	if err := s.repo.SetAdminTwoFactorSecret(adminId, secret); err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return TwoFactorEnrollment{}, ErrTwoFactorAlreadyEnabled
+		}
+		return TwoFactorEnrollment{}, err
+	}
+
+	return TwoFactorEnrollment{Secret: secret, ProvisioningURI: otp.ProvisioningURI(s.TwoFactor.Issuer, twoFactor.Account, secret)}, nil
+}
+
+// AdminConfirmTwoFactor enables the second factor once the authenticator app proves to have the secret,
+// the returned recovery codes are shown only once. Permissions are granted starting from the next access token.
+func (s *AdminService) AdminConfirmTwoFactor(adminId, code string) ([]string, error) {
+	twoFactor, err := s.repo.GetAdminTwoFactor(adminId)
+	if err != nil {
+		return nil, err
+	}
+
+	if twoFactor.Enabled {
+		return nil, ErrTwoFactorAlreadyEnabled
+	}
+	if twoFactor.Secret == "" {
+		return nil, ErrTwoFactorNotEnrolled
+	}
+
+	step, ok := otp.Validate(code, twoFactor.Secret, time.Now())
+	if !ok {
+		return nil, ErrInvalidTwoFactorCode
+	}
+
+	codes, hashes, err := newRecoveryCodes()
+	if err != nil {
+		return nil, err
+	}
+
+	if err := s.repo.EnableAdminTwoFactor(adminId, step, hashes); err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, ErrTwoFactorAlreadyEnabled
+		}
+		return nil, err
+	}
+
+	return codes, nil
+}
+
+func (s *AdminService) AdminDisableTwoFactor(adminId, code string) error {
+	if s.TwoFactor.RequiredForAdmins {
+		return ErrTwoFactorRequired
+	}
+
+	if err := s.verifySecondFactor(adminId, code); err != nil {
+		return err
+	}
+
+	return s.repo.DisableAdminTwoFactor(adminId)
+}
+
+// verifySecondFactor accepts a TOTP code or an unused recovery code, every code works only once.
+func (s *AdminService) verifySecondFactor(adminId, code string) error {
+	twoFactor, err := s.repo.GetAdminTwoFactor(adminId)
+	if err != nil {
+		return err
+	}
+
+	if !twoFactor.Enabled {
+		return ErrTwoFactorNotEnabled
+	}
+
+	now := time.Now()
+	if twoFactorLocked(twoFactor.LockedUntil, now) {
+		return ErrTwoFactorLocked
+	}
+
+	if step, ok := otp.Validate(code, twoFactor.Secret, now); ok {
+		err = s.repo.UseAdminTotpStep(adminId, step)
+	} else if otp.IsRecoveryCode(code) {
+		err = s.repo.UseAdminRecoveryCode(adminId, otp.HashRecoveryCode(code), now)
+	} else {
+		err = sql.ErrNoRows
+	}
+
+	if !errors.Is(err, sql.ErrNoRows) {
+		return err
+	}
+
+	if err := s.repo.FailAdminTwoFactor(adminId, maxTwoFactorAttempts, now.Add(twoFactorLockout)); err != nil {
+		return err
+	}
+
+	return ErrInvalidTwoFactorCode
+}
+
+// BanUser keeps the user from signing in and ends their sessions, access tokens already issued work until they expire.
+func (s *AdminService) BanUser(userId, reason string) error {
+	if err := s.repo.BanUser(userId, reason); err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return ErrUserNotFound
+		}
+		return err
+	}
+
+	return nil
+}
+
+func (s *AdminService) UnbanUser(userId string) error {
+	if err := s.repo.UnbanUser(userId); err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return ErrUserNotFound
+		}
+		return err
+	}
+
+	return nil
 }
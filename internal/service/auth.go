@@ -1,90 +1,548 @@
 package service
 
 import (
+	"database/sql"
+	"errors"
+	"fmt"
 	"github.com/TakoB222/postingAds-api/internal/domain"
 	"github.com/TakoB222/postingAds-api/internal/repository"
+	"github.com/TakoB222/postingAds-api/pkg/apperror"
 	"github.com/TakoB222/postingAds-api/pkg/auth"
+	"github.com/TakoB222/postingAds-api/pkg/email"
 	"github.com/TakoB222/postingAds-api/pkg/hash"
+	"github.com/TakoB222/postingAds-api/pkg/logger"
+	"github.com/TakoB222/postingAds-api/pkg/otp"
+	"net/url"
+	"strconv"
 	"time"
 )
 
 type AuthService struct {
 	repo         repository.User
+	roles        repository.Role
 	tokenManager auth.TokenManager
-	hasher       *hash.SHA1Hasher
+	hasher       hash.PasswordHasher
+	mailer       email.Mailer
 
 	AccessTokenTTL  time.Duration
 	RefreshTokenTTL time.Duration
+	AccountEmails   AccountEmailsConfig
+	TwoFactor       TwoFactorConfig
 }
 
-func NewAuthService(repo repository.User, tokenManager *auth.Manager, hasher *hash.SHA1Hasher, AccesTokenTTL, RefreshTokenTTL time.Duration) *AuthService {
-	return &AuthService{repo: repo, tokenManager: tokenManager, hasher: hasher, AccessTokenTTL: AccesTokenTTL, RefreshTokenTTL: RefreshTokenTTL}
+func NewAuthService(repo repository.User, roles repository.Role, tokenManager *auth.Manager, hasher hash.PasswordHasher, mailer email.Mailer,
+	AccesTokenTTL, RefreshTokenTTL time.Duration, accountEmails AccountEmailsConfig, twoFactor TwoFactorConfig) *AuthService {
+	return &AuthService{repo: repo, roles: roles, tokenManager: tokenManager, hasher: hasher, mailer: mailer,
+		AccessTokenTTL: AccesTokenTTL, RefreshTokenTTL: RefreshTokenTTL, AccountEmails: accountEmails, TwoFactor: twoFactor}
 }
 
 func (s *AuthService) SignUp(input UserSignUpInput) (int, error) {
+	passwordHash, err := s.hasher.Hash(input.Password)
+	if err != nil {
+		return 0, err
+	}
+
 	user := domain.User{
 		Email:         input.Email,
-		Password_hash: s.hasher.Hash(input.Password),
+		Password_hash: passwordHash,
 		First_name:    input.FirsName,
 		Last_name:     input.LastName,
 		Registered_at: time.Now(),
 	}
 
-	return s.repo.CreateUser(user)
+	id, err := s.repo.CreateUser(user)
+	if err != nil {
+		if errors.Is(err, repository.ErrUserExists) {
+			return 0, ErrUserExists
+		}
+		return 0, err
+	}
+
+	user.Id = strconv.Itoa(id)
+	if err := s.sendVerificationEmail(user); err != nil {
+		logAccountEmailError("verification", user, err)
+	}
+
+	return id, nil
 }
 
 func (s *AuthService) SignIn(input SignInInput) (Tokens, error) {
-	user, err := s.repo.GetUser(input.Email, s.hasher.Hash(input.Password))
+	user, err := s.repo.GetUserByEmail(input.Email)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return Tokens{}, ErrInvalidCredentials
+		}
+		return Tokens{}, err
+	}
+
+	ok, err := s.hasher.Verify(input.Password, user.Password_hash)
 	if err != nil {
-		//TODO: create custom repository errors and handle them here
 		return Tokens{}, err
 	}
+	if !ok {
+		return Tokens{}, ErrInvalidCredentials
+	}
+
+	if user.BannedAt != nil {
+		return Tokens{}, ErrUserBanned
+	}
+
+	if s.AccountEmails.RequireVerification && !user.EmailVerified {
+		return Tokens{}, ErrEmailNotVerified
+	}
+
+	// the password is known only at this point, so legacy hashes are upgraded on sign in
+	if s.hasher.NeedsRehash(user.Password_hash) {
+		if err := s.rehashPassword(user.Id, input.Password); err != nil {
+			logger.Errorf("failed to rehash password of user %s: %s", user.Id, err.Error())
+		}
+	}
+
+	if user.TwoFactor {
+		challengeToken, err := s.tokenManager.NewChallengeToken(auth.Subject{Id: user.Id, Kind: auth.KindUser}, s.TwoFactor.ChallengeTTL)
+		if err != nil {
+			return Tokens{}, err
+		}
+		return Tokens{ChallengeToken: challengeToken}, nil
+	}
 
-	return s.createSession(user.Id)
+	return s.createSession(user.Id, input.UserAgent, input.Ip)
 }
 
-func (s *AuthService) createSession(userId string) (Tokens, error) {
-	var (
-		res Tokens
-		err error
-	)
+func (s *AuthService) SignInSecondFactor(input TwoFactorSignInInput) (Tokens, error) {
+	subject, err := s.tokenManager.ParseChallengeToken(input.ChallengeToken)
+	if err != nil || subject.Kind != auth.KindUser {
+		return Tokens{}, secondFactorError(ErrInvalidToken)
+	}
 
-	res.AccessToken, err = s.tokenManager.NewJWT(userId, s.AccessTokenTTL)
+	if err := s.verifySecondFactor(subject.Id, input.Code); err != nil {
+		return Tokens{}, secondFactorError(err)
+	}
 
+	return s.createSession(subject.Id, input.UserAgent, input.Ip)
+}
+
+// secondFactorError fails a sign in with a wrong challenge token or code as unauthorized, like a wrong password does.
+func secondFactorError(err error) error {
+	if errors.Is(err, ErrInvalidToken) || errors.Is(err, ErrInvalidTwoFactorCode) || errors.Is(err, ErrTwoFactorNotEnabled) {
+		if appErr, ok := apperror.As(err); ok {
+			return appErr.WithKind(apperror.KindUnauthorized)
+		}
+	}
+
+	return err
+}
+
+func (s *AuthService) rehashPassword(userId, password string) error {
+	passwordHash, err := s.hasher.Hash(password)
 	if err != nil {
-		return res, err
+		return err
 	}
 
-	res.RefreshToken, err = s.tokenManager.NewRefreshToken()
+	return s.repo.UpdatePasswordHash(userId, passwordHash)
+}
+
+func (s *AuthService) createSession(userId, userAgent, ip string) (Tokens, error) {
+	refreshToken, err := s.tokenManager.NewRefreshToken()
 	if err != nil {
-		return res, err
+		return Tokens{}, err
 	}
 
+	now := time.Now()
 	session := domain.Session{
 		UserId:       userId,
-		RefreshToken: res.RefreshToken,
-		CreatedAt:    time.Now(),
-		ExpiresIn:    time.Now().Add(s.RefreshTokenTTL),
+		RefreshToken: auth.HashRefreshToken(refreshToken),
+		CreatedAt:    now,
+		LastUsedAt:   now,
+		ExpiresIn:    now.Add(s.RefreshTokenTTL),
+		UserAgent:    userAgent,
+		Ip:           ip,
 	}
 
-	err = s.repo.SetSession(session)
+	sessionId, err := s.repo.SetSession(session)
 	if err != nil {
 		return Tokens{}, err
 	}
 
-	return res, nil
+	return s.newTokens(userId, sessionId, refreshToken)
+}
+
+// newTokens binds the access token to the session, so streams opened with it end when the session does.
+func (s *AuthService) newTokens(userId, sessionId, refreshToken string) (Tokens, error) {
+	roles, err := s.roles.GetUserRoles(userId)
+	if err != nil {
+		return Tokens{}, err
+	}
+
+	subject := newSubject(userId, auth.KindUser, roles)
+	subject.SessionId = sessionId
+
+	// users moderate through their roles, so permissions need the second factor just like for admins
+	if s.TwoFactor.RequiredForAdmins && len(subject.Permissions) > 0 {
+		twoFactor, err := s.repo.GetUserTwoFactor(userId)
+		if err != nil {
+			return Tokens{}, err
+		}
+		if !twoFactor.Enabled {
+			subject.Roles, subject.Permissions = nil, nil
+		}
+	}
+
+	accessToken, err := s.tokenManager.NewJWT(subject, s.AccessTokenTTL)
+	if err != nil {
+		return Tokens{}, err
+	}
+
+	return Tokens{AccessToken: accessToken, RefreshToken: refreshToken}, nil
 }
 
 func (s *AuthService) RefreshSession(input RefreshInput) (Tokens, error) {
-	session, err := s.repo.GetSessionByRefreshToken(input.RefreshToken)
+	session, err := s.getSession(input.RefreshToken)
+	if errors.Is(err, ErrSessionNotFound) {
+		return Tokens{}, s.checkRefreshTokenReuse(input)
+	}
 	if err != nil {
 		return Tokens{}, err
 	}
 
-	err = s.repo.DeleteSessionByUserId(session.UserId)
+	if session.ExpiresIn.Before(time.Now()) {
+		return Tokens{}, ErrSessionExpired
+	}
+
+	// every refresh rotates the token of the session, the previous one is remembered to detect its reuse
+	refreshToken, err := s.tokenManager.NewRefreshToken()
 	if err != nil {
 		return Tokens{}, err
 	}
 
-	return s.createSession(session.UserId)
+	usedRefreshToken := session.RefreshToken
+
+	now := time.Now()
+	session.RefreshToken = auth.HashRefreshToken(refreshToken)
+	session.ExpiresIn = now.Add(s.RefreshTokenTTL)
+	session.LastUsedAt = now
+	session.UserAgent = input.UserAgent
+	session.Ip = input.Ip
+
+	if err = s.repo.RotateSession(session, usedRefreshToken); err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return Tokens{}, ErrSessionNotFound
+		}
+		return Tokens{}, err
+	}
+
+	return s.newTokens(session.UserId, session.Id, refreshToken)
+}
+
+// checkRefreshTokenReuse revokes the whole session when an already rotated refresh token is presented:
+// either the legitimate client or an attacker holds a stolen token, and there is no way to tell which one.
+func (s *AuthService) checkRefreshTokenReuse(input RefreshInput) error {
+	session, err := s.repo.GetSessionByUsedRefreshToken(auth.HashRefreshToken(input.RefreshToken))
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return ErrSessionNotFound
+		}
+		return err
+	}
+
+	logger.Warnf("security event: reuse of rotated refresh token, user %s, session %s, ip %s, user agent %s",
+		session.UserId, session.Id, input.Ip, input.UserAgent)
+
+	if err := s.repo.DeleteSession(session.UserId, session.Id); err != nil && !errors.Is(err, sql.ErrNoRows) {
+		return err
+	}
+
+	return ErrRefreshTokenReused
+}
+
+func (s *AuthService) Logout(userId string, input RefreshInput) error {
+	err := s.repo.DeleteSessionByRefreshToken(userId, auth.HashRefreshToken(input.RefreshToken))
+	if errors.Is(err, sql.ErrNoRows) {
+		return ErrSessionNotFound
+	}
+
+	return err
+}
+
+func (s *AuthService) GetSessions(userId string) ([]domain.Session, error) {
+	sessions, err := s.repo.GetSessionsByUserId(userId)
+	if err != nil {
+		return nil, err
+	}
+
+	return sessions, nil
+}
+
+func (s *AuthService) RevokeSession(userId, sessionId string) error {
+	err := s.repo.DeleteSession(userId, sessionId)
+	if errors.Is(err, sql.ErrNoRows) {
+		// the session is given by id here, not by a token, so its absence does not sign the user out
+		return ErrSessionNotFound.WithKind(apperror.KindNotFound)
+	}
+
+	return err
+}
+
+// CheckSession fails with ErrSessionNotFound once the user signed out of the session or it was revoked.
+func (s *AuthService) CheckSession(userId, sessionId string) error {
+	if _, err := s.repo.GetSession(userId, sessionId); err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return ErrSessionNotFound
+		}
+		return err
+	}
+
+	return nil
+}
+
+func (s *AuthService) RevokeOtherSessions(userId string, input RefreshInput) error {
+	session, err := s.getSession(input.RefreshToken)
+	if err != nil {
+		return err
+	}
+
+	if session.UserId != userId {
+		return ErrSessionNotFound
+	}
+
+	return s.repo.DeleteOtherSessions(userId, session.RefreshToken)
+}
+
+func (s *AuthService) getSession(refreshToken string) (domain.Session, error) {
+	session, err := s.repo.GetSessionByRefreshToken(auth.HashRefreshToken(refreshToken))
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return domain.Session{}, ErrSessionNotFound
+		}
+		return domain.Session{}, err
+	}
+
+	return session, nil
+}
+
+func (s *AuthService) VerifyEmail(token string) error {
+	err := s.repo.VerifyEmail(auth.HashRefreshToken(token), time.Now())
+	if errors.Is(err, sql.ErrNoRows) {
+		return ErrInvalidToken
+	}
+
+	return err
+}
+
+// ResendVerificationEmail does not tell whether the account exists, so emails can not be enumerated.
+// Emails requested within the cooldown are silently dropped.
+func (s *AuthService) ResendVerificationEmail(address string) error {
+	user, err := s.repo.GetUserByEmail(address)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil
+		}
+		return err
+	}
+
+	if user.EmailVerified {
+		return nil
+	}
+
+	// a failed email would only be reported for existing accounts
+	if err := s.sendVerificationEmail(user); err != nil {
+		logAccountEmailError("verification", user, err)
+	}
+
+	return nil
+}
+
+// RequestPasswordReset does not tell whether the account exists, so emails can not be enumerated.
+// Emails requested within the cooldown are silently dropped.
+func (s *AuthService) RequestPasswordReset(address string) error {
+	user, err := s.repo.GetUserByEmail(address)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil
+		}
+		return err
+	}
+
+	// a failed email would only be reported for existing accounts
+	if err := s.sendPasswordResetEmail(user); err != nil {
+		logAccountEmailError("password reset", user, err)
+	}
+
+	return nil
+}
+
+func (s *AuthService) sendPasswordResetEmail(user domain.User) error {
+	token, err := s.newUserToken(user.Id, domain.UserTokenPasswordReset, s.AccountEmails.PasswordResetTokenTTL)
+	if err != nil {
+		return err
+	}
+
+	return s.mailer.Send(email.Message{
+		To:      user.Email,
+		Subject: "Password reset",
+		Body: fmt.Sprintf("Hello, %s!\n\nTo set a new password follow the link:\n%s\n\nThe link expires in %s. If you did not request a password reset, ignore this email.",
+			user.First_name, s.AccountEmails.PasswordResetURL+url.QueryEscape(token), s.AccountEmails.PasswordResetTokenTTL),
+	})
+}
+
+// logAccountEmailError logs an email which was not sent, an email refused for the cooldown is not an error.
+func logAccountEmailError(kind string, user domain.User, err error) {
+	if errors.Is(err, repository.ErrUserTokenCooldown) {
+		return
+	}
+
+	logger.Errorf("failed to send %s email to user %s: %s", kind, user.Id, err.Error())
+}
+
+func (s *AuthService) ResetPassword(input PasswordResetInput) error {
+	passwordHash, err := s.hasher.Hash(input.Password)
+	if err != nil {
+		return err
+	}
+
+	err = s.repo.ResetPassword(auth.HashRefreshToken(input.Token), passwordHash, time.Now())
+	if errors.Is(err, sql.ErrNoRows) {
+		return ErrInvalidToken
+	}
+
+	return err
+}
+
+func (s *AuthService) sendVerificationEmail(user domain.User) error {
+	token, err := s.newUserToken(user.Id, domain.UserTokenEmailVerification, s.AccountEmails.VerificationTokenTTL)
+	if err != nil {
+		return err
+	}
+
+	return s.mailer.Send(email.Message{
+		To:      user.Email,
+		Subject: "Email verification",
+		Body: fmt.Sprintf("Hello, %s!\n\nTo verify your email follow the link:\n%s\n\nThe link expires in %s.",
+			user.First_name, s.AccountEmails.VerificationURL+url.QueryEscape(token), s.AccountEmails.VerificationTokenTTL),
+	})
+}
+
+// newUserToken stores a single use token for the email, it has the same opaque format as refresh tokens.
+func (s *AuthService) newUserToken(userId, purpose string, ttl time.Duration) (string, error) {
+	token, err := s.tokenManager.NewRefreshToken()
+	if err != nil {
+		return "", err
+	}
+
+	now := time.Now()
+	err = s.repo.SetUserToken(domain.UserToken{
+		UserId:    userId,
+		Purpose:   purpose,
+		TokenHash: auth.HashRefreshToken(token),
+		ExpiresAt: now.Add(ttl),
+		CreatedAt: now,
+	}, now.Add(-s.AccountEmails.EmailCooldown))
+	if err != nil {
+		return "", err
+	}
+
+	return token, nil
+}
+
+func (s *AuthService) EnrollTwoFactor(userId string) (TwoFactorEnrollment, error) {
+	twoFactor, err := s.repo.GetUserTwoFactor(userId)
+	if err != nil {
+		return TwoFactorEnrollment{}, err
+	}
+
+	if twoFactor.Enabled {
+		return TwoFactorEnrollment{}, ErrTwoFactorAlreadyEnabled
+	}
+
+	secret, err := otp.GenerateSecret()
+	if err != nil {
+		return TwoFactorEnrollment{}, err
+	}
+
+	if err := s.repo.SetUserTwoFactorSecret(userId, secret); err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return TwoFactorEnrollment{}, ErrTwoFactorAlreadyEnabled
+		}
+		return TwoFactorEnrollment{}, err
+	}
+
+	return TwoFactorEnrollment{Secret: secret, ProvisioningURI: otp.ProvisioningURI(s.TwoFactor.Issuer, twoFactor.Account, secret)}, nil
+}
+
+// ConfirmTwoFactor enables the second factor once the authenticator app proves to have the secret,
+// the returned recovery codes are shown only once.
+func (s *AuthService) ConfirmTwoFactor(userId, code string) ([]string, error) {
+	twoFactor, err := s.repo.GetUserTwoFactor(userId)
+	if err != nil {
+		return nil, err
+	}
+
+	if twoFactor.Enabled {
+		return nil, ErrTwoFactorAlreadyEnabled
+	}
+	if twoFactor.Secret == "" {
+		return nil, ErrTwoFactorNotEnrolled
+	}
+
+	step, ok := otp.Validate(code, twoFactor.Secret, time.Now())
+	if !ok {
+		return nil, ErrInvalidTwoFactorCode
+	}
+
+	codes, hashes, err := newRecoveryCodes()
+	if err != nil {
+		return nil, err
+	}
+
+	if err := s.repo.EnableUserTwoFactor(userId, step, hashes); err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, ErrTwoFactorAlreadyEnabled
+		}
+		return nil, err
+	}
+
+	return codes, nil
+}
+
+func (s *AuthService) DisableTwoFactor(userId, code string) error {
+	if err := s.verifySecondFactor(userId, code); err != nil {
+		return err
+	}
+
+	return s.repo.DisableUserTwoFactor(userId)
+}
+
+// verifySecondFactor accepts a TOTP code or an unused recovery code, every code works only once.
+func (s *AuthService) verifySecondFactor(userId, code string) error {
+	twoFactor, err := s.repo.GetUserTwoFactor(userId)
+	if err != nil {
+		return err
+	}
+
+	if !twoFactor.Enabled {
+		return ErrTwoFactorNotEnabled
+	}
+
+	now := time.Now()
+	if twoFactorLocked(twoFactor.LockedUntil, now) {
+		return ErrTwoFactorLocked
+	}
+
+	if step, ok := otp.Validate(code, twoFactor.Secret, now); ok {
+		err = s.repo.UseUserTotpStep(userId, step)
+	} else if otp.IsRecoveryCode(code) {
+		err = s.repo.UseUserRecoveryCode(userId, otp.HashRecoveryCode(code), now)
+	} else {
+		err = sql.ErrNoRows
+	}
+
+	if !errors.Is(err, sql.ErrNoRows) {
+		return err
+	}
+
+	if err := s.repo.FailUserTwoFactor(userId, maxTwoFactorAttempts, now.Add(twoFactorLockout)); err != nil {
+		return err
+	}
+
+	return ErrInvalidTwoFactorCode
 }
@@ -0,0 +1,517 @@
+package service
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+	"github.com/TakoB222/postingAds-api/internal/domain"
+	"github.com/TakoB222/postingAds-api/internal/repository"
+	"sort"
+	"strconv"
+	"strings"
+	"unicode/utf8"
+)
+
+type CategoryService struct {
+	repo repository.Category
+}
+
+func NewCategoryService(repo repository.Category) *CategoryService {
+	return &CategoryService{repo: repo}
+}
+
+// GetCategoryTree returns root categories with their subcategories.
+func (s *CategoryService) GetCategoryTree() ([]domain.CategoryNode, error) {
+	categories, err := s.repo.GetCategories()
+	if err != nil {
+		return nil, err
+	}
+
+	return buildCategoryTree(categories), nil
+}
+
+func (s *CategoryService) CreateCategory(input CategoryInput) (domain.Categories, error) {
+	name, err := checkCategoryName(input.Name)
+	if err != nil {
+		return domain.Categories{}, err
+	}
+
+	id, err := s.repo.CreateCategory(name, input.ParentId)
+	if err != nil {
+		return domain.Categories{}, categoryError(err)
+	}
+
+	return s.getCategory(id)
+}
+
+func (s *CategoryService) UpdateCategory(categoryId int, input CategoryInput) (domain.Categories, error) {
+	name, err := checkCategoryName(input.Name)
+	if err != nil {
+		return domain.Categories{}, err
+	}
+
+	categories, err := s.repo.GetCategories()
+	if err != nil {
+		return domain.Categories{}, err
+	}
+
+	// attributes of the moved subtree must not clash with the ones inherited from the new parent
+	if input.ParentId != nil {
+		inherited, ok := attributeSchema(categories, *input.ParentId)
+		if ok {
+			if err := checkAttributeNames(inherited, subtreeAttributes(categories, categoryId)); err != nil {
+				return domain.Categories{}, err
+			}
+		}
+	}
+
+	if err := s.repo.UpdateCategory(categoryId, name, input.ParentId); err != nil {
+		return domain.Categories{}, categoryError(err)
+	}
+
+	return s.getCategory(categoryId)
+}
+
+// GetCategoryAttributes returns attributes of the category including the inherited ones.
+func (s *CategoryService) GetCategoryAttributes(categoryId int) (domain.AttributeSchema, error) {
+	categories, err := s.repo.GetCachedCategories()
+	if err != nil {
+		return nil, err
+	}
+
+	schema, ok := attributeSchema(categories, categoryId)
+	if !ok {
+		return nil, ErrCategoryNotFound
+	}
+
+	return schema, nil
+}
+
+// SetCategoryAttributes replaces attributes declared by the category, names may not repeat within a branch of the tree.
+func (s *CategoryService) SetCategoryAttributes(categoryId int, attributes domain.AttributeSchema) (domain.Categories, error) {
+	if err := attributes.Check(); err != nil {
+		return domain.Categories{}, fmt.Errorf("%w: %s", ErrInvalidAttributes, err.Error())
+	}
+
+	categories, err := s.repo.GetCategories()
+	if err != nil {
+		return domain.Categories{}, err
+	}
+
+	category, ok := findCategory(categories, categoryId)
+	if !ok {
+		return domain.Categories{}, ErrCategoryNotFound
+	}
+
+	var inherited domain.AttributeSchema
+	if category.ParentCategory != nil {
+		inherited, _ = attributeSchema(categories, *category.ParentCategory)
+	}
+
+	descendants := make(domain.AttributeSchema, 0)
+	for _, child := range categories {
+		if child.ParentCategory != nil && *child.ParentCategory == categoryId {
+			descendants = append(descendants, subtreeAttributes(categories, child.Id)...)
+		}
+	}
+
+	if err := checkAttributeNames(inherited, attributes); err != nil {
+		return domain.Categories{}, err
+	}
+	if err := checkAttributeNames(attributes, descendants); err != nil {
+		return domain.Categories{}, err
+	}
+
+	if err := s.repo.SetCategoryAttributes(categoryId, attributes); err != nil {
+		return domain.Categories{}, categoryError(err)
+	}
+
+	return s.getCategory(categoryId)
+}
+
+// resolveCategory finds a category by its id or by the full path of names separated with a slash.
+func (s *CategoryService) resolveCategory(ref string) (domain.Categories, error) {
+	ref = strings.Trim(strings.TrimSpace(ref), "/")
+
+	var (
+		category domain.Categories
+		err      error
+	)
+	if id, convErr := strconv.Atoi(ref); convErr == nil {
+		category, err = s.repo.GetCategory(id)
+	} else {
+		category, err = s.repo.GetCategoryByPath(splitCategoryPath(ref))
+	}
+
+	if errors.Is(err, sql.ErrNoRows) {
+		return domain.Categories{}, s.categoryNotFound(ref)
+	}
+	if err != nil {
+		return domain.Categories{}, err
+	}
+
+	return category, nil
+}
+
+// checkCategoryName keeps every category resolvable by resolveCategory: a name with a slash would split its path
+// and a number would be taken for an id.
+func checkCategoryName(name string) (string, error) {
+	name = strings.TrimSpace(name)
+
+	switch _, convErr := strconv.Atoi(name); {
+	case name == "":
+		return "", ErrInvalidCategoryName.Invalid("category", "must not be empty")
+	case strings.Contains(name, "/"):
+		return "", ErrInvalidCategoryName.Invalid("category", "must not contain /")
+	case convErr == nil:
+		return "", ErrInvalidCategoryName.Invalid("category", "must not be a number")
+	}
+
+	return name, nil
+}
+
+// categoryNotFound suggests paths of categories close to the path the client asked for.
+func (s *CategoryService) categoryNotFound(ref string) error {
+	categories, err := s.repo.GetCachedCategories()
+	if err != nil {
+		return &CategoryNotFoundError{Category: ref}
+	}
+
+	return &CategoryNotFoundError{Category: ref, Suggestions: closeCategoryPaths(ref, domain.CategoryPaths(categories))}
+}
+
+// validateAttributes checks values of an ad against attributes of its category.
+func (s *CategoryService) validateAttributes(categoryId int, values map[string]interface{}) (domain.AttributeValues, error) {
+	schema, err := s.getAttributeSchema(categoryId)
+	if err != nil {
+		return nil, err
+	}
+
+	res, err := schema.Validate(values)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %s", ErrInvalidAttributes, err.Error())
+	}
+
+	return res, nil
+}
+
+// attributeFilters parses filters given as name=value, integers also take a range as min..max with an optional side.
+func (s *CategoryService) attributeFilters(categoryId int, values map[string]string) ([]repository.AttributeFilter, error) {
+	if len(values) == 0 {
+		return nil, nil
+	}
+	if categoryId == 0 {
+		return nil, fmt.Errorf("%w: attribute filters require a category", ErrInvalidAttributes)
+	}
+
+	schema, err := s.getAttributeSchema(categoryId)
+	if err != nil {
+		return nil, err
+	}
+
+	names := make([]string, 0, len(values))
+	for name := range values {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	filters := make([]repository.AttributeFilter, 0, len(names))
+	for _, name := range names {
+		filter, err := parseAttributeFilter(schema, name, values[name])
+		if err != nil {
+			return nil, fmt.Errorf("%w: %s", ErrInvalidAttributes, err.Error())
+		}
+		filters = append(filters, filter)
+	}
+
+	return filters, nil
+}
+
+func (s *CategoryService) getAttributeSchema(categoryId int) (domain.AttributeSchema, error) {
+	categories, err := s.repo.GetCachedCategories()
+	if err != nil {
+		return nil, err
+	}
+
+	schema, ok := attributeSchema(categories, categoryId)
+	if !ok {
+		return nil, ErrCategoryNotFound
+	}
+
+	return schema, nil
+}
+
+func (s *CategoryService) DeleteCategory(categoryId int) error {
+	return categoryError(s.repo.DeleteCategory(categoryId))
+}
+
+func (s *CategoryService) getCategory(categoryId int) (domain.Categories, error) {
+	category, err := s.repo.GetCategory(categoryId)
+	if err != nil {
+		return domain.Categories{}, categoryError(err)
+	}
+
+	return category, nil
+}
+
+func buildCategoryTree(categories []domain.Categories) []domain.CategoryNode {
+	children := make(map[int][]domain.Categories)
+	roots := make([]domain.Categories, 0)
+	for _, category := range categories {
+		if category.ParentCategory == nil {
+			roots = append(roots, category)
+			continue
+		}
+		children[*category.ParentCategory] = append(children[*category.ParentCategory], category)
+	}
+
+	var build func(category domain.Categories) domain.CategoryNode
+	build = func(category domain.Categories) domain.CategoryNode {
+		node := domain.CategoryNode{Categories: category, Children: make([]domain.CategoryNode, 0, len(children[category.Id]))}
+		for _, child := range children[category.Id] {
+			childNode := build(child)
+			node.AdsCount += childNode.AdsCount
+			node.Children = append(node.Children, childNode)
+		}
+
+		return node
+	}
+
+	tree := make([]domain.CategoryNode, 0, len(roots))
+	for _, root := range roots {
+		tree = append(tree, build(root))
+	}
+
+	return tree
+}
+
+func splitCategoryPath(path string) []string {
+	segments := strings.Split(path, "/")
+	for i := range segments {
+		segments[i] = strings.TrimSpace(segments[i])
+	}
+
+	return segments
+}
+
+// closeCategoryPaths returns paths within a few edits of the given one, a path also matches by its last segment alone,
+// so "Buses" finds "Transport/Buses".
+func closeCategoryPaths(ref string, paths map[int]string) []string {
+	const maxSuggestions = 5
+
+	ref = strings.ToLower(ref)
+	leaf := ref[strings.LastIndex(ref, "/")+1:]
+
+	type match struct {
+		path     string
+		distance int
+	}
+	matches := make([]match, 0)
+	for _, path := range paths {
+		lower := strings.ToLower(path)
+		distance := editDistance(ref, lower)
+		if d := editDistance(leaf, lower[strings.LastIndex(lower, "/")+1:]); d < distance {
+			distance = d
+		}
+
+		if distance <= maxEditDistance(leaf) {
+			matches = append(matches, match{path: path, distance: distance})
+		}
+	}
+
+	sort.Slice(matches, func(i, j int) bool {
+		if matches[i].distance != matches[j].distance {
+			return matches[i].distance < matches[j].distance
+		}
+		return matches[i].path < matches[j].path
+	})
+
+	res := make([]string, 0, maxSuggestions)
+	for i := 0; i < len(matches) && i < maxSuggestions; i++ {
+		res = append(res, matches[i].path)
+	}
+
+	return res
+}
+
+func maxEditDistance(s string) int {
+	if n := utf8.RuneCountInString(s) / 3; n > 2 {
+		return n
+	}
+
+	return 2
+}
+
+// editDistance is the Levenshtein distance between strings in runes.
+func editDistance(a, b string) int {
+	ra, rb := []rune(a), []rune(b)
+	prev := make([]int, len(rb)+1)
+	cur := make([]int, len(rb)+1)
+	for j := range prev {
+		prev[j] = j
+	}
+
+	for i := 1; i <= len(ra); i++ {
+		cur[0] = i
+		for j := 1; j <= len(rb); j++ {
+			cost := 1
+			if ra[i-1] == rb[j-1] {
+				cost = 0
+			}
+			cur[j] = minInt(minInt(prev[j]+1, cur[j-1]+1), prev[j-1]+cost)
+		}
+		prev, cur = cur, prev
+	}
+
+	return prev[len(rb)]
+}
+
+func minInt(a, b int) int {
+	if a < b {
+		return a
+	}
+
+	return b
+}
+
+func findCategory(categories []domain.Categories, categoryId int) (domain.Categories, bool) {
+	for _, category := range categories {
+		if category.Id == categoryId {
+			return category, true
+		}
+	}
+
+	return domain.Categories{}, false
+}
+
+// attributeSchema collects attributes of the category and its ancestors, the root ones go first.
+func attributeSchema(categories []domain.Categories, categoryId int) (domain.AttributeSchema, bool) {
+	category, ok := findCategory(categories, categoryId)
+	if !ok {
+		return nil, false
+	}
+
+	schema := append(domain.AttributeSchema{}, category.Attributes...)
+	// the depth bound keeps a corrupted tree from looping forever
+	for depth := 0; category.ParentCategory != nil && depth < len(categories); depth++ {
+		category, ok = findCategory(categories, *category.ParentCategory)
+		if !ok {
+			break
+		}
+		schema = append(append(domain.AttributeSchema{}, category.Attributes...), schema...)
+	}
+
+	return schema, true
+}
+
+// subtreeAttributes collects attributes declared by the category and its descendants.
+func subtreeAttributes(categories []domain.Categories, categoryId int) domain.AttributeSchema {
+	res := make(domain.AttributeSchema, 0)
+	queue := []int{categoryId}
+	visited := make(map[int]bool)
+	for len(queue) > 0 {
+		id := queue[0]
+		queue = queue[1:]
+		if visited[id] {
+			continue
+		}
+		visited[id] = true
+
+		for _, category := range categories {
+			if category.Id == id {
+				res = append(res, category.Attributes...)
+			}
+			if category.ParentCategory != nil && *category.ParentCategory == id {
+				queue = append(queue, category.Id)
+			}
+		}
+	}
+
+	return res
+}
+
+func checkAttributeNames(inherited, declared domain.AttributeSchema) error {
+	for _, attribute := range declared {
+		if _, ok := inherited.Find(attribute.Name); ok {
+			return fmt.Errorf("%w: attribute %q is already declared by a parent category", ErrInvalidAttributes, attribute.Name)
+		}
+	}
+
+	return nil
+}
+
+func parseAttributeFilter(schema domain.AttributeSchema, name, value string) (repository.AttributeFilter, error) {
+	attribute, ok := schema.Find(name)
+	if !ok {
+		return repository.AttributeFilter{}, fmt.Errorf("unknown attribute %q", name)
+	}
+
+	filter := repository.AttributeFilter{Name: name}
+	switch attribute.Type {
+	case domain.AttributeText:
+		filter.Contains = strings.TrimSpace(value)
+		if filter.Contains == "" {
+			return repository.AttributeFilter{}, fmt.Errorf("attribute %q: empty filter", name)
+		}
+	case domain.AttributeInteger:
+		if bounds := strings.SplitN(value, "..", 2); len(bounds) == 2 {
+			var err error
+			if filter.Min, err = parseBound(bounds[0]); err != nil {
+				return repository.AttributeFilter{}, fmt.Errorf("attribute %q: invalid range", name)
+			}
+			if filter.Max, err = parseBound(bounds[1]); err != nil {
+				return repository.AttributeFilter{}, fmt.Errorf("attribute %q: invalid range", name)
+			}
+			if filter.Min == nil && filter.Max == nil || filter.Min != nil && filter.Max != nil && *filter.Min > *filter.Max {
+				return repository.AttributeFilter{}, fmt.Errorf("attribute %q: invalid range", name)
+			}
+			break
+		}
+
+		n, err := strconv.ParseInt(value, 10, 64)
+		if err != nil {
+			return repository.AttributeFilter{}, fmt.Errorf("attribute %q must be an integer", name)
+		}
+		filter.Equals = n
+	default:
+		parsed, err := attribute.ParseString(value)
+		if err != nil {
+			return repository.AttributeFilter{}, err
+		}
+		filter.Equals = parsed
+	}
+
+	return filter, nil
+}
+
+func parseBound(value string) (*int64, error) {
+	if value == "" {
+		return nil, nil
+	}
+
+	n, err := strconv.ParseInt(value, 10, 64)
+	if err != nil {
+		return nil, err
+	}
+
+	return &n, nil
+}
+
+func categoryError(err error) error {
+	switch {
+	case errors.Is(err, sql.ErrNoRows):
+		return ErrCategoryNotFound
+	case errors.Is(err, repository.ErrParentCategoryNotFound):
+		return ErrParentCategoryNotFound
+	case errors.Is(err, repository.ErrCategoryExists):
+		return ErrCategoryExists
+	case errors.Is(err, repository.ErrCategoryCycle):
+		return ErrCategoryCycle
+	case errors.Is(err, repository.ErrCategoryHasSubcategories):
+		return ErrCategoryHasSubcategories
+	case errors.Is(err, repository.ErrCategoryHasAds):
+		return ErrCategoryHasAds
+	default:
+		return err
+	}
+}
@@ -0,0 +1,194 @@
+package service
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+	"github.com/TakoB222/postingAds-api/internal/domain"
+	"github.com/TakoB222/postingAds-api/internal/repository"
+	"time"
+	"unicode"
+)
+
+// ContactsConfig limits how many ads a user may reveal contacts of, so contacts can not be scraped through the api.
+type ContactsConfig struct {
+	RevealLimit  int
+	RevealWindow time.Duration
+}
+
+type ContactService struct {
+	repo repository.Contact
+	cfg  ContactsConfig
+}
+
+func NewContactService(repo repository.Contact, cfg ContactsConfig) *ContactService {
+	return &ContactService{repo: repo, cfg: cfg}
+}
+
+// RevealContacts returns contacts of a published ad and logs the user revealed them.
+// Revealing the same ad again within the window does not count towards the limit.
+func (s *ContactService) RevealContacts(userId, adId, ip string) (domain.Contacts, error) {
+	ownerId, contacts, err := s.repo.GetPublishedAdContacts(adId)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return domain.Contacts{}, ErrAdNotFound
+		}
+		return domain.Contacts{}, err
+	}
+
+	if ownerId == userId {
+		return contacts, nil
+	}
+
+	if err := s.repo.CreateContactReveal(userId, adId, ip, time.Now().Add(-s.cfg.RevealWindow), s.cfg.RevealLimit); err != nil {
+		if errors.Is(err, repository.ErrTooManyContactReveals) {
+			return domain.Contacts{}, fmt.Errorf("%w, %d per %s at most", ErrTooManyContactReveals, s.cfg.RevealLimit, s.cfg.RevealWindow)
+		}
+		return domain.Contacts{}, err
+	}
+
+	return contacts, nil
+}
+
+func (s *ContactService) GetContactRevealStats(userId string) ([]domain.ContactRevealStats, error) {
+	return s.repo.GetContactRevealStats(userId)
+}
+
+func (s *ContactService) CreateContactProfile(userId string, profile domain.ContactProfile) (domain.ContactProfile, error) {
+	id, err := s.repo.CreateContactProfile(userId, profile)
+	if err != nil {
+		if errors.Is(err, repository.ErrContactProfileExists) {
+			return domain.ContactProfile{}, ErrContactProfileExists
+		}
+		return domain.ContactProfile{}, err
+	}
+
+	profile.Id = id
+	profile.AdsCount = 0
+
+	return profile, nil
+}
+
+func (s *ContactService) GetContactProfiles(userId string) ([]domain.ContactProfile, error) {
+	return s.repo.GetContactProfiles(userId)
+}
+
+func (s *ContactService) GetContactProfile(userId string, profileId int) (domain.ContactProfile, error) {
+	profile, err := s.repo.GetContactProfile(userId, profileId)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return domain.ContactProfile{}, ErrContactProfileNotFound
+		}
+		return domain.ContactProfile{}, err
+	}
+
+	return profile, nil
+}
+
+// UpdateContactProfile changes the profile, ads using it show the new contacts right away.
+func (s *ContactService) UpdateContactProfile(userId string, profile domain.ContactProfile) (domain.ContactProfile, error) {
+	if err := s.repo.UpdateContactProfile(userId, profile); err != nil {
+		switch {
+		case errors.Is(err, sql.ErrNoRows):
+			return domain.ContactProfile{}, ErrContactProfileNotFound
+		case errors.Is(err, repository.ErrContactProfileExists):
+			return domain.ContactProfile{}, ErrContactProfileExists
+		default:
+			return domain.ContactProfile{}, err
+		}
+	}
+
+	return s.GetContactProfile(userId, profile.Id)
+}
+
+// DeleteContactProfile deletes a profile no ad uses, ads have to be moved to other contacts first.
+func (s *ContactService) DeleteContactProfile(userId string, profileId int) error {
+	profile, err := s.GetContactProfile(userId, profileId)
+	if err != nil {
+		return err
+	}
+
+	if profile.AdsCount > 0 {
+		return fmt.Errorf("%w, %d ads use it", ErrContactProfileInUse, profile.AdsCount)
+	}
+
+	if err := s.repo.DeleteContactProfile(userId, profileId); err != nil {
+		// an ad started using the profile in the meantime
+		if errors.Is(err, sql.ErrNoRows) {
+			return ErrContactProfileInUse
+		}
+		return err
+	}
+
+	return nil
+}
+
+// checkAdContacts makes sure the ad gets either its own contacts or a contact profile of the user.
+func (s *ContactService) checkAdContacts(userId string, ad Ads) error {
+	if (ad.ContactProfileId == 0) == (ad.Contacts == Contacts{}) {
+		return ErrContactsRequired
+	}
+
+	if ad.ContactProfileId == 0 {
+		return nil
+	}
+
+	if _, err := s.GetContactProfile(userId, ad.ContactProfileId); err != nil {
+		if errors.Is(err, ErrContactProfileNotFound) {
+			return ErrContactProfileNotFound.Invalid("contact_profile_id", fmt.Sprintf("contact profile %d not found", ad.ContactProfileId))
+		}
+		return err
+	}
+
+	return nil
+}
+
+// maskContacts hides the email and most of the phone number of ads shown to other users than their owners.
+func maskContacts(ads []domain.Ad) {
+	for i := range ads {
+		maskAdContacts(&ads[i])
+	}
+}
+
+func maskAdContacts(ad *domain.Ad) {
+	if ad.ContactsInfo == nil {
+		return
+	}
+
+	ad.ContactsInfo = &domain.Contacts{
+		Name:         ad.ContactsInfo.Name,
+		Phone_number: maskPhone(ad.ContactsInfo.Phone_number),
+		Location:     ad.ContactsInfo.Location,
+		Masked:       true,
+	}
+}
+
+// maskPhone hides the seven digits of the subscriber number but the last two, digits of the country and operator codes stay.
+func maskPhone(phone string) string {
+	digits := 0
+	for _, r := range phone {
+		if unicode.IsDigit(r) {
+			digits++
+		}
+	}
+
+	head := digits - 7
+	tail := 2
+	if digits <= 4 {
+		tail = 0
+	}
+
+	masked := []rune(phone)
+	position := 0
+	for i, r := range masked {
+		if !unicode.IsDigit(r) {
+			continue
+		}
+		if position >= head && position < digits-tail {
+			masked[i] = '*'
+		}
+		position++
+	}
+
+	return string(masked)
+}
@@ -0,0 +1,77 @@
+package service
+
+import (
+	"context"
+	"encoding/json"
+	"github.com/TakoB222/postingAds-api/internal/domain"
+	"github.com/TakoB222/postingAds-api/pkg/hub"
+	"github.com/TakoB222/postingAds-api/pkg/logger"
+	"time"
+)
+
+// Types of events pushed to connected users.
+const (
+	EventNewMessage           = "new_message"
+	EventAdApproved           = "ad_approved"
+	EventAdRejected           = "ad_rejected"
+	EventFavoritePriceChanged = "favorite_price_changed"
+)
+
+const eventsCleanupInterval = time.Minute
+
+type adModeratedEvent struct {
+	AdId            int             `json:"ad_id"`
+	Title           string          `json:"title"`
+	Status          domain.AdStatus `json:"status"`
+	RejectionReason string          `json:"rejection_reason,omitempty"`
+}
+
+// EventsConfig configures events kept to replay to reconnecting clients.
+type EventsConfig struct {
+	ReplaySize int // per user
+	ReplayTTL  time.Duration
+}
+
+type EventService struct {
+	hub *hub.Hub
+}
+
+func NewEventService(cfg EventsConfig) *EventService {
+	return &EventService{hub: hub.New(cfg.ReplaySize, cfg.ReplayTTL)}
+}
+
+// Subscribe starts delivery of events of the user, events published after lastEventId are replayed first.
+func (s *EventService) Subscribe(userId, lastEventId string) *hub.Subscription {
+	return s.hub.Subscribe(userId, lastEventId)
+}
+
+func (s *EventService) Unsubscribe(sub *hub.Subscription) {
+	s.hub.Unsubscribe(sub)
+}
+
+// RunCleanup drops events that can no longer be replayed until ctx is done.
+func (s *EventService) RunCleanup(ctx context.Context) {
+	ticker := time.NewTicker(eventsCleanupInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+
+		s.hub.Prune(time.Now())
+	}
+}
+
+// publish never fails the change that caused the event, clients that miss it see the change on the next fetch.
+func (s *EventService) publish(userId, eventType string, data interface{}) {
+	payload, err := json.Marshal(data)
+	if err != nil {
+		logger.Errorf("failed to publish %s event: %s", eventType, err.Error())
+		return
+	}
+
+	s.hub.Publish(userId, eventType, payload)
+}
@@ -0,0 +1,140 @@
+package service
+
+import (
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"github.com/TakoB222/postingAds-api/internal/domain"
+	"github.com/TakoB222/postingAds-api/internal/repository"
+	"github.com/TakoB222/postingAds-api/pkg/logger"
+	"strconv"
+)
+
+const (
+	notificationKindFavoritePriceChanged = "favorite_price_changed"
+	notificationKindFavoriteRemoved      = "favorite_removed"
+)
+
+type FavoriteService struct {
+	repo          repository.Favorite
+	notifications repository.Notification
+	events        *EventService
+}
+
+func NewFavoriteService(repo repository.Favorite, notifications repository.Notification, events *EventService) *FavoriteService {
+	return &FavoriteService{repo: repo, notifications: notifications, events: events}
+}
+
+// AddFavorite adds a published ad of another user to favorites, adding it again changes nothing.
+func (s *FavoriteService) AddFavorite(userId, adId string) error {
+	ownerId, err := s.repo.GetPublishedAdOwner(adId)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return ErrAdNotFound
+		}
+		return err
+	}
+
+	if ownerId == userId {
+		return ErrOwnAdFavorite
+	}
+
+	return s.repo.AddFavorite(userId, adId)
+}
+
+func (s *FavoriteService) RemoveFavorite(userId, adId string) error {
+	if err := s.repo.RemoveFavorite(userId, adId); err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return ErrFavoriteNotFound
+		}
+		return err
+	}
+
+	return nil
+}
+
+// GetFavorites returns favorite ads of the user, ads that are no longer published stay until the user removes them.
+// Only approved ads are shown in full, of the others just the id and the status are left: edits of an ad
+// waiting for review or rejected must not reach other users.
+func (s *FavoriteService) GetFavorites(userId string) ([]domain.Ad, error) {
+	ads, err := s.repo.GetFavoriteAds(userId)
+	if err != nil {
+		return nil, err
+	}
+
+	for i, ad := range ads {
+		if ad.Status != domain.AdStatusApproved {
+			ads[i] = domain.Ad{Id: ad.Id, Status: ad.Status}
+		}
+	}
+	maskContacts(ads)
+
+	return ads, nil
+}
+
+type favoritePayload struct {
+	AdId     int  `json:"ad_id"`
+	OldPrice *int `json:"old_price,omitempty"`
+	Price    *int `json:"price,omitempty"`
+}
+
+// favoriteUserIds returns users to notify about the ad. Favorites go away with a deleted ad, so they are read before the delete.
+// A failure is only logged, it must not fail the change of the ad.
+func (s *FavoriteService) favoriteUserIds(adId string) []string {
+	userIds, err := s.repo.GetFavoriteUserIds(adId)
+	if err != nil {
+		logger.Errorf("failed to get users of favorite ad %s: %s", adId, err.Error())
+		return nil
+	}
+
+	return userIds
+}
+
+// notifyPriceChanged tells about the price of an approved ad that differs from approvedPrice, the one approved before.
+// An ad waiting for review is not announced, its price may still be rejected.
+func (s *FavoriteService) notifyPriceChanged(ad domain.Ad, approvedPrice *int) {
+	if ad.Status != domain.AdStatusApproved || approvedPrice == nil || *approvedPrice == ad.Price {
+		return
+	}
+
+	oldPrice := *approvedPrice
+	price := ad.Price
+	userIds := s.favoriteUserIds(strconv.Itoa(ad.Id))
+	payload := favoritePayload{AdId: ad.Id, OldPrice: &oldPrice, Price: &price}
+
+	s.notify(userIds, domain.Notification{
+		Kind:  notificationKindFavoritePriceChanged,
+		Title: fmt.Sprintf("The price of %q has changed", ad.Title),
+		Body:  fmt.Sprintf("The price of your favorite ad %q has changed from %d to %d.", ad.Title, oldPrice, ad.Price),
+	}, payload)
+
+	for _, userId := range userIds {
+		s.events.publish(userId, EventFavoritePriceChanged, payload)
+	}
+}
+
+func (s *FavoriteService) notifyRemoved(ad domain.Ad, userIds []string) {
+	s.notify(userIds, domain.Notification{
+		Kind:  notificationKindFavoriteRemoved,
+		Title: fmt.Sprintf("%q is no longer available", ad.Title),
+		Body:  fmt.Sprintf("Your favorite ad %q has been removed by its owner or a moderator.", ad.Title),
+	}, favoritePayload{AdId: ad.Id})
+}
+
+func (s *FavoriteService) notify(userIds []string, notification domain.Notification, payload favoritePayload) {
+	if len(userIds) == 0 {
+		return
+	}
+
+	data, err := json.Marshal(payload)
+	if err != nil {
+		logger.Errorf("failed to notify users of favorite ad %d: %s", payload.AdId, err.Error())
+		return
+	}
+	notification.Payload = data
+
+	if err := s.notifications.CreateNotifications(userIds, notification); err != nil {
+		logger.Errorf("failed to notify users of favorite ad %d: %s", payload.AdId, err.Error())
+	}
+}
@@ -0,0 +1,310 @@
+package service
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"fmt"
+	"github.com/TakoB222/postingAds-api/internal/domain"
+	"github.com/TakoB222/postingAds-api/internal/repository"
+	"github.com/TakoB222/postingAds-api/pkg/imaging"
+	"github.com/TakoB222/postingAds-api/pkg/logger"
+	"github.com/TakoB222/postingAds-api/pkg/storage"
+	"regexp"
+	"time"
+)
+
+const (
+	imageOriginal = "original"
+
+	imageCleanupInterval = time.Hour
+	storageTimeout       = 30 * time.Second
+)
+
+var (
+	imageIdPattern = regexp.MustCompile(`^[0-9a-f]{32}$`)
+
+	// thumbnails are scaled down to fit a square of the size
+	thumbnailSizes = []struct {
+		name string
+		side int
+	}{
+		{"small", 160},
+		{"medium", 480},
+		{"large", 1024},
+	}
+)
+
+type ImagesConfig struct {
+	MaxSize   int // bytes
+	MaxPixels int
+	// uploaded images never attached to an ad are deleted after UnattachedTTL
+	UnattachedTTL time.Duration
+}
+
+type UploadedImage struct {
+	domain.Image
+	URL        string            `json:"url"`
+	Thumbnails map[string]string `json:"thumbnails"`
+}
+
+type ImageService struct {
+	repo    repository.Image
+	storage storage.Storage
+	cfg     ImagesConfig
+}
+
+func NewImageService(repo repository.Image, storage storage.Storage, cfg ImagesConfig) *ImageService {
+	return &ImageService{repo: repo, storage: storage, cfg: cfg}
+}
+
+// UploadImage stores the image re-encoded without metadata along with its thumbnails.
+func (s *ImageService) UploadImage(userId string, data []byte) (UploadedImage, error) {
+	if len(data) > s.cfg.MaxSize {
+		return UploadedImage{}, ErrImageTooLarge
+	}
+
+	img, err := imaging.Decode(data, s.cfg.MaxPixels)
+	if err != nil {
+		return UploadedImage{}, fmt.Errorf("%w: %s", ErrInvalidImage, err.Error())
+	}
+
+	id, err := newImageId()
+	if err != nil {
+		return UploadedImage{}, err
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), storageTimeout)
+	defer cancel()
+
+	original, err := imaging.Encode(img)
+	if err != nil {
+		return UploadedImage{}, err
+	}
+
+	keys := make([]string, 0, len(thumbnailSizes)+1)
+	put := func(name string, data []byte) error {
+		key := imageKey(id, name)
+		if err := s.storage.Put(ctx, key, data, img.ContentType); err != nil {
+			return err
+		}
+		keys = append(keys, key)
+		return nil
+	}
+
+	err = put(imageOriginal, original)
+	for _, size := range thumbnailSizes {
+		if err != nil {
+			break
+		}
+
+		var thumbnail []byte
+		thumbnail, err = imaging.Encode(imaging.Thumbnail(img, size.side))
+		if err == nil {
+			err = put(size.name, thumbnail)
+		}
+	}
+
+	image := domain.Image{
+		Id:          id,
+		UserId:      userId,
+		ContentType: img.ContentType,
+		Width:       img.Bounds().Dx(),
+		Height:      img.Bounds().Dy(),
+		Size:        len(original),
+		CreatedAt:   time.Now(),
+	}
+	if err == nil {
+		err = s.repo.CreateImage(image)
+	}
+
+	if err != nil {
+		s.deleteObjects(keys)
+		return UploadedImage{}, err
+	}
+
+	return s.describe(image), nil
+}
+
+// GetImageURL returns the public url of the image or one of its thumbnails.
+func (s *ImageService) GetImageURL(imageId, size string) (string, error) {
+	if !imageIdPattern.MatchString(imageId) {
+		return "", ErrImageNotFound
+	}
+
+	if size == "" {
+		size = imageOriginal
+	}
+	if size != imageOriginal && !isThumbnailSize(size) {
+		return "", ErrImageSizeUnknown
+	}
+
+	return s.storage.URL(imageKey(imageId, size)), nil
+}
+
+// RunCleanup deletes uploads that were never attached to an ad, as well as the images left by deleted ads.
+func (s *ImageService) RunCleanup(ctx context.Context) {
+	ticker := time.NewTicker(imageCleanupInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+
+		images, err := s.repo.GetUnattachedImages(time.Now().Add(-s.cfg.UnattachedTTL))
+		if err != nil {
+			logger.Errorf("failed to get unattached images: %s", err.Error())
+			continue
+		}
+
+		s.removeImages(images)
+	}
+}
+
+// checkAdImages validates images_url of an ad: values kept from the current ad are accepted as they are,
+// new ones must be images of the user not attached to another ad.
+func (s *ImageService) checkAdImages(userId, adId string, values, current []string) error {
+	added := make([]string, 0, len(values))
+	for _, value := range values {
+		if !contains(current, value) {
+			added = append(added, value)
+		}
+	}
+	if len(added) == 0 {
+		return nil
+	}
+
+	images, err := s.repo.GetImagesByIds(added)
+	if err != nil {
+		return err
+	}
+
+	found := make(map[string]domain.Image, len(images))
+	for _, image := range images {
+		found[image.Id] = image
+	}
+
+	for _, id := range added {
+		image, ok := found[id]
+		if !ok || image.UserId != userId || image.AdId.Valid && image.AdId.String != adId {
+			return ErrImageNotFound.Invalid("images_url", fmt.Sprintf("image %s not found", id))
+		}
+	}
+
+	return nil
+}
+
+// syncAdImages attaches the images listed by the ad and removes the ones it does not list anymore.
+func (s *ImageService) syncAdImages(adId string, values []string) error {
+	if len(values) > 0 {
+		if err := s.repo.AttachImages(adId, values); err != nil {
+			return err
+		}
+	}
+
+	images, err := s.repo.GetImagesByAdId(adId)
+	if err != nil {
+		return err
+	}
+
+	removed := make([]domain.Image, 0)
+	for _, image := range images {
+		if !contains(values, image.Id) {
+			removed = append(removed, image)
+		}
+	}
+	s.removeImages(removed)
+
+	return nil
+}
+
+// removeImages deletes files first, so a failure leaves the record to be cleaned up later.
+func (s *ImageService) removeImages(images []domain.Image) {
+	if len(images) == 0 {
+		return
+	}
+
+	ids := make([]string, 0, len(images))
+	for _, image := range images {
+		keys := []string{imageKey(image.Id, imageOriginal)}
+		for _, size := range thumbnailSizes {
+			keys = append(keys, imageKey(image.Id, size.name))
+		}
+
+		if s.deleteObjects(keys) {
+			ids = append(ids, image.Id)
+		}
+	}
+
+	if len(ids) == 0 {
+		return
+	}
+
+	if err := s.repo.DeleteImages(ids); err != nil {
+		logger.Errorf("failed to delete images %v: %s", ids, err.Error())
+	}
+}
+
+func (s *ImageService) deleteObjects(keys []string) bool {
+	ctx, cancel := context.WithTimeout(context.Background(), storageTimeout)
+	defer cancel()
+
+	ok := true
+	for _, key := range keys {
+		if err := s.storage.Delete(ctx, key); err != nil {
+			logger.Errorf("failed to delete image object %s: %s", key, err.Error())
+			ok = false
+		}
+	}
+
+	return ok
+}
+
+func (s *ImageService) describe(image domain.Image) UploadedImage {
+	res := UploadedImage{
+		Image:      image,
+		URL:        s.storage.URL(imageKey(image.Id, imageOriginal)),
+		Thumbnails: make(map[string]string, len(thumbnailSizes)),
+	}
+	for _, size := range thumbnailSizes {
+		res.Thumbnails[size.name] = s.storage.URL(imageKey(image.Id, size.name))
+	}
+
+	return res
+}
+
+func imageKey(imageId, name string) string {
+	return "images/" + imageId + "/" + name
+}
+
+func newImageId() (string, error) {
+	b := make([]byte, 16)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+
+	return hex.EncodeToString(b), nil
+}
+
+func isThumbnailSize(name string) bool {
+	for _, size := range thumbnailSizes {
+		if size.name == name {
+			return true
+		}
+	}
+
+	return false
+}
+
+func contains(values []string, value string) bool {
+	for _, v := range values {
+		if v == value {
+			return true
+		}
+	}
+
+	return false
+}
@@ -5,11 +5,14 @@
 package mock_service
 
 import (
+	context "context"
 	reflect "reflect"
 
 	domain "github.com/TakoB222/postingAds-api/internal/domain"
 	repository "github.com/TakoB222/postingAds-api/internal/repository"
 	service "github.com/TakoB222/postingAds-api/internal/service"
+	auth "github.com/TakoB222/postingAds-api/pkg/auth"
+	hub "github.com/TakoB222/postingAds-api/pkg/hub"
 	gomock "github.com/golang/mock/gomock"
 )
 
@@ -36,6 +39,93 @@ func (m *MockAuthorization) EXPECT() *MockAuthorizationMockRecorder {
 	return m.recorder
 }
 
+// CheckSession mocks base method.
+func (m *MockAuthorization) CheckSession(userId, sessionId string) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "CheckSession", userId, sessionId)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// CheckSession indicates an expected call of CheckSession.
+func (mr *MockAuthorizationMockRecorder) CheckSession(userId, sessionId interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "CheckSession", reflect.TypeOf((*MockAuthorization)(nil).CheckSession), userId, sessionId)
+}
+
+// ConfirmTwoFactor mocks base method.
+func (m *MockAuthorization) ConfirmTwoFactor(userId, code string) ([]string, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "ConfirmTwoFactor", userId, code)
+	ret0, _ := ret[0].([]string)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// ConfirmTwoFactor indicates an expected call of ConfirmTwoFactor.
+func (mr *MockAuthorizationMockRecorder) ConfirmTwoFactor(userId, code interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "ConfirmTwoFactor", reflect.TypeOf((*MockAuthorization)(nil).ConfirmTwoFactor), userId, code)
+}
+
+// DisableTwoFactor mocks base method.
+func (m *MockAuthorization) DisableTwoFactor(userId, code string) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "DisableTwoFactor", userId, code)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// DisableTwoFactor indicates an expected call of DisableTwoFactor.
+func (mr *MockAuthorizationMockRecorder) DisableTwoFactor(userId, code interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "DisableTwoFactor", reflect.TypeOf((*MockAuthorization)(nil).DisableTwoFactor), userId, code)
+}
+
+// EnrollTwoFactor mocks base method.
+func (m *MockAuthorization) EnrollTwoFactor(userId string) (service.TwoFactorEnrollment, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "EnrollTwoFactor", userId)
+	ret0, _ := ret[0].(service.TwoFactorEnrollment)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// EnrollTwoFactor indicates an expected call of EnrollTwoFactor.
+func (mr *MockAuthorizationMockRecorder) EnrollTwoFactor(userId interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "EnrollTwoFactor", reflect.TypeOf((*MockAuthorization)(nil).EnrollTwoFactor), userId)
+}
+
+// GetSessions mocks base method.
+func (m *MockAuthorization) GetSessions(userId string) ([]domain.Session, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "GetSessions", userId)
+	ret0, _ := ret[0].([]domain.Session)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// GetSessions indicates an expected call of GetSessions.
+func (mr *MockAuthorizationMockRecorder) GetSessions(userId interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetSessions", reflect.TypeOf((*MockAuthorization)(nil).GetSessions), userId)
+}
+
+// Logout mocks base method.
+func (m *MockAuthorization) Logout(userId string, input service.RefreshInput) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "Logout", userId, input)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// Logout indicates an expected call of Logout.
+func (mr *MockAuthorizationMockRecorder) Logout(userId, input interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "Logout", reflect.TypeOf((*MockAuthorization)(nil).Logout), userId, input)
+}
+
 // RefreshSession mocks base method.
 func (m *MockAuthorization) RefreshSession(input service.RefreshInput) (service.Tokens, error) {
 	m.ctrl.T.Helper()
@@ -51,6 +141,76 @@ func (mr *MockAuthorizationMockRecorder) RefreshSession(input interface{}) *gomo
 	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "RefreshSession", reflect.TypeOf((*MockAuthorization)(nil).RefreshSession), input)
 }
 
+// RequestPasswordReset mocks base method.
+func (m *MockAuthorization) RequestPasswordReset(address string) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "RequestPasswordReset", address)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// RequestPasswordReset indicates an expected call of RequestPasswordReset.
+func (mr *MockAuthorizationMockRecorder) RequestPasswordReset(address interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "RequestPasswordReset", reflect.TypeOf((*MockAuthorization)(nil).RequestPasswordReset), address)
+}
+
+// ResendVerificationEmail mocks base method.
+func (m *MockAuthorization) ResendVerificationEmail(address string) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "ResendVerificationEmail", address)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// ResendVerificationEmail indicates an expected call of ResendVerificationEmail.
+func (mr *MockAuthorizationMockRecorder) ResendVerificationEmail(address interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "ResendVerificationEmail", reflect.TypeOf((*MockAuthorization)(nil).ResendVerificationEmail), address)
+}
+
+// ResetPassword mocks base method.
+func (m *MockAuthorization) ResetPassword(input service.PasswordResetInput) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "ResetPassword", input)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// ResetPassword indicates an expected call of ResetPassword.
+func (mr *MockAuthorizationMockRecorder) ResetPassword(input interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "ResetPassword", reflect.TypeOf((*MockAuthorization)(nil).ResetPassword), input)
+}
+
+// RevokeOtherSessions mocks base method.
+func (m *MockAuthorization) RevokeOtherSessions(userId string, input service.RefreshInput) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "RevokeOtherSessions", userId, input)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// RevokeOtherSessions indicates an expected call of RevokeOtherSessions.
+func (mr *MockAuthorizationMockRecorder) RevokeOtherSessions(userId, input interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "RevokeOtherSessions", reflect.TypeOf((*MockAuthorization)(nil).RevokeOtherSessions), userId, input)
+}
+
+// RevokeSession mocks base method.
+func (m *MockAuthorization) RevokeSession(userId, sessionId string) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "RevokeSession", userId, sessionId)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// RevokeSession indicates an expected call of RevokeSession.
+func (mr *MockAuthorizationMockRecorder) RevokeSession(userId, sessionId interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "RevokeSession", reflect.TypeOf((*MockAuthorization)(nil).RevokeSession), userId, sessionId)
+}
+
 // SignIn mocks base method.
 func (m *MockAuthorization) SignIn(input service.SignInInput) (service.Tokens, error) {
 	m.ctrl.T.Helper()
@@ -66,6 +226,21 @@ func (mr *MockAuthorizationMockRecorder) SignIn(input interface{}) *gomock.Call
 	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "SignIn", reflect.TypeOf((*MockAuthorization)(nil).SignIn), input)
 }
 
+// SignInSecondFactor mocks base method.
+func (m *MockAuthorization) SignInSecondFactor(input service.TwoFactorSignInInput) (service.Tokens, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "SignInSecondFactor", input)
+	ret0, _ := ret[0].(service.Tokens)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// SignInSecondFactor indicates an expected call of SignInSecondFactor.
+func (mr *MockAuthorizationMockRecorder) SignInSecondFactor(input interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "SignInSecondFactor", reflect.TypeOf((*MockAuthorization)(nil).SignInSecondFactor), input)
+}
+
 // SignUp mocks base method.
 func (m *MockAuthorization) SignUp(input service.UserSignUpInput) (int, error) {
 	m.ctrl.T.Helper()
@@ -81,6 +256,20 @@ func (mr *MockAuthorizationMockRecorder) SignUp(input interface{}) *gomock.Call
 	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "SignUp", reflect.TypeOf((*MockAuthorization)(nil).SignUp), input)
 }
 
+// VerifyEmail mocks base method.
+func (m *MockAuthorization) VerifyEmail(token string) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "VerifyEmail", token)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// VerifyEmail indicates an expected call of VerifyEmail.
+func (mr *MockAuthorizationMockRecorder) VerifyEmail(token interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "VerifyEmail", reflect.TypeOf((*MockAuthorization)(nil).VerifyEmail), token)
+}
+
 // MockAdmin is a mock of Admin interface.
 type MockAdmin struct {
 	ctrl     *gomock.Controller
@@ -104,6 +293,36 @@ func (m *MockAdmin) EXPECT() *MockAdminMockRecorder {
 	return m.recorder
 }
 
+// AdminApproveAd mocks base method.
+func (m *MockAdmin) AdminApproveAd(moderator auth.Subject, adId string) (domain.Ad, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "AdminApproveAd", moderator, adId)
+	ret0, _ := ret[0].(domain.Ad)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// AdminApproveAd indicates an expected call of AdminApproveAd.
+func (mr *MockAdminMockRecorder) AdminApproveAd(moderator, adId interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "AdminApproveAd", reflect.TypeOf((*MockAdmin)(nil).AdminApproveAd), moderator, adId)
+}
+
+// AdminConfirmTwoFactor mocks base method.
+func (m *MockAdmin) AdminConfirmTwoFactor(adminId, code string) ([]string, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "AdminConfirmTwoFactor", adminId, code)
+	ret0, _ := ret[0].([]string)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// AdminConfirmTwoFactor indicates an expected call of AdminConfirmTwoFactor.
+func (mr *MockAdminMockRecorder) AdminConfirmTwoFactor(adminId, code interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "AdminConfirmTwoFactor", reflect.TypeOf((*MockAdmin)(nil).AdminConfirmTwoFactor), adminId, code)
+}
+
 // AdminDeleteUserAdById mocks base method.
 func (m *MockAdmin) AdminDeleteUserAdById(adId string) error {
 	m.ctrl.T.Helper()
@@ -118,6 +337,35 @@ func (mr *MockAdminMockRecorder) AdminDeleteUserAdById(adId interface{}) *gomock
 	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "AdminDeleteUserAdById", reflect.TypeOf((*MockAdmin)(nil).AdminDeleteUserAdById), adId)
 }
 
+// AdminDisableTwoFactor mocks base method.
+func (m *MockAdmin) AdminDisableTwoFactor(adminId, code string) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "AdminDisableTwoFactor", adminId, code)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// AdminDisableTwoFactor indicates an expected call of AdminDisableTwoFactor.
+func (mr *MockAdminMockRecorder) AdminDisableTwoFactor(adminId, code interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "AdminDisableTwoFactor", reflect.TypeOf((*MockAdmin)(nil).AdminDisableTwoFactor), adminId, code)
+}
+
+// AdminEnrollTwoFactor mocks base method.
+func (m *MockAdmin) AdminEnrollTwoFactor(adminId string) (service.TwoFactorEnrollment, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "AdminEnrollTwoFactor", adminId)
+	ret0, _ := ret[0].(service.TwoFactorEnrollment)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// AdminEnrollTwoFactor indicates an expected call of AdminEnrollTwoFactor.
+func (mr *MockAdminMockRecorder) AdminEnrollTwoFactor(adminId interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "AdminEnrollTwoFactor", reflect.TypeOf((*MockAdmin)(nil).AdminEnrollTwoFactor), adminId)
+}
+
 // AdminGetAd mocks base method.
 func (m *MockAdmin) AdminGetAd(adId string) (domain.Ad, error) {
 	m.ctrl.T.Helper()
@@ -133,6 +381,21 @@ func (mr *MockAdminMockRecorder) AdminGetAd(adId interface{}) *gomock.Call {
 	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "AdminGetAd", reflect.TypeOf((*MockAdmin)(nil).AdminGetAd), adId)
 }
 
+// AdminGetAdsForModeration mocks base method.
+func (m *MockAdmin) AdminGetAdsForModeration() ([]domain.Ad, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "AdminGetAdsForModeration")
+	ret0, _ := ret[0].([]domain.Ad)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// AdminGetAdsForModeration indicates an expected call of AdminGetAdsForModeration.
+func (mr *MockAdminMockRecorder) AdminGetAdsForModeration() *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "AdminGetAdsForModeration", reflect.TypeOf((*MockAdmin)(nil).AdminGetAdsForModeration))
+}
+
 // AdminGetAllAdsByAdmin mocks base method.
 func (m *MockAdmin) AdminGetAllAdsByAdmin() ([]domain.Ad, error) {
 	m.ctrl.T.Helper()
@@ -148,6 +411,35 @@ func (mr *MockAdminMockRecorder) AdminGetAllAdsByAdmin() *gomock.Call {
 	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "AdminGetAllAdsByAdmin", reflect.TypeOf((*MockAdmin)(nil).AdminGetAllAdsByAdmin))
 }
 
+// AdminGetSessions mocks base method.
+func (m *MockAdmin) AdminGetSessions(adminId string) ([]domain.AdminSession, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "AdminGetSessions", adminId)
+	ret0, _ := ret[0].([]domain.AdminSession)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// AdminGetSessions indicates an expected call of AdminGetSessions.
+func (mr *MockAdminMockRecorder) AdminGetSessions(adminId interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "AdminGetSessions", reflect.TypeOf((*MockAdmin)(nil).AdminGetSessions), adminId)
+}
+
+// AdminLogout mocks base method.
+func (m *MockAdmin) AdminLogout(adminId string, input service.RefreshInput) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "AdminLogout", adminId, input)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// AdminLogout indicates an expected call of AdminLogout.
+func (mr *MockAdminMockRecorder) AdminLogout(adminId, input interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "AdminLogout", reflect.TypeOf((*MockAdmin)(nil).AdminLogout), adminId, input)
+}
+
 // AdminRefreshSession mocks base method.
 func (m *MockAdmin) AdminRefreshSession(input service.RefreshInput) (service.Tokens, error) {
 	m.ctrl.T.Helper()
@@ -163,6 +455,49 @@ func (mr *MockAdminMockRecorder) AdminRefreshSession(input interface{}) *gomock.
 	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "AdminRefreshSession", reflect.TypeOf((*MockAdmin)(nil).AdminRefreshSession), input)
 }
 
+// AdminRejectAd mocks base method.
+func (m *MockAdmin) AdminRejectAd(moderator auth.Subject, adId, reason string) (domain.Ad, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "AdminRejectAd", moderator, adId, reason)
+	ret0, _ := ret[0].(domain.Ad)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// AdminRejectAd indicates an expected call of AdminRejectAd.
+func (mr *MockAdminMockRecorder) AdminRejectAd(moderator, adId, reason interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "AdminRejectAd", reflect.TypeOf((*MockAdmin)(nil).AdminRejectAd), moderator, adId, reason)
+}
+
+// AdminRevokeOtherSessions mocks base method.
+func (m *MockAdmin) AdminRevokeOtherSessions(adminId string, input service.RefreshInput) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "AdminRevokeOtherSessions", adminId, input)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// AdminRevokeOtherSessions indicates an expected call of AdminRevokeOtherSessions.
+func (mr *MockAdminMockRecorder) AdminRevokeOtherSessions(adminId, input interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "AdminRevokeOtherSessions", reflect.TypeOf((*MockAdmin)(nil).AdminRevokeOtherSessions), adminId, input)
+}
+
+// AdminRevokeSession mocks base method.
+func (m *MockAdmin) AdminRevokeSession(adminId, sessionId string) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "AdminRevokeSession", adminId, sessionId)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// AdminRevokeSession indicates an expected call of AdminRevokeSession.
+func (mr *MockAdminMockRecorder) AdminRevokeSession(adminId, sessionId interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "AdminRevokeSession", reflect.TypeOf((*MockAdmin)(nil).AdminRevokeSession), adminId, sessionId)
+}
+
 // AdminSignIn mocks base method.
 func (m *MockAdmin) AdminSignIn(input service.SignInInput) (service.Tokens, error) {
 	m.ctrl.T.Helper()
@@ -178,6 +513,21 @@ func (mr *MockAdminMockRecorder) AdminSignIn(input interface{}) *gomock.Call {
 	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "AdminSignIn", reflect.TypeOf((*MockAdmin)(nil).AdminSignIn), input)
 }
 
+// AdminSignInSecondFactor mocks base method.
+func (m *MockAdmin) AdminSignInSecondFactor(input service.TwoFactorSignInInput) (service.Tokens, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "AdminSignInSecondFactor", input)
+	ret0, _ := ret[0].(service.Tokens)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// AdminSignInSecondFactor indicates an expected call of AdminSignInSecondFactor.
+func (mr *MockAdminMockRecorder) AdminSignInSecondFactor(input interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "AdminSignInSecondFactor", reflect.TypeOf((*MockAdmin)(nil).AdminSignInSecondFactor), input)
+}
+
 // AdminUpdateAd mocks base method.
 func (m *MockAdmin) AdminUpdateAd(adId string, ad service.Ads) (domain.Ad, error) {
 	m.ctrl.T.Helper()
@@ -193,6 +543,34 @@ func (mr *MockAdminMockRecorder) AdminUpdateAd(adId, ad interface{}) *gomock.Cal
 	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "AdminUpdateAd", reflect.TypeOf((*MockAdmin)(nil).AdminUpdateAd), adId, ad)
 }
 
+// BanUser mocks base method.
+func (m *MockAdmin) BanUser(userId, reason string) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "BanUser", userId, reason)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// BanUser indicates an expected call of BanUser.
+func (mr *MockAdminMockRecorder) BanUser(userId, reason interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "BanUser", reflect.TypeOf((*MockAdmin)(nil).BanUser), userId, reason)
+}
+
+// UnbanUser mocks base method.
+func (m *MockAdmin) UnbanUser(userId string) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "UnbanUser", userId)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// UnbanUser indicates an expected call of UnbanUser.
+func (mr *MockAdminMockRecorder) UnbanUser(userId interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "UnbanUser", reflect.TypeOf((*MockAdmin)(nil).UnbanUser), userId)
+}
+
 // MockAd is a mock of Ad interface.
 type MockAd struct {
 	ctrl     *gomock.Controller
@@ -216,6 +594,21 @@ func (m *MockAd) EXPECT() *MockAdMockRecorder {
 	return m.recorder
 }
 
+// ArchiveAd mocks base method.
+func (m *MockAd) ArchiveAd(userId, adId string) (domain.Ad, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "ArchiveAd", userId, adId)
+	ret0, _ := ret[0].(domain.Ad)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// ArchiveAd indicates an expected call of ArchiveAd.
+func (mr *MockAdMockRecorder) ArchiveAd(userId, adId interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "ArchiveAd", reflect.TypeOf((*MockAd)(nil).ArchiveAd), userId, adId)
+}
+
 // CreateAd mocks base method.
 func (m *MockAd) CreateAd(userId string, adInput service.Ads) (int, error) {
 	m.ctrl.T.Helper()
@@ -246,18 +639,18 @@ func (mr *MockAdMockRecorder) DeleteAd(userId, adId interface{}) *gomock.Call {
 }
 
 // Fts mocks base method.
-func (m *MockAd) Fts(search_request string) ([]repository.FtsResponse, error) {
+func (m *MockAd) Fts(input service.FtsInput) (service.FtsResult, error) {
 	m.ctrl.T.Helper()
-	ret := m.ctrl.Call(m, "Fts", search_request)
-	ret0, _ := ret[0].([]repository.FtsResponse)
+	ret := m.ctrl.Call(m, "Fts", input)
+	ret0, _ := ret[0].(service.FtsResult)
 	ret1, _ := ret[1].(error)
 	return ret0, ret1
 }
 
 // Fts indicates an expected call of Fts.
-func (mr *MockAdMockRecorder) Fts(search_request interface{}) *gomock.Call {
+func (mr *MockAdMockRecorder) Fts(input interface{}) *gomock.Call {
 	mr.mock.ctrl.T.Helper()
-	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "Fts", reflect.TypeOf((*MockAd)(nil).Fts), search_request)
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "Fts", reflect.TypeOf((*MockAd)(nil).Fts), input)
 }
 
 // GetAdById mocks base method.
@@ -290,6 +683,37 @@ func (mr *MockAdMockRecorder) GetAllAds(userId interface{}) *gomock.Call {
 	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetAllAds", reflect.TypeOf((*MockAd)(nil).GetAllAds), userId)
 }
 
+// GetPublishedAds mocks base method.
+func (m *MockAd) GetPublishedAds(filter service.AdsFilter) ([]domain.Ad, int, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "GetPublishedAds", filter)
+	ret0, _ := ret[0].([]domain.Ad)
+	ret1, _ := ret[1].(int)
+	ret2, _ := ret[2].(error)
+	return ret0, ret1, ret2
+}
+
+// GetPublishedAds indicates an expected call of GetPublishedAds.
+func (mr *MockAdMockRecorder) GetPublishedAds(filter interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetPublishedAds", reflect.TypeOf((*MockAd)(nil).GetPublishedAds), filter)
+}
+
+// Suggest mocks base method.
+func (m *MockAd) Suggest(query string, limit int) ([]repository.Suggestion, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "Suggest", query, limit)
+	ret0, _ := ret[0].([]repository.Suggestion)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// Suggest indicates an expected call of Suggest.
+func (mr *MockAdMockRecorder) Suggest(query, limit interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "Suggest", reflect.TypeOf((*MockAd)(nil).Suggest), query, limit)
+}
+
 // UpdateAd mocks base method.
 func (m *MockAd) UpdateAd(userId, adId string, ad service.Ads) (domain.Ad, error) {
 	m.ctrl.T.Helper()
@@ -304,3 +728,773 @@ func (mr *MockAdMockRecorder) UpdateAd(userId, adId, ad interface{}) *gomock.Cal
 	mr.mock.ctrl.T.Helper()
 	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "UpdateAd", reflect.TypeOf((*MockAd)(nil).UpdateAd), userId, adId, ad)
 }
+
+// MockRole is a mock of Role interface.
+type MockRole struct {
+	ctrl     *gomock.Controller
+	recorder *MockRoleMockRecorder
+}
+
+// MockRoleMockRecorder is the mock recorder for MockRole.
+type MockRoleMockRecorder struct {
+	mock *MockRole
+}
+
+// NewMockRole creates a new mock instance.
+func NewMockRole(ctrl *gomock.Controller) *MockRole {
+	mock := &MockRole{ctrl: ctrl}
+	mock.recorder = &MockRoleMockRecorder{mock}
+	return mock
+}
+
+// EXPECT returns an object that allows the caller to indicate expected use.
+func (m *MockRole) EXPECT() *MockRoleMockRecorder {
+	return m.recorder
+}
+
+// AssignRole mocks base method.
+func (m *MockRole) AssignRole(input service.RoleAssignmentInput) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "AssignRole", input)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// AssignRole indicates an expected call of AssignRole.
+func (mr *MockRoleMockRecorder) AssignRole(input interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "AssignRole", reflect.TypeOf((*MockRole)(nil).AssignRole), input)
+}
+
+// GetRoles mocks base method.
+func (m *MockRole) GetRoles() ([]domain.Role, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "GetRoles")
+	ret0, _ := ret[0].([]domain.Role)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// GetRoles indicates an expected call of GetRoles.
+func (mr *MockRoleMockRecorder) GetRoles() *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetRoles", reflect.TypeOf((*MockRole)(nil).GetRoles))
+}
+
+// RevokeRole mocks base method.
+func (m *MockRole) RevokeRole(input service.RoleAssignmentInput) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "RevokeRole", input)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// RevokeRole indicates an expected call of RevokeRole.
+func (mr *MockRoleMockRecorder) RevokeRole(input interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "RevokeRole", reflect.TypeOf((*MockRole)(nil).RevokeRole), input)
+}
+
+// MockImage is a mock of Image interface.
+type MockImage struct {
+	ctrl     *gomock.Controller
+	recorder *MockImageMockRecorder
+}
+
+// MockImageMockRecorder is the mock recorder for MockImage.
+type MockImageMockRecorder struct {
+	mock *MockImage
+}
+
+// NewMockImage creates a new mock instance.
+func NewMockImage(ctrl *gomock.Controller) *MockImage {
+	mock := &MockImage{ctrl: ctrl}
+	mock.recorder = &MockImageMockRecorder{mock}
+	return mock
+}
+
+// EXPECT returns an object that allows the caller to indicate expected use.
+func (m *MockImage) EXPECT() *MockImageMockRecorder {
+	return m.recorder
+}
+
+// GetImageURL mocks base method.
+func (m *MockImage) GetImageURL(imageId, size string) (string, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "GetImageURL", imageId, size)
+	ret0, _ := ret[0].(string)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// GetImageURL indicates an expected call of GetImageURL.
+func (mr *MockImageMockRecorder) GetImageURL(imageId, size interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetImageURL", reflect.TypeOf((*MockImage)(nil).GetImageURL), imageId, size)
+}
+
+// RunCleanup mocks base method.
+func (m *MockImage) RunCleanup(ctx context.Context) {
+	m.ctrl.T.Helper()
+	m.ctrl.Call(m, "RunCleanup", ctx)
+}
+
+// RunCleanup indicates an expected call of RunCleanup.
+func (mr *MockImageMockRecorder) RunCleanup(ctx interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "RunCleanup", reflect.TypeOf((*MockImage)(nil).RunCleanup), ctx)
+}
+
+// UploadImage mocks base method.
+func (m *MockImage) UploadImage(userId string, data []byte) (service.UploadedImage, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "UploadImage", userId, data)
+	ret0, _ := ret[0].(service.UploadedImage)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// UploadImage indicates an expected call of UploadImage.
+func (mr *MockImageMockRecorder) UploadImage(userId, data interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "UploadImage", reflect.TypeOf((*MockImage)(nil).UploadImage), userId, data)
+}
+
+// MockCategory is a mock of Category interface.
+type MockCategory struct {
+	ctrl     *gomock.Controller
+	recorder *MockCategoryMockRecorder
+}
+
+// MockCategoryMockRecorder is the mock recorder for MockCategory.
+type MockCategoryMockRecorder struct {
+	mock *MockCategory
+}
+
+// NewMockCategory creates a new mock instance.
+func NewMockCategory(ctrl *gomock.Controller) *MockCategory {
+	mock := &MockCategory{ctrl: ctrl}
+	mock.recorder = &MockCategoryMockRecorder{mock}
+	return mock
+}
+
+// EXPECT returns an object that allows the caller to indicate expected use.
+func (m *MockCategory) EXPECT() *MockCategoryMockRecorder {
+	return m.recorder
+}
+
+// CreateCategory mocks base method.
+func (m *MockCategory) CreateCategory(input service.CategoryInput) (domain.Categories, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "CreateCategory", input)
+	ret0, _ := ret[0].(domain.Categories)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// CreateCategory indicates an expected call of CreateCategory.
+func (mr *MockCategoryMockRecorder) CreateCategory(input interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "CreateCategory", reflect.TypeOf((*MockCategory)(nil).CreateCategory), input)
+}
+
+// DeleteCategory mocks base method.
+func (m *MockCategory) DeleteCategory(categoryId int) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "DeleteCategory", categoryId)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// DeleteCategory indicates an expected call of DeleteCategory.
+func (mr *MockCategoryMockRecorder) DeleteCategory(categoryId interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "DeleteCategory", reflect.TypeOf((*MockCategory)(nil).DeleteCategory), categoryId)
+}
+
+// GetCategoryAttributes mocks base method.
+func (m *MockCategory) GetCategoryAttributes(categoryId int) (domain.AttributeSchema, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "GetCategoryAttributes", categoryId)
+	ret0, _ := ret[0].(domain.AttributeSchema)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// GetCategoryAttributes indicates an expected call of GetCategoryAttributes.
+func (mr *MockCategoryMockRecorder) GetCategoryAttributes(categoryId interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetCategoryAttributes", reflect.TypeOf((*MockCategory)(nil).GetCategoryAttributes), categoryId)
+}
+
+// GetCategoryTree mocks base method.
+func (m *MockCategory) GetCategoryTree() ([]domain.CategoryNode, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "GetCategoryTree")
+	ret0, _ := ret[0].([]domain.CategoryNode)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// GetCategoryTree indicates an expected call of GetCategoryTree.
+func (mr *MockCategoryMockRecorder) GetCategoryTree() *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetCategoryTree", reflect.TypeOf((*MockCategory)(nil).GetCategoryTree))
+}
+
+// SetCategoryAttributes mocks base method.
+func (m *MockCategory) SetCategoryAttributes(categoryId int, attributes domain.AttributeSchema) (domain.Categories, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "SetCategoryAttributes", categoryId, attributes)
+	ret0, _ := ret[0].(domain.Categories)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// SetCategoryAttributes indicates an expected call of SetCategoryAttributes.
+func (mr *MockCategoryMockRecorder) SetCategoryAttributes(categoryId, attributes interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "SetCategoryAttributes", reflect.TypeOf((*MockCategory)(nil).SetCategoryAttributes), categoryId, attributes)
+}
+
+// UpdateCategory mocks base method.
+func (m *MockCategory) UpdateCategory(categoryId int, input service.CategoryInput) (domain.Categories, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "UpdateCategory", categoryId, input)
+	ret0, _ := ret[0].(domain.Categories)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// UpdateCategory indicates an expected call of UpdateCategory.
+func (mr *MockCategoryMockRecorder) UpdateCategory(categoryId, input interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "UpdateCategory", reflect.TypeOf((*MockCategory)(nil).UpdateCategory), categoryId, input)
+}
+
+// MockSavedSearch is a mock of SavedSearch interface.
+type MockSavedSearch struct {
+	ctrl     *gomock.Controller
+	recorder *MockSavedSearchMockRecorder
+}
+
+// MockSavedSearchMockRecorder is the mock recorder for MockSavedSearch.
+type MockSavedSearchMockRecorder struct {
+	mock *MockSavedSearch
+}
+
+// NewMockSavedSearch creates a new mock instance.
+func NewMockSavedSearch(ctrl *gomock.Controller) *MockSavedSearch {
+	mock := &MockSavedSearch{ctrl: ctrl}
+	mock.recorder = &MockSavedSearchMockRecorder{mock}
+	return mock
+}
+
+// EXPECT returns an object that allows the caller to indicate expected use.
+func (m *MockSavedSearch) EXPECT() *MockSavedSearchMockRecorder {
+	return m.recorder
+}
+
+// CreateSavedSearch mocks base method.
+func (m *MockSavedSearch) CreateSavedSearch(userId string, input service.SavedSearchInput) (domain.SavedSearch, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "CreateSavedSearch", userId, input)
+	ret0, _ := ret[0].(domain.SavedSearch)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// CreateSavedSearch indicates an expected call of CreateSavedSearch.
+func (mr *MockSavedSearchMockRecorder) CreateSavedSearch(userId, input interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "CreateSavedSearch", reflect.TypeOf((*MockSavedSearch)(nil).CreateSavedSearch), userId, input)
+}
+
+// DeleteSavedSearch mocks base method.
+func (m *MockSavedSearch) DeleteSavedSearch(userId string, searchId int) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "DeleteSavedSearch", userId, searchId)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// DeleteSavedSearch indicates an expected call of DeleteSavedSearch.
+func (mr *MockSavedSearchMockRecorder) DeleteSavedSearch(userId, searchId interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "DeleteSavedSearch", reflect.TypeOf((*MockSavedSearch)(nil).DeleteSavedSearch), userId, searchId)
+}
+
+// GetSavedSearches mocks base method.
+func (m *MockSavedSearch) GetSavedSearches(userId string) ([]domain.SavedSearch, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "GetSavedSearches", userId)
+	ret0, _ := ret[0].([]domain.SavedSearch)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// GetSavedSearches indicates an expected call of GetSavedSearches.
+func (mr *MockSavedSearchMockRecorder) GetSavedSearches(userId interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetSavedSearches", reflect.TypeOf((*MockSavedSearch)(nil).GetSavedSearches), userId)
+}
+
+// RunNotifications mocks base method.
+func (m *MockSavedSearch) RunNotifications(ctx context.Context) {
+	m.ctrl.T.Helper()
+	m.ctrl.Call(m, "RunNotifications", ctx)
+}
+
+// RunNotifications indicates an expected call of RunNotifications.
+func (mr *MockSavedSearchMockRecorder) RunNotifications(ctx interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "RunNotifications", reflect.TypeOf((*MockSavedSearch)(nil).RunNotifications), ctx)
+}
+
+// MockNotification is a mock of Notification interface.
+type MockNotification struct {
+	ctrl     *gomock.Controller
+	recorder *MockNotificationMockRecorder
+}
+
+// MockNotificationMockRecorder is the mock recorder for MockNotification.
+type MockNotificationMockRecorder struct {
+	mock *MockNotification
+}
+
+// NewMockNotification creates a new mock instance.
+func NewMockNotification(ctrl *gomock.Controller) *MockNotification {
+	mock := &MockNotification{ctrl: ctrl}
+	mock.recorder = &MockNotificationMockRecorder{mock}
+	return mock
+}
+
+// EXPECT returns an object that allows the caller to indicate expected use.
+func (m *MockNotification) EXPECT() *MockNotificationMockRecorder {
+	return m.recorder
+}
+
+// GetNotifications mocks base method.
+func (m *MockNotification) GetNotifications(userId string, limit, offset int) ([]domain.Notification, int, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "GetNotifications", userId, limit, offset)
+	ret0, _ := ret[0].([]domain.Notification)
+	ret1, _ := ret[1].(int)
+	ret2, _ := ret[2].(error)
+	return ret0, ret1, ret2
+}
+
+// GetNotifications indicates an expected call of GetNotifications.
+func (mr *MockNotificationMockRecorder) GetNotifications(userId, limit, offset interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetNotifications", reflect.TypeOf((*MockNotification)(nil).GetNotifications), userId, limit, offset)
+}
+
+// ReadNotification mocks base method.
+func (m *MockNotification) ReadNotification(userId string, notificationId int) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "ReadNotification", userId, notificationId)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// ReadNotification indicates an expected call of ReadNotification.
+func (mr *MockNotificationMockRecorder) ReadNotification(userId, notificationId interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "ReadNotification", reflect.TypeOf((*MockNotification)(nil).ReadNotification), userId, notificationId)
+}
+
+// MockFavorite is a mock of Favorite interface.
+type MockFavorite struct {
+	ctrl     *gomock.Controller
+	recorder *MockFavoriteMockRecorder
+}
+
+// MockFavoriteMockRecorder is the mock recorder for MockFavorite.
+type MockFavoriteMockRecorder struct {
+	mock *MockFavorite
+}
+
+// NewMockFavorite creates a new mock instance.
+func NewMockFavorite(ctrl *gomock.Controller) *MockFavorite {
+	mock := &MockFavorite{ctrl: ctrl}
+	mock.recorder = &MockFavoriteMockRecorder{mock}
+	return mock
+}
+
+// EXPECT returns an object that allows the caller to indicate expected use.
+func (m *MockFavorite) EXPECT() *MockFavoriteMockRecorder {
+	return m.recorder
+}
+
+// AddFavorite mocks base method.
+func (m *MockFavorite) AddFavorite(userId, adId string) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "AddFavorite", userId, adId)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// AddFavorite indicates an expected call of AddFavorite.
+func (mr *MockFavoriteMockRecorder) AddFavorite(userId, adId interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "AddFavorite", reflect.TypeOf((*MockFavorite)(nil).AddFavorite), userId, adId)
+}
+
+// GetFavorites mocks base method.
+func (m *MockFavorite) GetFavorites(userId string) ([]domain.Ad, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "GetFavorites", userId)
+	ret0, _ := ret[0].([]domain.Ad)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// GetFavorites indicates an expected call of GetFavorites.
+func (mr *MockFavoriteMockRecorder) GetFavorites(userId interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetFavorites", reflect.TypeOf((*MockFavorite)(nil).GetFavorites), userId)
+}
+
+// RemoveFavorite mocks base method.
+func (m *MockFavorite) RemoveFavorite(userId, adId string) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "RemoveFavorite", userId, adId)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// RemoveFavorite indicates an expected call of RemoveFavorite.
+func (mr *MockFavoriteMockRecorder) RemoveFavorite(userId, adId interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "RemoveFavorite", reflect.TypeOf((*MockFavorite)(nil).RemoveFavorite), userId, adId)
+}
+
+// MockThread is a mock of Thread interface.
+type MockThread struct {
+	ctrl     *gomock.Controller
+	recorder *MockThreadMockRecorder
+}
+
+// MockThreadMockRecorder is the mock recorder for MockThread.
+type MockThreadMockRecorder struct {
+	mock *MockThread
+}
+
+// NewMockThread creates a new mock instance.
+func NewMockThread(ctrl *gomock.Controller) *MockThread {
+	mock := &MockThread{ctrl: ctrl}
+	mock.recorder = &MockThreadMockRecorder{mock}
+	return mock
+}
+
+// EXPECT returns an object that allows the caller to indicate expected use.
+func (m *MockThread) EXPECT() *MockThreadMockRecorder {
+	return m.recorder
+}
+
+// BlockBuyer mocks base method.
+func (m *MockThread) BlockBuyer(userId string, threadId int) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "BlockBuyer", userId, threadId)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// BlockBuyer indicates an expected call of BlockBuyer.
+func (mr *MockThreadMockRecorder) BlockBuyer(userId, threadId interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "BlockBuyer", reflect.TypeOf((*MockThread)(nil).BlockBuyer), userId, threadId)
+}
+
+// GetMessages mocks base method.
+func (m *MockThread) GetMessages(userId string, threadId, limit, offset int) ([]domain.Message, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "GetMessages", userId, threadId, limit, offset)
+	ret0, _ := ret[0].([]domain.Message)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// GetMessages indicates an expected call of GetMessages.
+func (mr *MockThreadMockRecorder) GetMessages(userId, threadId, limit, offset interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetMessages", reflect.TypeOf((*MockThread)(nil).GetMessages), userId, threadId, limit, offset)
+}
+
+// GetThread mocks base method.
+func (m *MockThread) GetThread(userId string, threadId int) (domain.Thread, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "GetThread", userId, threadId)
+	ret0, _ := ret[0].(domain.Thread)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// GetThread indicates an expected call of GetThread.
+func (mr *MockThreadMockRecorder) GetThread(userId, threadId interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetThread", reflect.TypeOf((*MockThread)(nil).GetThread), userId, threadId)
+}
+
+// GetThreads mocks base method.
+func (m *MockThread) GetThreads(userId string, limit, offset int) ([]domain.Thread, int, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "GetThreads", userId, limit, offset)
+	ret0, _ := ret[0].([]domain.Thread)
+	ret1, _ := ret[1].(int)
+	ret2, _ := ret[2].(error)
+	return ret0, ret1, ret2
+}
+
+// GetThreads indicates an expected call of GetThreads.
+func (mr *MockThreadMockRecorder) GetThreads(userId, limit, offset interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetThreads", reflect.TypeOf((*MockThread)(nil).GetThreads), userId, limit, offset)
+}
+
+// OpenThread mocks base method.
+func (m *MockThread) OpenThread(userId, adId, message string) (domain.Thread, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "OpenThread", userId, adId, message)
+	ret0, _ := ret[0].(domain.Thread)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// OpenThread indicates an expected call of OpenThread.
+func (mr *MockThreadMockRecorder) OpenThread(userId, adId, message interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "OpenThread", reflect.TypeOf((*MockThread)(nil).OpenThread), userId, adId, message)
+}
+
+// ReadThread mocks base method.
+func (m *MockThread) ReadThread(userId string, threadId int) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "ReadThread", userId, threadId)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// ReadThread indicates an expected call of ReadThread.
+func (mr *MockThreadMockRecorder) ReadThread(userId, threadId interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "ReadThread", reflect.TypeOf((*MockThread)(nil).ReadThread), userId, threadId)
+}
+
+// SendMessage mocks base method.
+func (m *MockThread) SendMessage(userId string, threadId int, message string) (domain.Message, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "SendMessage", userId, threadId, message)
+	ret0, _ := ret[0].(domain.Message)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// SendMessage indicates an expected call of SendMessage.
+func (mr *MockThreadMockRecorder) SendMessage(userId, threadId, message interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "SendMessage", reflect.TypeOf((*MockThread)(nil).SendMessage), userId, threadId, message)
+}
+
+// UnblockBuyer mocks base method.
+func (m *MockThread) UnblockBuyer(userId string, threadId int) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "UnblockBuyer", userId, threadId)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// UnblockBuyer indicates an expected call of UnblockBuyer.
+func (mr *MockThreadMockRecorder) UnblockBuyer(userId, threadId interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "UnblockBuyer", reflect.TypeOf((*MockThread)(nil).UnblockBuyer), userId, threadId)
+}
+
+// MockEvents is a mock of Events interface.
+type MockEvents struct {
+	ctrl     *gomock.Controller
+	recorder *MockEventsMockRecorder
+}
+
+// MockEventsMockRecorder is the mock recorder for MockEvents.
+type MockEventsMockRecorder struct {
+	mock *MockEvents
+}
+
+// NewMockEvents creates a new mock instance.
+func NewMockEvents(ctrl *gomock.Controller) *MockEvents {
+	mock := &MockEvents{ctrl: ctrl}
+	mock.recorder = &MockEventsMockRecorder{mock}
+	return mock
+}
+
+// EXPECT returns an object that allows the caller to indicate expected use.
+func (m *MockEvents) EXPECT() *MockEventsMockRecorder {
+	return m.recorder
+}
+
+// RunCleanup mocks base method.
+func (m *MockEvents) RunCleanup(ctx context.Context) {
+	m.ctrl.T.Helper()
+	m.ctrl.Call(m, "RunCleanup", ctx)
+}
+
+// RunCleanup indicates an expected call of RunCleanup.
+func (mr *MockEventsMockRecorder) RunCleanup(ctx interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "RunCleanup", reflect.TypeOf((*MockEvents)(nil).RunCleanup), ctx)
+}
+
+// Subscribe mocks base method.
+func (m *MockEvents) Subscribe(userId, lastEventId string) *hub.Subscription {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "Subscribe", userId, lastEventId)
+	ret0, _ := ret[0].(*hub.Subscription)
+	return ret0
+}
+
+// Subscribe indicates an expected call of Subscribe.
+func (mr *MockEventsMockRecorder) Subscribe(userId, lastEventId interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "Subscribe", reflect.TypeOf((*MockEvents)(nil).Subscribe), userId, lastEventId)
+}
+
+// Unsubscribe mocks base method.
+func (m *MockEvents) Unsubscribe(sub *hub.Subscription) {
+	m.ctrl.T.Helper()
+	m.ctrl.Call(m, "Unsubscribe", sub)
+}
+
+// Unsubscribe indicates an expected call of Unsubscribe.
+func (mr *MockEventsMockRecorder) Unsubscribe(sub interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "Unsubscribe", reflect.TypeOf((*MockEvents)(nil).Unsubscribe), sub)
+}
+
+// MockContact is a mock of Contact interface.
+type MockContact struct {
+	ctrl     *gomock.Controller
+	recorder *MockContactMockRecorder
+}
+
+// MockContactMockRecorder is the mock recorder for MockContact.
+type MockContactMockRecorder struct {
+	mock *MockContact
+}
+
+// NewMockContact creates a new mock instance.
+func NewMockContact(ctrl *gomock.Controller) *MockContact {
+	mock := &MockContact{ctrl: ctrl}
+	mock.recorder = &MockContactMockRecorder{mock}
+	return mock
+}
+
+// EXPECT returns an object that allows the caller to indicate expected use.
+func (m *MockContact) EXPECT() *MockContactMockRecorder {
+	return m.recorder
+}
+
+// CreateContactProfile mocks base method.
+func (m *MockContact) CreateContactProfile(userId string, profile domain.ContactProfile) (domain.ContactProfile, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "CreateContactProfile", userId, profile)
+	ret0, _ := ret[0].(domain.ContactProfile)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// CreateContactProfile indicates an expected call of CreateContactProfile.
+func (mr *MockContactMockRecorder) CreateContactProfile(userId, profile interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "CreateContactProfile", reflect.TypeOf((*MockContact)(nil).CreateContactProfile), userId, profile)
+}
+
+// DeleteContactProfile mocks base method.
+func (m *MockContact) DeleteContactProfile(userId string, profileId int) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "DeleteContactProfile", userId, profileId)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// DeleteContactProfile indicates an expected call of DeleteContactProfile.
+func (mr *MockContactMockRecorder) DeleteContactProfile(userId, profileId interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "DeleteContactProfile", reflect.TypeOf((*MockContact)(nil).DeleteContactProfile), userId, profileId)
+}
+
+// GetContactProfile mocks base method.
+func (m *MockContact) GetContactProfile(userId string, profileId int) (domain.ContactProfile, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "GetContactProfile", userId, profileId)
+	ret0, _ := ret[0].(domain.ContactProfile)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// GetContactProfile indicates an expected call of GetContactProfile.
+func (mr *MockContactMockRecorder) GetContactProfile(userId, profileId interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetContactProfile", reflect.TypeOf((*MockContact)(nil).GetContactProfile), userId, profileId)
+}
+
+// GetContactProfiles mocks base method.
+func (m *MockContact) GetContactProfiles(userId string) ([]domain.ContactProfile, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "GetContactProfiles", userId)
+	ret0, _ := ret[0].([]domain.ContactProfile)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// GetContactProfiles indicates an expected call of GetContactProfiles.
+func (mr *MockContactMockRecorder) GetContactProfiles(userId interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetContactProfiles", reflect.TypeOf((*MockContact)(nil).GetContactProfiles), userId)
+}
+
+// GetContactRevealStats mocks base method.
+func (m *MockContact) GetContactRevealStats(userId string) ([]domain.ContactRevealStats, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "GetContactRevealStats", userId)
+	ret0, _ := ret[0].([]domain.ContactRevealStats)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// GetContactRevealStats indicates an expected call of GetContactRevealStats.
+func (mr *MockContactMockRecorder) GetContactRevealStats(userId interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetContactRevealStats", reflect.TypeOf((*MockContact)(nil).GetContactRevealStats), userId)
+}
+
+// RevealContacts mocks base method.
+func (m *MockContact) RevealContacts(userId, adId, ip string) (domain.Contacts, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "RevealContacts", userId, adId, ip)
+	ret0, _ := ret[0].(domain.Contacts)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// RevealContacts indicates an expected call of RevealContacts.
+func (mr *MockContactMockRecorder) RevealContacts(userId, adId, ip interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "RevealContacts", reflect.TypeOf((*MockContact)(nil).RevealContacts), userId, adId, ip)
+}
+
+// UpdateContactProfile mocks base method.
+func (m *MockContact) UpdateContactProfile(userId string, profile domain.ContactProfile) (domain.ContactProfile, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "UpdateContactProfile", userId, profile)
+	ret0, _ := ret[0].(domain.ContactProfile)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// UpdateContactProfile indicates an expected call of UpdateContactProfile.
+func (mr *MockContactMockRecorder) UpdateContactProfile(userId, profile interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "UpdateContactProfile", reflect.TypeOf((*MockContact)(nil).UpdateContactProfile), userId, profile)
+}
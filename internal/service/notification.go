@@ -0,0 +1,32 @@
+package service
+
+import (
+	"database/sql"
+	"errors"
+	"github.com/TakoB222/postingAds-api/internal/domain"
+	"github.com/TakoB222/postingAds-api/internal/repository"
+)
+
+type NotificationService struct {
+	repo repository.Notification
+}
+
+func NewNotificationService(repo repository.Notification) *NotificationService {
+	return &NotificationService{repo: repo}
+}
+
+// GetNotifications returns a page of the inbox of the user and the number of unread notifications.
+func (s *NotificationService) GetNotifications(userId string, limit, offset int) ([]domain.Notification, int, error) {
+	return s.repo.GetNotifications(userId, limit, offset)
+}
+
+func (s *NotificationService) ReadNotification(userId string, notificationId int) error {
+	if err := s.repo.ReadNotification(userId, notificationId); err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return ErrNotificationNotFound
+		}
+		return err
+	}
+
+	return nil
+}
@@ -0,0 +1,230 @@
+package service
+
+import (
+	"bytes"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"github.com/TakoB222/postingAds-api/internal/domain"
+	"github.com/TakoB222/postingAds-api/internal/repository"
+	"github.com/TakoB222/postingAds-api/pkg/email"
+	"net"
+	"net/http"
+	"strconv"
+	"strings"
+	"syscall"
+	"time"
+)
+
+const notificationKindSavedSearch = "saved_search"
+
+// SearchMatches are new ads of a saved search to tell its user about.
+type SearchMatches struct {
+	Search domain.SavedSearch
+	Email  string
+	Ads    []domain.Ad
+}
+
+// Notifier delivers new ads of saved searches through one of the channels.
+type Notifier interface {
+	Notify(matches SearchMatches) error
+}
+
+type EmailNotifier struct {
+	mailer email.Mailer
+}
+
+func NewEmailNotifier(mailer email.Mailer) *EmailNotifier {
+	return &EmailNotifier{mailer: mailer}
+}
+
+func (n *EmailNotifier) Notify(matches SearchMatches) error {
+	return n.mailer.Send(email.Message{
+		To:      matches.Email,
+		Subject: matchesTitle(matches),
+		Body:    matchesBody(matches),
+	})
+}
+
+const (
+	webhookTimestampHeader = "X-Webhook-Timestamp"
+	webhookSignatureHeader = "X-Webhook-Signature"
+)
+
+var errNonPublicAddress = errors.New("webhook address is not public")
+
+// nonPublicNetworks are addresses of this host and of internal networks, webhooks are never posted to them.
+var nonPublicNetworks = parseNetworks(
+	"0.0.0.0/8", "10.0.0.0/8", "100.64.0.0/10", "127.0.0.0/8", "169.254.0.0/16", "172.16.0.0/12",
+	"192.0.0.0/24", "192.168.0.0/16", "198.18.0.0/15", "224.0.0.0/4", "240.0.0.0/4",
+	"::/128", "::1/128", "fc00::/7", "fe80::/10", "ff00::/8",
+)
+
+// WebhookNotifier posts new ads as json to the url of the saved search.
+// Posts are signed with the secret, receivers check the signature to know the post comes from us.
+type WebhookNotifier struct {
+	client *http.Client
+	secret []byte
+}
+
+func NewWebhookNotifier(timeout time.Duration, secret string) *WebhookNotifier {
+	// the address is checked once more when dialing, the host could resolve to another one than when the search was saved
+	dialer := &net.Dialer{Timeout: timeout, Control: dialPublic}
+
+	transport := http.DefaultTransport.(*http.Transport).Clone()
+	transport.Proxy = nil
+	transport.DialContext = dialer.DialContext
+
+	return &WebhookNotifier{
+		client: &http.Client{
+			Timeout:   timeout,
+			Transport: transport,
+			// a redirect is answered as is, following it could lead anywhere
+			CheckRedirect: func(*http.Request, []*http.Request) error {
+				return http.ErrUseLastResponse
+			},
+		},
+		secret: []byte(secret),
+	}
+}
+
+type webhookPayload struct {
+	SavedSearch webhookSavedSearch `json:"saved_search"`
+	Ads         []domain.Ad        `json:"ads"`
+}
+
+type webhookSavedSearch struct {
+	Id    int    `json:"id"`
+	Name  string `json:"name"`
+	Query string `json:"query"`
+}
+
+func (n *WebhookNotifier) Notify(matches SearchMatches) error {
+	body, err := json.Marshal(webhookPayload{
+		SavedSearch: webhookSavedSearch{Id: matches.Search.Id, Name: matches.Search.Name, Query: matches.Search.Query},
+		Ads:         matches.Ads,
+	})
+	if err != nil {
+		return err
+	}
+
+	req, err := http.NewRequest(http.MethodPost, matches.Search.WebhookURL, bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+
+	timestamp := strconv.FormatInt(time.Now().Unix(), 10)
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set(webhookTimestampHeader, timestamp)
+	req.Header.Set(webhookSignatureHeader, "sha256="+n.sign(timestamp, body))
+
+	resp, err := n.client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		return fmt.Errorf("webhook responded with status %d", resp.StatusCode)
+	}
+
+	return nil
+}
+
+// sign is the hex hmac-sha256 of the timestamp and the body joined by a dot, the timestamp lets receivers reject replays.
+func (n *WebhookNotifier) sign(timestamp string, body []byte) string {
+	mac := hmac.New(sha256.New, n.secret)
+	mac.Write([]byte(timestamp + "."))
+	mac.Write(body)
+
+	return hex.EncodeToString(mac.Sum(nil))
+}
+
+func dialPublic(network, address string, _ syscall.RawConn) error {
+	host, _, err := net.SplitHostPort(address)
+	if err != nil {
+		return err
+	}
+
+	if ip := net.ParseIP(host); ip == nil || !isPublicIP(ip) {
+		return fmt.Errorf("%w: %s", errNonPublicAddress, host)
+	}
+
+	return nil
+}
+
+func isPublicIP(ip net.IP) bool {
+	for _, network := range nonPublicNetworks {
+		if network.Contains(ip) {
+			return false
+		}
+	}
+
+	return true
+}
+
+func parseNetworks(cidrs ...string) []*net.IPNet {
+	networks := make([]*net.IPNet, 0, len(cidrs))
+	for _, cidr := range cidrs {
+		_, network, err := net.ParseCIDR(cidr)
+		if err != nil {
+			panic(err)
+		}
+		networks = append(networks, network)
+	}
+
+	return networks
+}
+
+// InboxNotifier keeps new ads in the in app inbox of the user.
+type InboxNotifier struct {
+	repo repository.Notification
+}
+
+func NewInboxNotifier(repo repository.Notification) *InboxNotifier {
+	return &InboxNotifier{repo: repo}
+}
+
+type inboxPayload struct {
+	SavedSearchId int   `json:"saved_search_id"`
+	AdIds         []int `json:"ad_ids"`
+}
+
+func (n *InboxNotifier) Notify(matches SearchMatches) error {
+	payload := inboxPayload{SavedSearchId: matches.Search.Id, AdIds: make([]int, 0, len(matches.Ads))}
+	for _, ad := range matches.Ads {
+		payload.AdIds = append(payload.AdIds, ad.Id)
+	}
+
+	data, err := json.Marshal(payload)
+	if err != nil {
+		return err
+	}
+
+	_, err = n.repo.CreateNotification(domain.Notification{
+		UserId:  matches.Search.UserId,
+		Kind:    notificationKindSavedSearch,
+		Title:   matchesTitle(matches),
+		Body:    matchesBody(matches),
+		Payload: data,
+	})
+
+	return err
+}
+
+func matchesTitle(matches SearchMatches) string {
+	return fmt.Sprintf("New ads for your saved search %q", matches.Search.Name)
+}
+
+func matchesBody(matches SearchMatches) string {
+	var b strings.Builder
+	fmt.Fprintf(&b, "%d new ads match your search %q:\n\n", len(matches.Ads), matches.Search.Query)
+	for _, ad := range matches.Ads {
+		fmt.Fprintf(&b, "- %s, %d (ad %d)\n", ad.Title, ad.Price, ad.Id)
+	}
+
+	return b.String()
+}
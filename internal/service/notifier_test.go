@@ -0,0 +1,89 @@
+package service
+
+import (
+	"errors"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/TakoB222/postingAds-api/internal/domain"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestIsPublicIP(t *testing.T) {
+	testTable := []struct {
+		ip       string
+		expected bool
+	}{
+		{ip: "93.184.216.34", expected: true},
+		{ip: "8.8.8.8", expected: true},
+		{ip: "2606:4700:4700::1111", expected: true},
+		{ip: "127.0.0.1"},
+		{ip: "127.10.0.1"},
+		{ip: "::1"},
+		{ip: "0.0.0.0"},
+		{ip: "::"},
+		{ip: "10.1.2.3"},
+		{ip: "172.16.0.1"},
+		{ip: "172.31.255.254"},
+		{ip: "192.168.1.1"},
+		{ip: "100.64.0.1"},
+		{ip: "169.254.169.254"}, // cloud metadata
+		{ip: "fe80::1"},
+		{ip: "fc00::1"},
+		{ip: "fd12:3456:789a::1"},
+		{ip: "ff02::1"},
+		{ip: "::ffff:127.0.0.1"},
+		{ip: "::ffff:169.254.169.254"},
+	}
+
+	for _, testCase := range testTable {
+		t.Run(testCase.ip, func(t *testing.T) {
+			assert.Equal(t, testCase.expected, isPublicIP(net.ParseIP(testCase.ip)))
+		})
+	}
+}
+
+func TestDialPublic(t *testing.T) {
+	testTable := []struct {
+		address  string
+		expected error
+	}{
+		{address: "93.184.216.34:443"},
+		{address: "[2606:4700:4700::1111]:443"},
+		{address: "127.0.0.1:80", expected: errNonPublicAddress},
+		{address: "[::1]:80", expected: errNonPublicAddress},
+		{address: "169.254.169.254:80", expected: errNonPublicAddress},
+		{address: "[fd00::1]:80", expected: errNonPublicAddress},
+	}
+
+	for _, testCase := range testTable {
+		t.Run(testCase.address, func(t *testing.T) {
+			err := dialPublic("tcp", testCase.address, nil)
+			assert.True(t, errors.Is(err, testCase.expected), "unexpected error: %v", err)
+		})
+	}
+}
+
+func TestWebhookNotifierRefusesLocalAddresses(t *testing.T) {
+	posted := false
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		posted = true
+	}))
+	defer server.Close()
+
+	notifier := NewWebhookNotifier(time.Second, "secret")
+	err := notifier.Notify(SearchMatches{Search: domain.SavedSearch{WebhookURL: server.URL}})
+
+	assert.True(t, errors.Is(err, errNonPublicAddress), "unexpected error: %v", err)
+	assert.False(t, posted)
+}
+
+func TestWebhookNotifierSign(t *testing.T) {
+	notifier := NewWebhookNotifier(time.Second, "secret")
+
+	// echo -n '1700000000.{"ads":[]}' | openssl dgst -sha256 -hmac secret
+	assert.Equal(t, "6c6ac7817fd2c0b4e9b3f5db05fc40306a2e8800f4157097c916819a9de287fb", notifier.sign("1700000000", []byte(`{"ads":[]}`)))
+}
@@ -0,0 +1,94 @@
+package service
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+	"github.com/TakoB222/postingAds-api/internal/domain"
+	"github.com/TakoB222/postingAds-api/internal/repository"
+	"github.com/TakoB222/postingAds-api/pkg/auth"
+)
+
+type RoleService struct {
+	repo repository.Role
+}
+
+func NewRoleService(repo repository.Role) *RoleService {
+	return &RoleService{repo: repo}
+}
+
+func (s *RoleService) GetRoles() ([]domain.Role, error) {
+	return s.repo.GetRoles()
+}
+
+func (s *RoleService) AssignRole(input RoleAssignmentInput) error {
+	role, err := s.getRole(input.Role)
+	if err != nil {
+		return err
+	}
+
+	switch input.Kind {
+	case auth.KindUser:
+		err = s.repo.AssignUserRole(input.SubjectId, role.Id)
+	case auth.KindAdmin:
+		err = s.repo.AssignAdminRole(input.SubjectId, role.Id)
+	default:
+		return fmt.Errorf("%w: %s", ErrUnknownSubjectKind, input.Kind)
+	}
+
+	if errors.Is(err, sql.ErrNoRows) {
+		return ErrRoleSubjectNotFound
+	}
+
+	return err
+}
+
+func (s *RoleService) RevokeRole(input RoleAssignmentInput) error {
+	role, err := s.getRole(input.Role)
+	if err != nil {
+		return err
+	}
+
+	switch input.Kind {
+	case auth.KindUser:
+		err = s.repo.RevokeUserRole(input.SubjectId, role.Id)
+	case auth.KindAdmin:
+		err = s.repo.RevokeAdminRole(input.SubjectId, role.Id)
+	default:
+		return fmt.Errorf("%w: %s", ErrUnknownSubjectKind, input.Kind)
+	}
+
+	if errors.Is(err, sql.ErrNoRows) {
+		return ErrRoleNotAssigned
+	}
+
+	return err
+}
+
+func (s *RoleService) getRole(name string) (domain.Role, error) {
+	role, err := s.repo.GetRoleByName(name)
+	if errors.Is(err, sql.ErrNoRows) {
+		return domain.Role{}, ErrRoleNotFound
+	}
+
+	return role, err
+}
+
+// newSubject describes a token owner, the permissions of all its roles are merged.
+func newSubject(id, kind string, roles []domain.Role) auth.Subject {
+	subject := auth.Subject{Id: id, Kind: kind}
+
+	granted := make(map[string]bool)
+	for _, role := range roles {
+		subject.Roles = append(subject.Roles, role.Name)
+
+		for _, permission := range role.Permissions {
+			if !granted[permission] {
+				granted[permission] = true
+				subject.Permissions = append(subject.Permissions, permission)
+			}
+		}
+	}
+
+	return subject
+}
@@ -0,0 +1,226 @@
+package service
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"github.com/TakoB222/postingAds-api/internal/domain"
+	"github.com/TakoB222/postingAds-api/internal/repository"
+	"github.com/TakoB222/postingAds-api/pkg/logger"
+	"net"
+	"net/url"
+	"strings"
+	"time"
+)
+
+const (
+	savedSearchPollInterval = time.Minute
+	savedSearchBatch        = 100
+	// the rest of the new ads are notified by the next check
+	maxSearchMatches = 50
+)
+
+// SavedSearchesConfig configures checks of saved searches for new ads.
+type SavedSearchesConfig struct {
+	CheckInterval  time.Duration // a search is checked at most once per interval
+	MaxPerUser     int
+	WebhookTimeout time.Duration
+	WebhookSecret  string // signs webhook posts, the webhook channel is disabled without it
+}
+
+type SavedSearchService struct {
+	repo       repository.SavedSearch
+	categories *CategoryService
+	notifiers  map[string]Notifier // by channel
+	cfg        SavedSearchesConfig
+}
+
+func NewSavedSearchService(repo repository.SavedSearch, categories *CategoryService, notifiers map[string]Notifier, cfg SavedSearchesConfig) *SavedSearchService {
+	return &SavedSearchService{repo: repo, categories: categories, notifiers: notifiers, cfg: cfg}
+}
+
+func (s *SavedSearchService) CreateSavedSearch(userId string, input SavedSearchInput) (domain.SavedSearch, error) {
+	if _, ok := s.notifiers[input.Channel]; !ok {
+		return domain.SavedSearch{}, fmt.Errorf("%w %q", ErrUnsupportedChannel, input.Channel)
+	}
+
+	webhookURL := ""
+	if input.Channel == domain.ChannelWebhook {
+		var err error
+		if webhookURL, err = checkWebhookURL(input.WebhookURL); err != nil {
+			return domain.SavedSearch{}, err
+		}
+	}
+
+	if input.Language != "" && !domain.IsLanguage(input.Language) {
+		return domain.SavedSearch{}, fmt.Errorf("%w %q", ErrUnsupportedLanguage, input.Language)
+	}
+
+	categoryId := 0
+	if input.Filter.Category != "" {
+		category, err := s.categories.resolveCategory(input.Filter.Category)
+		if err != nil {
+			return domain.SavedSearch{}, err
+		}
+		categoryId = category.Id
+	}
+
+	// the filters are parsed again on every check, this only rejects the invalid ones early
+	if _, err := s.categories.attributeFilters(categoryId, input.Filter.Attributes); err != nil {
+		return domain.SavedSearch{}, err
+	}
+
+	query := strings.TrimSpace(input.Query)
+	name := strings.TrimSpace(input.Name)
+	if name == "" {
+		name = query
+	}
+
+	id, err := s.repo.CreateSavedSearch(domain.SavedSearch{
+		UserId: userId,
+		Name:   name,
+		Query:  query,
+		Filters: domain.SearchFilters{
+			CategoryId: categoryId,
+			MinPrice:   input.Filter.MinPrice,
+			MaxPrice:   input.Filter.MaxPrice,
+			Location:   input.Filter.Location,
+			Language:   input.Language,
+			Attributes: input.Filter.Attributes,
+		},
+		Channel:    input.Channel,
+		WebhookURL: webhookURL,
+	}, s.cfg.MaxPerUser)
+	if err != nil {
+		if errors.Is(err, repository.ErrTooManySavedSearches) {
+			return domain.SavedSearch{}, fmt.Errorf("%w, %d at most", ErrTooManySavedSearches, s.cfg.MaxPerUser)
+		}
+		return domain.SavedSearch{}, err
+	}
+
+	return s.repo.GetSavedSearch(userId, id)
+}
+
+// checkWebhookURL accepts http and https urls of hosts resolving to public addresses only,
+// otherwise any user could make the server post to its own internal network.
+func checkWebhookURL(rawURL string) (string, error) {
+	u, err := url.Parse(rawURL)
+	if err != nil || u.Scheme != "http" && u.Scheme != "https" || u.Hostname() == "" {
+		return "", ErrInvalidWebhookURL
+	}
+
+	ips, err := net.LookupIP(u.Hostname())
+	if err != nil || len(ips) == 0 {
+		return "", fmt.Errorf("%w, host %s can not be resolved", ErrInvalidWebhookURL, u.Hostname())
+	}
+	for _, ip := range ips {
+		if !isPublicIP(ip) {
+			return "", ErrWebhookURLNotPublic
+		}
+	}
+
+	return u.String(), nil
+}
+
+func (s *SavedSearchService) GetSavedSearches(userId string) ([]domain.SavedSearch, error) {
+	return s.repo.GetSavedSearches(userId)
+}
+
+func (s *SavedSearchService) DeleteSavedSearch(userId string, searchId int) error {
+	if err := s.repo.DeleteSavedSearch(userId, searchId); err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return ErrSavedSearchNotFound
+		}
+		return err
+	}
+
+	return nil
+}
+
+// RunNotifications checks saved searches for newly published ads and notifies their users until ctx is done.
+func (s *SavedSearchService) RunNotifications(ctx context.Context) {
+	ticker := time.NewTicker(savedSearchPollInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+
+		s.checkSavedSearches(ctx)
+	}
+}
+
+func (s *SavedSearchService) checkSavedSearches(ctx context.Context) {
+	for ctx.Err() == nil {
+		searches, err := s.repo.ClaimDueSavedSearches(time.Now().Add(-s.cfg.CheckInterval), savedSearchBatch)
+		if err != nil {
+			logger.Errorf("failed to claim saved searches: %s", err.Error())
+			return
+		}
+
+		for _, search := range searches {
+			if err := s.checkSavedSearch(search); err != nil {
+				logger.Errorf("failed to check saved search %d: %s", search.Id, err.Error())
+			}
+		}
+
+		if len(searches) < savedSearchBatch {
+			return
+		}
+	}
+}
+
+// checkSavedSearch records new matches before notifying, so an ad is never notified twice even if the delivery fails.
+func (s *SavedSearchService) checkSavedSearch(search repository.DueSavedSearch) error {
+	notifier, ok := s.notifiers[search.Channel]
+	if !ok {
+		return fmt.Errorf("%w %q", ErrUnsupportedChannel, search.Channel)
+	}
+
+	attributes, err := s.categories.attributeFilters(search.Filters.CategoryId, search.Filters.Attributes)
+	if err != nil {
+		return err
+	}
+
+	ads, err := s.repo.GetNewSearchMatches(search.SavedSearch, repository.AdsFilter{
+		CategoryId: search.Filters.CategoryId,
+		MinPrice:   search.Filters.MinPrice,
+		MaxPrice:   search.Filters.MaxPrice,
+		Location:   search.Filters.Location,
+		Language:   search.Filters.Language,
+		Attributes: attributes,
+	}, maxSearchMatches)
+	if err != nil || len(ads) == 0 {
+		return err
+	}
+
+	ids := make([]int, 0, len(ads))
+	for _, ad := range ads {
+		ids = append(ids, ad.Id)
+	}
+
+	recorded, err := s.repo.RecordSearchMatches(search.Id, ids)
+	if err != nil {
+		return err
+	}
+
+	fresh := make([]domain.Ad, 0, len(recorded))
+	for _, ad := range ads {
+		for _, id := range recorded {
+			if ad.Id == id {
+				fresh = append(fresh, ad)
+				break
+			}
+		}
+	}
+	if len(fresh) == 0 {
+		return nil
+	}
+	maskContacts(fresh)
+
+	return notifier.Notify(SearchMatches{Search: search.SavedSearch, Email: search.Email, Ads: fresh})
+}
@@ -0,0 +1,82 @@
+package service
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestCheckWebhookURL(t *testing.T) {
+	testTable := []struct {
+		name          string
+		url           string
+		expectedURL   string
+		expectedError error
+	}{
+		{
+			name:        "public address",
+			url:         "https://93.184.216.34/hooks/ads",
+			expectedURL: "https://93.184.216.34/hooks/ads",
+		},
+		{
+			name:        "public ipv6 address",
+			url:         "http://[2606:4700:4700::1111]:8080/hooks",
+			expectedURL: "http://[2606:4700:4700::1111]:8080/hooks",
+		},
+		{
+			name:          "loopback",
+			url:           "http://127.0.0.1:8000/hooks",
+			expectedError: ErrWebhookURLNotPublic,
+		},
+		{
+			name:          "localhost",
+			url:           "http://localhost/hooks",
+			expectedError: ErrWebhookURLNotPublic,
+		},
+		{
+			name:          "rfc 1918",
+			url:           "http://192.168.0.10/hooks",
+			expectedError: ErrWebhookURLNotPublic,
+		},
+		{
+			name:          "metadata",
+			url:           "http://169.254.169.254/latest/meta-data/",
+			expectedError: ErrWebhookURLNotPublic,
+		},
+		{
+			name:          "ipv6 unique local",
+			url:           "http://[fd00::1]/hooks",
+			expectedError: ErrWebhookURLNotPublic,
+		},
+		{
+			name:          "ipv6 loopback",
+			url:           "http://[::1]/hooks",
+			expectedError: ErrWebhookURLNotPublic,
+		},
+		{
+			name:          "scheme",
+			url:           "ftp://93.184.216.34/hooks",
+			expectedError: ErrInvalidWebhookURL,
+		},
+		{
+			name:          "relative",
+			url:           "/hooks",
+			expectedError: ErrInvalidWebhookURL,
+		},
+		{
+			name:          "unresolvable host",
+			url:           "http://webhook.invalid/hooks",
+			expectedError: ErrInvalidWebhookURL,
+		},
+	}
+
+	for _, testCase := range testTable {
+		t.Run(testCase.name, func(t *testing.T) {
+			url, err := checkWebhookURL(testCase.url)
+
+			assert.True(t, errors.Is(err, testCase.expectedError), "unexpected error: %v", err)
+			assert.Equal(t, testCase.expectedURL, url)
+		})
+	}
+}
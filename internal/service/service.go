@@ -1,19 +1,99 @@
 package service
 
 import (
+	"context"
+	"fmt"
 	"github.com/TakoB222/postingAds-api/internal/domain"
 	"github.com/TakoB222/postingAds-api/internal/repository"
+	"github.com/TakoB222/postingAds-api/pkg/apperror"
 	"github.com/TakoB222/postingAds-api/pkg/auth"
+	"github.com/TakoB222/postingAds-api/pkg/email"
 	"github.com/TakoB222/postingAds-api/pkg/hash"
+	"github.com/TakoB222/postingAds-api/pkg/hub"
+	"github.com/TakoB222/postingAds-api/pkg/logger"
+	"github.com/TakoB222/postingAds-api/pkg/storage"
 	"time"
 )
 
 //go:generate mockgen -source=service.go -destination=mocks/mock.go
 
+var (
+	ErrIllegalAdStatusTransition = apperror.Conflict("illegal_ad_status_transition", "illegal ad status transition")
+	ErrInvalidCredentials        = apperror.Unauthorized("invalid_credentials", "invalid email or password")
+	ErrUserExists                = apperror.Conflict("user_exists", "user with the same email already exists")
+	ErrSessionNotFound           = apperror.Unauthorized("session_not_found", "session not found")
+	ErrSessionExpired            = apperror.Unauthorized("session_expired", "session is expired")
+	ErrRefreshTokenReused        = apperror.Unauthorized("refresh_token_reused", "refresh token has already been used")
+	ErrUserNotFound              = apperror.NotFound("user_not_found", "user not found")
+	ErrSelfModeration            = apperror.Forbidden("self_moderation", "ads can not be moderated by their owner")
+	ErrUserBanned                = apperror.Forbidden("user_banned", "user is banned")
+	ErrRoleNotFound              = apperror.NotFound("role_not_found", "role not found")
+	ErrRoleSubjectNotFound       = apperror.NotFound("role_subject_not_found", "user or admin not found")
+	ErrUnknownSubjectKind        = apperror.Validation("unknown_subject_kind", "unknown subject kind")
+	ErrRoleNotAssigned           = apperror.NotFound("role_not_assigned", "role is not assigned")
+	ErrEmailNotVerified          = apperror.Forbidden("email_not_verified", "email is not verified")
+	ErrInvalidToken              = apperror.Validation("invalid_token", "token is invalid or expired")
+	ErrInvalidTwoFactorCode      = apperror.Validation("invalid_two_factor_code", "invalid two-factor code")
+	ErrTwoFactorLocked           = apperror.TooManyRequests("two_factor_locked", "too many invalid two-factor codes, try again later")
+	ErrTwoFactorAlreadyEnabled   = apperror.Conflict("two_factor_already_enabled", "two-factor authentication is already enabled")
+	ErrTwoFactorNotEnabled       = apperror.Conflict("two_factor_not_enabled", "two-factor authentication is not enabled")
+	ErrTwoFactorNotEnrolled      = apperror.Conflict("two_factor_not_enrolled", "two-factor enrolment is not started")
+	ErrTwoFactorRequired         = apperror.Forbidden("two_factor_required", "two-factor authentication is required")
+	ErrInvalidImage              = apperror.Validation("invalid_image", "invalid image")
+	ErrImageTooLarge             = apperror.TooLarge("image_too_large", "image is too large")
+	ErrImageNotFound             = apperror.NotFound("image_not_found", "image not found")
+	ErrImageSizeUnknown          = apperror.Validation("image_size_unknown", "unknown image size")
+	ErrCategoryNotFound          = apperror.NotFound("category_not_found", "category not found")
+	ErrParentCategoryNotFound    = apperror.NotFound("parent_category_not_found", "parent category not found")
+	ErrInvalidCategoryName       = apperror.Validation("invalid_category_name", "invalid category name")
+	ErrCategoryExists            = apperror.Conflict("category_exists", "category with the same name already exists")
+	ErrCategoryCycle             = apperror.Conflict("category_cycle", "category can not be moved into its own subtree")
+	ErrCategoryHasSubcategories  = apperror.Conflict("category_has_subcategories", "category has subcategories")
+	ErrCategoryHasAds            = apperror.Conflict("category_has_ads", "category still has ads")
+	ErrInvalidAttributes         = apperror.Validation("invalid_attributes", "invalid attributes")
+	ErrUnsupportedLanguage       = apperror.Validation("unsupported_language", "unsupported language")
+	ErrSavedSearchNotFound       = apperror.NotFound("saved_search_not_found", "saved search not found")
+	ErrTooManySavedSearches      = apperror.Conflict("too_many_saved_searches", "too many saved searches")
+	ErrUnsupportedChannel        = apperror.Validation("unsupported_channel", "unsupported notification channel")
+	ErrInvalidWebhookURL         = apperror.Validation("invalid_webhook_url", "webhook url must be an absolute http or https url")
+	ErrWebhookURLNotPublic       = apperror.Validation("webhook_url_not_public", "webhook url must point to a public address")
+	ErrNotificationNotFound      = apperror.NotFound("notification_not_found", "notification not found")
+	ErrAdNotFound                = apperror.NotFound("ad_not_found", "ad not found")
+	ErrFavoriteNotFound          = apperror.NotFound("favorite_not_found", "ad is not in favorites")
+	ErrOwnAdFavorite             = apperror.Validation("own_ad_favorite", "own ads can not be added to favorites")
+	ErrThreadNotFound            = apperror.NotFound("thread_not_found", "thread not found")
+	ErrOwnAdThread               = apperror.Validation("own_ad_thread", "users can not write about their own ads")
+	ErrEmptyMessage              = apperror.Validation("empty_message", "message is empty")
+	ErrUserBlocked               = apperror.Forbidden("user_blocked", "the seller has blocked you")
+	ErrNotThreadSeller           = apperror.Forbidden("not_thread_seller", "only the seller of the ad can block the buyer")
+	ErrTooManyContactReveals     = apperror.TooManyRequests("too_many_contact_reveals", "too many contact reveals, try again later")
+	ErrContactsRequired          = apperror.Validation("contacts_required", "ad needs either contacts or a contact profile")
+	ErrContactProfileNotFound    = apperror.NotFound("contact_profile_not_found", "contact profile not found")
+	ErrContactProfileExists      = apperror.Conflict("contact_profile_exists", "contact profile with the same label already exists")
+	ErrContactProfileInUse       = apperror.Conflict("contact_profile_in_use", "contact profile is used by ads")
+)
+
+// CategoryNotFoundError is returned when a category given by a client does not exist, it lists categories with similar paths.
+type CategoryNotFoundError struct {
+	Category    string
+	Suggestions []string
+}
+
+func (e *CategoryNotFoundError) Error() string {
+	return fmt.Sprintf("category %q not found", e.Category)
+}
+
+// Unwrap blames the category field of the input, the category is not a resource the client asked for.
+func (e *CategoryNotFoundError) Unwrap() error {
+	return ErrCategoryNotFound.Invalid("category", e.Error())
+}
+
 type (
 	SignInInput struct {
-		Email    string
-		Password string
+		Email     string
+		Password  string
+		UserAgent string
+		Ip        string
 	}
 
 	UserSignUpInput struct {
@@ -25,11 +105,22 @@ type (
 
 	RefreshInput struct {
 		RefreshToken string `json:"refreshToken"`
+		UserAgent    string `json:"-"`
+		Ip           string `json:"-"`
 	}
 
 	Tokens struct {
-		AccessToken  string `json:"access_token"`
-		RefreshToken string `json:"refresh_token"`
+		AccessToken  string `json:"access_token,omitempty"`
+		RefreshToken string `json:"refresh_token,omitempty"`
+		// ChallengeToken is returned instead of the tokens when the account has the second factor
+		ChallengeToken string `json:"challenge_token,omitempty"`
+	}
+
+	TwoFactorSignInInput struct {
+		ChallengeToken string
+		Code           string // TOTP or recovery code
+		UserAgent      string
+		Ip             string
 	}
 
 	Ads struct {
@@ -39,8 +130,12 @@ type (
 		Description string   `json:"description"`
 		Price       int      `json:"price"`
 		Contacts    Contacts `json:"contacts"`
-		Published   bool     `json:"published"`
-		ImagesURL   []string `json:"images_url"`
+		// ContactProfileId makes the ad show contacts of the profile of the user instead of Contacts
+		ContactProfileId int                    `json:"contact_profile_id"`
+		Published        bool                   `json:"published"` // submit for moderation instead of saving as a draft
+		ImagesURL        []string               `json:"images_url"`
+		Attributes       map[string]interface{} `json:"attributes"`
+		Language         string                 `json:"language"` // detected by the text when empty
 	}
 
 	Contacts struct {
@@ -50,24 +145,100 @@ type (
 		Location     string `json:"location"`
 	}
 	FtsResponse struct {
-		Id string `db:"id"`
+		Id    string `db:"id"`
 		Title string `db:"title"`
 	}
+
+	AdsFilter struct {
+		Category   string
+		MinPrice   *int
+		MaxPrice   *int
+		Location   string
+		Attributes map[string]string
+		Sort       string
+		Limit      int
+		Offset     int
+	}
+
+	FtsResult struct {
+		Ads        []repository.FtsResponse
+		Total      int
+		DidYouMean string                   // the request with misspelled words corrected, set when nothing is found
+		Facets     *repository.SearchFacets // set when requested
+	}
+
+	FtsInput struct {
+		Request  string
+		Language string    // only ads of the language are searched, empty means any
+		Filter   AdsFilter // results are ordered by relevance, the sort of the filter is ignored
+		Facets   bool      // count found ads per category, location and price
+	}
+
+	PasswordResetInput struct {
+		Token    string
+		Password string
+	}
+
+	RoleAssignmentInput struct {
+		Kind      string
+		SubjectId string
+		Role      string
+	}
+
+	SavedSearchInput struct {
+		Name       string // the query is used when empty
+		Query      string
+		Language   string
+		Filter     AdsFilter // sort, limit and offset are ignored
+		Channel    string
+		WebhookURL string // required by the webhook channel
+	}
+
+	CategoryInput struct {
+		Name     string
+		ParentId *int
+	}
 )
 
 type Authorization interface {
 	SignUp(input UserSignUpInput) (int, error)
 	SignIn(input SignInInput) (Tokens, error)
 	RefreshSession(input RefreshInput) (Tokens, error)
+	Logout(userId string, input RefreshInput) error
+	GetSessions(userId string) ([]domain.Session, error)
+	RevokeSession(userId, sessionId string) error
+	RevokeOtherSessions(userId string, input RefreshInput) error
+	CheckSession(userId, sessionId string) error
+	VerifyEmail(token string) error
+	ResendVerificationEmail(address string) error
+	RequestPasswordReset(address string) error
+	ResetPassword(input PasswordResetInput) error
+	SignInSecondFactor(input TwoFactorSignInInput) (Tokens, error)
+	EnrollTwoFactor(userId string) (TwoFactorEnrollment, error)
+	ConfirmTwoFactor(userId, code string) ([]string, error)
+	DisableTwoFactor(userId, code string) error
 }
 
 type Admin interface {
 	AdminSignIn(input SignInInput) (Tokens, error)
 	AdminRefreshSession(input RefreshInput) (Tokens, error)
+	AdminLogout(adminId string, input RefreshInput) error
+	AdminGetSessions(adminId string) ([]domain.AdminSession, error)
+	AdminRevokeSession(adminId, sessionId string) error
+	AdminRevokeOtherSessions(adminId string, input RefreshInput) error
+	AdminSignInSecondFactor(input TwoFactorSignInInput) (Tokens, error)
+	AdminEnrollTwoFactor(adminId string) (TwoFactorEnrollment, error)
+	AdminConfirmTwoFactor(adminId, code string) ([]string, error)
+	AdminDisableTwoFactor(adminId, code string) error
 	AdminGetAllAdsByAdmin() ([]domain.Ad, error)
 	AdminGetAd(adId string) (domain.Ad, error)
 	AdminDeleteUserAdById(adId string) error
 	AdminUpdateAd(adId string, ad Ads) (domain.Ad, error)
+	AdminGetAdsForModeration() ([]domain.Ad, error)
+	AdminApproveAd(moderator auth.Subject, adId string) (domain.Ad, error)
+	AdminRejectAd(moderator auth.Subject, adId, reason string) (domain.Ad, error)
+	BanUser(userId, reason string) error
+	UnbanUser(userId string) error
 }
 
 type Ad interface {
@@ -76,28 +247,151 @@ type Ad interface {
 	GetAdById(userId string, adId string) (domain.Ad, error)
 	UpdateAd(userId, adId string, ad Ads) (domain.Ad, error)
 	DeleteAd(userId string, adId string) error
-	Fts(search_request string)([]repository.FtsResponse, error)
+	Fts(input FtsInput) (FtsResult, error)
+	Suggest(query string, limit int) ([]repository.Suggestion, error)
+	GetPublishedAds(filter AdsFilter) ([]domain.Ad, int, error)
+	ArchiveAd(userId, adId string) (domain.Ad, error)
+}
+
+type Role interface {
+	GetRoles() ([]domain.Role, error)
+	AssignRole(input RoleAssignmentInput) error
+	RevokeRole(input RoleAssignmentInput) error
+}
+
+type Image interface {
+	UploadImage(userId string, data []byte) (UploadedImage, error)
+	GetImageURL(imageId, size string) (string, error)
+	RunCleanup(ctx context.Context)
+}
+
+type Category interface {
+	GetCategoryTree() ([]domain.CategoryNode, error)
+	CreateCategory(input CategoryInput) (domain.Categories, error)
+	UpdateCategory(categoryId int, input CategoryInput) (domain.Categories, error)
+	GetCategoryAttributes(categoryId int) (domain.AttributeSchema, error)
+	SetCategoryAttributes(categoryId int, attributes domain.AttributeSchema) (domain.Categories, error)
+	DeleteCategory(categoryId int) error
+}
+
+type SavedSearch interface {
+	CreateSavedSearch(userId string, input SavedSearchInput) (domain.SavedSearch, error)
+	GetSavedSearches(userId string) ([]domain.SavedSearch, error)
+	DeleteSavedSearch(userId string, searchId int) error
+	RunNotifications(ctx context.Context)
+}
+
+type Notification interface {
+	GetNotifications(userId string, limit, offset int) ([]domain.Notification, int, error)
+	ReadNotification(userId string, notificationId int) error
+}
+
+type Favorite interface {
+	AddFavorite(userId, adId string) error
+	RemoveFavorite(userId, adId string) error
+	GetFavorites(userId string) ([]domain.Ad, error)
+}
+
+type Thread interface {
+	OpenThread(userId, adId, message string) (domain.Thread, error)
+	GetThreads(userId string, limit, offset int) ([]domain.Thread, int, error)
+	GetThread(userId string, threadId int) (domain.Thread, error)
+	GetMessages(userId string, threadId int, limit, offset int) ([]domain.Message, error)
+	SendMessage(userId string, threadId int, message string) (domain.Message, error)
+	ReadThread(userId string, threadId int) error
+	BlockBuyer(userId string, threadId int) error
+	UnblockBuyer(userId string, threadId int) error
+}
+
+type Events interface {
+	Subscribe(userId, lastEventId string) *hub.Subscription
+	Unsubscribe(sub *hub.Subscription)
+	RunCleanup(ctx context.Context)
+}
+
+type Contact interface {
+	RevealContacts(userId, adId, ip string) (domain.Contacts, error)
+	GetContactRevealStats(userId string) ([]domain.ContactRevealStats, error)
+	CreateContactProfile(userId string, profile domain.ContactProfile) (domain.ContactProfile, error)
+	GetContactProfiles(userId string) ([]domain.ContactProfile, error)
+	GetContactProfile(userId string, profileId int) (domain.ContactProfile, error)
+	UpdateContactProfile(userId string, profile domain.ContactProfile) (domain.ContactProfile, error)
+	DeleteContactProfile(userId string, profileId int) error
 }
 
 type Service struct {
 	Authorization
 	Admin
 	Ad
+	Role
+	Image
+	Category
+	SavedSearch
+	Notification
+	Favorite
+	Thread
+	Events
+	Contact
 }
 
 type Dependencies struct {
 	Repository   *repository.Repository
 	TokenManager *auth.Manager
-	Hasher       *hash.SHA1Hasher
+	Hasher       hash.PasswordHasher
+	Mailer       email.Mailer
+	Storage      storage.Storage
 
 	AccessTokenTTL  time.Duration
 	RefreshTokenTTL time.Duration
+	AccountEmails   AccountEmailsConfig
+	TwoFactor       TwoFactorConfig
+	Images          ImagesConfig
+	SavedSearches   SavedSearchesConfig
+	Events          EventsConfig
+	Contacts        ContactsConfig
+}
+
+// AccountEmailsConfig configures emails sent to prove the ownership of an account email.
+type AccountEmailsConfig struct {
+	RequireVerification   bool // refuse sign in until the email is verified
+	VerificationTokenTTL  time.Duration
+	PasswordResetTokenTTL time.Duration
+	// no other email of the same purpose is sent to the account until the cooldown passes, so nobody can flood a mailbox
+	EmailCooldown time.Duration
+	// the token is appended to the urls to build links of the emails
+	VerificationURL  string
+	PasswordResetURL string
 }
 
 func NewServices(dep Dependencies) *Service {
+	images := NewImageService(dep.Repository, dep.Storage, dep.Images)
+	categories := NewCategoryService(dep.Repository)
+	events := NewEventService(dep.Events)
+	favorites := NewFavoriteService(dep.Repository, dep.Repository, events)
+	contacts := NewContactService(dep.Repository, dep.Contacts)
+	notifiers := map[string]Notifier{
+		domain.ChannelEmail: NewEmailNotifier(dep.Mailer),
+		domain.ChannelInbox: NewInboxNotifier(dep.Repository),
+	}
+	// receivers could not tell unsigned posts from forged ones, so webhooks need the secret
+	if dep.SavedSearches.WebhookSecret != "" {
+		notifiers[domain.ChannelWebhook] = NewWebhookNotifier(dep.SavedSearches.WebhookTimeout, dep.SavedSearches.WebhookSecret)
+	} else {
+		logger.Warn("WEBHOOK_SECRET is not set, the webhook channel of saved searches is disabled")
+	}
+
 	return &Service{
-		Authorization: NewAuthService(dep.Repository, dep.TokenManager, dep.Hasher, dep.AccessTokenTTL, dep.RefreshTokenTTL),
-		Ad:            NewAdService(dep.Repository),
-		Admin:         NewAdminService(dep.Repository, dep.TokenManager, dep.Hasher, dep.AccessTokenTTL, dep.RefreshTokenTTL),
+		Authorization: NewAuthService(dep.Repository, dep.Repository, dep.TokenManager, dep.Hasher, dep.Mailer, dep.AccessTokenTTL, dep.RefreshTokenTTL, dep.AccountEmails, dep.TwoFactor),
+		Ad:            NewAdService(dep.Repository, images, categories, favorites, contacts),
+		Admin:         NewAdminService(dep.Repository, dep.Repository, images, categories, favorites, events, dep.TokenManager, dep.Hasher, dep.AccessTokenTTL, dep.RefreshTokenTTL, dep.TwoFactor),
+		Role:          NewRoleService(dep.Repository),
+		Image:         images,
+		Category:      categories,
+		SavedSearch:   NewSavedSearchService(dep.Repository, categories, notifiers, dep.SavedSearches),
+		Notification:  NewNotificationService(dep.Repository),
+		Favorite:      favorites,
+		Thread:        NewThreadService(dep.Repository, events),
+		Events:        events,
+		Contact:       contacts,
 	}
 }
@@ -0,0 +1,156 @@
+package service
+
+import (
+	"database/sql"
+	"errors"
+	"github.com/TakoB222/postingAds-api/internal/domain"
+	"github.com/TakoB222/postingAds-api/internal/repository"
+	"strings"
+)
+
+type ThreadService struct {
+	repo   repository.Thread
+	events *EventService
+}
+
+func NewThreadService(repo repository.Thread, events *EventService) *ThreadService {
+	return &ThreadService{repo: repo, events: events}
+}
+
+// OpenThread sends the first message of a buyer about a published ad, a buyer writing about the same ad again continues their thread.
+func (s *ThreadService) OpenThread(userId, adId, message string) (domain.Thread, error) {
+	message = strings.TrimSpace(message)
+	if message == "" {
+		return domain.Thread{}, ErrEmptyMessage
+	}
+
+	sellerId, err := s.repo.GetAdSeller(adId)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return domain.Thread{}, ErrAdNotFound
+		}
+		return domain.Thread{}, err
+	}
+
+	if sellerId == userId {
+		return domain.Thread{}, ErrOwnAdThread
+	}
+
+	blocked, err := s.repo.IsBlocked(sellerId, userId)
+	if err != nil {
+		return domain.Thread{}, err
+	}
+	if blocked {
+		return domain.Thread{}, ErrUserBlocked
+	}
+
+	threadId, err := s.repo.CreateThread(adId, userId, sellerId)
+	if err != nil {
+		return domain.Thread{}, err
+	}
+
+	created, err := s.repo.CreateMessage(threadId, userId, message)
+	if err != nil {
+		return domain.Thread{}, err
+	}
+
+	s.events.publish(sellerId, EventNewMessage, created)
+
+	return s.repo.GetThread(userId, threadId)
+}
+
+// GetThreads returns a page of threads of the user and the number of messages they have not read.
+func (s *ThreadService) GetThreads(userId string, limit, offset int) ([]domain.Thread, int, error) {
+	return s.repo.GetThreads(userId, limit, offset)
+}
+
+func (s *ThreadService) GetThread(userId string, threadId int) (domain.Thread, error) {
+	thread, err := s.repo.GetThread(userId, threadId)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return domain.Thread{}, ErrThreadNotFound
+		}
+		return domain.Thread{}, err
+	}
+
+	return thread, nil
+}
+
+func (s *ThreadService) GetMessages(userId string, threadId int, limit, offset int) ([]domain.Message, error) {
+	if _, err := s.GetThread(userId, threadId); err != nil {
+		return nil, err
+	}
+
+	return s.repo.GetMessages(threadId, limit, offset)
+}
+
+// SendMessage adds a message of a participant to the thread, a blocked buyer can no longer write to the seller.
+func (s *ThreadService) SendMessage(userId string, threadId int, message string) (domain.Message, error) {
+	message = strings.TrimSpace(message)
+	if message == "" {
+		return domain.Message{}, ErrEmptyMessage
+	}
+
+	thread, err := s.GetThread(userId, threadId)
+	if err != nil {
+		return domain.Message{}, err
+	}
+
+	if thread.Blocked && userId == thread.BuyerId {
+		return domain.Message{}, ErrUserBlocked
+	}
+
+	created, err := s.repo.CreateMessage(threadId, userId, message)
+	if err != nil {
+		return domain.Message{}, err
+	}
+
+	recipientId := thread.SellerId
+	if userId == thread.SellerId {
+		recipientId = thread.BuyerId
+	}
+	s.events.publish(recipientId, EventNewMessage, created)
+
+	return created, nil
+}
+
+// ReadThread sets read receipts of messages the user received in the thread.
+func (s *ThreadService) ReadThread(userId string, threadId int) error {
+	if _, err := s.GetThread(userId, threadId); err != nil {
+		return err
+	}
+
+	return s.repo.ReadThread(userId, threadId)
+}
+
+// BlockBuyer stops the buyer of the thread from writing to the seller about any of their ads.
+func (s *ThreadService) BlockBuyer(userId string, threadId int) error {
+	thread, err := s.sellerThread(userId, threadId)
+	if err != nil {
+		return err
+	}
+
+	return s.repo.BlockUser(userId, thread.BuyerId)
+}
+
+func (s *ThreadService) UnblockBuyer(userId string, threadId int) error {
+	thread, err := s.sellerThread(userId, threadId)
+	if err != nil {
+		return err
+	}
+
+	return s.repo.UnblockUser(userId, thread.BuyerId)
+}
+
+func (s *ThreadService) sellerThread(userId string, threadId int) (domain.Thread, error) {
+	thread, err := s.GetThread(userId, threadId)
+	if err != nil {
+		return domain.Thread{}, err
+	}
+
+	if thread.SellerId != userId {
+		return domain.Thread{}, ErrNotThreadSeller
+	}
+
+	return thread, nil
+}
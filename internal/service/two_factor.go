@@ -0,0 +1,44 @@
+package service
+
+import (
+	"time"
+
+	"github.com/TakoB222/postingAds-api/pkg/otp"
+)
+
+const (
+	recoveryCodesCount = 10
+
+	// the second factor is locked after this many invalid codes in a row
+	maxTwoFactorAttempts = 5
+	twoFactorLockout     = 15 * time.Minute
+)
+
+type TwoFactorConfig struct {
+	Issuer            string // shown by authenticator apps next to the account
+	ChallengeTTL      time.Duration
+	RequiredForAdmins bool // admins and users granted permissions by roles get none without the second factor
+}
+
+type TwoFactorEnrollment struct {
+	Secret          string `json:"secret"`
+	ProvisioningURI string `json:"provisioning_uri"`
+}
+
+func newRecoveryCodes() ([]string, []string, error) {
+	codes, err := otp.GenerateRecoveryCodes(recoveryCodesCount)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	hashes := make([]string, 0, len(codes))
+	for _, code := range codes {
+		hashes = append(hashes, otp.HashRecoveryCode(code))
+	}
+
+	return codes, hashes, nil
+}
+
+func twoFactorLocked(lockedUntil *time.Time, now time.Time) bool {
+	return lockedUntil != nil && lockedUntil.After(now)
+}
@@ -0,0 +1,149 @@
+package service
+
+import (
+	"database/sql"
+	"testing"
+	"time"
+
+	"github.com/TakoB222/postingAds-api/internal/domain"
+	"github.com/TakoB222/postingAds-api/internal/repository"
+	"github.com/TakoB222/postingAds-api/pkg/otp"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// twoFactorRepo keeps the second factor of one user the way the postgres repository does:
+// a step is used only if it is newer than the last one and a recovery code only if it is unused.
+type twoFactorRepo struct {
+	repository.User
+
+	twoFactor     domain.TwoFactor
+	recoveryCodes map[string]bool // hash -> used
+	failures      int
+}
+
+func (r *twoFactorRepo) GetUserTwoFactor(userId string) (domain.TwoFactor, error) {
+	return r.twoFactor, nil
+}
+
+func (r *twoFactorRepo) UseUserTotpStep(userId string, step int64) error {
+	if step <= r.twoFactor.LastStep {
+		return sql.ErrNoRows
+	}
+	r.twoFactor.LastStep = step
+
+	return nil
+}
+
+func (r *twoFactorRepo) UseUserRecoveryCode(userId, codeHash string, now time.Time) error {
+	used, ok := r.recoveryCodes[codeHash]
+	if !ok || used {
+		return sql.ErrNoRows
+	}
+	r.recoveryCodes[codeHash] = true
+
+	return nil
+}
+
+func (r *twoFactorRepo) FailUserTwoFactor(userId string, maxAttempts int, lockedUntil time.Time) error {
+	r.failures++
+
+	return nil
+}
+
+func newTwoFactorRepo(t *testing.T) (*twoFactorRepo, []string) {
+	secret, err := otp.GenerateSecret()
+	require.NoError(t, err)
+
+	codes, hashes, err := newRecoveryCodes()
+	require.NoError(t, err)
+
+	repo := &twoFactorRepo{
+		twoFactor:     domain.TwoFactor{Secret: secret, Enabled: true},
+		recoveryCodes: make(map[string]bool),
+	}
+	for _, hash := range hashes {
+		repo.recoveryCodes[hash] = false
+	}
+
+	return repo, codes
+}
+
+func TestVerifySecondFactorRecoveryCode(t *testing.T) {
+	repo, codes := newTwoFactorRepo(t)
+	s := &AuthService{repo: repo}
+
+	assert.NoError(t, s.verifySecondFactor("1", codes[0]))
+	assert.Equal(t, ErrInvalidTwoFactorCode, s.verifySecondFactor("1", codes[0]))
+
+	// the other codes are still valid, however the used one is typed
+	assert.NoError(t, s.verifySecondFactor("1", codes[1]))
+	assert.Equal(t, ErrInvalidTwoFactorCode, s.verifySecondFactor("1", codes[1][:5]+codes[1][6:]))
+
+	assert.Equal(t, ErrInvalidTwoFactorCode, s.verifySecondFactor("1", "aaaaa-aaaaa"))
+	assert.Equal(t, 3, repo.failures)
+}
+
+func TestVerifySecondFactor(t *testing.T) {
+	now := time.Now()
+	current := now.Unix() / int64(otp.Period.Seconds())
+	lockedUntil := now.Add(time.Minute)
+
+	testTable := []struct {
+		name          string
+		twoFactor     func(twoFactor *domain.TwoFactor)
+		code          func(repo *twoFactorRepo) string
+		expectedError error
+	}{
+		{
+			name: "ok",
+			code: func(repo *twoFactorRepo) string { return totpCode(t, repo.twoFactor.Secret, now) },
+		},
+		{
+			name:          "replayed step",
+			twoFactor:     func(twoFactor *domain.TwoFactor) { twoFactor.LastStep = current + 1 },
+			code:          func(repo *twoFactorRepo) string { return totpCode(t, repo.twoFactor.Secret, now) },
+			expectedError: ErrInvalidTwoFactorCode,
+		},
+		{
+			name:          "not enabled",
+			twoFactor:     func(twoFactor *domain.TwoFactor) { twoFactor.Enabled = false },
+			code:          func(repo *twoFactorRepo) string { return totpCode(t, repo.twoFactor.Secret, now) },
+			expectedError: ErrTwoFactorNotEnabled,
+		},
+		{
+			name:          "locked",
+			twoFactor:     func(twoFactor *domain.TwoFactor) { twoFactor.LockedUntil = &lockedUntil },
+			code:          func(repo *twoFactorRepo) string { return totpCode(t, repo.twoFactor.Secret, now) },
+			expectedError: ErrTwoFactorLocked,
+		},
+	}
+
+	for _, testCase := range testTable {
+		t.Run(testCase.name, func(t *testing.T) {
+			repo, _ := newTwoFactorRepo(t)
+			if testCase.twoFactor != nil {
+				testCase.twoFactor(&repo.twoFactor)
+			}
+			s := &AuthService{repo: repo}
+
+			assert.Equal(t, testCase.expectedError, s.verifySecondFactor("1", testCase.code(repo)))
+		})
+	}
+}
+
+func TestVerifySecondFactorTotpOnce(t *testing.T) {
+	repo, _ := newTwoFactorRepo(t)
+	s := &AuthService{repo: repo}
+	code := totpCode(t, repo.twoFactor.Secret, time.Now())
+
+	assert.NoError(t, s.verifySecondFactor("1", code))
+	assert.Equal(t, ErrInvalidTwoFactorCode, s.verifySecondFactor("1", code))
+}
+
+func totpCode(t *testing.T, secret string, now time.Time) string {
+	code, err := otp.Code(secret, now)
+	require.NoError(t, err)
+
+	return code
+}
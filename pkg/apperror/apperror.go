@@ -0,0 +1,120 @@
+// Package apperror describes errors of the application by their kind and a stable code,
+// so the delivery layer maps any of them to a response in one place.
+package apperror
+
+import "errors"
+
+type Kind int
+
+const (
+	KindInternal Kind = iota
+	KindNotFound
+	KindConflict
+	KindValidation
+	KindUnauthorized
+	KindForbidden
+	KindTooManyRequests
+	KindTooLarge
+)
+
+// FieldError tells which field of the input is invalid and why.
+type FieldError struct {
+	Field   string `json:"field"`
+	Message string `json:"message"`
+}
+
+type Error struct {
+	Kind    Kind
+	Code    string // stable and machine readable, clients rely on it rather than on the message
+	Message string
+	Fields  []FieldError
+	Err     error // cause, hidden from clients
+}
+
+func New(kind Kind, code, message string) *Error {
+	return &Error{Kind: kind, Code: code, Message: message}
+}
+
+func NotFound(code, message string) *Error {
+	return New(KindNotFound, code, message)
+}
+
+func Conflict(code, message string) *Error {
+	return New(KindConflict, code, message)
+}
+
+func Validation(code, message string, fields ...FieldError) *Error {
+	e := New(KindValidation, code, message)
+	e.Fields = fields
+
+	return e
+}
+
+func Unauthorized(code, message string) *Error {
+	return New(KindUnauthorized, code, message)
+}
+
+func Forbidden(code, message string) *Error {
+	return New(KindForbidden, code, message)
+}
+
+func TooManyRequests(code, message string) *Error {
+	return New(KindTooManyRequests, code, message)
+}
+
+func TooLarge(code, message string) *Error {
+	return New(KindTooLarge, code, message)
+}
+
+func (e *Error) Error() string {
+	return e.Message
+}
+
+func (e *Error) Unwrap() error {
+	return e.Err
+}
+
+// Is matches errors with the same code, so copies made by Wrap and Invalid still match the original.
+func (e *Error) Is(target error) bool {
+	t, ok := target.(*Error)
+	return ok && t.Code == e.Code
+}
+
+// Wrap returns a copy of e caused by err.
+func (e *Error) Wrap(err error) *Error {
+	wrapped := *e
+	wrapped.Err = err
+
+	return &wrapped
+}
+
+// WithKind returns a copy of e of another kind, for an error that means something else to some requests.
+func (e *Error) WithKind(kind Kind) *Error {
+	copied := *e
+	copied.Kind = kind
+
+	return &copied
+}
+
+// WithFields returns a validation copy of e listing the invalid fields of the input.
+func (e *Error) WithFields(fields ...FieldError) *Error {
+	invalid := e.WithKind(KindValidation)
+	invalid.Fields = fields
+
+	return invalid
+}
+
+// Invalid blames e on a field of the input, e.g. for an id in a body referring to nothing.
+func (e *Error) Invalid(field, message string) *Error {
+	return e.WithFields(FieldError{Field: field, Message: message})
+}
+
+// As finds the first application error in the chain of err.
+func As(err error) (*Error, bool) {
+	var e *Error
+	if errors.As(err, &e) {
+		return e, true
+	}
+
+	return nil, false
+}
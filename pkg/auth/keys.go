@@ -0,0 +1,206 @@
+package auth
+
+import (
+	"crypto/ed25519"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"math/big"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/dgrijalva/jwt-go"
+)
+
+const (
+	rsaKeyBits = 2048
+
+	keyFileExt       = ".pem"
+	createdAtPEMHead = "Created-At"
+)
+
+type (
+	JWK struct {
+		Kty string `json:"kty"`
+		Kid string `json:"kid"`
+		Use string `json:"use"`
+		Alg string `json:"alg"`
+		N   string `json:"n,omitempty"`
+		E   string `json:"e,omitempty"`
+		Crv string `json:"crv,omitempty"`
+		X   string `json:"x,omitempty"`
+	}
+
+	JWKS struct {
+		Keys []JWK `json:"keys"`
+	}
+)
+
+type signingKey struct {
+	id         string
+	method     jwt.SigningMethod
+	privateKey interface{}
+	publicKey  interface{}
+	createdAt  time.Time
+	retiredAt  time.Time // zero while the key is used for signing
+}
+
+func generateSigningKey(algorithm string) (*signingKey, error) {
+	id := make([]byte, 8)
+	if _, err := rand.Read(id); err != nil {
+		return nil, err
+	}
+
+	key := &signingKey{id: hex.EncodeToString(id), createdAt: time.Now().UTC()}
+
+	switch algorithm {
+	case RS256:
+		privateKey, err := rsa.GenerateKey(rand.Reader, rsaKeyBits)
+		if err != nil {
+			return nil, err
+		}
+		key.method, key.privateKey, key.publicKey = jwt.SigningMethodRS256, privateKey, &privateKey.PublicKey
+	case EdDSA:
+		publicKey, privateKey, err := ed25519.GenerateKey(rand.Reader)
+		if err != nil {
+			return nil, err
+		}
+		key.method, key.privateKey, key.publicKey = SigningMethodEdDSA, privateKey, publicKey
+	default:
+		return nil, fmt.Errorf("unsupported signing algorithm: %s", algorithm)
+	}
+
+	return key, nil
+}
+
+// expired reports whether tokens signed with a rotated out key can no longer be alive.
+func (k *signingKey) expired(verificationTTL time.Duration) bool {
+	return !k.retiredAt.IsZero() && time.Since(k.retiredAt) > verificationTTL
+}
+
+func (k *signingKey) jwk() JWK {
+	jwk := JWK{Kid: k.id, Use: "sig", Alg: k.method.Alg()}
+
+	switch publicKey := k.publicKey.(type) {
+	case *rsa.PublicKey:
+		jwk.Kty = "RSA"
+		jwk.N = base64.RawURLEncoding.EncodeToString(publicKey.N.Bytes())
+		jwk.E = base64.RawURLEncoding.EncodeToString(big.NewInt(int64(publicKey.E)).Bytes())
+	case ed25519.PublicKey:
+		jwk.Kty = "OKP"
+		jwk.Crv = "Ed25519"
+		jwk.X = base64.RawURLEncoding.EncodeToString(publicKey)
+	}
+
+	return jwk
+}
+
+func (k *signingKey) save(dir string) error {
+	der, err := x509.MarshalPKCS8PrivateKey(k.privateKey)
+	if err != nil {
+		return err
+	}
+
+	if err := os.MkdirAll(dir, 0700); err != nil {
+		return err
+	}
+
+	data := pem.EncodeToMemory(&pem.Block{
+		Type:    "PRIVATE KEY",
+		Headers: map[string]string{createdAtPEMHead: k.createdAt.Format(time.RFC3339Nano)},
+		Bytes:   der,
+	})
+
+	return ioutil.WriteFile(filepath.Join(dir, k.id+keyFileExt), data, 0600)
+}
+
+func (k *signingKey) remove(dir string) error {
+	err := os.Remove(filepath.Join(dir, k.id+keyFileExt))
+	if os.IsNotExist(err) {
+		return nil
+	}
+
+	return err
+}
+
+// loadSigningKeys reads the keys persisted in dir, the newest one is the current signing key
+// and every other key is considered rotated out by the moment its successor was created.
+func loadSigningKeys(dir string) ([]*signingKey, error) {
+	files, err := ioutil.ReadDir(dir)
+	if os.IsNotExist(err) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	keys := make([]*signingKey, 0)
+	for _, file := range files {
+		if file.IsDir() || filepath.Ext(file.Name()) != keyFileExt {
+			continue
+		}
+
+		key, err := readSigningKey(filepath.Join(dir, file.Name()))
+		if err != nil {
+			return nil, fmt.Errorf("error reading signing key %s: %w", file.Name(), err)
+		}
+		keys = append(keys, key)
+	}
+
+	sort.Slice(keys, func(i, j int) bool {
+		return keys[i].createdAt.Before(keys[j].createdAt)
+	})
+	for i := 0; i < len(keys)-1; i++ {
+		keys[i].retiredAt = keys[i+1].createdAt
+	}
+
+	return keys, nil
+}
+
+func readSigningKey(path string) (*signingKey, error) {
+	data, err := ioutil.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+
+	block, _ := pem.Decode(data)
+	if block == nil {
+		return nil, errors.New("no PEM data found")
+	}
+
+	privateKey, err := x509.ParsePKCS8PrivateKey(block.Bytes)
+	if err != nil {
+		return nil, err
+	}
+
+	createdAt, err := time.Parse(time.RFC3339Nano, block.Headers[createdAtPEMHead])
+	if err != nil {
+		return nil, err
+	}
+
+	key := &signingKey{
+		id:         strings.TrimSuffix(filepath.Base(path), keyFileExt),
+		privateKey: privateKey,
+		createdAt:  createdAt,
+	}
+
+	switch privateKey := privateKey.(type) {
+	case *rsa.PrivateKey:
+		key.method, key.publicKey = jwt.SigningMethodRS256, &privateKey.PublicKey
+	case ed25519.PrivateKey:
+		key.method, key.publicKey = SigningMethodEdDSA, privateKey.Public()
+	default:
+		return nil, fmt.Errorf("unsupported key type: %T", privateKey)
+	}
+
+	return key, nil
+}
@@ -1,69 +1,362 @@
 package auth
 
 import (
+	"context"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
 	"errors"
 	"fmt"
-	"math/rand"
+	"sort"
+	"sync"
 	"time"
 
+	"github.com/TakoB222/postingAds-api/pkg/logger"
 	"github.com/dgrijalva/jwt-go"
 )
 
+const (
+	HS256 = "HS256"
+	RS256 = "RS256"
+	EdDSA = "EdDSA"
+
+	rotationRetryInterval = time.Minute
+)
+
+// Kinds of token subjects, users and admins live in separate tables, so their ids may collide.
+const (
+	KindUser  = "user"
+	KindAdmin = "admin"
+)
+
 type TokenManager interface {
-	NewJWT(userId string, ttl time.Duration) (string, error)
-	Parse(token string) (string, error)
+	NewJWT(subject Subject, ttl time.Duration) (string, error)
+	Parse(token string) (Subject, error)
+	NewChallengeToken(subject Subject, ttl time.Duration) (string, error)
+	ParseChallengeToken(token string) (Subject, error)
 	NewRefreshToken() (string, error)
+	JWKS() JWKS
+}
+
+type Subject struct {
+	Id          string
+	Kind        string
+	Roles       []string
+	Permissions []string
+	// SessionId is the session of a user the token is issued for, it is empty in tokens of admins
+	SessionId string
+	ExpiresAt time.Time // set by Parse
+}
+
+type claims struct {
+	jwt.StandardClaims
+	Kind        string   `json:"kind"`
+	Roles       []string `json:"roles,omitempty"`
+	Permissions []string `json:"permissions,omitempty"`
+	SessionId   string   `json:"sid,omitempty"`
+	// Challenge marks a token proving only the password, it is exchanged for tokens with the second factor.
+	Challenge bool `json:"challenge,omitempty"`
+}
+
+type ManagerConfig struct {
+	Algorithm string
+	// SigningKey is the HS256 secret. With an asymmetric algorithm it is optional and only
+	// keeps tokens issued before the switch verifiable, for VerificationTTL after the start.
+	SigningKey       string
+	KeysDir          string
+	RotationInterval time.Duration
+	// VerificationTTL is how long a rotated out key still verifies tokens, at least the access token TTL.
+	VerificationTTL time.Duration
 }
 
 type Manager struct {
-	signingKey string
+	sync.RWMutex
+
+	cfg     ManagerConfig
+	hmacKey []byte
+	// hmacUntil bounds expiry of HS256 tokens after a switch to an asymmetric algorithm, zero while HS256 signs.
+	// Tokens issued before the start expire by then, so a token living longer was minted with the retired secret.
+	hmacUntil  time.Time
+	currentKey *signingKey
+	keys       map[string]*signingKey
 }
 
-func NewManager(signingKey string) (*Manager, error) {
-	if signingKey == "" {
-		return nil, errors.New("signing key is empty")
+func NewManager(cfg ManagerConfig) (*Manager, error) {
+	m := &Manager{cfg: cfg, keys: make(map[string]*signingKey)}
+	if cfg.SigningKey != "" {
+		m.hmacKey = []byte(cfg.SigningKey)
 	}
-	return &Manager{signingKey: signingKey}, nil
+
+	switch cfg.Algorithm {
+	case HS256:
+		if cfg.SigningKey == "" {
+			return nil, errors.New("signing key is empty")
+		}
+		return m, nil
+	case RS256, EdDSA:
+		m.hmacUntil = time.Now().Add(cfg.VerificationTTL)
+	default:
+		return nil, fmt.Errorf("unsupported signing algorithm: %s", cfg.Algorithm)
+	}
+
+	if cfg.KeysDir != "" {
+		keys, err := loadSigningKeys(cfg.KeysDir)
+		if err != nil {
+			return nil, err
+		}
+
+		for _, key := range keys {
+			if !key.expired(cfg.VerificationTTL) {
+				m.keys[key.id] = key
+			}
+		}
+		if len(keys) > 0 {
+			m.currentKey = keys[len(keys)-1]
+		}
+	}
+
+	if m.currentKey == nil || m.currentKey.method.Alg() != cfg.Algorithm || m.rotationDue() {
+		if err := m.Rotate(); err != nil {
+			return nil, err
+		}
+	}
+
+	return m, nil
 }
 
-func (m *Manager) NewJWT(userId string, ttl time.Duration) (string, error) {
-	token := jwt.NewWithClaims(jwt.SigningMethodHS256, jwt.StandardClaims{
-		ExpiresAt: time.Now().Add(ttl).Unix(),
-		Subject:   userId,
+func (m *Manager) NewJWT(subject Subject, ttl time.Duration) (string, error) {
+	return m.sign(claims{
+		StandardClaims: jwt.StandardClaims{
+			ExpiresAt: time.Now().Add(ttl).Unix(),
+			Subject:   subject.Id,
+		},
+		Kind:        subject.Kind,
+		Roles:       subject.Roles,
+		Permissions: subject.Permissions,
+		SessionId:   subject.SessionId,
 	})
-
-	return token.SignedString([]byte(m.signingKey))
 }
 
-func (m *Manager) Parse(accessToken string) (string, error) {
-	token, err := jwt.Parse(accessToken, func(token *jwt.Token) (i interface{}, err error) {
-		if _, ok := token.Method.(*jwt.SigningMethodHMAC); !ok {
-			return nil, fmt.Errorf("unexpected signing method: %v", token.Header["alg"])
-		}
-		return []byte(m.signingKey), nil
+func (m *Manager) NewChallengeToken(subject Subject, ttl time.Duration) (string, error) {
+	return m.sign(claims{
+		StandardClaims: jwt.StandardClaims{
+			ExpiresAt: time.Now().Add(ttl).Unix(),
+			Subject:   subject.Id,
+		},
+		Kind:      subject.Kind,
+		Challenge: true,
 	})
+}
+
+func (m *Manager) sign(claims claims) (string, error) {
+	m.RLock()
+	key := m.currentKey
+	m.RUnlock()
+
+	if key == nil {
+		return jwt.NewWithClaims(jwt.SigningMethodHS256, claims).SignedString(m.hmacKey)
+	}
+
+	token := jwt.NewWithClaims(key.method, claims)
+	token.Header["kid"] = key.id
+
+	return token.SignedString(key.privateKey)
+}
+
+func (m *Manager) Parse(accessToken string) (Subject, error) {
+	claims, err := m.parse(accessToken)
 	if err != nil {
-		return "", err
+		return Subject{}, err
+	}
+
+	if claims.Challenge {
+		return Subject{}, fmt.Errorf("challenge token can not be used as access token")
+	}
+
+	return Subject{
+		Id:          claims.Subject,
+		Kind:        claims.Kind,
+		Roles:       claims.Roles,
+		Permissions: claims.Permissions,
+		SessionId:   claims.SessionId,
+		ExpiresAt:   time.Unix(claims.ExpiresAt, 0),
+	}, nil
+}
+
+func (m *Manager) ParseChallengeToken(challengeToken string) (Subject, error) {
+	claims, err := m.parse(challengeToken)
+	if err != nil {
+		return Subject{}, err
 	}
 
-	claims, ok := token.Claims.(jwt.MapClaims)
+	if !claims.Challenge {
+		return Subject{}, fmt.Errorf("token is not a challenge token")
+	}
+
+	return Subject{Id: claims.Subject, Kind: claims.Kind}, nil
+}
+
+func (m *Manager) parse(tokenString string) (*claims, error) {
+	token, err := jwt.ParseWithClaims(tokenString, &claims{}, m.verificationKey)
+	if err != nil {
+		return nil, err
+	}
+
+	claims, ok := token.Claims.(*claims)
 	if !ok {
-		return "", fmt.Errorf("error get user claims from token")
+		return nil, fmt.Errorf("error get user claims from token")
+	}
+
+	if claims.Subject == "" {
+		return nil, fmt.Errorf("error get subject from token")
 	}
+	if claims.Kind != KindUser && claims.Kind != KindAdmin {
+		return nil, fmt.Errorf("unknown subject kind: %q", claims.Kind)
+	}
+
+	return claims, nil
+}
+
+func (m *Manager) verificationKey(token *jwt.Token) (interface{}, error) {
+	if _, ok := token.Method.(*jwt.SigningMethodHMAC); ok {
+		if m.hmacKey == nil {
+			return nil, fmt.Errorf("unexpected signing method: %v", token.Header["alg"])
+		}
+		if !m.hmacUntil.IsZero() {
+			claims, ok := token.Claims.(*claims)
+			if !ok || claims.ExpiresAt == 0 || time.Unix(claims.ExpiresAt, 0).After(m.hmacUntil) {
+				return nil, fmt.Errorf("signing method %v is retired", token.Header["alg"])
+			}
+		}
+		return m.hmacKey, nil
+	}
+
+	kid, _ := token.Header["kid"].(string)
 
-	return claims["sub"].(string), nil
+	m.RLock()
+	key, ok := m.keys[kid]
+	m.RUnlock()
+
+	if !ok || key.expired(m.cfg.VerificationTTL) {
+		return nil, fmt.Errorf("unknown signing key: %s", kid)
+	}
+	if token.Method.Alg() != key.method.Alg() {
+		return nil, fmt.Errorf("unexpected signing method: %v", token.Header["alg"])
+	}
+
+	return key.publicKey, nil
 }
 
 func (m *Manager) NewRefreshToken() (string, error) {
 	b := make([]byte, 32)
 
-	s := rand.NewSource(time.Now().Unix())
-	r := rand.New(s)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+
+	return hex.EncodeToString(b), nil
+}
+
+// HashRefreshToken returns the form in which a refresh token is stored, so a leaked database does not leak live tokens.
+func HashRefreshToken(refreshToken string) string {
+	sum := sha256.Sum256([]byte(refreshToken))
+
+	return hex.EncodeToString(sum[:])
+}
+
+// JWKS returns public keys of every asymmetric key still able to verify tokens, the current one first.
+func (m *Manager) JWKS() JWKS {
+	m.RLock()
+	defer m.RUnlock()
+
+	keys := make([]*signingKey, 0, len(m.keys))
+	for _, key := range m.keys {
+		if !key.expired(m.cfg.VerificationTTL) {
+			keys = append(keys, key)
+		}
+	}
+	sort.Slice(keys, func(i, j int) bool {
+		return keys[i].createdAt.After(keys[j].createdAt)
+	})
+
+	jwks := JWKS{Keys: make([]JWK, 0, len(keys))}
+	for _, key := range keys {
+		jwks.Keys = append(jwks.Keys, key.jwk())
+	}
 
-	_, err := r.Read(b)
+	return jwks
+}
+
+// Rotate generates a new signing key. The previous one stops signing, but keeps verifying
+// tokens until VerificationTTL passes, after which it is dropped.
+func (m *Manager) Rotate() error {
+	key, err := generateSigningKey(m.cfg.Algorithm)
 	if err != nil {
-		return "", err
+		return err
+	}
+
+	if m.cfg.KeysDir != "" {
+		if err := key.save(m.cfg.KeysDir); err != nil {
+			return err
+		}
+	}
+
+	m.Lock()
+	defer m.Unlock()
+
+	if m.currentKey != nil {
+		m.currentKey.retiredAt = key.createdAt
+	}
+	m.currentKey = key
+	m.keys[key.id] = key
+
+	for id, key := range m.keys {
+		if !key.expired(m.cfg.VerificationTTL) {
+			continue
+		}
+
+		delete(m.keys, id)
+		if m.cfg.KeysDir != "" {
+			if err := key.remove(m.cfg.KeysDir); err != nil {
+				logger.Errorf("failed to remove expired signing key %s: %s", id, err.Error())
+			}
+		}
 	}
 
-	return fmt.Sprintf("%x", b), nil
+	return nil
+}
+
+// RunRotation rotates the signing key every RotationInterval until ctx is done.
+func (m *Manager) RunRotation(ctx context.Context) {
+	if m.cfg.Algorithm == HS256 || m.cfg.RotationInterval <= 0 {
+		return
+	}
+
+	for {
+		m.RLock()
+		next := m.currentKey.createdAt.Add(m.cfg.RotationInterval)
+		m.RUnlock()
+
+		timer := time.NewTimer(time.Until(next))
+		select {
+		case <-ctx.Done():
+			timer.Stop()
+			return
+		case <-timer.C:
+		}
+
+		if err := m.Rotate(); err != nil {
+			logger.Errorf("failed to rotate signing key: %s", err.Error())
+
+			select {
+			case <-ctx.Done():
+				return
+			case <-time.After(rotationRetryInterval):
+			}
+		}
+	}
+}
+
+func (m *Manager) rotationDue() bool {
+	return m.cfg.RotationInterval > 0 && time.Since(m.currentKey.createdAt) >= m.cfg.RotationInterval
 }
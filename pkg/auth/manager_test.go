@@ -0,0 +1,291 @@
+package auth
+
+import (
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/dgrijalva/jwt-go"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+const testSigningKey = "secret"
+
+func newTestManager(t *testing.T, algorithm string) *Manager {
+	m, err := NewManager(ManagerConfig{Algorithm: algorithm, SigningKey: testSigningKey, VerificationTTL: time.Minute})
+	require.NoError(t, err)
+
+	return m
+}
+
+func tokenKid(t *testing.T, token string) string {
+	parsed, _, err := new(jwt.Parser).ParseUnverified(token, &claims{})
+	require.NoError(t, err)
+
+	kid, _ := parsed.Header["kid"].(string)
+	return kid
+}
+
+func TestManagerNewJWT(t *testing.T) {
+	subject := Subject{
+		Id:          "1",
+		Kind:        KindUser,
+		Roles:       []string{"moderator"},
+		Permissions: []string{"ads:moderate"},
+		SessionId:   "7",
+	}
+
+	for _, algorithm := range []string{HS256, RS256, EdDSA} {
+		t.Run(algorithm, func(t *testing.T) {
+			m := newTestManager(t, algorithm)
+
+			token, err := m.NewJWT(subject, time.Minute)
+			require.NoError(t, err)
+
+			parsed, err := m.Parse(token)
+			require.NoError(t, err)
+
+			assert.WithinDuration(t, time.Now().Add(time.Minute), parsed.ExpiresAt, 2*time.Second)
+			parsed.ExpiresAt = time.Time{}
+			assert.Equal(t, subject, parsed)
+
+			_, err = m.ParseChallengeToken(token)
+			assert.Error(t, err)
+		})
+	}
+}
+
+func TestManagerParse(t *testing.T) {
+	m := newTestManager(t, RS256)
+	other := newTestManager(t, RS256)
+	subject := Subject{Id: "1", Kind: KindUser}
+
+	testTable := []struct {
+		name  string
+		token func() (string, error)
+	}{
+		{
+			name: "expired",
+			token: func() (string, error) {
+				return m.NewJWT(subject, -time.Minute)
+			},
+		},
+		{
+			name: "unknown key",
+			token: func() (string, error) {
+				return other.NewJWT(subject, time.Minute)
+			},
+		},
+		{
+			name: "challenge token",
+			token: func() (string, error) {
+				return m.NewChallengeToken(subject, time.Minute)
+			},
+		},
+		{
+			name: "unknown kind",
+			token: func() (string, error) {
+				return m.NewJWT(Subject{Id: "1", Kind: "robot"}, time.Minute)
+			},
+		},
+		{
+			name: "tampered",
+			token: func() (string, error) {
+				token, err := m.NewJWT(subject, time.Minute)
+				parts := strings.Split(token, ".")
+				forged, _ := m.NewJWT(Subject{Id: "2", Kind: KindAdmin}, time.Minute)
+				parts[1] = strings.Split(forged, ".")[1]
+				return strings.Join(parts, "."), err
+			},
+		},
+	}
+
+	for _, testCase := range testTable {
+		t.Run(testCase.name, func(t *testing.T) {
+			token, err := testCase.token()
+			require.NoError(t, err)
+
+			_, err = m.Parse(token)
+			assert.Error(t, err)
+		})
+	}
+}
+
+func TestManagerChallengeToken(t *testing.T) {
+	m := newTestManager(t, EdDSA)
+
+	token, err := m.NewChallengeToken(Subject{Id: "1", Kind: KindAdmin}, time.Minute)
+	require.NoError(t, err)
+
+	subject, err := m.ParseChallengeToken(token)
+	require.NoError(t, err)
+	assert.Equal(t, Subject{Id: "1", Kind: KindAdmin}, subject)
+}
+
+func TestManagerRotate(t *testing.T) {
+	m := newTestManager(t, RS256)
+	subject := Subject{Id: "1", Kind: KindUser}
+
+	before, err := m.NewJWT(subject, time.Minute)
+	require.NoError(t, err)
+	retiredKid := tokenKid(t, before)
+
+	require.NoError(t, m.Rotate())
+
+	after, err := m.NewJWT(subject, time.Minute)
+	require.NoError(t, err)
+	assert.NotEqual(t, retiredKid, tokenKid(t, after))
+
+	// the rotated out key keeps verifying tokens it signed until VerificationTTL passes
+	_, err = m.Parse(before)
+	assert.NoError(t, err)
+	_, err = m.Parse(after)
+	assert.NoError(t, err)
+
+	m.keys[retiredKid].retiredAt = time.Now().Add(-2 * time.Minute)
+
+	_, err = m.Parse(before)
+	assert.Error(t, err)
+	_, err = m.Parse(after)
+	assert.NoError(t, err)
+
+	// the next rotation drops the expired key
+	require.NoError(t, m.Rotate())
+	assert.NotContains(t, m.keys, retiredKid)
+}
+
+func TestManagerKeysDir(t *testing.T) {
+	dir := t.TempDir()
+	cfg := ManagerConfig{Algorithm: EdDSA, KeysDir: dir, VerificationTTL: time.Minute}
+
+	m, err := NewManager(cfg)
+	require.NoError(t, err)
+
+	token, err := m.NewJWT(Subject{Id: "1", Kind: KindUser}, time.Minute)
+	require.NoError(t, err)
+
+	// a restarted instance keeps signing with the persisted key and verifies its tokens
+	restarted, err := NewManager(cfg)
+	require.NoError(t, err)
+
+	_, err = restarted.Parse(token)
+	assert.NoError(t, err)
+
+	next, err := restarted.NewJWT(Subject{Id: "1", Kind: KindUser}, time.Minute)
+	require.NoError(t, err)
+	assert.Equal(t, tokenKid(t, token), tokenKid(t, next))
+}
+
+func TestManagerRetiredHMAC(t *testing.T) {
+	legacy := newTestManager(t, HS256)
+	subject := Subject{Id: "1", Kind: KindUser}
+
+	issuedBefore, err := legacy.NewJWT(subject, 30*time.Second)
+	require.NoError(t, err)
+	mintedAfter, err := legacy.NewJWT(subject, time.Hour)
+	require.NoError(t, err)
+
+	testTable := []struct {
+		name          string
+		signingKey    string
+		hmacUntil     time.Duration
+		token         string
+		expectedError bool
+	}{
+		{
+			name:       "issued before the switch",
+			signingKey: testSigningKey,
+			token:      issuedBefore,
+		},
+		{
+			name:          "living past the migration window",
+			signingKey:    testSigningKey,
+			token:         mintedAfter,
+			expectedError: true,
+		},
+		{
+			name:          "migration window is over",
+			signingKey:    testSigningKey,
+			hmacUntil:     -time.Second,
+			token:         issuedBefore,
+			expectedError: true,
+		},
+		{
+			name:          "no shared secret",
+			token:         issuedBefore,
+			expectedError: true,
+		},
+	}
+
+	for _, testCase := range testTable {
+		t.Run(testCase.name, func(t *testing.T) {
+			m, err := NewManager(ManagerConfig{Algorithm: RS256, SigningKey: testCase.signingKey, VerificationTTL: time.Minute})
+			require.NoError(t, err)
+			if testCase.hmacUntil != 0 {
+				m.hmacUntil = time.Now().Add(testCase.hmacUntil)
+			}
+
+			_, err = m.Parse(testCase.token)
+			assert.Equal(t, testCase.expectedError, err != nil, "unexpected error: %v", err)
+		})
+	}
+}
+
+func TestManagerJWKS(t *testing.T) {
+	testTable := []struct {
+		name      string
+		algorithm string
+		check     func(t *testing.T, jwk JWK)
+	}{
+		{
+			name:      "RS256",
+			algorithm: RS256,
+			check: func(t *testing.T, jwk JWK) {
+				assert.Equal(t, "RSA", jwk.Kty)
+				assert.Equal(t, "AQAB", jwk.E)
+				assert.NotEmpty(t, jwk.N)
+				assert.Empty(t, jwk.X)
+			},
+		},
+		{
+			name:      "EdDSA",
+			algorithm: EdDSA,
+			check: func(t *testing.T, jwk JWK) {
+				assert.Equal(t, "OKP", jwk.Kty)
+				assert.Equal(t, "Ed25519", jwk.Crv)
+				assert.NotEmpty(t, jwk.X)
+				assert.Empty(t, jwk.N)
+			},
+		},
+	}
+
+	for _, testCase := range testTable {
+		t.Run(testCase.name, func(t *testing.T) {
+			m := newTestManager(t, testCase.algorithm)
+
+			retired, err := m.NewJWT(Subject{Id: "1", Kind: KindUser}, time.Minute)
+			require.NoError(t, err)
+			require.NoError(t, m.Rotate())
+			current, err := m.NewJWT(Subject{Id: "1", Kind: KindUser}, time.Minute)
+			require.NoError(t, err)
+
+			jwks := m.JWKS()
+			require.Len(t, jwks.Keys, 2)
+			assert.Equal(t, tokenKid(t, current), jwks.Keys[0].Kid)
+			assert.Equal(t, tokenKid(t, retired), jwks.Keys[1].Kid)
+
+			for _, jwk := range jwks.Keys {
+				assert.Equal(t, "sig", jwk.Use)
+				assert.Equal(t, testCase.algorithm, jwk.Alg)
+				testCase.check(t, jwk)
+			}
+
+			// a key past VerificationTTL is not published any more
+			m.keys[tokenKid(t, retired)].retiredAt = time.Now().Add(-2 * time.Minute)
+			assert.Len(t, m.JWKS().Keys, 1)
+		})
+	}
+
+	assert.Empty(t, newTestManager(t, HS256).JWKS().Keys)
+}
@@ -7,13 +7,30 @@ import (
 )
 
 const (
-	UsersTable               = "users"
-	AdminsTable              = "admins"
-	RefreshSessionsTable     = "refreshSessions"
-	AdsTable                 = "ads"
-	CategoriesTable          = "categories"
-	ContactsInfoTable        = "contacts_info"
-	AdminRefreshSessionTable = "adminsRefreshSessions"
+	UsersTable                  = "users"
+	AdminsTable                 = "admins"
+	RefreshSessionsTable        = "refreshSessions"
+	AdsTable                    = "ads"
+	CategoriesTable             = "categories"
+	ContactsInfoTable           = "contacts_info"
+	AdminRefreshSessionTable    = "adminsRefreshSessions"
+	UsedRefreshTokensTable      = "usedRefreshTokens"
+	UsedAdminRefreshTokensTable = "usedAdminsRefreshTokens"
+	RolesTable                  = "roles"
+	UserRolesTable              = "userRoles"
+	AdminRolesTable             = "adminRoles"
+	UserTokensTable             = "userTokens"
+	UserRecoveryCodesTable      = "userRecoveryCodes"
+	AdminRecoveryCodesTable     = "adminRecoveryCodes"
+	ImagesTable                 = "images"
+	SavedSearchesTable          = "savedSearches"
+	SavedSearchMatchesTable     = "savedSearchMatches"
+	NotificationsTable          = "notifications"
+	FavoritesTable              = "favorites"
+	ThreadsTable                = "threads"
+	ThreadMessagesTable         = "threadMessages"
+	UserBlocksTable             = "userBlocks"
+	ContactRevealsTable         = "contactReveals"
 )
 
 type DBConfig struct {
@@ -0,0 +1,86 @@
+package hash
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// testArgon2Params keep the tests fast, the format does not depend on the cost.
+var testArgon2Params = Argon2Params{Time: 1, Memory: 64, Threads: 1, KeyLength: 16, SaltLength: 8}
+
+func TestArgon2idHasher(t *testing.T) {
+	h, err := NewArgon2idHasher(testArgon2Params)
+	require.NoError(t, err)
+
+	hash, err := h.Hash("qwerty")
+	require.NoError(t, err)
+	assert.True(t, strings.HasPrefix(hash, "$argon2id$v=19$m=64,t=1,p=1$"), hash)
+
+	other, err := h.Hash("qwerty")
+	require.NoError(t, err)
+	assert.NotEqual(t, hash, other, "salt is not random")
+
+	testTable := []struct {
+		name          string
+		password      string
+		hash          string
+		expected      bool
+		expectedError error
+	}{
+		{name: "ok", password: "qwerty", hash: hash, expected: true},
+		{name: "wrong password", password: "qwerty1", hash: hash},
+		{name: "bcrypt hash", password: "qwerty", hash: "$2a$10$N9qo8uLOickgx2ZMRZoMyeIjZAgcfl7p92ldGxad68LJZdL17lhWy", expectedError: ErrUnsupportedHash},
+		{name: "sha1 hash", password: "qwerty", hash: "73616c74b1b3773a05c0ed0176787a4f1574ff0075f7521e", expectedError: ErrUnsupportedHash},
+	}
+
+	for _, testCase := range testTable {
+		t.Run(testCase.name, func(t *testing.T) {
+			ok, err := h.Verify(testCase.password, testCase.hash)
+
+			assert.Equal(t, testCase.expectedError, err)
+			assert.Equal(t, testCase.expected, ok)
+		})
+	}
+
+	_, err = h.Verify("qwerty", "$argon2id$v=19$m=64,t=1$salt")
+	assert.Error(t, err)
+	_, err = h.Verify("qwerty", strings.Replace(hash, "v=19", "v=16", 1))
+	assert.Error(t, err)
+}
+
+func TestArgon2idHasherNeedsRehash(t *testing.T) {
+	h, err := NewArgon2idHasher(testArgon2Params)
+	require.NoError(t, err)
+
+	hash, err := h.Hash("qwerty")
+	require.NoError(t, err)
+
+	stronger := testArgon2Params
+	stronger.Time = 2
+	strongerHasher, err := NewArgon2idHasher(stronger)
+	require.NoError(t, err)
+
+	testTable := []struct {
+		name     string
+		hasher   *Argon2idHasher
+		hash     string
+		expected bool
+	}{
+		{name: "same params", hasher: h, hash: hash},
+		{name: "changed params", hasher: strongerHasher, hash: hash, expected: true},
+		{name: "bcrypt hash", hasher: h, hash: "$2a$10$N9qo8uLOickgx2ZMRZoMyeIjZAgcfl7p92ldGxad68LJZdL17lhWy", expected: true},
+		{name: "malformed", hasher: h, hash: "$argon2id$", expected: true},
+	}
+
+	for _, testCase := range testTable {
+		t.Run(testCase.name, func(t *testing.T) {
+			assert.Equal(t, testCase.expected, testCase.hasher.NeedsRehash(testCase.hash))
+		})
+	}
+
+	_, err = NewArgon2idHasher(Argon2Params{Time: 1})
+	assert.Error(t, err)
+}
@@ -0,0 +1,71 @@
+package hash
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"golang.org/x/crypto/bcrypt"
+)
+
+func TestBcryptHasher(t *testing.T) {
+	h, err := NewBcryptHasher(bcrypt.MinCost)
+	require.NoError(t, err)
+
+	hash, err := h.Hash("qwerty")
+	require.NoError(t, err)
+
+	testTable := []struct {
+		name          string
+		password      string
+		hash          string
+		expected      bool
+		expectedError error
+	}{
+		{name: "ok", password: "qwerty", hash: hash, expected: true},
+		{name: "wrong password", password: "qwerty1", hash: hash},
+		{name: "argon2id hash", password: "qwerty", hash: "$argon2id$v=19$m=64,t=1,p=1$c2FsdA$a2V5", expectedError: ErrUnsupportedHash},
+		{name: "sha1 hash", password: "qwerty", hash: "73616c74b1b3773a05c0ed0176787a4f1574ff0075f7521e", expectedError: ErrUnsupportedHash},
+	}
+
+	for _, testCase := range testTable {
+		t.Run(testCase.name, func(t *testing.T) {
+			ok, err := h.Verify(testCase.password, testCase.hash)
+
+			assert.Equal(t, testCase.expectedError, err)
+			assert.Equal(t, testCase.expected, ok)
+		})
+	}
+}
+
+func TestBcryptHasherNeedsRehash(t *testing.T) {
+	h, err := NewBcryptHasher(bcrypt.MinCost)
+	require.NoError(t, err)
+
+	hash, err := h.Hash("qwerty")
+	require.NoError(t, err)
+
+	stronger, err := NewBcryptHasher(bcrypt.MinCost + 1)
+	require.NoError(t, err)
+
+	testTable := []struct {
+		name     string
+		hasher   *BcryptHasher
+		hash     string
+		expected bool
+	}{
+		{name: "same cost", hasher: h, hash: hash},
+		{name: "changed cost", hasher: stronger, hash: hash, expected: true},
+		{name: "argon2id hash", hasher: h, hash: "$argon2id$v=19$m=64,t=1,p=1$c2FsdA$a2V5", expected: true},
+		{name: "malformed", hasher: h, hash: "$2a$", expected: true},
+	}
+
+	for _, testCase := range testTable {
+		t.Run(testCase.name, func(t *testing.T) {
+			assert.Equal(t, testCase.expected, testCase.hasher.NeedsRehash(testCase.hash))
+		})
+	}
+
+	_, err = NewBcryptHasher(bcrypt.MaxCost + 1)
+	assert.Error(t, err)
+}
@@ -2,14 +2,57 @@ package hash
 
 import (
 	"crypto/sha1"
+	"crypto/subtle"
 	"errors"
 	"fmt"
+	"regexp"
 )
 
+var ErrUnsupportedHash = errors.New("unsupported password hash format")
+
+// PasswordHasher produces self-describing password hashes: everything needed to verify
+// a password (algorithm, parameters, salt) is encoded in the stored hash itself.
 type PasswordHasher interface {
-	Hash(password string) string
+	Hash(password string) (string, error)
+	Verify(password, hash string) (bool, error)
+	NeedsRehash(hash string) bool
+}
+
+// UpgradeableHasher hashes new passwords with the primary hasher and still verifies hashes
+// produced by the legacy ones, so that they can be transparently replaced on the next sign in.
+type UpgradeableHasher struct {
+	primary PasswordHasher
+	legacy  []PasswordHasher
+}
+
+func NewUpgradeableHasher(primary PasswordHasher, legacy ...PasswordHasher) *UpgradeableHasher {
+	return &UpgradeableHasher{primary: primary, legacy: legacy}
+}
+
+func (h *UpgradeableHasher) Hash(password string) (string, error) {
+	return h.primary.Hash(password)
+}
+
+func (h *UpgradeableHasher) Verify(password, hash string) (bool, error) {
+	for _, hasher := range append([]PasswordHasher{h.primary}, h.legacy...) {
+		ok, err := hasher.Verify(password, hash)
+		if errors.Is(err, ErrUnsupportedHash) {
+			continue
+		}
+
+		return ok, err
+	}
+
+	return false, ErrUnsupportedHash
 }
 
+func (h *UpgradeableHasher) NeedsRehash(hash string) bool {
+	return h.primary.NeedsRehash(hash)
+}
+
+var sha1HashRegexp = regexp.MustCompile("^[0-9a-f]+$")
+
+// SHA1Hasher is the legacy hasher kept only to verify hashes created before the migration to argon2id/bcrypt.
 type SHA1Hasher struct {
 	salt string
 }
@@ -21,9 +64,26 @@ func NewSHA1Hasher(salt string) (*SHA1Hasher, error) {
 	return &SHA1Hasher{salt: salt}, nil
 }
 
-func (h *SHA1Hasher) Hash(password string) string {
+func (h *SHA1Hasher) Hash(password string) (string, error) {
 	hash := sha1.New()
 	hash.Write([]byte(password))
 
-	return fmt.Sprintf("%x", hash.Sum([]byte(h.salt)))
+	return fmt.Sprintf("%x", hash.Sum([]byte(h.salt))), nil
+}
+
+func (h *SHA1Hasher) Verify(password, hash string) (bool, error) {
+	if len(hash) != 2*(len(h.salt)+sha1.Size) || !sha1HashRegexp.MatchString(hash) {
+		return false, ErrUnsupportedHash
+	}
+
+	expected, err := h.Hash(password)
+	if err != nil {
+		return false, err
+	}
+
+	return subtle.ConstantTimeCompare([]byte(expected), []byte(hash)) == 1, nil
+}
+
+func (h *SHA1Hasher) NeedsRehash(hash string) bool {
+	return true
 }
@@ -0,0 +1,101 @@
+package hash
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"golang.org/x/crypto/bcrypt"
+)
+
+func TestSHA1Hasher(t *testing.T) {
+	h, err := NewSHA1Hasher("salt")
+	require.NoError(t, err)
+
+	// the hex of the salt followed by sha1("password")
+	hash, err := h.Hash("password")
+	require.NoError(t, err)
+	assert.Equal(t, "73616c74"+"5baa61e4c9b93f3f0682250b6cf8331b7ee68fd8", hash)
+
+	testTable := []struct {
+		name          string
+		password      string
+		hash          string
+		expected      bool
+		expectedError error
+	}{
+		{name: "ok", password: "password", hash: hash, expected: true},
+		{name: "wrong password", password: "password1", hash: hash},
+		{name: "other length", password: "password", hash: hash[2:], expectedError: ErrUnsupportedHash},
+		{name: "bcrypt hash", password: "password", hash: "$2a$10$N9qo8uLOickgx2ZMRZoMyeIjZAgcfl7p92ldGxad68LJZdL17lhWy", expectedError: ErrUnsupportedHash},
+	}
+
+	for _, testCase := range testTable {
+		t.Run(testCase.name, func(t *testing.T) {
+			ok, err := h.Verify(testCase.password, testCase.hash)
+
+			assert.Equal(t, testCase.expectedError, err)
+			assert.Equal(t, testCase.expected, ok)
+		})
+	}
+
+	assert.True(t, h.NeedsRehash(hash))
+
+	_, err = NewSHA1Hasher("")
+	assert.Error(t, err)
+}
+
+// TestUpgradeableHasher checks the decision taken on sign in: a hash of a legacy hasher verifies
+// and needs a rehash, after it the password is verified by the primary hasher alone.
+func TestUpgradeableHasher(t *testing.T) {
+	argon2id, err := NewArgon2idHasher(testArgon2Params)
+	require.NoError(t, err)
+	bcryptHasher, err := NewBcryptHasher(bcrypt.MinCost)
+	require.NoError(t, err)
+	sha1Hasher, err := NewSHA1Hasher("salt")
+	require.NoError(t, err)
+
+	h := NewUpgradeableHasher(argon2id, bcryptHasher, sha1Hasher)
+
+	hashWith := func(hasher PasswordHasher) string {
+		hash, err := hasher.Hash("password")
+		require.NoError(t, err)
+		return hash
+	}
+
+	testTable := []struct {
+		name                string
+		password            string
+		hash                string
+		expected            bool
+		expectedError       error
+		expectedNeedsRehash bool
+	}{
+		{name: "primary", password: "password", hash: hashWith(h), expected: true},
+		{name: "legacy bcrypt", password: "password", hash: hashWith(bcryptHasher), expected: true, expectedNeedsRehash: true},
+		{name: "legacy sha1", password: "password", hash: hashWith(sha1Hasher), expected: true, expectedNeedsRehash: true},
+		{name: "legacy sha1 wrong password", password: "password1", hash: hashWith(sha1Hasher), expectedNeedsRehash: true},
+		{name: "unknown format", password: "password", hash: "$md5$abc", expectedError: ErrUnsupportedHash, expectedNeedsRehash: true},
+	}
+
+	for _, testCase := range testTable {
+		t.Run(testCase.name, func(t *testing.T) {
+			ok, err := h.Verify(testCase.password, testCase.hash)
+
+			assert.Equal(t, testCase.expectedError, err)
+			assert.Equal(t, testCase.expected, ok)
+			assert.Equal(t, testCase.expectedNeedsRehash, h.NeedsRehash(testCase.hash))
+
+			if !ok || !h.NeedsRehash(testCase.hash) {
+				return
+			}
+
+			// the upgraded hash verifies with the same password and is not upgraded again
+			upgraded := hashWith(h)
+			ok, err = h.Verify(testCase.password, upgraded)
+			assert.NoError(t, err)
+			assert.True(t, ok)
+			assert.False(t, h.NeedsRehash(upgraded))
+		})
+	}
+}
@@ -0,0 +1,151 @@
+package hub
+
+import (
+	"encoding/json"
+	"strconv"
+	"sync"
+	"time"
+)
+
+// a subscriber that falls further behind is dropped, it catches up by the replay when it reconnects
+const subscriberBuffer = 64
+
+// Event is sent to every subscriber of a user. Ids grow with every event, a reconnecting client passes the last one it got.
+type Event struct {
+	Id   string
+	Type string
+	Data json.RawMessage
+
+	seq         uint64
+	publishedAt time.Time
+}
+
+// Subscription receives events of a user until it is unsubscribed or falls behind, then Events is closed.
+type Subscription struct {
+	Events <-chan Event
+
+	userId string
+	events chan Event
+}
+
+type user struct {
+	events      []Event // the last events kept for the replay, the oldest go first
+	subscribers map[*Subscription]struct{}
+}
+
+// Hub fans out events to subscribers of users connected to this instance and keeps the last events of every user
+// for replaySize events and replayTTL at most.
+type Hub struct {
+	mu    sync.Mutex
+	users map[string]*user
+	seq   uint64
+
+	replaySize int
+	replayTTL  time.Duration
+}
+
+func New(replaySize int, replayTTL time.Duration) *Hub {
+	return &Hub{
+		users: make(map[string]*user),
+		// ids keep growing across restarts, so an id a client got before a restart does not hide new events
+		seq:        uint64(time.Now().UnixNano()),
+		replaySize: replaySize,
+		replayTTL:  replayTTL,
+	}
+}
+
+func (h *Hub) Publish(userId, eventType string, data json.RawMessage) Event {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	h.seq++
+	event := Event{
+		Id:          strconv.FormatUint(h.seq, 10),
+		Type:        eventType,
+		Data:        data,
+		seq:         h.seq,
+		publishedAt: time.Now(),
+	}
+
+	u := h.user(userId)
+	u.events = append(u.events, event)
+	if len(u.events) > h.replaySize {
+		u.events = u.events[len(u.events)-h.replaySize:]
+	}
+
+	for sub := range u.subscribers {
+		select {
+		case sub.events <- event:
+		default:
+			delete(u.subscribers, sub)
+			close(sub.events)
+		}
+	}
+
+	return event
+}
+
+// Subscribe replays the kept events published after lastEventId, an empty or unknown id replays nothing.
+func (h *Hub) Subscribe(userId, lastEventId string) *Subscription {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	events := make(chan Event, h.replaySize+subscriberBuffer)
+	sub := &Subscription{Events: events, userId: userId, events: events}
+
+	u := h.user(userId)
+	u.subscribers[sub] = struct{}{}
+
+	if last, err := strconv.ParseUint(lastEventId, 10, 64); err == nil {
+		for _, event := range u.events {
+			if event.seq > last {
+				events <- event
+			}
+		}
+	}
+
+	return sub
+}
+
+func (h *Hub) Unsubscribe(sub *Subscription) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	u, ok := h.users[sub.userId]
+	if !ok {
+		return
+	}
+
+	if _, ok := u.subscribers[sub]; ok {
+		delete(u.subscribers, sub)
+		close(sub.events)
+	}
+}
+
+// Prune drops events older than the replay ttl and forgets users left without events and subscribers.
+func (h *Hub) Prune(now time.Time) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	for userId, u := range h.users {
+		expired := 0
+		for expired < len(u.events) && now.Sub(u.events[expired].publishedAt) > h.replayTTL {
+			expired++
+		}
+		u.events = u.events[expired:]
+
+		if len(u.events) == 0 && len(u.subscribers) == 0 {
+			delete(h.users, userId)
+		}
+	}
+}
+
+func (h *Hub) user(userId string) *user {
+	u, ok := h.users[userId]
+	if !ok {
+		u = &user{subscribers: make(map[*Subscription]struct{})}
+		h.users[userId] = u
+	}
+
+	return u
+}
@@ -0,0 +1,157 @@
+package hub
+
+import (
+	"encoding/json"
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// drain reads the events already sent to the subscription, closed tells whether the hub dropped it.
+func drain(sub *Subscription) (events []Event, closed bool) {
+	for {
+		select {
+		case event, ok := <-sub.Events:
+			if !ok {
+				return events, true
+			}
+			events = append(events, event)
+		default:
+			return events, false
+		}
+	}
+}
+
+func eventTypes(events []Event) []string {
+	types := make([]string, 0, len(events))
+	for _, event := range events {
+		types = append(types, event.Type)
+	}
+
+	return types
+}
+
+func TestSubscribeReplay(t *testing.T) {
+	h := New(3, time.Minute)
+
+	first := h.Publish("1", "e1", json.RawMessage(`{}`))
+	second := h.Publish("1", "e2", json.RawMessage(`{}`))
+	h.Publish("2", "other user", json.RawMessage(`{}`))
+	h.Publish("1", "e3", json.RawMessage(`{}`))
+	last := h.Publish("1", "e4", json.RawMessage(`{}`))
+
+	testTable := []struct {
+		name          string
+		lastEventId   string
+		expectedTypes []string
+	}{
+		{
+			name:          "no last event",
+			expectedTypes: []string{},
+		},
+		{
+			name:          "unknown id",
+			lastEventId:   "abc",
+			expectedTypes: []string{},
+		},
+		{
+			name:          "missed events",
+			lastEventId:   second.Id,
+			expectedTypes: []string{"e3", "e4"},
+		},
+		{
+			// only replaySize events are kept, e1 is gone
+			name:          "older than the kept events",
+			lastEventId:   first.Id,
+			expectedTypes: []string{"e2", "e3", "e4"},
+		},
+		{
+			name:          "up to date",
+			lastEventId:   last.Id,
+			expectedTypes: []string{},
+		},
+	}
+
+	for _, testCase := range testTable {
+		t.Run(testCase.name, func(t *testing.T) {
+			sub := h.Subscribe("1", testCase.lastEventId)
+			defer h.Unsubscribe(sub)
+
+			events, closed := drain(sub)
+			assert.False(t, closed)
+			assert.Equal(t, testCase.expectedTypes, eventTypes(events))
+		})
+	}
+}
+
+func TestPublish(t *testing.T) {
+	h := New(10, time.Minute)
+
+	sub := h.Subscribe("1", "")
+	other := h.Subscribe("2", "")
+
+	first := h.Publish("1", "new_message", json.RawMessage(`{"id":1}`))
+	second := h.Publish("1", "ad_approved", json.RawMessage(`{"ad_id":2}`))
+
+	events, closed := drain(sub)
+	assert.False(t, closed)
+	require.Len(t, events, 2)
+	assert.Equal(t, first.Id, events[0].Id)
+	assert.Equal(t, json.RawMessage(`{"ad_id":2}`), events[1].Data)
+	assert.Less(t, first.seq, second.seq)
+
+	events, _ = drain(other)
+	assert.Empty(t, events)
+
+	h.Unsubscribe(sub)
+	_, closed = drain(sub)
+	assert.True(t, closed)
+}
+
+func TestPublishOverflow(t *testing.T) {
+	h := New(2, time.Minute)
+	sub := h.Subscribe("1", "")
+	capacity := 2 + subscriberBuffer
+
+	for i := 0; i <= capacity; i++ {
+		h.Publish("1", fmt.Sprintf("e%d", i), json.RawMessage(`{}`))
+	}
+
+	// the subscriber got what fit in its buffer and was dropped at the next event
+	events, closed := drain(sub)
+	assert.True(t, closed)
+	assert.Len(t, events, capacity)
+
+	// unsubscribing a dropped subscriber is safe
+	h.Unsubscribe(sub)
+
+	// on reconnect the client gets the kept events it missed
+	resubscribed := h.Subscribe("1", events[len(events)-1].Id)
+	defer h.Unsubscribe(resubscribed)
+
+	missed, closed := drain(resubscribed)
+	assert.False(t, closed)
+	assert.Equal(t, []string{fmt.Sprintf("e%d", capacity)}, eventTypes(missed))
+}
+
+func TestPrune(t *testing.T) {
+	h := New(10, time.Minute)
+
+	first := h.Publish("1", "old", json.RawMessage(`{}`))
+	h.Publish("2", "old", json.RawMessage(`{}`))
+
+	h.Prune(time.Now().Add(2 * time.Minute))
+	assert.Empty(t, h.users)
+
+	h.Publish("1", "new", json.RawMessage(`{}`))
+	h.Prune(time.Now())
+
+	sub := h.Subscribe("1", first.Id)
+	defer h.Unsubscribe(sub)
+
+	events, _ := drain(sub)
+	assert.Equal(t, []string{"new"}, eventTypes(events))
+}
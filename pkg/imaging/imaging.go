@@ -0,0 +1,154 @@
+package imaging
+
+import (
+	"bytes"
+	"errors"
+	"image"
+	"image/draw"
+	_ "image/gif" // decoding only
+	"image/jpeg"
+	"image/png"
+	"net/http"
+)
+
+const jpegQuality = 90
+
+var (
+	ErrUnsupportedType = errors.New("unsupported image type")
+	ErrTooLarge        = errors.New("image dimensions are too large")
+)
+
+// contentTypes maps the sniffed content type to the format images are re-encoded to,
+// gif is stored as png since only its first frame is kept.
+var contentTypes = map[string]string{
+	"image/jpeg": "image/jpeg",
+	"image/png":  "image/png",
+	"image/gif":  "image/png",
+}
+
+type Image struct {
+	image.Image
+	ContentType string // the one the image is encoded to
+}
+
+// Decode validates the real content type of data and decodes it. EXIF orientation of jpeg is applied
+// to the pixels, since re-encoding drops every metadata including the orientation.
+func Decode(data []byte, maxPixels int) (Image, error) {
+	contentType, ok := contentTypes[http.DetectContentType(data)]
+	if !ok {
+		return Image{}, ErrUnsupportedType
+	}
+
+	// the header is checked first, so a small file can not be decoded into a huge bitmap
+	cfg, _, err := image.DecodeConfig(bytes.NewReader(data))
+	if err != nil {
+		return Image{}, ErrUnsupportedType
+	}
+	if cfg.Width <= 0 || cfg.Height <= 0 || cfg.Width*cfg.Height > maxPixels {
+		return Image{}, ErrTooLarge
+	}
+
+	img, _, err := image.Decode(bytes.NewReader(data))
+	if err != nil {
+		return Image{}, ErrUnsupportedType
+	}
+
+	rgba := toRGBA(img)
+	if contentType == "image/jpeg" {
+		rgba = orient(rgba, exifOrientation(data))
+	}
+
+	return Image{Image: rgba, ContentType: contentType}, nil
+}
+
+// Encode writes the image without any metadata.
+func Encode(img Image) ([]byte, error) {
+	var buf bytes.Buffer
+
+	var err error
+	switch img.ContentType {
+	case "image/jpeg":
+		err = jpeg.Encode(&buf, img.Image, &jpeg.Options{Quality: jpegQuality})
+	case "image/png":
+		err = png.Encode(&buf, img.Image)
+	default:
+		return nil, ErrUnsupportedType
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	return buf.Bytes(), nil
+}
+
+// Thumbnail scales the image down to fit maxSide, smaller images are kept as is.
+func Thumbnail(img Image, maxSide int) Image {
+	b := img.Bounds()
+	w, h := b.Dx(), b.Dy()
+	if w <= maxSide && h <= maxSide {
+		return img
+	}
+
+	if w >= h {
+		h = maxInt(1, h*maxSide/w)
+		w = maxSide
+	} else {
+		w = maxInt(1, w*maxSide/h)
+		h = maxSide
+	}
+
+	return Image{Image: resize(toRGBA(img.Image), w, h), ContentType: img.ContentType}
+}
+
+// resize downscales with a box filter: every destination pixel is the average of the source pixels it covers.
+func resize(src *image.RGBA, w, h int) *image.RGBA {
+	dst := image.NewRGBA(image.Rect(0, 0, w, h))
+	sw, sh := src.Bounds().Dx(), src.Bounds().Dy()
+
+	for y := 0; y < h; y++ {
+		sy0, sy1 := y*sh/h, maxInt((y+1)*sh/h, y*sh/h+1)
+		for x := 0; x < w; x++ {
+			sx0, sx1 := x*sw/w, maxInt((x+1)*sw/w, x*sw/w+1)
+
+			var r, g, b, a, n uint64
+			for sy := sy0; sy < sy1; sy++ {
+				offset := src.PixOffset(sx0, sy)
+				for sx := sx0; sx < sx1; sx++ {
+					r += uint64(src.Pix[offset])
+					g += uint64(src.Pix[offset+1])
+					b += uint64(src.Pix[offset+2])
+					a += uint64(src.Pix[offset+3])
+					offset += 4
+					n++
+				}
+			}
+
+			offset := dst.PixOffset(x, y)
+			dst.Pix[offset] = uint8(r / n)
+			dst.Pix[offset+1] = uint8(g / n)
+			dst.Pix[offset+2] = uint8(b / n)
+			dst.Pix[offset+3] = uint8(a / n)
+		}
+	}
+
+	return dst
+}
+
+func toRGBA(img image.Image) *image.RGBA {
+	if rgba, ok := img.(*image.RGBA); ok && rgba.Bounds().Min == (image.Point{}) {
+		return rgba
+	}
+
+	b := img.Bounds()
+	rgba := image.NewRGBA(image.Rect(0, 0, b.Dx(), b.Dy()))
+	draw.Draw(rgba, rgba.Bounds(), img, b.Min, draw.Src)
+
+	return rgba
+}
+
+func maxInt(a, b int) int {
+	if a > b {
+		return a
+	}
+	return b
+}
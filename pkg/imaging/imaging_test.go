@@ -0,0 +1,198 @@
+package imaging
+
+import (
+	"bytes"
+	"encoding/binary"
+	"image"
+	"image/color"
+	"image/jpeg"
+	"image/png"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+var (
+	red  = color.RGBA{R: 255, A: 255}
+	blue = color.RGBA{B: 255, A: 255}
+)
+
+// halves returns an image with the left half red and the right half blue.
+func halves(w, h int) *image.RGBA {
+	img := image.NewRGBA(image.Rect(0, 0, w, h))
+	for y := 0; y < h; y++ {
+		for x := 0; x < w; x++ {
+			if x < w/2 {
+				img.Set(x, y, red)
+			} else {
+				img.Set(x, y, blue)
+			}
+		}
+	}
+
+	return img
+}
+
+// exifSegment returns an APP1 segment with the orientation and a GPS latitude reference.
+func exifSegment(orientation uint16) []byte {
+	tiff := new(bytes.Buffer)
+	write := func(v interface{}) { _ = binary.Write(tiff, binary.BigEndian, v) }
+
+	tiff.WriteString("MM")
+	write(uint16(42))
+	write(uint32(8))
+
+	// IFD0: orientation and the pointer to the GPS IFD right after it
+	write(uint16(2))
+	write([]uint16{orientationTag, 3})
+	write(uint32(1))
+	write([]uint16{orientation, 0})
+	write([]uint16{0x8825, 4})
+	write(uint32(1))
+	write(uint32(8 + 2 + 2*12 + 4))
+	write(uint32(0))
+
+	// GPS IFD: GPSLatitudeRef
+	write(uint16(1))
+	write([]uint16{0x0001, 2})
+	write(uint32(2))
+	tiff.WriteString("N\x00\x00\x00")
+	write(uint32(0))
+
+	payload := append([]byte("Exif\x00\x00"), tiff.Bytes()...)
+	segment := []byte{0xFF, 0xE1, 0, 0}
+	binary.BigEndian.PutUint16(segment[2:], uint16(len(payload)+2))
+
+	return append(segment, payload...)
+}
+
+// jpegWithExif encodes the image and puts the EXIF segment right after the start of image marker.
+func jpegWithExif(t *testing.T, img image.Image, orientation uint16) []byte {
+	var buf bytes.Buffer
+	require.NoError(t, jpeg.Encode(&buf, img, &jpeg.Options{Quality: 100}))
+
+	data := buf.Bytes()
+	return append(append(append([]byte{}, data[:2]...), exifSegment(orientation)...), data[2:]...)
+}
+
+func assertColor(t *testing.T, expected color.RGBA, img image.Image, x, y int) {
+	actual := color.RGBAModel.Convert(img.At(x, y)).(color.RGBA)
+
+	// jpeg compression shifts colors a little
+	near := func(a, b uint8) bool { return int(a)-int(b) < 40 && int(b)-int(a) < 40 }
+	assert.True(t, near(expected.R, actual.R) && near(expected.G, actual.G) && near(expected.B, actual.B),
+		"pixel (%d, %d) is %v, expected %v", x, y, actual, expected)
+}
+
+func TestDecodeStripsExif(t *testing.T) {
+	data := jpegWithExif(t, halves(32, 16), 6)
+	require.Equal(t, 6, exifOrientation(data))
+
+	img, err := Decode(data, 32*16)
+	require.NoError(t, err)
+	assert.Equal(t, "image/jpeg", img.ContentType)
+
+	// orientation 6 turns the image clockwise: the red half goes on top
+	assert.Equal(t, image.Rect(0, 0, 16, 32), img.Bounds())
+	assertColor(t, red, img, 8, 4)
+	assertColor(t, blue, img, 8, 28)
+
+	encoded, err := Encode(img)
+	require.NoError(t, err)
+
+	assert.False(t, bytes.Contains(encoded, []byte("Exif")), "EXIF is kept")
+	assert.False(t, bytes.Contains(encoded, []byte{0xFF, 0xE1}), "APP1 segment is kept")
+	assert.Equal(t, 1, exifOrientation(encoded))
+
+	decoded, err := jpeg.Decode(bytes.NewReader(encoded))
+	require.NoError(t, err)
+	assert.Equal(t, image.Rect(0, 0, 16, 32), decoded.Bounds())
+}
+
+func TestDecode(t *testing.T) {
+	var pngData bytes.Buffer
+	require.NoError(t, png.Encode(&pngData, halves(4, 4)))
+
+	testTable := []struct {
+		name                string
+		data                []byte
+		maxPixels           int
+		expectedContentType string
+		expectedError       error
+	}{
+		{
+			name:                "png",
+			data:                pngData.Bytes(),
+			maxPixels:           16,
+			expectedContentType: "image/png",
+		},
+		{
+			name:          "too large",
+			data:          pngData.Bytes(),
+			maxPixels:     15,
+			expectedError: ErrTooLarge,
+		},
+		{
+			name:          "not an image",
+			data:          []byte("<html><body>image</body></html>"),
+			maxPixels:     16,
+			expectedError: ErrUnsupportedType,
+		},
+		{
+			name:          "truncated",
+			data:          pngData.Bytes()[:len(pngData.Bytes())/2],
+			maxPixels:     16,
+			expectedError: ErrUnsupportedType,
+		},
+	}
+
+	for _, testCase := range testTable {
+		t.Run(testCase.name, func(t *testing.T) {
+			img, err := Decode(testCase.data, testCase.maxPixels)
+
+			assert.Equal(t, testCase.expectedError, err)
+			assert.Equal(t, testCase.expectedContentType, img.ContentType)
+		})
+	}
+}
+
+func TestOrient(t *testing.T) {
+	// a 2x1 image, where the red pixel goes with every orientation
+	src := image.NewRGBA(image.Rect(0, 0, 2, 1))
+	src.Set(0, 0, red)
+	src.Set(1, 0, blue)
+
+	testTable := []struct {
+		orientation    int
+		expectedBounds image.Rectangle
+		expectedRed    image.Point
+	}{
+		{orientation: 1, expectedBounds: image.Rect(0, 0, 2, 1), expectedRed: image.Pt(0, 0)},
+		{orientation: 2, expectedBounds: image.Rect(0, 0, 2, 1), expectedRed: image.Pt(1, 0)},
+		{orientation: 3, expectedBounds: image.Rect(0, 0, 2, 1), expectedRed: image.Pt(1, 0)},
+		{orientation: 4, expectedBounds: image.Rect(0, 0, 2, 1), expectedRed: image.Pt(0, 0)},
+		{orientation: 5, expectedBounds: image.Rect(0, 0, 1, 2), expectedRed: image.Pt(0, 0)},
+		{orientation: 6, expectedBounds: image.Rect(0, 0, 1, 2), expectedRed: image.Pt(0, 0)},
+		{orientation: 7, expectedBounds: image.Rect(0, 0, 1, 2), expectedRed: image.Pt(0, 1)},
+		{orientation: 8, expectedBounds: image.Rect(0, 0, 1, 2), expectedRed: image.Pt(0, 1)},
+	}
+
+	for _, testCase := range testTable {
+		dst := orient(src, testCase.orientation)
+
+		assert.Equal(t, testCase.expectedBounds, dst.Bounds(), "orientation %d", testCase.orientation)
+		assert.Equal(t, red, dst.RGBAAt(testCase.expectedRed.X, testCase.expectedRed.Y), "orientation %d", testCase.orientation)
+	}
+}
+
+func TestThumbnail(t *testing.T) {
+	img := Image{Image: halves(40, 20), ContentType: "image/png"}
+
+	thumbnail := Thumbnail(img, 10)
+	assert.Equal(t, image.Rect(0, 0, 10, 5), thumbnail.Bounds())
+	assertColor(t, red, thumbnail, 2, 2)
+	assertColor(t, blue, thumbnail, 7, 2)
+
+	assert.Equal(t, img, Thumbnail(img, 40))
+}
@@ -14,3 +14,19 @@ func Error(message ...interface{}) {
 func Errorf(format string, message ...interface{}) {
 	logrus.Errorf(format, message...)
 }
+
+func Warn(message ...interface{}) {
+	logrus.Warn(message...)
+}
+
+func Warnf(format string, message ...interface{}) {
+	logrus.Warnf(format, message...)
+}
+
+func Info(message ...interface{}) {
+	logrus.Info(message...)
+}
+
+func Infof(format string, message ...interface{}) {
+	logrus.Infof(format, message...)
+}
@@ -0,0 +1,148 @@
+package otp
+
+import (
+	"net/url"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// rfc6238Secret is the SHA1 seed of RFC 6238 Appendix B, "12345678901234567890" in base32.
+const rfc6238Secret = "GEZDGNBVGY3TQOJQGEZDGNBVGY3TQOJQ"
+
+func TestValidateRFC6238(t *testing.T) {
+	// the 8 digit codes of Appendix B cut to the last 6 digits
+	testTable := []struct {
+		unix         int64
+		expectedCode string
+	}{
+		{unix: 59, expectedCode: "287082"},
+		{unix: 1111111109, expectedCode: "081804"},
+		{unix: 1111111111, expectedCode: "050471"},
+		{unix: 1234567890, expectedCode: "005924"},
+		{unix: 2000000000, expectedCode: "279037"},
+		{unix: 20000000000, expectedCode: "353130"},
+	}
+
+	for _, testCase := range testTable {
+		now := time.Unix(testCase.unix, 0)
+
+		code, err := Code(rfc6238Secret, now)
+		require.NoError(t, err)
+		assert.Equal(t, testCase.expectedCode, code, "code of %d", testCase.unix)
+
+		step, ok := Validate(testCase.expectedCode, rfc6238Secret, now)
+		assert.True(t, ok, "code of %d", testCase.unix)
+		assert.Equal(t, testCase.unix/30, step, "step of %d", testCase.unix)
+	}
+}
+
+func TestValidateWindow(t *testing.T) {
+	now := time.Unix(1111111111, 0)
+	current := now.Unix() / 30
+	key, err := b32.DecodeString(rfc6238Secret)
+	require.NoError(t, err)
+
+	testTable := []struct {
+		name     string
+		step     int64
+		expected bool
+	}{
+		{name: "two steps behind", step: current - 2},
+		{name: "previous step", step: current - 1, expected: true},
+		{name: "current step", step: current, expected: true},
+		{name: "next step", step: current + 1, expected: true},
+		{name: "two steps ahead", step: current + 2},
+	}
+
+	for _, testCase := range testTable {
+		t.Run(testCase.name, func(t *testing.T) {
+			step, ok := Validate(generate(key, testCase.step), rfc6238Secret, now)
+
+			assert.Equal(t, testCase.expected, ok)
+			if testCase.expected {
+				assert.Equal(t, testCase.step, step)
+			}
+		})
+	}
+}
+
+func TestValidateMalformed(t *testing.T) {
+	now := time.Unix(59, 0)
+
+	testTable := []struct {
+		name   string
+		code   string
+		secret string
+	}{
+		{name: "short code", code: "28708", secret: rfc6238Secret},
+		{name: "8 digits", code: "94287082", secret: rfc6238Secret},
+		{name: "wrong code", code: "287083", secret: rfc6238Secret},
+		{name: "invalid secret", code: "287082", secret: "not base32!"},
+	}
+
+	for _, testCase := range testTable {
+		t.Run(testCase.name, func(t *testing.T) {
+			_, ok := Validate(testCase.code, testCase.secret, now)
+			assert.False(t, ok)
+		})
+	}
+
+	// spaces around the code and a lower case secret are tolerated
+	_, ok := Validate(" 287082 ", strings.ToLower(rfc6238Secret), now)
+	assert.True(t, ok)
+}
+
+func TestGenerateSecret(t *testing.T) {
+	secret, err := GenerateSecret()
+	require.NoError(t, err)
+
+	key, err := b32.DecodeString(secret)
+	require.NoError(t, err)
+	assert.Len(t, key, secretSize)
+
+	other, err := GenerateSecret()
+	require.NoError(t, err)
+	assert.NotEqual(t, secret, other)
+}
+
+func TestProvisioningURI(t *testing.T) {
+	uri, err := url.Parse(ProvisioningURI("posting Ads", "user@example.com", rfc6238Secret))
+	require.NoError(t, err)
+
+	assert.Equal(t, "otpauth", uri.Scheme)
+	assert.Equal(t, "totp", uri.Host)
+	assert.Equal(t, "/posting Ads:user@example.com", uri.Path)
+	assert.NotContains(t, uri.RawQuery, "+")
+	assert.Equal(t, url.Values{
+		"secret":    {rfc6238Secret},
+		"issuer":    {"posting Ads"},
+		"algorithm": {"SHA1"},
+		"digits":    {"6"},
+		"period":    {"30"},
+	}, uri.Query())
+}
+
+func TestRecoveryCodes(t *testing.T) {
+	codes, err := GenerateRecoveryCodes(10)
+	require.NoError(t, err)
+	require.Len(t, codes, 10)
+
+	hashes := make(map[string]bool)
+	for _, code := range codes {
+		assert.Regexp(t, `^[a-z2-9]{5}-[a-z2-9]{5}$`, code)
+		assert.True(t, IsRecoveryCode(code))
+
+		hash := HashRecoveryCode(code)
+		assert.False(t, hashes[hash], "duplicate code %s", code)
+		hashes[hash] = true
+
+		// a code typed without the dash, in upper case or with spaces is the same code
+		assert.Equal(t, hash, HashRecoveryCode(" "+strings.ToUpper(strings.ReplaceAll(code, "-", ""))+" "))
+	}
+
+	assert.False(t, IsRecoveryCode("287082"))
+}
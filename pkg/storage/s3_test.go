@@ -0,0 +1,154 @@
+package storage
+
+import (
+	"context"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// s3StandIn keeps objects put into it in memory and checks the signature of every request.
+type s3StandIn struct {
+	t       *testing.T
+	storage *S3Storage
+	objects map[string][]byte
+	status  int // answered instead of the real result when set
+}
+
+func (s *s3StandIn) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	body, err := ioutil.ReadAll(r.Body)
+	require.NoError(s.t, err)
+
+	// the request is signed again with the same time, the signatures must match
+	signedAt, err := time.Parse(s3TimeFormat, r.Header.Get("X-Amz-Date"))
+	require.NoError(s.t, err)
+
+	resigned := r.Clone(context.Background())
+	resigned.URL.Host = r.Host
+	if len(body) == 0 {
+		body = nil
+	}
+	s.storage.sign(resigned, body, signedAt)
+	assert.Equal(s.t, resigned.Header.Get("Authorization"), r.Header.Get("Authorization"))
+	assert.True(s.t, strings.HasPrefix(r.Header.Get("Authorization"),
+		"AWS4-HMAC-SHA256 Credential=access/"+signedAt.Format(s3DateFormat)+"/eu-central-1/s3/aws4_request, "))
+	assert.Equal(s.t, sha256Hex(body), r.Header.Get("X-Amz-Content-Sha256"))
+
+	if s.status != 0 {
+		w.WriteHeader(s.status)
+		_, _ = w.Write([]byte("<Error><Code>AccessDenied</Code></Error>"))
+		return
+	}
+
+	switch r.Method {
+	case http.MethodPut:
+		s.objects[r.URL.EscapedPath()] = body
+		w.WriteHeader(http.StatusOK)
+	case http.MethodDelete:
+		delete(s.objects, r.URL.EscapedPath())
+		w.WriteHeader(http.StatusNoContent)
+	default:
+		w.WriteHeader(http.StatusMethodNotAllowed)
+	}
+}
+
+func newS3StandIn(t *testing.T) (*s3StandIn, *S3Storage) {
+	standIn := &s3StandIn{t: t, objects: make(map[string][]byte)}
+	server := httptest.NewServer(standIn)
+	t.Cleanup(server.Close)
+
+	storage, err := NewS3Storage(S3Config{
+		Endpoint:  server.URL + "/",
+		Region:    "eu-central-1",
+		Bucket:    "ads",
+		AccessKey: "access",
+		SecretKey: "secret",
+	})
+	require.NoError(t, err)
+	standIn.storage = storage
+
+	return standIn, storage
+}
+
+func TestS3StoragePutDelete(t *testing.T) {
+	standIn, storage := newS3StandIn(t)
+	ctx := context.Background()
+
+	require.NoError(t, storage.Put(ctx, "images/1/photo 1.jpg", []byte("jpeg"), "image/jpeg"))
+	assert.Equal(t, map[string][]byte{"/ads/images/1/photo%201.jpg": []byte("jpeg")}, standIn.objects)
+
+	require.NoError(t, storage.Delete(ctx, "images/1/photo 1.jpg"))
+	assert.Empty(t, standIn.objects)
+}
+
+func TestS3StorageErrorStatus(t *testing.T) {
+	testTable := []struct {
+		name   string
+		status int
+		do     func(storage *S3Storage) error
+	}{
+		{
+			name:   "put",
+			status: http.StatusForbidden,
+			do: func(storage *S3Storage) error {
+				return storage.Put(context.Background(), "images/1.jpg", []byte("jpeg"), "image/jpeg")
+			},
+		},
+		{
+			name:   "delete",
+			status: http.StatusInternalServerError,
+			do: func(storage *S3Storage) error {
+				return storage.Delete(context.Background(), "images/1.jpg")
+			},
+		},
+	}
+
+	for _, testCase := range testTable {
+		t.Run(testCase.name, func(t *testing.T) {
+			standIn, storage := newS3StandIn(t)
+			standIn.status = testCase.status
+
+			err := testCase.do(storage)
+			require.Error(t, err)
+			assert.Contains(t, err.Error(), http.StatusText(testCase.status))
+			assert.Contains(t, err.Error(), "AccessDenied")
+		})
+	}
+}
+
+func TestS3StorageURL(t *testing.T) {
+	testTable := []struct {
+		name     string
+		cfg      S3Config
+		expected string
+	}{
+		{
+			name:     "endpoint",
+			cfg:      S3Config{Endpoint: "http://localhost:9000/", Region: "us-east-1", Bucket: "ads"},
+			expected: "http://localhost:9000/ads/images/1.jpg",
+		},
+		{
+			name:     "public url",
+			cfg:      S3Config{Endpoint: "http://localhost:9000", Region: "us-east-1", Bucket: "ads", PublicURL: "https://cdn.example.com/"},
+			expected: "https://cdn.example.com/images/1.jpg",
+		},
+	}
+
+	for _, testCase := range testTable {
+		t.Run(testCase.name, func(t *testing.T) {
+			storage, err := NewS3Storage(testCase.cfg)
+			require.NoError(t, err)
+
+			assert.Equal(t, testCase.expected, storage.URL("images/1.jpg"))
+		})
+	}
+
+	_, err := NewS3Storage(S3Config{Endpoint: "http://localhost:9000"})
+	assert.Error(t, err)
+}
@@ -0,0 +1,10 @@
+package storage
+
+import "context"
+
+type Storage interface {
+	Put(ctx context.Context, key string, data []byte, contentType string) error
+	Delete(ctx context.Context, key string) error
+	// URL is the public address of the object
+	URL(key string) string
+}
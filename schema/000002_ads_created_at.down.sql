@@ -0,0 +1,6 @@
+drop index if exists idx_categories_parent_category;
+
+drop index if exists idx_ads_published_created_at;
+
+alter table ads
+    drop column if exists created_at;
@@ -0,0 +1,5 @@
+alter table ads
+    add column if not exists created_at timestamp not null default now();
+
+create index if not exists idx_ads_published_created_at on ads (published, created_at desc);
+create index if not exists idx_categories_parent_category on categories (parent_category);
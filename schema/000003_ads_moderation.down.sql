@@ -0,0 +1,14 @@
+drop index if exists idx_ads_status_created_at;
+
+alter table ads
+    add column if not exists published boolean not null default false;
+
+update ads
+set published = true
+where status = 'approved';
+
+create index if not exists idx_ads_published_created_at on ads (published, created_at desc);
+
+alter table ads
+    drop column if exists rejection_reason,
+    drop column if exists status;
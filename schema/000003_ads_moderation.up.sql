@@ -0,0 +1,15 @@
+alter table ads
+    add column if not exists status varchar(32) not null default 'draft'
+        check (status in ('draft', 'pending_review', 'approved', 'rejected', 'archived')),
+    add column if not exists rejection_reason text not null default '';
+
+update ads
+set status = 'approved'
+where published = true;
+
+drop index if exists idx_ads_published_created_at;
+
+alter table ads
+    drop column if exists published;
+
+create index if not exists idx_ads_status_created_at on ads (status, created_at desc);
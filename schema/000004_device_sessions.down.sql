@@ -0,0 +1,13 @@
+drop index if exists idx_admins_refresh_sessions_token;
+
+drop index if exists idx_refresh_sessions_token;
+
+alter table adminsRefreshSessions
+    drop column if exists lastUsedAt,
+    drop column if exists ip,
+    drop column if exists userAgent;
+
+alter table refreshSessions
+    drop column if exists lastUsedAt,
+    drop column if exists ip,
+    drop column if exists userAgent;
@@ -0,0 +1,23 @@
+alter table refreshSessions
+    add column if not exists userAgent  varchar(512) not null default '',
+    add column if not exists ip         varchar(64)  not null default '',
+    add column if not exists lastUsedAt timestamp    not null default now();
+
+alter table adminsRefreshSessions
+    add column if not exists userAgent  varchar(512) not null default '',
+    add column if not exists ip         varchar(64)  not null default '',
+    add column if not exists lastUsedAt timestamp    not null default now();
+
+-- tokens issued in the same second used to be equal, only the latest session of such a token is kept
+delete from refreshSessions s
+    using refreshSessions newer
+where newer.refreshToken = s.refreshToken
+  and newer.id > s.id;
+
+delete from adminsRefreshSessions s
+    using adminsRefreshSessions newer
+where newer.refreshToken = s.refreshToken
+  and newer.id > s.id;
+
+create unique index if not exists idx_refresh_sessions_token on refreshSessions (refreshToken);
+create unique index if not exists idx_admins_refresh_sessions_token on adminsRefreshSessions (refreshToken);
@@ -0,0 +1,8 @@
+drop table if exists usedAdminsRefreshTokens;
+
+drop table if exists usedRefreshTokens;
+
+-- hashed refresh tokens can not be turned back into plaintext ones, so everybody signs in again
+delete from adminsRefreshSessions;
+
+delete from refreshSessions;
@@ -0,0 +1,20 @@
+-- refresh tokens are stored only as sha256 hashes from now on
+update refreshSessions
+set refreshToken = encode(sha256(convert_to(refreshToken, 'UTF8')), 'hex');
+
+update adminsRefreshSessions
+set refreshToken = encode(sha256(convert_to(refreshToken, 'UTF8')), 'hex');
+
+create table if not exists usedRefreshTokens
+(
+    refreshToken varchar(255)                                     not null unique,
+    sessionId    int references refreshSessions (id) on delete cascade not null,
+    rotatedAt    timestamp                                        not null default now()
+);
+
+create table if not exists usedAdminsRefreshTokens
+(
+    refreshToken varchar(255)                                           not null unique,
+    sessionId    int references adminsRefreshSessions (id) on delete cascade not null,
+    rotatedAt    timestamp                                              not null default now()
+);
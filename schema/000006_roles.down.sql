@@ -0,0 +1,3 @@
+drop table if exists adminRoles;
+drop table if exists userRoles;
+drop table if exists roles;
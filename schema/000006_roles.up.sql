@@ -0,0 +1,31 @@
+create table if not exists roles
+(
+    id          serial       not null unique,
+    name        varchar(255) not null unique,
+    permissions varchar[]    not null default '{}'
+);
+
+create table if not exists userRoles
+(
+    userId int references users (id) on delete cascade not null,
+    roleId int references roles (id) on delete cascade not null,
+    primary key (userId, roleId)
+);
+
+create table if not exists adminRoles
+(
+    adminId int references admins (id) on delete cascade not null,
+    roleId  int references roles (id) on delete cascade  not null,
+    primary key (adminId, roleId)
+);
+
+insert into roles (name, permissions)
+values ('admin', '{ads:moderate,ads:manage,users:ban,categories:write,roles:manage}'),
+       ('moderator', '{ads:moderate}');
+
+-- existing admins keep their full rights
+insert into adminRoles (adminId, roleId)
+select admins.id, roles.id
+from admins,
+     roles
+where roles.name = 'admin';
@@ -0,0 +1,4 @@
+drop table if exists userTokens;
+
+alter table users
+    drop column if exists email_verified;
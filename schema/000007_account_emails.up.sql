@@ -0,0 +1,19 @@
+alter table users
+    add column if not exists email_verified boolean not null default false;
+
+-- accounts registered before the verification existed are trusted
+update users
+set email_verified = true;
+
+create table if not exists userTokens
+(
+    id        serial                                      not null unique,
+    userId    int references users (id) on delete cascade not null,
+    purpose   varchar(32)                                 not null,
+    tokenHash varchar(255)                                not null unique,
+    expiresAt timestamp                                   not null,
+    usedAt    timestamp,
+    createdAt timestamp                                   not null default now()
+);
+
+create index if not exists idx_user_tokens_user_purpose on userTokens (userId, purpose);
@@ -0,0 +1,16 @@
+drop table if exists adminRecoveryCodes;
+drop table if exists userRecoveryCodes;
+
+alter table admins
+    drop column if exists totp_locked_until,
+    drop column if exists totp_failed_attempts,
+    drop column if exists totp_last_step,
+    drop column if exists totp_enabled,
+    drop column if exists totp_secret;
+
+alter table users
+    drop column if exists totp_locked_until,
+    drop column if exists totp_failed_attempts,
+    drop column if exists totp_last_step,
+    drop column if exists totp_enabled,
+    drop column if exists totp_secret;
@@ -0,0 +1,32 @@
+alter table users
+    add column if not exists totp_secret          varchar(64),
+    add column if not exists totp_enabled         boolean   not null default false,
+    add column if not exists totp_last_step       bigint    not null default 0,
+    add column if not exists totp_failed_attempts int       not null default 0,
+    add column if not exists totp_locked_until    timestamp;
+
+alter table admins
+    add column if not exists totp_secret          varchar(64),
+    add column if not exists totp_enabled         boolean   not null default false,
+    add column if not exists totp_last_step       bigint    not null default 0,
+    add column if not exists totp_failed_attempts int       not null default 0,
+    add column if not exists totp_locked_until    timestamp;
+
+create table if not exists userRecoveryCodes
+(
+    id       serial                                      not null unique,
+    userId   int references users (id) on delete cascade not null,
+    codeHash varchar(255)                                not null,
+    usedAt   timestamp
+);
+
+create table if not exists adminRecoveryCodes
+(
+    id       serial                                       not null unique,
+    adminId  int references admins (id) on delete cascade not null,
+    codeHash varchar(255)                                 not null,
+    usedAt   timestamp
+);
+
+create index if not exists idx_user_recovery_codes_user on userRecoveryCodes (userId);
+create index if not exists idx_admin_recovery_codes_admin on adminRecoveryCodes (adminId);
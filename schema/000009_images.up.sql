@@ -0,0 +1,16 @@
+create table if not exists images
+(
+    id           varchar(32)                                 not null unique,
+    user_id      int references users (id) on delete cascade not null,
+    ad_id        int references ads (id) on delete set null,
+    content_type varchar(64)                                 not null,
+    width        int                                         not null,
+    height       int                                         not null,
+    size         int                                         not null,
+    created_at   timestamp                                   not null default now()
+);
+
+create index if not exists idx_images_ad_id on images (ad_id);
+create index if not exists idx_images_unattached on images (created_at) where ad_id is null;
+
+-- images_url of existing ads keeps external urls, new values are ids of the images table
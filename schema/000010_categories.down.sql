@@ -0,0 +1,2 @@
+drop index if exists idx_ads_category_id;
+drop index if exists idx_categories_parent_name;
@@ -0,0 +1,3 @@
+-- siblings can not share a name, categories are resolved by their name in ads
+create unique index if not exists idx_categories_parent_name on categories (coalesce(parent_category, 0), lower(category));
+create index if not exists idx_ads_category_id on ads (category_id);
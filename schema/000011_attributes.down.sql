@@ -0,0 +1,7 @@
+drop index if exists idx_ads_attributes;
+
+alter table ads
+    drop column if exists attributes;
+
+alter table categories
+    drop column if exists attributes;
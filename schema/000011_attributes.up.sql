@@ -0,0 +1,8 @@
+-- schema of structured fields declared by a category, subcategories inherit it
+alter table categories
+    add column if not exists attributes jsonb not null default '[]';
+
+alter table ads
+    add column if not exists attributes jsonb not null default '{}';
+
+create index if not exists idx_ads_attributes on ads using gin (attributes jsonb_path_ops);
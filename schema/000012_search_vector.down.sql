@@ -0,0 +1,12 @@
+drop index if exists idx_ads_search_vector;
+create index if not exists idx_fts_ads on ads
+    using gin(make_tsvector(title, description));
+
+drop trigger if exists ads_search_vector_update on ads;
+drop function if exists ads_search_vector();
+
+alter table ads
+    drop column if exists search_vector,
+    drop column if exists language;
+
+drop text search configuration if exists ukrainian;
@@ -0,0 +1,41 @@
+-- postgres has no ukrainian stemmer, the config may be replaced with a hunspell based one where it is installed
+do $$
+begin
+    if not exists (select 1 from pg_ts_config where cfgname = 'ukrainian') then
+        create text search configuration ukrainian (copy = simple);
+    end if;
+end
+$$;
+
+-- name of the text search configuration the ad is indexed with
+alter table ads
+    add column if not exists language varchar(32) not null default 'russian',
+    add column if not exists search_vector tsvector;
+
+create or replace function ads_search_vector()
+    returns trigger as $$
+begin
+    new.search_vector := setweight(to_tsvector(new.language::regconfig, coalesce(new.title, '')), 'A') ||
+                         setweight(to_tsvector(new.language::regconfig, coalesce(new.description, '')), 'B');
+    return new;
+end
+    $$ language 'plpgsql';
+
+drop trigger if exists ads_search_vector_update on ads;
+create trigger ads_search_vector_update
+    before insert or update of title, description, language on ads
+    for each row execute procedure ads_search_vector();
+
+-- backfill, the trigger builds the vector of every row
+update ads
+set language = case
+                   when title || ' ' || description ~ '[іїєґІЇЄҐ]' then 'ukrainian'
+                   when title || ' ' || description !~ '[а-яА-ЯёЁ]' and title || ' ' || description ~ '[a-zA-Z]' then 'english'
+                   else 'russian'
+    end;
+
+alter table ads
+    alter column search_vector set not null;
+
+drop index if exists idx_fts_ads;
+create index if not exists idx_ads_search_vector on ads using gin (search_vector);
@@ -0,0 +1,4 @@
+drop index if exists idx_categories_category_trgm;
+drop index if exists idx_ads_title_trgm;
+
+drop extension if exists pg_trgm;
@@ -0,0 +1,5 @@
+-- trigram indexes serve prefix and typo tolerant matching of suggestions
+create extension if not exists pg_trgm;
+
+create index if not exists idx_ads_title_trgm on ads using gin (lower(title) gin_trgm_ops);
+create index if not exists idx_categories_category_trgm on categories using gin (lower(category) gin_trgm_ops);
@@ -0,0 +1,8 @@
+drop table if exists notifications;
+drop table if exists savedSearchMatches;
+drop table if exists savedSearches;
+
+drop index if exists idx_ads_published_at;
+
+alter table ads
+    drop column if exists published_at;
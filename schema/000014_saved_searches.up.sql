@@ -0,0 +1,49 @@
+-- saved searches are evaluated against ads published after the last check
+alter table ads
+    add column if not exists published_at timestamp;
+
+update ads
+set published_at = created_at
+where status = 'approved';
+
+create index if not exists idx_ads_published_at on ads (published_at) where status = 'approved';
+
+create table if not exists savedSearches
+(
+    id          serial                                      not null unique,
+    user_id     int references users (id) on delete cascade not null,
+    name        varchar(255)                                not null,
+    query       varchar(255)                                not null,
+    filters     jsonb                                       not null default '{}',
+    channel     varchar(16)                                 not null,
+    webhook_url varchar(2048)                               not null default '',
+    created_at  timestamp                                   not null default now(),
+    checked_at  timestamp                                   not null default now()
+);
+
+create index if not exists idx_saved_searches_user_id on savedSearches (user_id);
+create index if not exists idx_saved_searches_checked_at on savedSearches (checked_at);
+
+-- an ad is notified once per saved search
+create table if not exists savedSearchMatches
+(
+    saved_search_id int references savedSearches (id) on delete cascade not null,
+    ad_id           int references ads (id) on delete cascade           not null,
+    created_at      timestamp                                           not null default now(),
+    primary key (saved_search_id, ad_id)
+);
+
+-- in app inbox of users
+create table if not exists notifications
+(
+    id         serial                                      not null unique,
+    user_id    int references users (id) on delete cascade not null,
+    kind       varchar(32)                                 not null,
+    title      varchar(255)                                not null,
+    body       text                                        not null,
+    payload    jsonb                                       not null default '{}',
+    created_at timestamp                                   not null default now(),
+    read_at    timestamp
+);
+
+create index if not exists idx_notifications_user_id on notifications (user_id, created_at desc);
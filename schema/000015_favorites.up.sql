@@ -0,0 +1,10 @@
+create table if not exists favorites
+(
+    user_id    int references users (id) on delete cascade not null,
+    ad_id      int references ads (id) on delete cascade   not null,
+    created_at timestamp                                   not null default now(),
+    primary key (user_id, ad_id)
+);
+
+-- counts of favorites of ads and users to notify about an ad
+create index if not exists idx_favorites_ad_id on favorites (ad_id);
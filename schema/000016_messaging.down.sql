@@ -0,0 +1,3 @@
+drop table if exists userBlocks;
+drop table if exists threadMessages;
+drop table if exists threads;
@@ -0,0 +1,36 @@
+-- a buyer has one thread per ad, the seller is the user of the ad
+create table if not exists threads
+(
+    id              serial                                      not null unique,
+    ad_id           int references ads (id) on delete cascade   not null,
+    buyer_id        int references users (id) on delete cascade not null,
+    seller_id       int references users (id) on delete cascade not null,
+    created_at      timestamp                                   not null default now(),
+    last_message_at timestamp                                   not null default now(),
+    unique (ad_id, buyer_id)
+);
+
+create index if not exists idx_threads_buyer_id on threads (buyer_id, last_message_at desc);
+create index if not exists idx_threads_seller_id on threads (seller_id, last_message_at desc);
+
+create table if not exists threadMessages
+(
+    id         serial                                        not null unique,
+    thread_id  int references threads (id) on delete cascade not null,
+    sender_id  int references users (id) on delete cascade   not null,
+    body       text                                          not null,
+    created_at timestamp                                     not null default now(),
+    read_at    timestamp
+);
+
+create index if not exists idx_thread_messages_thread_id on threadMessages (thread_id, created_at desc);
+create index if not exists idx_thread_messages_unread on threadMessages (thread_id) where read_at is null;
+
+-- blocked users can not write to the user who blocked them
+create table if not exists userBlocks
+(
+    user_id         int references users (id) on delete cascade not null,
+    blocked_user_id int references users (id) on delete cascade not null,
+    created_at      timestamp                                   not null default now(),
+    primary key (user_id, blocked_user_id)
+);
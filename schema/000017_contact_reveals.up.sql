@@ -0,0 +1,12 @@
+-- who revealed contacts of which ad, reveals of a user are limited per time window
+create table if not exists contactReveals
+(
+    id         serial                                      not null unique,
+    user_id    int references users (id) on delete cascade not null,
+    ad_id      int references ads (id) on delete cascade   not null,
+    ip         varchar(64)                                 not null default '',
+    created_at timestamp                                   not null default now()
+);
+
+create index if not exists idx_contact_reveals_user_id on contactReveals (user_id, created_at);
+create index if not exists idx_contact_reveals_ad_id on contactReveals (ad_id);
@@ -0,0 +1,5 @@
+drop index if exists idx_contacts_info_user_id_label;
+
+alter table contacts_info
+    drop column if exists label,
+    drop column if exists user_id;
@@ -0,0 +1,6 @@
+-- contacts of a user saved under a label, ads referencing a profile share its row and show its current contacts
+alter table contacts_info
+    add column if not exists user_id int references users (id) on delete cascade,
+    add column if not exists label   varchar(255);
+
+create unique index if not exists idx_contacts_info_user_id_label on contacts_info (user_id, label) where user_id is not null;
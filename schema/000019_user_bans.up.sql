@@ -0,0 +1,4 @@
+-- banned users can not sign in, their sessions are deleted when they are banned
+alter table users
+    add column if not exists banned_at  timestamp,
+    add column if not exists ban_reason text not null default '';
@@ -0,0 +1,5 @@
+-- favorites are told about a price change once the new price is approved, approved_price is the price they saw last
+alter table ads
+    add column if not exists approved_price integer;
+
+update ads set approved_price = price where status = 'approved';